@@ -0,0 +1,633 @@
+// Package config loads the server configuration. Each setting comes from,
+// in increasing precedence, its default, a YAML or TOML config file, a .env
+// file, an environment variable and a command line flag.
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"task-manager/Infrastructure"
+	"task-manager/Repositories"
+	"task-manager/Usecases"
+
+	"github.com/joho/godotenv"
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// Storage backends
+const (
+	BackendMongo    = "mongo"
+	BackendSQLite   = "sqlite"
+	BackendPostgres = "postgres"
+	BackendMemory   = "memory"
+)
+
+// DefaultSQLiteFile is where SQLite stores its data unless database.url
+// says otherwise
+const DefaultSQLiteFile = "taskmanager.db"
+
+// MinSetupTokenLength is the shortest admin setup token accepted
+const MinSetupTokenLength = 16
+
+// Config is the configuration of the server
+type Config struct {
+	Server         Server
+	Database       Database
+	JWT            infrastructure.KeySources
+	Password       infrastructure.PasswordHashing
+	PasswordPolicy usecases.PasswordPolicy
+	Login          usecases.LoginPolicy
+	Lockout        usecases.LockoutPolicy
+	Auth           Auth
+	Mail           infrastructure.MailSettings
+	Audit          Audit
+	Timeouts       Timeouts
+}
+
+// Auth configures account setup and two-factor authentication
+type Auth struct {
+	// SetupToken is the secret POST /setup takes to create the first admin.
+	// Empty turns setup off.
+	SetupToken string
+	// MFAIssuer is the name authenticator apps show next to the account
+	MFAIssuer string
+}
+
+// Audit configures the audit log
+type Audit struct {
+	// LogFile is the file audit entries are appended to, standard error
+	// when empty
+	LogFile string
+}
+
+// Server configures the HTTP server
+type Server struct {
+	// Addr is the address to listen on, such as :8080
+	Addr string
+	// TrustedProxies are the addresses or CIDRs of reverse proxies whose
+	// X-Forwarded-For header gives the client IP
+	TrustedProxies []string
+	// ReadTimeout bounds reading a request, WriteTimeout handling it and
+	// writing the response, and IdleTimeout how long a keep-alive
+	// connection waits for the next request
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+	IdleTimeout  time.Duration
+	// ShutdownTimeout bounds draining connections, stopping background
+	// workers and closing the database on shutdown
+	ShutdownTimeout time.Duration
+}
+
+// Database configures the storage backend
+type Database struct {
+	// Backend is one of BackendMongo, BackendSQLite, BackendPostgres and
+	// BackendMemory
+	Backend  string
+	MongoURI string
+	// Name is the MongoDB database
+	Name        string
+	Collections repositories.CollectionNames
+	// URL is the connection string of PostgreSQL or the SQLite file
+	URL string
+	// TokenCleanupInterval is how often expired refresh tokens and revoked
+	// access tokens are deleted
+	TokenCleanupInterval time.Duration
+}
+
+// Timeouts bound the work done with the database and the readiness checks
+type Timeouts struct {
+	// Read and Write bound each database operation
+	Read  time.Duration
+	Write time.Duration
+	// Connect bounds connecting to MongoDB
+	Connect time.Duration
+	// Migrations bounds applying the pending migrations on startup
+	Migrations time.Duration
+	// HealthCheck bounds each check run by /readyz
+	HealthCheck time.Duration
+}
+
+// Default returns the configuration used where nothing is set
+func Default() Config {
+	return Config{
+		Server: Server{
+			Addr:            ":8080",
+			ReadTimeout:     15 * time.Second,
+			WriteTimeout:    30 * time.Second,
+			IdleTimeout:     2 * time.Minute,
+			ShutdownTimeout: 30 * time.Second,
+		},
+		Database: Database{
+			Backend:     BackendMongo,
+			MongoURI:    "mongodb://localhost:27017",
+			Name:        "taskdb",
+			Collections: repositories.DefaultCollectionNames,
+
+			TokenCleanupInterval: time.Hour,
+		},
+		Password:       infrastructure.DefaultPasswordHashing,
+		PasswordPolicy: usecases.DefaultPasswordPolicy,
+		Lockout:        usecases.DefaultLockoutPolicy,
+		Auth:           Auth{MFAIssuer: "Task Manager"},
+		Mail:           infrastructure.DefaultMailSettings,
+		Timeouts: Timeouts{
+			Read:        repositories.DefaultTimeouts.Read,
+			Write:       repositories.DefaultTimeouts.Write,
+			Connect:     10 * time.Second,
+			Migrations:  5 * time.Minute,
+			HealthCheck: 2 * time.Second,
+		},
+	}
+}
+
+// setting is one configuration value and the places it can be set
+type setting struct {
+	// key names the setting in the config file, with dots between
+	// sections, and is the name of its flag
+	key string
+	// env is the environment variable, if the setting has one
+	env string
+	// secret settings have no flag, as other users can see command lines
+	secret bool
+	usage  string
+	set    func(c *Config, value string) error
+}
+
+var settings = append([]setting{
+	{key: "server.addr", env: "SERVER_ADDR", usage: "address to listen on",
+		set: text(func(c *Config) *string { return &c.Server.Addr })},
+	{key: "server.trusted_proxies", env: "TRUSTED_PROXIES", usage: "comma-separated addresses or CIDRs of trusted reverse proxies",
+		set: list(func(c *Config) *[]string { return &c.Server.TrustedProxies })},
+	{key: "server.read_timeout", env: "SERVER_READ_TIMEOUT", usage: "how long reading a request may take",
+		set: duration(func(c *Config) *time.Duration { return &c.Server.ReadTimeout })},
+	{key: "server.write_timeout", env: "SERVER_WRITE_TIMEOUT", usage: "how long handling a request and writing the response may take",
+		set: duration(func(c *Config) *time.Duration { return &c.Server.WriteTimeout })},
+	{key: "server.idle_timeout", env: "SERVER_IDLE_TIMEOUT", usage: "how long a keep-alive connection waits for the next request",
+		set: duration(func(c *Config) *time.Duration { return &c.Server.IdleTimeout })},
+	{key: "server.shutdown_timeout", env: "SHUTDOWN_TIMEOUT", usage: "how long shutting down may take",
+		set: duration(func(c *Config) *time.Duration { return &c.Server.ShutdownTimeout })},
+
+	{key: "database.backend", env: "STORAGE_BACKEND", usage: "storage backend: mongo, sqlite, postgres or memory",
+		set: text(func(c *Config) *string { return &c.Database.Backend })},
+	{key: "database.mongodb_uri", env: "MONGODB_URI", secret: true,
+		set: text(func(c *Config) *string { return &c.Database.MongoURI })},
+	{key: "database.name", env: "MONGODB_DATABASE", usage: "MongoDB database",
+		set: text(func(c *Config) *string { return &c.Database.Name })},
+	{key: "database.url", env: "DATABASE_URL", secret: true,
+		set: text(func(c *Config) *string { return &c.Database.URL })},
+	{key: "database.token_cleanup_interval", env: "TOKEN_CLEANUP_INTERVAL", usage: "how often expired tokens are deleted",
+		set: duration(func(c *Config) *time.Duration { return &c.Database.TokenCleanupInterval })},
+
+	{key: "jwt.private_key_file", env: "JWT_PRIVATE_KEY_FILE", usage: "PEM file of the RSA or Ed25519 key signing tokens",
+		set: text(func(c *Config) *string { return &c.JWT.PrivateKeyFile })},
+	{key: "jwt.private_key", env: "JWT_PRIVATE_KEY", secret: true,
+		set: text(func(c *Config) *string { return &c.JWT.PrivateKey })},
+	{key: "jwt.secret", env: "JWT_SECRET", secret: true,
+		set: text(func(c *Config) *string { return &c.JWT.Secret })},
+	{key: "jwt.verification_key_files", env: "JWT_VERIFICATION_KEY_FILES", usage: "comma-separated PEM files of keys accepted but not signed with",
+		set: list(func(c *Config) *[]string { return &c.JWT.VerificationKeyFiles })},
+
+	{key: "password.hash", env: "PASSWORD_HASH", usage: "algorithm new passwords are hashed with: bcrypt or argon2id",
+		set: text(func(c *Config) *string { return &c.Password.Algorithm })},
+	{key: "password.bcrypt_cost", env: "BCRYPT_COST", usage: "bcrypt cost",
+		set: integer(func(c *Config) *int { return &c.Password.BcryptCost })},
+	{key: "password.argon2_time", env: "ARGON2_TIME", usage: "Argon2id passes",
+		set: unsigned(func(c *Config) *uint32 { return &c.Password.Argon2.Time })},
+	{key: "password.argon2_memory", env: "ARGON2_MEMORY", usage: "Argon2id memory in KiB",
+		set: unsigned(func(c *Config) *uint32 { return &c.Password.Argon2.Memory })},
+	{key: "password.argon2_threads", env: "ARGON2_THREADS", usage: "Argon2id threads, at most 255",
+		set: func(c *Config, value string) error {
+			n, err := strconv.ParseUint(value, 10, 8)
+			if err != nil {
+				return errors.New("expected a number from 0 to 255")
+			}
+			c.Password.Argon2.Threads = uint8(n)
+			return nil
+		}},
+	{key: "password.min_length", env: "PASSWORD_MIN_LENGTH", usage: "fewest characters of a password, 0 for no minimum",
+		set: integer(func(c *Config) *int { return &c.PasswordPolicy.MinLength })},
+	{key: "password.max_bytes", env: "PASSWORD_MAX_BYTES", usage: "most bytes of a password, at most 72",
+		set: integer(func(c *Config) *int { return &c.PasswordPolicy.MaxBytes })},
+	{key: "password.min_classes", env: "PASSWORD_MIN_CLASSES", usage: "character classes a password mixes, 0 to 4",
+		set: integer(func(c *Config) *int { return &c.PasswordPolicy.MinClasses })},
+	{key: "password.reject_username", env: "PASSWORD_REJECT_USERNAME", usage: "refuse passwords containing the username",
+		set: boolean(func(c *Config) *bool { return &c.PasswordPolicy.RejectUsername })},
+	{key: "password.reject_common", env: "PASSWORD_REJECT_COMMON", usage: "refuse passwords on the common password list",
+		set: boolean(func(c *Config) *bool { return &c.PasswordPolicy.RejectCommon })},
+
+	{key: "login.require_verified_email", env: "REQUIRE_VERIFIED_EMAIL", usage: "only let users with a verified email address log in",
+		set: boolean(func(c *Config) *bool { return &c.Login.RequireVerifiedEmail })},
+	{key: "login.max_failures", env: "LOGIN_MAX_FAILURES", usage: "failed logins locking out a username, 0 for no lockout",
+		set: integer(func(c *Config) *int { return &c.Lockout.MaxFailures })},
+	{key: "login.max_ip_failures", env: "LOGIN_MAX_IP_FAILURES", usage: "failed logins locking out a client IP, 0 for no lockout",
+		set: integer(func(c *Config) *int { return &c.Lockout.MaxIPFailures })},
+	{key: "login.lockout", env: "LOGIN_LOCKOUT", usage: "length of the first lockout",
+		set: duration(func(c *Config) *time.Duration { return &c.Lockout.Lockout })},
+	{key: "login.max_lockout", env: "LOGIN_MAX_LOCKOUT", usage: "length of the longest lockout",
+		set: duration(func(c *Config) *time.Duration { return &c.Lockout.MaxLockout })},
+
+	{key: "auth.setup_token", env: "ADMIN_SETUP_TOKEN", secret: true,
+		set: text(func(c *Config) *string { return &c.Auth.SetupToken })},
+	{key: "auth.mfa_issuer", env: "MFA_ISSUER", usage: "name authenticator apps show next to the account",
+		set: text(func(c *Config) *string { return &c.Auth.MFAIssuer })},
+
+	{key: "mail.smtp_host", env: "SMTP_HOST", usage: "SMTP server mail is sent through, unset to write mail to files",
+		set: text(func(c *Config) *string { return &c.Mail.SMTPHost })},
+	{key: "mail.smtp_port", env: "SMTP_PORT", usage: "SMTP server port",
+		set: text(func(c *Config) *string { return &c.Mail.SMTPPort })},
+	{key: "mail.smtp_username", env: "SMTP_USERNAME", usage: "SMTP user name",
+		set: text(func(c *Config) *string { return &c.Mail.SMTPUsername })},
+	{key: "mail.smtp_password", env: "SMTP_PASSWORD", secret: true,
+		set: text(func(c *Config) *string { return &c.Mail.SMTPPassword })},
+	{key: "mail.from", env: "MAIL_FROM", usage: "sender address",
+		set: text(func(c *Config) *string { return &c.Mail.From })},
+	{key: "mail.dir", env: "MAIL_DIR", usage: "directory mail is written to without an SMTP server",
+		set: text(func(c *Config) *string { return &c.Mail.Dir })},
+
+	{key: "audit.log_file", env: "AUDIT_LOG_FILE", usage: "file audit entries are appended to, standard error when unset",
+		set: text(func(c *Config) *string { return &c.Audit.LogFile })},
+
+	{key: "timeouts.db_read", env: "DB_READ_TIMEOUT", usage: "how long a database read may take",
+		set: duration(func(c *Config) *time.Duration { return &c.Timeouts.Read })},
+	{key: "timeouts.db_write", env: "DB_WRITE_TIMEOUT", usage: "how long a database write may take",
+		set: duration(func(c *Config) *time.Duration { return &c.Timeouts.Write })},
+	{key: "timeouts.db_connect", env: "DB_CONNECT_TIMEOUT", usage: "how long connecting to MongoDB may take",
+		set: duration(func(c *Config) *time.Duration { return &c.Timeouts.Connect })},
+	{key: "timeouts.migrations", env: "MIGRATION_TIMEOUT", usage: "how long applying migrations on startup may take",
+		set: duration(func(c *Config) *time.Duration { return &c.Timeouts.Migrations })},
+	{key: "timeouts.health_check", env: "HEALTH_CHECK_TIMEOUT", usage: "how long each readiness check may take",
+		set: duration(func(c *Config) *time.Duration { return &c.Timeouts.HealthCheck })},
+}, collectionSettings()...)
+
+// collections lists the MongoDB collection names that can be set, under
+// database.collections
+var collections = []struct {
+	key   string
+	field func(names *repositories.CollectionNames) *string
+}{
+	{"tasks", func(n *repositories.CollectionNames) *string { return &n.Tasks }},
+	{"users", func(n *repositories.CollectionNames) *string { return &n.Users }},
+	{"refresh_tokens", func(n *repositories.CollectionNames) *string { return &n.RefreshTokens }},
+	{"revoked_tokens", func(n *repositories.CollectionNames) *string { return &n.RevokedTokens }},
+	{"password_reset_tokens", func(n *repositories.CollectionNames) *string { return &n.PasswordResetTokens }},
+	{"email_verification_tokens", func(n *repositories.CollectionNames) *string { return &n.EmailVerificationTokens }},
+	{"mfa_enrollments", func(n *repositories.CollectionNames) *string { return &n.MFAEnrollments }},
+	{"mfa_challenges", func(n *repositories.CollectionNames) *string { return &n.MFAChallenges }},
+	{"login_throttles", func(n *repositories.CollectionNames) *string { return &n.LoginThrottles }},
+	{"migrations", func(n *repositories.CollectionNames) *string { return &n.Migrations }},
+}
+
+func collectionSettings() []setting {
+	var result []setting
+	for _, collection := range collections {
+		field := collection.field
+		result = append(result, setting{
+			key:   "database.collections." + collection.key,
+			usage: "MongoDB collection of " + strings.ReplaceAll(collection.key, "_", " "),
+			set:   text(func(c *Config) *string { return field(&c.Database.Collections) }),
+		})
+	}
+	return result
+}
+
+func text(field func(c *Config) *string) func(*Config, string) error {
+	return func(c *Config, value string) error {
+		*field(c) = value
+		return nil
+	}
+}
+
+// list reads comma-separated values, leaving out empty ones
+func list(field func(c *Config) *[]string) func(*Config, string) error {
+	return func(c *Config, value string) error {
+		var items []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		*field(c) = items
+		return nil
+	}
+}
+
+func boolean(field func(c *Config) *bool) func(*Config, string) error {
+	return func(c *Config, value string) error {
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return errors.New("expected true or false")
+		}
+		*field(c) = b
+		return nil
+	}
+}
+
+func integer(field func(c *Config) *int) func(*Config, string) error {
+	return func(c *Config, value string) error {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return errors.New("expected a number")
+		}
+		*field(c) = n
+		return nil
+	}
+}
+
+func unsigned(field func(c *Config) *uint32) func(*Config, string) error {
+	return func(c *Config, value string) error {
+		n, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			return errors.New("expected a positive number")
+		}
+		*field(c) = uint32(n)
+		return nil
+	}
+}
+
+func duration(field func(c *Config) *time.Duration) func(*Config, string) error {
+	return func(c *Config, value string) error {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return errors.New("expected a duration such as 5s")
+		}
+		*field(c) = d
+		return nil
+	}
+}
+
+// Load reads the configuration given the command line arguments, without
+// the program name. The config file is named by the -config flag or
+// CONFIG_FILE, and ends in .yaml, .yml or .toml. The .env file, named by the
+// -env-file flag, is loaded when it exists; its variables are added to the
+// environment but don't replace variables already set. Every source is
+// applied before the result is validated.
+func Load(args []string) (Config, error) {
+	flags := flag.NewFlagSet("task-manager", flag.ContinueOnError)
+	configFile := flags.String("config", "", "YAML or TOML config `file`, also set with CONFIG_FILE")
+	envFile := flags.String("env-file", ".env", "`file` of environment variables, loaded when it exists")
+	bySetting := map[string]setting{}
+	for _, s := range settings {
+		bySetting[s.key] = s
+		if !s.secret {
+			usage := s.usage
+			if s.env != "" {
+				usage += ", also set with " + s.env
+			}
+			flags.String(s.key, "", usage)
+		}
+	}
+	if err := flags.Parse(args); err != nil {
+		return Config{}, err
+	}
+	if flags.NArg() > 0 {
+		return Config{}, fmt.Errorf("unexpected argument %q", flags.Arg(0))
+	}
+
+	// The default .env is optional, but a file asked for has to exist
+	if err := godotenv.Load(*envFile); err != nil {
+		if !errors.Is(err, os.ErrNotExist) || isSet(flags, "env-file") {
+			return Config{}, fmt.Errorf("env file %s: %w", *envFile, err)
+		}
+	}
+
+	cfg := Default()
+	if *configFile == "" {
+		*configFile = os.Getenv("CONFIG_FILE")
+	}
+	if *configFile != "" {
+		if err := cfg.applyFile(*configFile, bySetting); err != nil {
+			return Config{}, err
+		}
+	}
+
+	for _, s := range settings {
+		if s.env == "" {
+			continue
+		}
+		if value := os.Getenv(s.env); value != "" {
+			if err := s.set(&cfg, value); err != nil {
+				return Config{}, fmt.Errorf("invalid %s %q: %w", s.env, value, err)
+			}
+		}
+	}
+
+	var flagErr error
+	flags.Visit(func(f *flag.Flag) {
+		if s, ok := bySetting[f.Name]; ok && flagErr == nil {
+			if err := s.set(&cfg, f.Value.String()); err != nil {
+				flagErr = fmt.Errorf("invalid -%s %q: %w", f.Name, f.Value, err)
+			}
+		}
+	})
+	if flagErr != nil {
+		return Config{}, flagErr
+	}
+
+	if cfg.Database.Backend == BackendSQLite && cfg.Database.URL == "" {
+		cfg.Database.URL = DefaultSQLiteFile
+	}
+	return cfg, cfg.Validate()
+}
+
+func isSet(flags *flag.FlagSet, name string) bool {
+	set := false
+	flags.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
+// applyFile applies the settings in a YAML or TOML file. Sections nest the
+// way the keys are dotted, so database.collections.tasks is the tasks key
+// of the collections section of the database section. Unknown keys are
+// rejected rather than ignored, as they are most likely typos.
+func (c *Config) applyFile(path string, bySetting map[string]setting) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("config file: %w", err)
+	}
+
+	raw := map[string]interface{}{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &raw)
+	case ".toml":
+		err = toml.Unmarshal(data, &raw)
+	default:
+		return fmt.Errorf("config file %s must end in .yaml, .yml or .toml", path)
+	}
+	if err != nil {
+		return fmt.Errorf("config file %s: %w", path, err)
+	}
+
+	values := map[string]string{}
+	if err := flatten("", raw, values); err != nil {
+		return fmt.Errorf("config file %s: %w", path, err)
+	}
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		s, ok := bySetting[key]
+		if !ok {
+			return fmt.Errorf("config file %s: unknown setting %s", path, key)
+		}
+		if err := s.set(c, values[key]); err != nil {
+			return fmt.Errorf("config file %s: invalid %s %q: %w", path, key, values[key], err)
+		}
+	}
+	return nil
+}
+
+// flatten turns nested sections into dotted keys, and lists into
+// comma-separated values
+func flatten(prefix string, value interface{}, into map[string]string) error {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			if prefix != "" {
+				key = prefix + "." + key
+			}
+			if err := flatten(key, child, into); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		items := make([]string, len(v))
+		for i, item := range v {
+			switch item.(type) {
+			case map[string]interface{}, []interface{}:
+				return fmt.Errorf("%s must list plain values", prefix)
+			}
+			items[i] = fmt.Sprint(item)
+		}
+		into[prefix] = strings.Join(items, ",")
+	case nil:
+		into[prefix] = ""
+	default:
+		into[prefix] = fmt.Sprint(v)
+	}
+	return nil
+}
+
+// Validate returns every problem with the configuration
+func (c Config) Validate() error {
+	var errs []error
+	fail := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	if _, _, err := net.SplitHostPort(c.Server.Addr); err != nil {
+		fail("server.addr %q is not a host and port, such as :8080", c.Server.Addr)
+	}
+	for _, proxy := range c.Server.TrustedProxies {
+		if _, _, err := net.ParseCIDR(proxy); err != nil && net.ParseIP(proxy) == nil {
+			fail("server.trusted_proxies entry %q is not an IP address or CIDR", proxy)
+		}
+	}
+
+	switch c.Database.Backend {
+	case BackendMongo:
+		if c.Database.MongoURI == "" {
+			fail("database.mongodb_uri is required for MongoDB")
+		}
+		if c.Database.Name == "" {
+			fail("database.name is required for MongoDB")
+		}
+		seen := map[string]string{}
+		for _, collection := range collections {
+			name := *collection.field(&c.Database.Collections)
+			if name == "" {
+				fail("database.collections.%s cannot be empty", collection.key)
+			} else if other, ok := seen[name]; ok {
+				fail("database.collections.%s and %s are both %q", other, collection.key, name)
+			}
+			seen[name] = collection.key
+		}
+	case BackendSQLite, BackendPostgres:
+		if c.Database.URL == "" {
+			fail("database.url is required for %s", c.Database.Backend)
+		}
+	case BackendMemory:
+	default:
+		fail("database.backend %q is not one of %s, %s, %s or %s",
+			c.Database.Backend, BackendMongo, BackendSQLite, BackendPostgres, BackendMemory)
+	}
+
+	if err := c.Password.Validate(); err != nil {
+		fail("password: %v", err)
+	}
+	for _, limit := range []struct {
+		key   string
+		value int
+	}{
+		{"password.min_length", c.PasswordPolicy.MinLength},
+		{"password.max_bytes", c.PasswordPolicy.MaxBytes},
+		{"password.min_classes", c.PasswordPolicy.MinClasses},
+		{"login.max_failures", c.Lockout.MaxFailures},
+		{"login.max_ip_failures", c.Lockout.MaxIPFailures},
+	} {
+		if limit.value < 0 {
+			fail("%s cannot be negative", limit.key)
+		}
+	}
+	if c.PasswordPolicy.MaxBytes > usecases.MaxPasswordBytes {
+		fail("password.max_bytes %d is more than the %d bytes bcrypt hashes", c.PasswordPolicy.MaxBytes, usecases.MaxPasswordBytes)
+	}
+	if c.PasswordPolicy.MinClasses > 4 {
+		fail("password.min_classes %d is more than the 4 character classes", c.PasswordPolicy.MinClasses)
+	}
+	if c.Lockout.MaxLockout < c.Lockout.Lockout {
+		fail("login.max_lockout %s is shorter than login.lockout %s", c.Lockout.MaxLockout, c.Lockout.Lockout)
+	}
+
+	if c.Auth.SetupToken != "" && len(c.Auth.SetupToken) < MinSetupTokenLength {
+		fail("auth.setup_token must be at least %d characters", MinSetupTokenLength)
+	}
+	if c.Auth.MFAIssuer == "" {
+		fail("auth.mfa_issuer cannot be empty")
+	}
+	if err := c.Mail.Validate(); err != nil {
+		fail("mail: %v", err)
+	}
+
+	for _, timeout := range []struct {
+		key      string
+		duration time.Duration
+	}{
+		{"server.read_timeout", c.Server.ReadTimeout},
+		{"server.write_timeout", c.Server.WriteTimeout},
+		{"server.idle_timeout", c.Server.IdleTimeout},
+		{"server.shutdown_timeout", c.Server.ShutdownTimeout},
+		{"database.token_cleanup_interval", c.Database.TokenCleanupInterval},
+		{"login.lockout", c.Lockout.Lockout},
+		{"login.max_lockout", c.Lockout.MaxLockout},
+		{"timeouts.db_read", c.Timeouts.Read},
+		{"timeouts.db_write", c.Timeouts.Write},
+		{"timeouts.db_connect", c.Timeouts.Connect},
+		{"timeouts.migrations", c.Timeouts.Migrations},
+		{"timeouts.health_check", c.Timeouts.HealthCheck},
+	} {
+		if timeout.duration <= 0 {
+			fail("%s must be positive", timeout.key)
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -1,93 +1,366 @@
 package controllers
 
 import (
+	"context"
+	"encoding/json"
+	"log"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 	"task-manager/Domain"
+	"task-manager/Infrastructure"
 
 	"github.com/gin-gonic/gin"
 )
 
 type Controller struct {
-	taskUsecase domain.TaskUsecase
-	userUsecase domain.UserUsecase
+	taskUsecase         domain.TaskUsecase
+	userUsecase         domain.UserUsecase
+	resetUsecase        domain.PasswordResetUsecase
+	verificationUsecase domain.EmailVerificationUsecase
+	mfaUsecase          domain.MFAUsecase
 }
 
-func NewController(taskUsecase domain.TaskUsecase, userUsecase domain.UserUsecase) *Controller {
+func NewController(
+	taskUsecase domain.TaskUsecase,
+	userUsecase domain.UserUsecase,
+	resetUsecase domain.PasswordResetUsecase,
+	verificationUsecase domain.EmailVerificationUsecase,
+	mfaUsecase domain.MFAUsecase,
+) *Controller {
 	return &Controller{
-		taskUsecase: taskUsecase,
-		userUsecase: userUsecase,
+		taskUsecase:         taskUsecase,
+		userUsecase:         userUsecase,
+		resetUsecase:        resetUsecase,
+		verificationUsecase: verificationUsecase,
+		mfaUsecase:          mfaUsecase,
+	}
+}
+
+// callerFromContext builds the caller identity from the claims set by AuthMiddleware
+func callerFromContext(c *gin.Context) domain.Caller {
+	return domain.Caller{
+		UserID:         c.GetString("user_id"),
+		Username:       c.GetString("username"),
+		Role:           c.GetString("role"),
+		TokenID:        c.GetString("token_id"),
+		TokenExpiresAt: c.GetTime("token_expires_at"),
 	}
 }
 
 // Task Controllers
 func (ctrl *Controller) GetTasks(c *gin.Context) {
-	tasks, err := ctrl.taskUsecase.GetAllTasks()
+	query, err := parseTaskQuery(c)
+	if err != nil {
+		infrastructure.AbortWithError(c, err)
+		return
+	}
+
+	page, err := ctrl.taskUsecase.GetAllTasks(c.Request.Context(), callerFromContext(c), query)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		infrastructure.AbortWithError(c, err)
 		return
 	}
-	c.JSON(http.StatusOK, tasks)
+
+	if next := page.Offset + len(page.Tasks); int64(next) < page.Total {
+		page.Next = pageLink(c, page.Limit, next)
+	}
+	c.JSON(http.StatusOK, page)
+}
+
+// parseTaskQuery reads listing options from the query string, e.g.
+// /tasks?status=todo&title=report&due_from=2024-01-01&sort=due_date&order=desc&limit=20&offset=40
+func parseTaskQuery(c *gin.Context) (domain.TaskQuery, error) {
+	query := domain.TaskQuery{
+		Status: domain.TaskStatus(c.Query("status")),
+		Title:  c.Query("title"),
+		SortBy: c.Query("sort"),
+	}
+
+	switch c.DefaultQuery("order", "asc") {
+	case "asc":
+	case "desc":
+		query.SortDesc = true
+	default:
+		return domain.TaskQuery{}, domain.Validation("order must be asc or desc")
+	}
+
+	var err error
+	if query.DueFrom, err = timeQuery(c, "due_from", false); err != nil {
+		return domain.TaskQuery{}, err
+	}
+	if query.DueTo, err = timeQuery(c, "due_to", true); err != nil {
+		return domain.TaskQuery{}, err
+	}
+	if query.Limit, err = intQuery(c, "limit"); err != nil {
+		return domain.TaskQuery{}, err
+	}
+	if query.Offset, err = intQuery(c, "offset"); err != nil {
+		return domain.TaskQuery{}, err
+	}
+
+	return query, nil
+}
+
+func intQuery(c *gin.Context, key string) (int, error) {
+	raw := c.Query(key)
+	if raw == "" {
+		return 0, nil
+	}
+	value, err := strconv.Atoi(raw)
+	if err != nil {
+		return 0, domain.Validation("%s must be an integer", key)
+	}
+	return value, nil
+}
+
+// timeQuery accepts an RFC 3339 timestamp or a plain YYYY-MM-DD date. A plain
+// date used as an upper bound covers the whole day.
+func timeQuery(c *gin.Context, key string, endOfDay bool) (time.Time, error) {
+	raw := c.Query(key)
+	if raw == "" {
+		return time.Time{}, nil
+	}
+	if value, err := time.Parse(time.RFC3339, raw); err == nil {
+		return value, nil
+	}
+	value, err := time.Parse("2006-01-02", raw)
+	if err != nil {
+		return time.Time{}, domain.Validation("%s must be an RFC 3339 timestamp or YYYY-MM-DD date", key)
+	}
+	if endOfDay {
+		value = value.Add(24*time.Hour - time.Millisecond)
+	}
+	return value, nil
+}
+
+// pageLink rebuilds the current request URL pointing at another page
+func pageLink(c *gin.Context, limit, offset int) string {
+	values := c.Request.URL.Query()
+	values.Set("limit", strconv.Itoa(limit))
+	values.Set("offset", strconv.Itoa(offset))
+	return c.Request.URL.Path + "?" + values.Encode()
 }
 
 func (ctrl *Controller) GetTaskByID(c *gin.Context) {
 	id := c.Param("id")
-	task, err := ctrl.taskUsecase.GetTaskByID(id)
+	task, err := ctrl.taskUsecase.GetTaskByID(c.Request.Context(), callerFromContext(c), id)
 	if err != nil {
-		if err.Error() == "not found" {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
-		} else {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		}
+		infrastructure.AbortWithError(c, err)
 		return
 	}
+	setETag(c, task)
 	c.JSON(http.StatusOK, task)
 }
 
 func (ctrl *Controller) CreateTask(c *gin.Context) {
 	var newTask domain.Task
 	if err := c.ShouldBindJSON(&newTask); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		infrastructure.AbortWithProblem(c, http.StatusBadRequest, err.Error())
 		return
 	}
-	created, err := ctrl.taskUsecase.CreateTask(newTask)
+	created, err := ctrl.taskUsecase.CreateTask(c.Request.Context(), callerFromContext(c), newTask)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		infrastructure.AbortWithError(c, err)
 		return
 	}
+	setETag(c, created)
 	c.JSON(http.StatusCreated, created)
 }
 
 func (ctrl *Controller) UpdateTask(c *gin.Context) {
 	id := c.Param("id")
 
+	version, err := ctrl.ifMatchVersion(c, id)
+	if err != nil {
+		infrastructure.AbortWithError(c, err)
+		return
+	}
+
 	var updatedTask domain.Task
 	if err := c.ShouldBindJSON(&updatedTask); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		infrastructure.AbortWithProblem(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	task, err := ctrl.taskUsecase.UpdateTask(c.Request.Context(), callerFromContext(c), id, updatedTask, version)
+	if err != nil {
+		infrastructure.AbortWithError(c, err)
+		return
+	}
+
+	setETag(c, task)
+	c.JSON(http.StatusOK, task)
+}
+
+// PatchTask applies a JSON Merge Patch (RFC 7396) to a task. Only the
+// supplied fields are changed; a null due_date clears it.
+func (ctrl *Controller) PatchTask(c *gin.Context) {
+	id := c.Param("id")
+
+	version, err := ctrl.ifMatchVersion(c, id)
+	if err != nil {
+		infrastructure.AbortWithError(c, err)
+		return
+	}
+
+	patch, err := parseTaskPatch(c)
+	if err != nil {
+		infrastructure.AbortWithError(c, err)
 		return
 	}
 
-	task, err := ctrl.taskUsecase.UpdateTask(id, updatedTask)
+	task, err := ctrl.taskUsecase.PatchTask(c.Request.Context(), callerFromContext(c), id, patch, version)
 	if err != nil {
-		if err.Error() == "not found" {
-			c.JSON(http.StatusNotFound, gin.H{"message": "Task not Found"})
-		} else {
-			c.JSON(http.StatusBadRequest, gin.H{"Error": err.Error()})
+		infrastructure.AbortWithError(c, err)
+		return
+	}
+
+	setETag(c, task)
+	c.JSON(http.StatusOK, task)
+}
+
+func parseTaskPatch(c *gin.Context) (domain.TaskPatch, error) {
+	var fields map[string]json.RawMessage
+	if err := json.NewDecoder(c.Request.Body).Decode(&fields); err != nil {
+		return domain.TaskPatch{}, domain.Validation("patch body must be a JSON object")
+	}
+
+	var patch domain.TaskPatch
+	for key, raw := range fields {
+		isNull := string(raw) == "null"
+
+		var err error
+		switch key {
+		case "title":
+			if isNull {
+				return domain.TaskPatch{}, domain.Validation("title cannot be null")
+			}
+			err = json.Unmarshal(raw, &patch.Title)
+		case "description":
+			if isNull {
+				patch.Description = new(string)
+				continue
+			}
+			err = json.Unmarshal(raw, &patch.Description)
+		case "due_date":
+			if isNull {
+				patch.ClearDueDate = true
+				continue
+			}
+			err = json.Unmarshal(raw, &patch.DueDate)
+		case "status":
+			if isNull {
+				return domain.TaskPatch{}, domain.Validation("status cannot be null")
+			}
+			err = json.Unmarshal(raw, &patch.Status)
+		case "assignee_id":
+			if isNull {
+				return domain.TaskPatch{}, domain.Validation("assignee_id cannot be null")
+			}
+			err = json.Unmarshal(raw, &patch.AssigneeID)
+		case "id", "created_by", "created_at", "updated_at", "version":
+			return domain.TaskPatch{}, domain.Validation("%s is read-only", key)
+		default:
+			return domain.TaskPatch{}, domain.Validation("unknown field %q", key)
 		}
+		if err != nil {
+			return domain.TaskPatch{}, domain.Validation("invalid value for %s", key)
+		}
+	}
+
+	return patch, nil
+}
+
+type transitionRequest struct {
+	Status domain.TaskStatus `json:"status" binding:"required"`
+}
+
+func (ctrl *Controller) TransitionTask(c *gin.Context) {
+	id := c.Param("id")
+
+	var req transitionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		infrastructure.AbortWithProblem(c, http.StatusBadRequest, err.Error())
 		return
 	}
 
+	task, err := ctrl.taskUsecase.TransitionTask(c.Request.Context(), callerFromContext(c), id, req.Status)
+	if err != nil {
+		infrastructure.AbortWithError(c, err)
+		return
+	}
+
+	setETag(c, task)
 	c.JSON(http.StatusOK, task)
 }
 
+// setETag exposes the task version so clients can send it back in If-Match
+func setETag(c *gin.Context, task domain.Task) {
+	c.Header("ETag", strconv.Quote(strconv.FormatInt(task.Version, 10)))
+}
+
+// errIfMatchFailed is returned when no entity tag in If-Match matches the task
+var errIfMatchFailed = &domain.Error{Kind: domain.ErrPreconditionFailed, Message: "If-Match does not match the current task ETag"}
+
+// ifMatchVersion reads the version from an If-Match header. A missing header
+// or "*" returns zero, which skips the version check. If-Match uses strong
+// comparison, so weak tags never match; when several tags are listed the
+// current version is returned if any of them matches it.
+func (ctrl *Controller) ifMatchVersion(c *gin.Context, id string) (int64, error) {
+	header := strings.TrimSpace(c.GetHeader("If-Match"))
+	if header == "" || header == "*" {
+		return 0, nil
+	}
+
+	var versions []int64
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if strings.HasPrefix(tag, "W/") {
+			continue
+		}
+		raw, err := strconv.Unquote(tag)
+		if err != nil {
+			return 0, domain.Validation("If-Match must be a list of quoted ETags")
+		}
+		// Tags that are not task versions are well-formed but can never match
+		if version, err := strconv.ParseInt(raw, 10, 64); err == nil && version > 0 {
+			versions = append(versions, version)
+		}
+	}
+
+	switch len(versions) {
+	case 0:
+		return 0, errIfMatchFailed
+	case 1:
+		return versions[0], nil
+	}
+
+	current, err := ctrl.taskUsecase.GetTaskByID(c.Request.Context(), callerFromContext(c), id)
+	if err != nil {
+		return 0, err
+	}
+	for _, version := range versions {
+		if version == current.Version {
+			return version, nil
+		}
+	}
+	return 0, errIfMatchFailed
+}
+
 func (ctrl *Controller) DeleteTask(c *gin.Context) {
 	id := c.Param("id")
-	err := ctrl.taskUsecase.DeleteTask(id)
+
+	version, err := ctrl.ifMatchVersion(c, id)
 	if err != nil {
-		if err.Error() == "not found" {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
-		} else {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		}
+		infrastructure.AbortWithError(c, err)
+		return
+	}
+
+	err = ctrl.taskUsecase.DeleteTask(c.Request.Context(), callerFromContext(c), id, version)
+	if err != nil {
+		infrastructure.AbortWithError(c, err)
 		return
 	}
 	c.Status(http.StatusNoContent)
@@ -97,57 +370,468 @@ func (ctrl *Controller) DeleteTask(c *gin.Context) {
 func (ctrl *Controller) Register(c *gin.Context) {
 	var user domain.User
 	if err := c.ShouldBindJSON(&user); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		infrastructure.AbortWithProblem(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	createdUser, err := ctrl.userUsecase.RegisterUser(c.Request.Context(), user)
+	if err != nil {
+		infrastructure.AbortWithError(c, err)
+		return
+	}
+	ctrl.sendVerification(c.Request.Context(), createdUser)
+	c.JSON(http.StatusCreated, createdUser.Public())
+}
+
+type setupAdminRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Email    string `json:"email"`
+}
+
+// SetupAdmin creates the first admin with the setup token
+func (ctrl *Controller) SetupAdmin(c *gin.Context) {
+	var req setupAdminRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		infrastructure.AbortWithProblem(c, http.StatusBadRequest, err.Error())
 		return
 	}
-	createdUser, err := ctrl.userUsecase.RegisterUser(user)
+	admin, err := ctrl.userUsecase.SetupAdmin(c.Request.Context(), req.Token, domain.User{Username: req.Username, Password: req.Password, Email: req.Email})
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		infrastructure.AbortWithError(c, err)
 		return
 	}
-	c.JSON(http.StatusCreated, createdUser)
+	ctrl.sendVerification(c.Request.Context(), admin)
+	c.JSON(http.StatusCreated, admin.Public())
+}
+
+// sendVerification mails a verification token when the user has an address
+// to verify. The account change already succeeded, so a failure is only
+// logged; the user can ask for another mail.
+func (ctrl *Controller) sendVerification(ctx context.Context, user domain.User) {
+	if user.Email == "" || user.EmailVerified {
+		return
+	}
+	if err := ctrl.verificationUsecase.SendVerification(ctx, user.ID.String()); err != nil {
+		log.Printf("verification mail for user %s failed: %v", user.ID, err)
+	}
 }
 
 func (ctrl *Controller) Login(c *gin.Context) {
 	var user domain.User
 	if err := c.ShouldBindJSON(&user); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		infrastructure.AbortWithProblem(c, http.StatusBadRequest, err.Error())
 		return
 	}
-	loginResp, err := ctrl.userUsecase.LoginUser(user)
+	loginResp, err := ctrl.userUsecase.LoginUser(c.Request.Context(), user, c.ClientIP())
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		infrastructure.AbortWithError(c, err)
 		return
 	}
 	c.JSON(http.StatusOK, loginResp)
 }
 
+type mfaLoginRequest struct {
+	MFAToken string `json:"mfa_token" binding:"required"`
+	Code     string `json:"code" binding:"required"`
+}
+
+// LoginMFA finishes a login that answered mfa_required
+func (ctrl *Controller) LoginMFA(c *gin.Context) {
+	var req mfaLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		infrastructure.AbortWithProblem(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	loginResp, err := ctrl.userUsecase.CompleteMFALogin(c.Request.Context(), req.MFAToken, req.Code)
+	if err != nil {
+		infrastructure.AbortWithError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, loginResp)
+}
+
+type refreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+func (ctrl *Controller) Refresh(c *gin.Context) {
+	var req refreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		infrastructure.AbortWithProblem(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	loginResp, err := ctrl.userUsecase.RefreshToken(c.Request.Context(), req.RefreshToken)
+	if err != nil {
+		infrastructure.AbortWithError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, loginResp)
+}
+
+type logoutRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// Logout revokes the access token used for the request. The body is optional;
+// a refresh token in it is revoked along with every token rotated from it.
+func (ctrl *Controller) Logout(c *gin.Context) {
+	var req logoutRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			infrastructure.AbortWithProblem(c, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	if err := ctrl.userUsecase.Logout(c.Request.Context(), callerFromContext(c), req.RefreshToken); err != nil {
+		infrastructure.AbortWithError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
 func (ctrl *Controller) Promote(c *gin.Context) {
 	id := c.Param("id")
-	updatedUser, err := ctrl.userUsecase.PromoteUser(id)
-	if err != nil {
-		if err.Error() == "invalid user ID" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		} else if err.Error() == "user not found" {
-			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
-		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		}
+	updatedUser, err := ctrl.userUsecase.PromoteUser(c.Request.Context(), callerFromContext(c), id)
+	if err != nil {
+		infrastructure.AbortWithError(c, err)
 		return
 	}
-	c.JSON(http.StatusOK, updatedUser)
+	c.JSON(http.StatusOK, updatedUser.Public())
 }
 
 func (ctrl *Controller) GetUserByUsername(c *gin.Context) {
 	username := c.Param("username")
-	user, err := ctrl.userUsecase.GetUserByUsername(username)
+	user, err := ctrl.userUsecase.GetUserByUsername(c.Request.Context(), callerFromContext(c), username)
+	if err != nil {
+		infrastructure.AbortWithError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, user.Public())
+}
+
+// ListUsers lists users page by page, e.g.
+// /users?role=admin&disabled=false&limit=20&offset=40
+func (ctrl *Controller) ListUsers(c *gin.Context) {
+	query := domain.UserQuery{Role: c.Query("role")}
+	if raw := c.Query("disabled"); raw != "" {
+		disabled, err := strconv.ParseBool(raw)
+		if err != nil {
+			infrastructure.AbortWithError(c, domain.Validation("disabled must be true or false"))
+			return
+		}
+		query.Disabled = &disabled
+	}
+
+	var err error
+	if query.Limit, err = intQuery(c, "limit"); err != nil {
+		infrastructure.AbortWithError(c, err)
+		return
+	}
+	if query.Offset, err = intQuery(c, "offset"); err != nil {
+		infrastructure.AbortWithError(c, err)
+		return
+	}
+
+	page, err := ctrl.userUsecase.ListUsers(c.Request.Context(), callerFromContext(c), query)
+	if err != nil {
+		infrastructure.AbortWithError(c, err)
+		return
+	}
+
+	if next := page.Offset + len(page.Users); int64(next) < page.Total {
+		page.Next = pageLink(c, page.Limit, next)
+	}
+	c.JSON(http.StatusOK, page)
+}
+
+type roleRequest struct {
+	Role string `json:"role" binding:"required"`
+}
+
+func (ctrl *Controller) SetUserRole(c *gin.Context) {
+	var req roleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		infrastructure.AbortWithProblem(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	user, err := ctrl.userUsecase.SetUserRole(c.Request.Context(), callerFromContext(c), c.Param("id"), req.Role)
+	if err != nil {
+		infrastructure.AbortWithError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, user.Public())
+}
+
+func (ctrl *Controller) DisableUser(c *gin.Context) {
+	ctrl.setUserDisabled(c, true)
+}
+
+func (ctrl *Controller) EnableUser(c *gin.Context) {
+	ctrl.setUserDisabled(c, false)
+}
+
+func (ctrl *Controller) setUserDisabled(c *gin.Context, disabled bool) {
+	user, err := ctrl.userUsecase.SetUserDisabled(c.Request.Context(), callerFromContext(c), c.Param("id"), disabled)
+	if err != nil {
+		infrastructure.AbortWithError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, user.Public())
+}
+
+// ResetUserMFA turns off a user's two-factor authentication
+func (ctrl *Controller) ResetUserMFA(c *gin.Context) {
+	if err := ctrl.userUsecase.ResetUserMFA(c.Request.Context(), callerFromContext(c), c.Param("id")); err != nil {
+		infrastructure.AbortWithError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// UnlockUser lifts the lockout of a user who failed to log in too often
+func (ctrl *Controller) UnlockUser(c *gin.Context) {
+	if err := ctrl.userUsecase.UnlockUser(c.Request.Context(), callerFromContext(c), c.Param("id")); err != nil {
+		infrastructure.AbortWithError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// DeleteUser deletes a user. ?tasks=reassign (the default) hands their tasks
+// to ?reassign_to or the caller; ?tasks=delete deletes the tasks they created.
+func (ctrl *Controller) DeleteUser(c *gin.Context) {
+	options := domain.DeleteUserOptions{
+		Tasks:      domain.TaskPolicy(c.Query("tasks")),
+		ReassignTo: domain.ID(c.Query("reassign_to")),
+	}
+	if err := ctrl.userUsecase.DeleteUser(c.Request.Context(), callerFromContext(c), c.Param("id"), options); err != nil {
+		infrastructure.AbortWithError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// GetMe returns the caller's own profile
+func (ctrl *Controller) GetMe(c *gin.Context) {
+	user, err := ctrl.userUsecase.GetProfile(c.Request.Context(), callerFromContext(c))
 	if err != nil {
-		if err.Error() == "user not found" {
-			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
-		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		infrastructure.AbortWithError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, user.Public())
+}
+
+// PatchMe updates the caller's own profile. The role, password and other
+// account fields are rejected; they have their own endpoints.
+func (ctrl *Controller) PatchMe(c *gin.Context) {
+	patch, err := parseProfilePatch(c)
+	if err != nil {
+		infrastructure.AbortWithError(c, err)
+		return
+	}
+
+	user, err := ctrl.userUsecase.UpdateProfile(c.Request.Context(), callerFromContext(c), patch)
+	if err != nil {
+		infrastructure.AbortWithError(c, err)
+		return
+	}
+	if patch.Email != nil {
+		ctrl.sendVerification(c.Request.Context(), user)
+	}
+	c.JSON(http.StatusOK, user.Public())
+}
+
+func parseProfilePatch(c *gin.Context) (domain.ProfilePatch, error) {
+	var fields map[string]json.RawMessage
+	if err := json.NewDecoder(c.Request.Body).Decode(&fields); err != nil {
+		return domain.ProfilePatch{}, domain.Validation("patch body must be a JSON object")
+	}
+
+	var patch domain.ProfilePatch
+	for key, raw := range fields {
+		var err error
+		switch key {
+		case "username":
+			if string(raw) == "null" {
+				return domain.ProfilePatch{}, domain.Validation("username cannot be null")
+			}
+			err = json.Unmarshal(raw, &patch.Username)
+		case "email":
+			// null and "" both remove the address
+			patch.Email = new(string)
+			if string(raw) != "null" {
+				err = json.Unmarshal(raw, patch.Email)
+			}
+		case "id", "role", "disabled", "password", "email_verified":
+			return domain.ProfilePatch{}, domain.Validation("%s cannot be changed here", key)
+		default:
+			return domain.ProfilePatch{}, domain.Validation("unknown field %q", key)
+		}
+		if err != nil {
+			return domain.ProfilePatch{}, domain.Validation("invalid value for %s", key)
 		}
+	}
+
+	return patch, nil
+}
+
+type passwordChangeRequest struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required"`
+}
+
+func (ctrl *Controller) ChangePassword(c *gin.Context) {
+	var req passwordChangeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		infrastructure.AbortWithProblem(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := ctrl.userUsecase.ChangePassword(c.Request.Context(), callerFromContext(c), req.CurrentPassword, req.NewPassword); err != nil {
+		infrastructure.AbortWithError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (ctrl *Controller) GetMFAStatus(c *gin.Context) {
+	status, err := ctrl.mfaUsecase.GetMFAStatus(c.Request.Context(), callerFromContext(c))
+	if err != nil {
+		infrastructure.AbortWithError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, status)
+}
+
+type mfaEnrollRequest struct {
+	Password string `json:"password" binding:"required"`
+}
+
+// EnrollMFA returns a new TOTP secret and its otpauth URI, for the client
+// to show as a QR code
+func (ctrl *Controller) EnrollMFA(c *gin.Context) {
+	var req mfaEnrollRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		infrastructure.AbortWithProblem(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	setup, err := ctrl.mfaUsecase.EnrollMFA(c.Request.Context(), callerFromContext(c), req.Password)
+	if err != nil {
+		infrastructure.AbortWithError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, setup)
+}
+
+type mfaConfirmRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+type recoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// ConfirmMFA turns two-factor authentication on and returns the recovery
+// codes, which can't be retrieved later
+func (ctrl *Controller) ConfirmMFA(c *gin.Context) {
+	var req mfaConfirmRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		infrastructure.AbortWithProblem(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	codes, err := ctrl.mfaUsecase.ConfirmMFA(c.Request.Context(), callerFromContext(c), req.Code)
+	if err != nil {
+		infrastructure.AbortWithError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, recoveryCodesResponse{RecoveryCodes: codes})
+}
+
+type mfaDisableRequest struct {
+	Password string `json:"password" binding:"required"`
+	Code     string `json:"code" binding:"required"`
+}
+
+func (ctrl *Controller) DisableMFA(c *gin.Context) {
+	var req mfaDisableRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		infrastructure.AbortWithProblem(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := ctrl.mfaUsecase.DisableMFA(c.Request.Context(), callerFromContext(c), req.Password, req.Code); err != nil {
+		infrastructure.AbortWithError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+type resetRequest struct {
+	Username string `json:"username" binding:"required"`
+}
+
+// RequestPasswordReset answers 202 whether or not the user exists
+func (ctrl *Controller) RequestPasswordReset(c *gin.Context) {
+	var req resetRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		infrastructure.AbortWithProblem(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := ctrl.resetUsecase.RequestPasswordReset(c.Request.Context(), req.Username); err != nil {
+		infrastructure.AbortWithError(c, err)
 		return
 	}
-	c.JSON(http.StatusOK, user)
-}
\ No newline at end of file
+	c.Status(http.StatusAccepted)
+}
+
+type resetConfirmRequest struct {
+	Token       string `json:"token" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required"`
+}
+
+func (ctrl *Controller) ResetPassword(c *gin.Context) {
+	var req resetConfirmRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		infrastructure.AbortWithProblem(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := ctrl.resetUsecase.ResetPassword(c.Request.Context(), req.Token, req.NewPassword); err != nil {
+		infrastructure.AbortWithError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+type verificationRequest struct {
+	Username string `json:"username" binding:"required"`
+}
+
+// RequestVerification answers 202 whether or not the user exists
+func (ctrl *Controller) RequestVerification(c *gin.Context) {
+	var req verificationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		infrastructure.AbortWithProblem(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := ctrl.verificationUsecase.RequestVerification(c.Request.Context(), req.Username); err != nil {
+		infrastructure.AbortWithError(c, err)
+		return
+	}
+	c.Status(http.StatusAccepted)
+}
+
+type verifyEmailRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+func (ctrl *Controller) VerifyEmail(c *gin.Context) {
+	var req verifyEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		infrastructure.AbortWithProblem(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := ctrl.verificationUsecase.VerifyEmail(c.Request.Context(), req.Token); err != nil {
+		infrastructure.AbortWithError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
@@ -2,73 +2,267 @@ package main
 
 import (
 	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
 	"log"
+	"net"
+	"net/http"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 	"time"
 
+	"task-manager/Delivery/config"
 	"task-manager/Delivery/controllers"
 	"task-manager/Delivery/routers"
+	"task-manager/Domain"
 	infrastructure "task-manager/Infrastructure"
 	"task-manager/Repositories"
 	"task-manager/Usecases"
 
-	"github.com/joho/godotenv"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
 )
 
 func main() {
-	// Load environment variables from .env file
-	err := godotenv.Load()
-	if err != nil {
-		log.Fatal("Error loading .env file")
+	cfg, err := config.Load(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
 	}
-
-	// Get MongoDB URI from environment variable
-	uri := os.Getenv("MONGODB_URI")
-	if uri == "" {
-		log.Fatal("MONGODB_URI not set in environment")
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
 	}
 
-	// Connect to MongoDB
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
+	// Resources are closed in reverse order once the server has drained
+	lifecycle := infrastructure.NewLifecycle()
 
-	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	// Initialize services
+	passwordService, err := infrastructure.NewPasswordServiceWithHashing(cfg.Password)
 	if err != nil {
-		log.Fatalf("Failed to connect to MongoDB: %v", err)
+		log.Fatalf("Failed to configure password hashing: %v", err)
 	}
+	jwtService := infrastructure.NewJWTService(loadSigningKeys(cfg.JWT))
+	health := infrastructure.NewHealthRegistry(cfg.Timeouts.HealthCheck)
+	health.Register("jwt_keys", jwtService.CheckKeys)
+	totpService := infrastructure.NewTOTPService(cfg.Auth.MFAIssuer)
 
-	// Select database and collections
-	db := client.Database("taskdb")
-	taskCollection := db.Collection("tasks")
-	userCollection := db.Collection("users")
+	// Initialize repositories for the configured storage backend
+	timeouts := repositories.Timeouts{Read: cfg.Timeouts.Read, Write: cfg.Timeouts.Write}
+	var taskRepo domain.TaskRepository
+	var userRepo domain.UserRepository
+	var tokenRepo domain.TokenRepository
+	var resetRepo domain.ResetTokenRepository
+	var verificationRepo domain.VerificationTokenRepository
+	var mfaRepo domain.MFARepository
+	var throttleRepo domain.LoginThrottleRepository
 
-	// Initialize services
-	passwordService := infrastructure.NewPasswordService()
-	jwtService := infrastructure.NewJWTService()
+	switch cfg.Database.Backend {
+	case config.BackendMemory:
+		log.Println("Using in-memory storage, data is lost on restart")
+		taskRepo = repositories.NewMemoryTaskRepository()
+		userRepo = repositories.NewMemoryUserRepository(passwordService)
+		tokenRepo = repositories.NewMemoryTokenRepository()
+		resetRepo = repositories.NewMemoryResetTokenRepository()
+		verificationRepo = repositories.NewMemoryVerificationTokenRepository()
+		mfaRepo = repositories.NewMemoryMFARepository()
+		throttleRepo = repositories.NewMemoryLoginThrottleRepository()
+	case config.BackendMongo:
+		db := connectMongo(cfg.Database, cfg.Timeouts)
+		lifecycle.OnStop("MongoDB client", db.Client().Disconnect)
+		names := cfg.Database.Collections
+		health.Register("mongodb", func(ctx context.Context) error { return db.Client().Ping(ctx, readpref.Primary()) })
+		health.Register("migrations", migrationCheck(func(ctx context.Context) ([]string, error) {
+			return repositories.PendingMigrations(ctx, db, names)
+		}))
+		taskRepo = repositories.NewTaskRepository(db.Collection(names.Tasks), timeouts)
+		userRepo = repositories.NewUserRepository(db.Collection(names.Users), passwordService, timeouts)
+		tokenRepo = repositories.NewTokenRepository(db.Collection(names.RefreshTokens), db.Collection(names.RevokedTokens), timeouts)
+		resetRepo = repositories.NewResetTokenRepository(db.Collection(names.PasswordResetTokens), timeouts)
+		verificationRepo = repositories.NewVerificationTokenRepository(db.Collection(names.EmailVerificationTokens), timeouts)
+		mfaRepo = repositories.NewMFARepository(db.Collection(names.MFAEnrollments), db.Collection(names.MFAChallenges), timeouts)
+		throttleRepo = repositories.NewLoginThrottleRepository(db.Collection(names.LoginThrottles), timeouts)
+	case config.BackendSQLite, config.BackendPostgres:
+		dialect, _ := repositories.SQLDialectByName(cfg.Database.Backend)
+		db := connectSQL(dialect, cfg.Database.URL, cfg.Timeouts)
+		lifecycle.OnStop(dialect.Name+" database", func(context.Context) error { return db.Close() })
+		health.Register(dialect.Name, db.PingContext)
+		health.Register("migrations", migrationCheck(func(ctx context.Context) ([]string, error) {
+			return repositories.PendingSQLMigrations(ctx, db, dialect)
+		}))
+		taskRepo = repositories.NewSQLTaskRepository(db, dialect, timeouts)
+		userRepo = repositories.NewSQLUserRepository(db, dialect, passwordService, timeouts)
+		tokenRepo = repositories.NewSQLTokenRepository(db, dialect, timeouts)
+		resetRepo = repositories.NewSQLResetTokenRepository(db, dialect, timeouts)
+		verificationRepo = repositories.NewSQLVerificationTokenRepository(db, dialect, timeouts)
+		mfaRepo = repositories.NewSQLMFARepository(db, dialect, timeouts)
+		throttleRepo = repositories.NewSQLLoginThrottleRepository(db, dialect, timeouts)
+	}
 
-	// Initialize repositories
-	taskRepo := repositories.NewTaskRepository(taskCollection)
-	userRepo := repositories.NewUserRepository(userCollection, jwtService, passwordService)
+	// Expired tokens are deleted in the background until shutdown
+	lifecycle.Every("expired token cleanup", cfg.Database.TokenCleanupInterval, func(ctx context.Context) error {
+		return tokenRepo.DeleteExpiredTokens(ctx, time.Now())
+	})
 
 	// Initialize usecases
-	taskUsecase := usecases.NewTaskUsecase(taskRepo)
-	userUsecase := usecases.NewUserUsecase(userRepo)
+	taskUsecase := usecases.NewTaskUsecase(taskRepo, userRepo)
+	mailer := loadMailer(cfg.Mail)
+	auditLog := loadAuditLog(cfg.Audit)
+	userUsecase := usecases.NewUserUsecase(userRepo, taskRepo, tokenRepo, mfaRepo, throttleRepo, jwtService, passwordService, totpService, auditLog)
+	userUsecase.SetLoginPolicy(cfg.Login)
+	userUsecase.SetLockoutPolicy(cfg.Lockout)
+	userUsecase.SetPasswordPolicy(cfg.PasswordPolicy)
+	if cfg.Auth.SetupToken != "" {
+		log.Println("auth.setup_token is set, POST /setup creates an admin until one exists")
+	}
+	userUsecase.SetAdminSetupToken(cfg.Auth.SetupToken)
+	resetUsecase := usecases.NewPasswordResetUsecase(userRepo, resetRepo, tokenRepo, passwordService, mailer)
+	resetUsecase.SetPasswordPolicy(cfg.PasswordPolicy)
+	verificationUsecase := usecases.NewEmailVerificationUsecase(userRepo, verificationRepo, mailer)
+	mfaUsecase := usecases.NewMFAUsecase(userRepo, mfaRepo, totpService, passwordService)
 
 	// Initialize controllers
-	controller := controllers.NewController(taskUsecase, userUsecase)
+	controller := controllers.NewController(taskUsecase, userUsecase, resetUsecase, verificationUsecase, mfaUsecase)
 
 	// Initialize middleware
-	authMiddleware := infrastructure.NewAuthMiddleware(jwtService)
+	authMiddleware := infrastructure.NewAuthMiddleware(jwtService, tokenRepo, userRepo)
 
 	// Setup router
-	r := routers.SetupRouter(controller, authMiddleware)
+	r := routers.SetupRouter(controller, authMiddleware, jwtService, health)
+	if len(cfg.Server.TrustedProxies) > 0 {
+		if err := r.SetTrustedProxies(cfg.Server.TrustedProxies); err != nil {
+			log.Fatalf("Invalid trusted proxies %q: %v", cfg.Server.TrustedProxies, err)
+		}
+	}
 
 	// Start server
-	log.Println("Server starting on :8080")
-	err = r.Run(":8080")
+	server := &http.Server{
+		Handler:           r,
+		ReadHeaderTimeout: cfg.Server.ReadTimeout,
+		ReadTimeout:       cfg.Server.ReadTimeout,
+		WriteTimeout:      cfg.Server.WriteTimeout,
+		IdleTimeout:       cfg.Server.IdleTimeout,
+	}
+	listener, err := net.Listen("tcp", cfg.Server.Addr)
 	if err != nil {
 		log.Fatalf("Failed to start server: %v", err)
 	}
+	log.Printf("Server starting on %s", listener.Addr())
+
+	// SIGINT or SIGTERM starts a graceful shutdown, and a second one ends
+	// the process at once
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	if err := lifecycle.Run(ctx, server, listener, cfg.Server.ShutdownTimeout); err != nil {
+		log.Fatalf("Server stopped with errors: %v", err)
+	}
+	log.Println("Server stopped")
+}
+
+// loadSigningKeys reads the configured JWT keys. Without any, a random key
+// is generated so local runs work, but tokens stop working on restart and
+// aren't accepted by other instances.
+func loadSigningKeys(sources infrastructure.KeySources) *infrastructure.KeySet {
+	keys, err := infrastructure.LoadKeySet(sources)
+	if errors.Is(err, infrastructure.ErrNoSigningKey) {
+		log.Println("No JWT private key or secret configured, signing tokens with a temporary key")
+		var key infrastructure.SigningKey
+		if key, err = infrastructure.GenerateEd25519Key(); err == nil {
+			keys, err = infrastructure.NewKeySet(key)
+		}
+	}
+	if err != nil {
+		log.Fatalf("Failed to load JWT signing keys: %v", err)
+	}
+
+	return keys
+}
+
+// loadMailer sends mail through the SMTP host, or writes it to files when
+// none is set, which is only meant for local runs
+func loadMailer(settings infrastructure.MailSettings) domain.Mailer {
+	mailer, err := infrastructure.NewMailer(settings)
+	if err != nil {
+		log.Fatalf("Failed to configure mail: %v", err)
+	}
+	if settings.SMTPHost == "" {
+		log.Printf("No mail.smtp_host set, writing mail to files in %s instead of sending it", settings.Dir)
+	}
+
+	return mailer
+}
+
+// loadAuditLog appends audit entries to the configured file, or writes
+// them to standard error
+func loadAuditLog(audit config.Audit) domain.AuditLog {
+	auditLog, err := infrastructure.OpenAuditLog(audit.LogFile)
+	if err != nil {
+		log.Fatalf("Failed to open audit log: %v", err)
+	}
+
+	return auditLog
+}
+
+// connectMongo connects to the configured MongoDB database and applies
+// pending migrations
+func connectMongo(database config.Database, timeouts config.Timeouts) *mongo.Database {
+	ctx, cancel := context.WithTimeout(context.Background(), timeouts.Connect)
+	defer cancel()
+
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(database.MongoURI))
+	if err != nil {
+		log.Fatalf("Failed to connect to MongoDB: %v", err)
+	}
+
+	db := client.Database(database.Name)
+
+	// Apply pending data migrations before serving requests
+	migrationCtx, cancelMigrations := context.WithTimeout(context.Background(), timeouts.Migrations)
+	defer cancelMigrations()
+
+	if err := repositories.RunMigrations(migrationCtx, db, database.Collections); err != nil {
+		log.Fatalf("Failed to apply migrations: %v", err)
+	}
+
+	return db
+}
+
+// connectSQL opens the database at dsn with the given dialect and applies
+// pending schema migrations
+func connectSQL(dialect repositories.SQLDialect, dsn string, timeouts config.Timeouts) *sql.DB {
+	db, err := repositories.OpenSQL(dialect, dsn)
+	if err != nil {
+		log.Fatalf("Failed to connect to %s: %v", dialect.Name, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeouts.Migrations)
+	defer cancel()
+
+	if err := repositories.RunSQLMigrations(ctx, db, dialect); err != nil {
+		log.Fatalf("Failed to apply migrations: %v", err)
+	}
+
+	return db
+}
+
+// migrationCheck is a readiness check failing while any migration returned
+// by pending has not been applied
+func migrationCheck(pending func(ctx context.Context) ([]string, error)) infrastructure.HealthCheck {
+	return func(ctx context.Context) error {
+		ids, err := pending(ctx)
+		if err != nil {
+			return err
+		}
+		if len(ids) > 0 {
+			return fmt.Errorf("pending migrations: %s", strings.Join(ids, ", "))
+		}
+		return nil
+	}
 }
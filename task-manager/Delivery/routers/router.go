@@ -2,38 +2,83 @@ package routers
 
 import (
 	"task-manager/Delivery/controllers"
+	"task-manager/Domain"
 	"task-manager/Infrastructure"
 
 	"github.com/gin-gonic/gin"
 )
 
-func SetupRouter(controller *controllers.Controller, authMiddleware *infrastructure.AuthMiddleware) *gin.Engine {
+func SetupRouter(controller *controllers.Controller, authMiddleware *infrastructure.AuthMiddleware, jwtService *infrastructure.JWTService, health *infrastructure.HealthRegistry) *gin.Engine {
 	r := gin.Default()
+	// Client IPs count towards login lockouts, so X-Forwarded-For is
+	// ignored unless the trusted proxies are configured
+	r.SetTrustedProxies(nil)
+
+	// Probes for the orchestrator: liveness and readiness
+	r.GET("/healthz", health.Healthz)
+	r.GET("/readyz", health.Readyz)
+
+	// Public keys for services verifying our tokens
+	r.GET("/.well-known/jwks.json", jwtService.JWKS)
 
 	// Public routes
+	r.POST("/setup", controller.SetupAdmin)
 	r.POST("/register", controller.Register)
 	r.POST("/login", controller.Login)
+	r.POST("/login/mfa", controller.LoginMFA)
+	r.POST("/auth/refresh", controller.Refresh)
+	r.POST("/logout", authMiddleware.AuthMiddleware(), controller.Logout)
+	r.POST("/password-reset", controller.RequestPasswordReset)
+	r.POST("/password-reset/confirm", controller.ResetPassword)
+	r.POST("/email/verification", controller.RequestVerification)
+	r.POST("/email/verify", controller.VerifyEmail)
 
 	// Protected task routes
+	read := authMiddleware.RequirePermission(domain.PermTaskReadOwn, domain.PermTaskReadAny)
+	update := authMiddleware.RequirePermission(domain.PermTaskUpdateOwn, domain.PermTaskUpdateAny)
+	transition := authMiddleware.RequirePermission(domain.PermTaskTransitionOwn, domain.PermTaskTransitionAny)
+	remove := authMiddleware.RequirePermission(domain.PermTaskDeleteOwn, domain.PermTaskDeleteAny)
+
 	tasks := r.Group("/tasks")
 	tasks.Use(authMiddleware.AuthMiddleware())
 	{
-		tasks.GET("", controller.GetTasks)
-		tasks.GET(":id", controller.GetTaskByID)
-		tasks.POST("", authMiddleware.AdminOnly(), controller.CreateTask)
-		tasks.PUT(":id", authMiddleware.AdminOnly(), controller.UpdateTask)
-		tasks.DELETE(":id", authMiddleware.AdminOnly(), controller.DeleteTask)
+		tasks.GET("", read, controller.GetTasks)
+		tasks.GET(":id", read, controller.GetTaskByID)
+		tasks.POST("", authMiddleware.RequirePermission(domain.PermTaskCreate), controller.CreateTask)
+		tasks.PUT(":id", update, controller.UpdateTask)
+		tasks.PATCH(":id", update, controller.PatchTask)
+		tasks.POST(":id/transition", transition, controller.TransitionTask)
+		tasks.DELETE(":id", remove, controller.DeleteTask)
 	}
 
 	// Protected user routes
-	users := r.Group("/users")
-	users.Use(authMiddleware.AuthMiddleware())
+	// The caller's own account, open to every role
+	me := r.Group("/me")
+	me.Use(authMiddleware.AuthMiddleware())
 	{
-		users.GET(":username", controller.GetUserByUsername)
+		me.GET("", controller.GetMe)
+		me.PATCH("", controller.PatchMe)
+		me.POST("password", controller.ChangePassword)
+		me.GET("mfa", controller.GetMFAStatus)
+		me.POST("mfa", controller.EnrollMFA)
+		me.POST("mfa/confirm", controller.ConfirmMFA)
+		me.DELETE("mfa", controller.DisableMFA)
 	}
-	users.Use(authMiddleware.AuthMiddleware(), authMiddleware.AdminOnly())
+
+	manage := authMiddleware.RequirePermission(domain.PermUserManage)
+
+	users := r.Group("/users")
+	users.Use(authMiddleware.AuthMiddleware())
 	{
-		users.POST(":id/promote", controller.Promote)
+		users.GET("", manage, controller.ListUsers)
+		users.GET(":username", authMiddleware.RequirePermission(domain.PermUserRead), controller.GetUserByUsername)
+		users.POST(":id/promote", authMiddleware.RequirePermission(domain.PermUserPromote), controller.Promote)
+		users.PUT(":id/role", manage, controller.SetUserRole)
+		users.POST(":id/disable", manage, controller.DisableUser)
+		users.POST(":id/enable", manage, controller.EnableUser)
+		users.DELETE(":id/mfa", manage, controller.ResetUserMFA)
+		users.POST(":id/unlock", manage, controller.UnlockUser)
+		users.DELETE(":id", manage, controller.DeleteUser)
 	}
 
 	return r
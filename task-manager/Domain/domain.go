@@ -1,65 +1,467 @@
 package domain
 
 import (
-	"go.mongodb.org/mongo-driver/bson/primitive"
+	"context"
+	"time"
 )
 
 // Task represents a task entity
 type Task struct {
-	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
-	Title       string             `bson:"title" json:"title"`
-	Description string             `bson:"description" json:"description"`
-	DueDate     string             `bson:"due_date" json:"due_date"`
-	Status      string             `bson:"status" json:"status"`
+	ID          ID         `json:"id"`
+	Title       string     `json:"title"`
+	Description string     `json:"description"`
+	DueDate     *time.Time `json:"due_date,omitempty"`
+	Status      TaskStatus `json:"status"`
+	CreatedBy   ID         `json:"created_by"`
+	AssigneeID  ID         `json:"assignee_id"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	Version     int64      `json:"version"`
 }
 
 // User represents a user entity
 type User struct {
-	ID       primitive.ObjectID `bson:"_id,omitempty" json:"id"`
-	Username string             `bson:"username" json:"username"`
-	Password string             `bson:"password" json:"password"`
-	Role     string             `bson:"role" json:"role"`
+	ID       ID     `json:"id"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Role     string `json:"role"`
+	// Disabled users cannot log in and their tokens stop working
+	Disabled bool `json:"disabled"`
+	// Email is optional and unique regardless of case. It is only trusted
+	// once EmailVerified is set, which changing it clears.
+	Email         string `json:"email,omitempty"`
+	EmailVerified bool   `json:"email_verified"`
 }
 
-// LoginResponse represents the response after successful login
+// PublicUser is what the API shows of a user. It has no password field, so
+// responses built from it can never leak a hash.
+type PublicUser struct {
+	ID            ID     `json:"id"`
+	Username      string `json:"username"`
+	Role          string `json:"role"`
+	Disabled      bool   `json:"disabled"`
+	Email         string `json:"email,omitempty"`
+	EmailVerified bool   `json:"email_verified"`
+}
+
+// Public returns the user's public projection
+func (u User) Public() PublicUser {
+	return PublicUser{
+		ID:            u.ID,
+		Username:      u.Username,
+		Role:          u.Role,
+		Disabled:      u.Disabled,
+		Email:         u.Email,
+		EmailVerified: u.EmailVerified,
+	}
+}
+
+// ProfilePatch is a change users make to their own profile. Nil fields are
+// left unchanged. An empty Email removes the address.
+type ProfilePatch struct {
+	Username *string
+	Email    *string
+}
+
+// IsEmpty reports whether the patch would not change anything
+func (p ProfilePatch) IsEmpty() bool {
+	return p.Username == nil && p.Email == nil
+}
+
+// UserQuery describes filtering and pagination for user listings
+type UserQuery struct {
+	Role     string // exact role match
+	Disabled *bool  // when set, only enabled or only disabled users
+	Limit    int
+	Offset   int
+}
+
+// UserPage is a single page of a user listing
+type UserPage struct {
+	Users  []PublicUser `json:"users"`
+	Total  int64        `json:"total"`
+	Limit  int          `json:"limit"`
+	Offset int          `json:"offset"`
+	Next   string       `json:"next,omitempty"`
+}
+
+// TaskPolicy says what happens to the tasks of a deleted user
+type TaskPolicy string
+
+const (
+	// TaskPolicyReassign hands every task the user created or is assigned
+	// to over to another user
+	TaskPolicyReassign TaskPolicy = "reassign"
+	// TaskPolicyDelete deletes the tasks the user created and hands tasks
+	// assigned to them back to their creators
+	TaskPolicyDelete TaskPolicy = "delete"
+)
+
+// DeleteUserOptions controls how a user's tasks are handled on deletion.
+// ReassignTo defaults to the caller.
+type DeleteUserOptions struct {
+	Tasks      TaskPolicy
+	ReassignTo ID
+}
+
+// LoginResponse represents the response after successful login. Users with
+// two-factor authentication get MFARequired and an MFAToken instead of the
+// tokens, and finish logging in by sending it with a code.
 type LoginResponse struct {
-	ID       primitive.ObjectID `json:"id"`
-	Username string             `json:"username"`
-	Token    string             `json:"token"`
+	ID           ID     `json:"id"`
+	Username     string `json:"username"`
+	Token        string `json:"token,omitempty"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	MFARequired  bool   `json:"mfa_required,omitempty"`
+	MFAToken     string `json:"mfa_token,omitempty"`
+}
+
+// RefreshToken is a long-lived, single-use credential exchanged for a new
+// access token. Only a hash of the token is stored. Tokens rotated from the
+// same login share a FamilyID, so reuse of a rotated token can revoke them all.
+type RefreshToken struct {
+	TokenHash string
+	UserID    ID
+	FamilyID  ID
+	ExpiresAt time.Time
+	Revoked   bool
+}
+
+// PasswordResetToken lets whoever holds it set a new password for the user
+// until it expires. Only a hash of the token is stored and it is deleted
+// when used, so each token works once.
+type PasswordResetToken struct {
+	TokenHash string
+	UserID    ID
+	ExpiresAt time.Time
+}
+
+// EmailVerificationToken proves the user received mail at Email. It only
+// verifies that address, so changing the email makes it useless. Only a
+// hash of the token is stored.
+type EmailVerificationToken struct {
+	TokenHash string
+	UserID    ID
+	Email     string
+	ExpiresAt time.Time
+}
+
+// MFAEnrollment is a user's TOTP second factor. It only applies to logins
+// once Confirmed, which happens when the user proves their authenticator has
+// the secret.
+type MFAEnrollment struct {
+	UserID    ID
+	Secret    string // base32 TOTP secret
+	Confirmed bool
+	// RecoveryCodes are hashes of the unused recovery codes, each of which
+	// can stand in for a TOTP code once
+	RecoveryCodes []string
+	// LastStep is the time step of the last TOTP code accepted, so a code
+	// can't be used twice
+	LastStep int64
+}
+
+// MFAChallenge is a login waiting for its second factor. Only a hash of the
+// token is stored. Attempts counts the wrong codes sent with it. Username and
+// ClientIP are what the login was made with, so wrong codes count towards
+// the same lockouts as wrong passwords.
+type MFAChallenge struct {
+	TokenHash string
+	UserID    ID
+	Username  string
+	ClientIP  string
+	ExpiresAt time.Time
+	Attempts  int
+}
+
+// MFASetup is what an authenticator app needs to add the account
+type MFASetup struct {
+	Secret string `json:"secret"`
+	URI    string `json:"otpauth_uri"`
+}
+
+// MFAStatus tells users whether two-factor authentication is on
+type MFAStatus struct {
+	Enabled                bool `json:"enabled"`
+	RecoveryCodesRemaining int  `json:"recovery_codes_remaining"`
+}
+
+// LoginThrottle counts the failed logins of a username or client IP. The
+// count is forgotten once ExpiresAt passes without another failure.
+type LoginThrottle struct {
+	Key         string
+	Failures    int
+	LastFailure time.Time
+	ExpiresAt   time.Time
+}
+
+// Audit events
+const (
+	AuditLoginLocked   = "login.locked"
+	AuditLoginUnlocked = "login.unlocked"
+)
+
+// AuditEntry records a security relevant event
+type AuditEntry struct {
+	Time  time.Time `json:"time"`
+	Event string    `json:"event"`
+	// ActorID is the user who caused the event, when someone signed in did
+	ActorID  string `json:"actor_id,omitempty"`
+	Username string `json:"username,omitempty"`
+	ClientIP string `json:"client_ip,omitempty"`
+	Detail   string `json:"detail,omitempty"`
 }
 
-// TaskRepository interface defines task data access operations
+// Message is a plain text email
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// TaskPatch is a partial task update. Nil fields are left unchanged.
+type TaskPatch struct {
+	Title        *string
+	Description  *string
+	DueDate      *time.Time
+	ClearDueDate bool
+	Status       *TaskStatus
+	AssigneeID   *ID
+}
+
+// IsEmpty reports whether the patch would not change anything
+func (p TaskPatch) IsEmpty() bool {
+	return p.Title == nil && p.Description == nil && p.DueDate == nil && !p.ClearDueDate &&
+		p.Status == nil && p.AssigneeID == nil
+}
+
+// TaskQuery describes filtering, sorting and pagination for task listings
+type TaskQuery struct {
+	Status    TaskStatus // exact status match
+	DueFrom   time.Time  // inclusive lower bound on due_date, ignored when zero
+	DueTo     time.Time  // inclusive upper bound on due_date, ignored when zero
+	Title     string     // case-insensitive substring of the title
+	SortBy    string     // one of TaskSortFields
+	SortDesc  bool
+	Limit     int
+	Offset    int
+	VisibleTo string // when set, only tasks created by or assigned to this user ID
+}
+
+// TaskSortFields lists the fields task listings can be sorted by
+var TaskSortFields = []string{"_id", "title", "due_date", "status", "created_at", "updated_at"}
+
+// TaskPage is a single page of a task listing
+type TaskPage struct {
+	Tasks  []Task `json:"tasks"`
+	Total  int64  `json:"total"`
+	Limit  int    `json:"limit"`
+	Offset int    `json:"offset"`
+	Next   string `json:"next,omitempty"`
+}
+
+// Caller identifies the authenticated user on whose behalf an operation runs
+type Caller struct {
+	UserID   string
+	Username string
+	Role     string
+	// TokenID and TokenExpiresAt identify the access token the caller used
+	TokenID        string
+	TokenExpiresAt time.Time
+}
+
+// TaskRepository interface defines task data access operations.
+// Write methods take the version the caller expects the task to be at;
+// zero skips the check. A mismatch returns ErrVersionConflict.
 type TaskRepository interface {
-	GetAllTasks() ([]Task, error)
-	GetTaskByID(id string) (Task, error)
-	CreateTask(task Task) (Task, error)
-	UpdateTask(id string, task Task) (Task, error)
-	DeleteTask(id string) error
+	QueryTasks(ctx context.Context, query TaskQuery) ([]Task, int64, error)
+	GetTaskByID(ctx context.Context, id string) (Task, error)
+	CreateTask(ctx context.Context, task Task) (Task, error)
+	UpdateTask(ctx context.Context, id string, task Task, version int64) (Task, error)
+	PatchTask(ctx context.Context, id string, patch TaskPatch, version int64) (Task, error)
+	TransitionTask(ctx context.Context, id string, from, to TaskStatus) (Task, error)
+	DeleteTask(ctx context.Context, id string, version int64) error
+	// ReassignTasks moves tasks created by or assigned to from over to to
+	ReassignTasks(ctx context.Context, from, to ID) error
+	// DeleteTasksCreatedBy deletes every task the user created
+	DeleteTasksCreatedBy(ctx context.Context, userID ID) error
+	// UnassignTasks assigns tasks assigned to the user back to their creators
+	UnassignTasks(ctx context.Context, userID ID) error
 }
 
-// UserRepository interface defines user data access operations
+// UserRepository interface defines user data access operations.
+// ListUsers returns users without passwords.
 type UserRepository interface {
-	RegisterUser(user User) (User, error)
-	LoginUser(user User) (LoginResponse, error)
-	PromoteUser(id string) (User, error)
-	GetUserByUsername(username string) (User, error)
+	// RegisterUser stores a new user with its role, member when empty.
+	// Taken usernames return a Conflict error and taken email addresses
+	// ErrEmailTaken, even for registrations racing each other.
+	RegisterUser(ctx context.Context, user User) (User, error)
+	// RegisterFirstAdmin stores a new user as an admin unless an admin
+	// exists, returning ErrSetupComplete then. Of calls racing each other
+	// at most one succeeds.
+	RegisterFirstAdmin(ctx context.Context, user User) (User, error)
+	PromoteUser(ctx context.Context, id string) (User, error)
+	GetUserByUsername(ctx context.Context, username string) (User, error)
+	GetUserByID(ctx context.Context, id string) (User, error)
+	ListUsers(ctx context.Context, query UserQuery) ([]User, int64, error)
+	// SetUserRole, SetUserDisabled and DeleteUser return ErrLastAdmin
+	// rather than demote, disable or delete the only enabled admin, even
+	// when changes to several admins race each other
+	SetUserRole(ctx context.Context, id string, role string) (User, error)
+	SetUserDisabled(ctx context.Context, id string, disabled bool) (User, error)
+	DeleteUser(ctx context.Context, id string) error
+	// UpdateProfile applies the patch, returning a Conflict error when the
+	// new username is taken
+	UpdateProfile(ctx context.Context, id string, patch ProfilePatch) (User, error)
+	// SetPassword replaces the stored password hash
+	SetPassword(ctx context.Context, id string, hash string) error
+	// MarkEmailVerified verifies the user's email if it is still email. It
+	// returns ErrUserNotFound when no user has both the ID and the address.
+	MarkEmailVerified(ctx context.Context, id string, email string) (User, error)
+}
+
+// TokenRepository stores refresh tokens and the access tokens revoked
+// before they expire
+type TokenRepository interface {
+	SaveRefreshToken(ctx context.Context, token RefreshToken) error
+	GetRefreshToken(ctx context.Context, tokenHash string) (RefreshToken, error)
+	// RevokeRefreshToken revokes an active token. It returns
+	// ErrRefreshTokenNotFound when no active token has the hash, so only one
+	// of two concurrent rotations of the same token succeeds.
+	RevokeRefreshToken(ctx context.Context, tokenHash string) error
+	RevokeTokenFamily(ctx context.Context, familyID ID) error
+	// RevokeUserTokens revokes every refresh token issued to the user
+	RevokeUserTokens(ctx context.Context, userID ID) error
+	RevokeAccessToken(ctx context.Context, tokenID string, expiresAt time.Time) error
+	IsAccessTokenRevoked(ctx context.Context, tokenID string) (bool, error)
+	// DeleteExpiredTokens deletes the refresh tokens and revoked access
+	// tokens that expired before the given time
+	DeleteExpiredTokens(ctx context.Context, before time.Time) error
+}
+
+// ResetTokenRepository stores password reset tokens
+type ResetTokenRepository interface {
+	SaveResetToken(ctx context.Context, token PasswordResetToken) error
+	// ConsumeResetToken deletes the token and returns it. It returns
+	// ErrResetTokenNotFound when no token has the hash, so only one of two
+	// concurrent uses of the same token succeeds.
+	ConsumeResetToken(ctx context.Context, tokenHash string) (PasswordResetToken, error)
+	// DeleteResetTokens deletes every reset token issued to the user
+	DeleteResetTokens(ctx context.Context, userID ID) error
+}
+
+// VerificationTokenRepository stores email verification tokens
+type VerificationTokenRepository interface {
+	SaveVerificationToken(ctx context.Context, token EmailVerificationToken) error
+	// ConsumeVerificationToken deletes the token and returns it. It returns
+	// ErrVerificationTokenNotFound when no token has the hash.
+	ConsumeVerificationToken(ctx context.Context, tokenHash string) (EmailVerificationToken, error)
+	// DeleteVerificationTokens deletes every verification token issued to the user
+	DeleteVerificationTokens(ctx context.Context, userID ID) error
+}
+
+// MFARepository stores TOTP enrollments and the challenges of logins
+// waiting for a code
+type MFARepository interface {
+	// SaveEnrollment creates or replaces the user's enrollment
+	SaveEnrollment(ctx context.Context, enrollment MFAEnrollment) error
+	// GetEnrollment returns ErrMFAEnrollmentNotFound when the user has none
+	GetEnrollment(ctx context.Context, userID ID) (MFAEnrollment, error)
+	DeleteEnrollment(ctx context.Context, userID ID) error
+	// UseTOTPStep records that a code for step was accepted. It returns
+	// ErrMFACodeReused unless the enrollment's LastStep is earlier, so only
+	// one of two concurrent uses of a code succeeds.
+	UseTOTPStep(ctx context.Context, userID ID, step int64) error
+	// UseRecoveryCode removes the code hash from the enrollment. It returns
+	// ErrRecoveryCodeNotFound when the enrollment doesn't have it.
+	UseRecoveryCode(ctx context.Context, userID ID, codeHash string) error
+	SaveMFAChallenge(ctx context.Context, challenge MFAChallenge) error
+	// ConsumeMFAChallenge deletes the challenge and returns it. It returns
+	// ErrMFAChallengeNotFound when no challenge has the hash.
+	ConsumeMFAChallenge(ctx context.Context, tokenHash string) (MFAChallenge, error)
+}
+
+// LoginThrottleRepository counts failed logins per key, such as a username
+// or client IP
+type LoginThrottleRepository interface {
+	// GetLoginThrottle returns ErrLoginThrottleNotFound when the key has no
+	// failures on record. The throttle returned may have expired.
+	GetLoginThrottle(ctx context.Context, key string) (LoginThrottle, error)
+	// RecordLoginFailure adds a failure at the given time and returns the
+	// new count. Expired counts start over, and every failure keeps the
+	// count for another window.
+	RecordLoginFailure(ctx context.Context, key string, at time.Time, window time.Duration) (LoginThrottle, error)
+	ClearLoginThrottle(ctx context.Context, key string) error
 }
 
 // TaskUsecase interface defines task business logic operations
 type TaskUsecase interface {
-	GetAllTasks() ([]Task, error)
-	GetTaskByID(id string) (Task, error)
-	CreateTask(task Task) (Task, error)
-	UpdateTask(id string, task Task) (Task, error)
-	DeleteTask(id string) error
+	GetAllTasks(ctx context.Context, caller Caller, query TaskQuery) (TaskPage, error)
+	GetTaskByID(ctx context.Context, caller Caller, id string) (Task, error)
+	CreateTask(ctx context.Context, caller Caller, task Task) (Task, error)
+	UpdateTask(ctx context.Context, caller Caller, id string, task Task, version int64) (Task, error)
+	PatchTask(ctx context.Context, caller Caller, id string, patch TaskPatch, version int64) (Task, error)
+	TransitionTask(ctx context.Context, caller Caller, id string, status TaskStatus) (Task, error)
+	DeleteTask(ctx context.Context, caller Caller, id string, version int64) error
 }
 
 // UserUsecase interface defines user business logic operations
 type UserUsecase interface {
-	RegisterUser(user User) (User, error)
-	LoginUser(user User) (LoginResponse, error)
-	PromoteUser(id string) (User, error)
-	GetUserByUsername(username string) (User, error)
+	RegisterUser(ctx context.Context, user User) (User, error)
+	// SetupAdmin creates the first admin given the setup token
+	SetupAdmin(ctx context.Context, token string, user User) (User, error)
+	// LoginUser checks the credentials sent from clientIP, which may be
+	// empty when it isn't known
+	LoginUser(ctx context.Context, user User, clientIP string) (LoginResponse, error)
+	// CompleteMFALogin finishes a login that returned MFARequired, given a
+	// TOTP or recovery code
+	CompleteMFALogin(ctx context.Context, mfaToken string, code string) (LoginResponse, error)
+	RefreshToken(ctx context.Context, refreshToken string) (LoginResponse, error)
+	Logout(ctx context.Context, caller Caller, refreshToken string) error
+	PromoteUser(ctx context.Context, caller Caller, id string) (User, error)
+	GetUserByUsername(ctx context.Context, caller Caller, username string) (User, error)
+	ListUsers(ctx context.Context, caller Caller, query UserQuery) (UserPage, error)
+	SetUserRole(ctx context.Context, caller Caller, id string, role string) (User, error)
+	SetUserDisabled(ctx context.Context, caller Caller, id string, disabled bool) (User, error)
+	// ResetUserMFA turns off another user's two-factor authentication
+	ResetUserMFA(ctx context.Context, caller Caller, id string) error
+	// UnlockUser lifts the lockout of a username that failed to log in
+	// too often
+	UnlockUser(ctx context.Context, caller Caller, id string) error
+	DeleteUser(ctx context.Context, caller Caller, id string, options DeleteUserOptions) error
+	GetProfile(ctx context.Context, caller Caller) (User, error)
+	UpdateProfile(ctx context.Context, caller Caller, patch ProfilePatch) (User, error)
+	ChangePassword(ctx context.Context, caller Caller, current, next string) error
+}
+
+// PasswordResetUsecase interface defines the forgotten password flow
+type PasswordResetUsecase interface {
+	// RequestPasswordReset mails a reset token to the user. It succeeds
+	// whether or not the user exists, so it can't be used to find usernames.
+	RequestPasswordReset(ctx context.Context, username string) error
+	ResetPassword(ctx context.Context, token string, newPassword string) error
+}
+
+// EmailVerificationUsecase interface defines the email verification flow
+type EmailVerificationUsecase interface {
+	// SendVerification mails a verification token to the user's email
+	// address. Users without an address or with a verified one get nothing.
+	SendVerification(ctx context.Context, userID string) error
+	// RequestVerification is SendVerification for signed-out users. Like
+	// RequestPasswordReset, it succeeds whether or not the user exists.
+	RequestVerification(ctx context.Context, username string) error
+	VerifyEmail(ctx context.Context, token string) error
+}
+
+// MFAUsecase interface defines how users manage their second factor
+type MFAUsecase interface {
+	GetMFAStatus(ctx context.Context, caller Caller) (MFAStatus, error)
+	// EnrollMFA starts setting up TOTP. It only applies to logins once
+	// ConfirmMFA checks a code from the authenticator and returns the
+	// recovery codes, which are shown this one time.
+	EnrollMFA(ctx context.Context, caller Caller, password string) (MFASetup, error)
+	ConfirmMFA(ctx context.Context, caller Caller, code string) ([]string, error)
+	DisableMFA(ctx context.Context, caller Caller, password string, code string) error
 }
 
 // JWTService interface defines JWT operations
@@ -72,4 +474,28 @@ type JWTService interface {
 type PasswordService interface {
 	HashPassword(password string) (string, error)
 	ComparePassword(hashedPassword, password string) error
-}
\ No newline at end of file
+	// NeedsRehash reports whether a hash uses outdated parameters, so a
+	// password that matches it should be hashed again
+	NeedsRehash(hashedPassword string) bool
+}
+
+// TOTPService generates and checks RFC 6238 time-based one-time passwords
+type TOTPService interface {
+	GenerateSecret() (string, error)
+	// URI returns the otpauth:// URI authenticator apps import, usually
+	// from a QR code
+	URI(secret, accountName string) string
+	// Validate checks a code at the given time, allowing for some clock
+	// drift, and returns the time step the code belongs to
+	Validate(secret, code string, at time.Time) (int64, bool)
+}
+
+// Mailer sends email
+type Mailer interface {
+	Send(message Message) error
+}
+
+// AuditLog keeps a record of security relevant events
+type AuditLog interface {
+	Record(entry AuditEntry) error
+}
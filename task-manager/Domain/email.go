@@ -0,0 +1,26 @@
+package domain
+
+import (
+	"net/mail"
+	"strings"
+)
+
+// maxEmailLength is the longest address SMTP can deliver to
+const maxEmailLength = 254
+
+// ValidateEmail returns a Validation error unless email is a bare address
+// such as jane@example.com, without a display name or angle brackets
+func ValidateEmail(email string) error {
+	if len(email) > maxEmailLength {
+		return Validation("email must be at most %d characters", maxEmailLength)
+	}
+
+	address, err := mail.ParseAddress(email)
+	if err != nil || address.Name != "" || address.Address != email {
+		return Validation("invalid email address %q", email)
+	}
+	if host := email[strings.LastIndex(email, "@")+1:]; !strings.Contains(host, ".") {
+		return Validation("invalid email address %q", email)
+	}
+	return nil
+}
@@ -0,0 +1,169 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Error kinds. Repositories and usecases return errors that wrap one of these
+// so callers can branch with errors.Is instead of comparing messages.
+var (
+	ErrNotFound           = errors.New("not found")
+	ErrInvalidID          = errors.New("invalid id")
+	ErrConflict           = errors.New("conflict")
+	ErrValidation         = errors.New("validation failed")
+	ErrUnauthorized       = errors.New("unauthorized")
+	ErrForbidden          = errors.New("forbidden")
+	ErrPreconditionFailed = errors.New("precondition failed")
+	ErrUnprocessable      = errors.New("unprocessable")
+	ErrTooManyRequests    = errors.New("too many requests")
+)
+
+// Error is a domain error of a given kind with a message safe to show clients
+type Error struct {
+	Kind    error
+	Message string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Kind
+}
+
+func newError(kind error, format string, args ...interface{}) *Error {
+	return &Error{Kind: kind, Message: fmt.Sprintf(format, args...)}
+}
+
+func NotFound(format string, args ...interface{}) error {
+	return newError(ErrNotFound, format, args...)
+}
+
+func InvalidID(format string, args ...interface{}) error {
+	return newError(ErrInvalidID, format, args...)
+}
+
+func Conflict(format string, args ...interface{}) error {
+	return newError(ErrConflict, format, args...)
+}
+
+func Validation(format string, args ...interface{}) error {
+	return newError(ErrValidation, format, args...)
+}
+
+func Unauthorized(format string, args ...interface{}) error {
+	return newError(ErrUnauthorized, format, args...)
+}
+
+func Forbidden(format string, args ...interface{}) error {
+	return newError(ErrForbidden, format, args...)
+}
+
+// Errors shared across layers
+var (
+	ErrTaskNotFound = &Error{Kind: ErrNotFound, Message: "task not found"}
+	ErrUserNotFound = &Error{Kind: ErrNotFound, Message: "user not found"}
+	// ErrInvalidTaskQuery is returned when task listing parameters are rejected
+	ErrInvalidTaskQuery = &Error{Kind: ErrValidation, Message: "invalid task query"}
+	// ErrEmptyPatch is returned when a partial update has no fields to change
+	ErrEmptyPatch = &Error{Kind: ErrValidation, Message: "patch does not change any fields"}
+	// ErrVersionConflict is returned when a task changed since the caller read it
+	ErrVersionConflict = &Error{Kind: ErrPreconditionFailed, Message: "task was modified by another request"}
+	// ErrRefreshTokenNotFound is returned when no active refresh token has the given hash
+	ErrRefreshTokenNotFound = &Error{Kind: ErrNotFound, Message: "refresh token not found"}
+	// ErrInvalidRefreshToken is returned when a refresh token is unknown, expired or revoked
+	ErrInvalidRefreshToken = &Error{Kind: ErrUnauthorized, Message: "invalid or expired refresh token"}
+	// ErrAccountDisabled is returned when a disabled user logs in or uses a token
+	ErrAccountDisabled = &Error{Kind: ErrForbidden, Message: "account is disabled"}
+	// ErrLastAdmin is returned when a change would leave no enabled admin
+	ErrLastAdmin = &Error{Kind: ErrConflict, Message: "cannot remove the last admin"}
+	// ErrWrongPassword is returned when the current password given to change it is wrong
+	ErrWrongPassword = &Error{Kind: ErrForbidden, Message: "current password is incorrect"}
+	// ErrResetTokenNotFound is returned when no password reset token has the given hash
+	ErrResetTokenNotFound = &Error{Kind: ErrNotFound, Message: "reset token not found"}
+	// ErrInvalidResetToken is returned when a password reset token is unknown, used or expired
+	ErrInvalidResetToken = &Error{Kind: ErrValidation, Message: "invalid or expired reset token"}
+	// ErrVerificationTokenNotFound is returned when no email verification token has the given hash
+	ErrVerificationTokenNotFound = &Error{Kind: ErrNotFound, Message: "verification token not found"}
+	// ErrInvalidVerificationToken is returned when an email verification token is unknown, used, expired or for an old address
+	ErrInvalidVerificationToken = &Error{Kind: ErrValidation, Message: "invalid or expired verification token"}
+	// ErrEmailNotVerified is returned on login when verified email is required and the user's isn't
+	ErrEmailNotVerified = &Error{Kind: ErrForbidden, Message: "email address is not verified"}
+	// ErrEmailTaken is returned when another user has the email address, in any case
+	ErrEmailTaken = &Error{Kind: ErrConflict, Message: "email already taken"}
+	// ErrMFAEnrollmentNotFound is returned when the user has not set up two-factor authentication
+	ErrMFAEnrollmentNotFound = &Error{Kind: ErrNotFound, Message: "two-factor authentication is not set up"}
+	// ErrMFAChallengeNotFound is returned when no MFA challenge has the given hash
+	ErrMFAChallengeNotFound = &Error{Kind: ErrNotFound, Message: "MFA challenge not found"}
+	// ErrMFACodeReused is returned when a TOTP code, or an earlier one, was already accepted
+	ErrMFACodeReused = &Error{Kind: ErrConflict, Message: "authentication code already used"}
+	// ErrRecoveryCodeNotFound is returned when the user has no unused recovery code with the given hash
+	ErrRecoveryCodeNotFound = &Error{Kind: ErrNotFound, Message: "recovery code not found"}
+	// ErrInvalidMFAChallenge is returned when an MFA token is unknown, used up or expired
+	ErrInvalidMFAChallenge = &Error{Kind: ErrUnauthorized, Message: "invalid or expired MFA token"}
+	// ErrInvalidMFACode is returned when the code sent to finish a login is wrong
+	ErrInvalidMFACode = &Error{Kind: ErrUnauthorized, Message: "invalid authentication code"}
+	// ErrWrongMFACode is returned when the code given to change two-factor settings is wrong
+	ErrWrongMFACode = &Error{Kind: ErrForbidden, Message: "authentication code is incorrect"}
+	// ErrMFAAlreadyEnabled is returned when enrolling while two-factor authentication is on
+	ErrMFAAlreadyEnabled = &Error{Kind: ErrConflict, Message: "two-factor authentication is already enabled"}
+	// ErrMFANotEnabled is returned when disabling two-factor authentication that is off
+	ErrMFANotEnabled = &Error{Kind: ErrConflict, Message: "two-factor authentication is not enabled"}
+	// ErrInvalidSetupToken is returned when admin setup is off or given the wrong token
+	ErrInvalidSetupToken = &Error{Kind: ErrForbidden, Message: "invalid setup token"}
+	// ErrSetupComplete is returned by admin setup once an admin exists
+	ErrSetupComplete = &Error{Kind: ErrConflict, Message: "setup is complete, an admin already exists"}
+	// ErrLoginThrottleNotFound is returned when a username or client IP has no failed logins on record
+	ErrLoginThrottleNotFound = &Error{Kind: ErrNotFound, Message: "login throttle not found"}
+)
+
+// LoginLockedError is returned for logins refused because the username or
+// client IP failed too often. Clients may retry once Until has passed.
+type LoginLockedError struct {
+	Until time.Time
+}
+
+func (e *LoginLockedError) Error() string {
+	return "too many failed login attempts, try again later"
+}
+
+func (e *LoginLockedError) Unwrap() error {
+	return ErrTooManyRequests
+}
+
+// Password policy rules, as reported in RuleViolation.Rule
+const (
+	PasswordRuleMinLength = "min_length"
+	PasswordRuleMaxLength = "max_length"
+	PasswordRuleClasses   = "character_classes"
+	PasswordRuleUsername  = "username"
+	PasswordRuleCommon    = "common_password"
+)
+
+// RuleViolation is a rule a value failed, with a message safe to show clients
+type RuleViolation struct {
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// PasswordPolicyError is returned for passwords the password policy
+// rejects, listing every rule they fail so users can fix them at once
+type PasswordPolicyError struct {
+	Violations []RuleViolation
+}
+
+func (e *PasswordPolicyError) Error() string {
+	messages := make([]string, len(e.Violations))
+	for i, violation := range e.Violations {
+		messages[i] = violation.Message
+	}
+	return "password does not meet the policy: " + strings.Join(messages, "; ")
+}
+
+func (e *PasswordPolicyError) Unwrap() error {
+	return ErrValidation
+}
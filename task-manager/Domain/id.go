@@ -0,0 +1,45 @@
+package domain
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"time"
+)
+
+// ID identifies a stored entity. IDs are 24 lowercase hex characters, a
+// 4-byte creation timestamp followed by 8 random bytes, so they sort roughly
+// by creation time and existing MongoDB ObjectIDs remain valid IDs.
+type ID string
+
+// NewID returns a new unique ID
+func NewID() ID {
+	var b [12]byte
+	binary.BigEndian.PutUint32(b[:4], uint32(time.Now().Unix()))
+	if _, err := rand.Read(b[4:]); err != nil {
+		panic("domain: cannot read random bytes: " + err.Error())
+	}
+	return ID(hex.EncodeToString(b[:]))
+}
+
+// Valid reports whether id is well-formed
+func (id ID) Valid() bool {
+	if len(id) != 24 {
+		return false
+	}
+	for _, c := range id {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// IsZero reports whether id is unset
+func (id ID) IsZero() bool {
+	return id == ""
+}
+
+func (id ID) String() string {
+	return string(id)
+}
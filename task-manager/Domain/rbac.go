@@ -0,0 +1,83 @@
+package domain
+
+// Roles a user can have, from least to most privileged
+const (
+	RoleViewer     = "viewer"
+	RoleMember     = "member"
+	RoleMaintainer = "maintainer"
+	RoleAdmin      = "admin"
+)
+
+// Roles lists every role, from least to most privileged
+var Roles = []string{RoleViewer, RoleMember, RoleMaintainer, RoleAdmin}
+
+// legacyRoleUser is what members were called before roles were split up.
+// Stored users are migrated, but access tokens issued earlier still carry it.
+const legacyRoleUser = "user"
+
+// Permission is an action a role may perform. The ":own" variants cover
+// tasks the caller created or is assigned to, ":any" covers every task.
+type Permission string
+
+const (
+	PermTaskReadOwn       Permission = "task:read:own"
+	PermTaskReadAny       Permission = "task:read:any"
+	PermTaskCreate        Permission = "task:create"
+	PermTaskUpdateOwn     Permission = "task:update:own"
+	PermTaskUpdateAny     Permission = "task:update:any"
+	PermTaskTransitionOwn Permission = "task:transition:own"
+	PermTaskTransitionAny Permission = "task:transition:any"
+	PermTaskDeleteOwn     Permission = "task:delete:own"
+	PermTaskDeleteAny     Permission = "task:delete:any"
+	PermUserRead          Permission = "user:read"
+	PermUserPromote       Permission = "user:promote"
+	PermUserManage        Permission = "user:manage"
+)
+
+// rolePermissions grants each role the permissions of the role below it
+// plus its own
+var rolePermissions = func() map[string]map[Permission]bool {
+	grants := map[string][]Permission{
+		RoleViewer:     {PermTaskReadOwn, PermUserRead},
+		RoleMember:     {PermTaskTransitionOwn},
+		RoleMaintainer: {PermTaskReadAny, PermTaskCreate, PermTaskUpdateOwn, PermTaskDeleteOwn, PermTaskTransitionAny},
+		RoleAdmin:      {PermTaskUpdateAny, PermTaskDeleteAny, PermUserPromote, PermUserManage},
+	}
+
+	permissions := make(map[string]map[Permission]bool)
+	inherited := map[Permission]bool{}
+	for _, role := range Roles {
+		own := make(map[Permission]bool)
+		for permission := range inherited {
+			own[permission] = true
+		}
+		for _, permission := range grants[role] {
+			own[permission] = true
+		}
+		permissions[role] = own
+		inherited = own
+	}
+	permissions[legacyRoleUser] = permissions[RoleMember]
+	return permissions
+}()
+
+// ValidRole reports whether role is one of Roles
+func ValidRole(role string) bool {
+	for _, r := range Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// HasPermission reports whether role grants the permission. Unknown roles
+// grant nothing.
+func HasPermission(role string, permission Permission) bool {
+	return rolePermissions[role][permission]
+}
+
+// Can reports whether the caller's role grants the permission
+func (c Caller) Can(permission Permission) bool {
+	return HasPermission(c.Role, permission)
+}
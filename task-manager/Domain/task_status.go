@@ -0,0 +1,73 @@
+package domain
+
+import (
+	"fmt"
+)
+
+// TaskStatus is the lifecycle state of a task
+type TaskStatus string
+
+const (
+	StatusTodo       TaskStatus = "todo"
+	StatusInProgress TaskStatus = "in_progress"
+	StatusBlocked    TaskStatus = "blocked"
+	StatusDone       TaskStatus = "done"
+	StatusCancelled  TaskStatus = "cancelled"
+)
+
+// taskTransitions maps each status to the statuses a task may move to next
+var taskTransitions = map[TaskStatus][]TaskStatus{
+	StatusTodo:       {StatusInProgress, StatusBlocked, StatusDone, StatusCancelled},
+	StatusInProgress: {StatusTodo, StatusBlocked, StatusDone, StatusCancelled},
+	StatusBlocked:    {StatusTodo, StatusInProgress, StatusCancelled},
+	StatusDone:       {StatusInProgress},
+	StatusCancelled:  {StatusTodo},
+}
+
+// ErrInvalidStatus is returned for a status outside the defined set
+var ErrInvalidStatus = &Error{Kind: ErrUnprocessable, Message: "invalid task status"}
+
+// TransitionError is returned when a task cannot move between two valid statuses
+type TransitionError struct {
+	From TaskStatus
+	To   TaskStatus
+}
+
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("cannot move task from %s to %s", e.From, e.To)
+}
+
+func (e *TransitionError) Unwrap() error {
+	return ErrConflict
+}
+
+// Valid reports whether s is one of the defined statuses
+func (s TaskStatus) Valid() bool {
+	_, ok := taskTransitions[s]
+	return ok
+}
+
+// CanTransitionTo reports whether a task in status s may move to next
+func (s TaskStatus) CanTransitionTo(next TaskStatus) bool {
+	for _, allowed := range taskTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateTransition returns ErrInvalidStatus or a *TransitionError when a
+// task may not move from one status to the other. Staying put is allowed.
+func ValidateTransition(from, to TaskStatus) error {
+	if !to.Valid() {
+		return fmt.Errorf("%w: %q", ErrInvalidStatus, to)
+	}
+	if from == to {
+		return nil
+	}
+	if !from.CanTransitionTo(to) {
+		return &TransitionError{From: from, To: to}
+	}
+	return nil
+}
@@ -4,11 +4,15 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
 	"testing"
 	"time"
 
@@ -20,14 +24,19 @@ import (
 	usecases "task-manager/Usecases"
 
 	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v4"
 	"github.com/joho/godotenv"
 	"github.com/stretchr/testify/suite"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 // E2ETestSuite represents the end-to-end test suite
+// e2eSetupToken is the ADMIN_SETUP_TOKEN the first admin is created with
+const e2eSetupToken = "e2e-admin-setup-token"
+
 type E2ETestSuite struct {
 	suite.Suite
 	router       *gin.Engine
@@ -40,6 +49,13 @@ type E2ETestSuite struct {
 	adminUserID  string
 	regularUserID string
 	testTaskID   string
+	jwtService   *infrastructure.JWTService
+	mailDir      string
+	totpService  *infrastructure.TOTPService
+	auditLog     *infrastructure.WriterAuditLog
+	auditBuf     *bytes.Buffer
+	userUsecase  *usecases.UserUsecase
+	health       *infrastructure.HealthRegistry
 }
 
 // TestE2ETestSuite runs the end-to-end test suite
@@ -47,17 +63,21 @@ func TestE2ETestSuite(t *testing.T) {
 	suite.Run(t, new(E2ETestSuite))
 }
 
-// SetupSuite initializes the test environment
+// SetupSuite initializes the test environment. It runs against MongoDB when
+// MONGODB_URI is set and against the in-memory repositories otherwise.
 func (suite *E2ETestSuite) SetupSuite() {
 	// Load environment variables
 	if err := godotenv.Load(".env"); err != nil {
 		log.Printf("Warning: Could not load .env file: %v", err)
 	}
 
-	// Get MongoDB URI
+	// Set Gin to test mode
+	gin.SetMode(gin.TestMode)
+
 	uri := os.Getenv("MONGODB_URI")
 	if uri == "" {
-		uri = "mongodb://localhost:27017"
+		log.Println("MONGODB_URI not set, running E2E tests against in-memory storage")
+		return
 	}
 
 	// Connect to MongoDB
@@ -76,31 +96,50 @@ func (suite *E2ETestSuite) SetupSuite() {
 	suite.taskColl = suite.db.Collection("tasks")
 	suite.userColl = suite.db.Collection("users")
 
-	// Set Gin to test mode
-	gin.SetMode(gin.TestMode)
+	// Unique indexes come with the migrations, as in production
+	suite.Require().NoError(repositories.RunMigrations(ctx, suite.db, repositories.DefaultCollectionNames), "Failed to migrate MongoDB")
 
-	// Initialize services
-	passwordService := infrastructure.NewPasswordService()
-	jwtService := infrastructure.NewJWTService()
+	log.Println("✅ E2E Test Suite initialized successfully")
+}
 
-	// Initialize repositories
-	taskRepo := repositories.NewTaskRepository(suite.taskColl)
-	userRepo := repositories.NewUserRepository(suite.userColl, jwtService, passwordService)
+// setupRouter wires the application on top of the given repositories
+func (suite *E2ETestSuite) setupRouter(
+	taskRepo domain.TaskRepository,
+	userRepo domain.UserRepository,
+	tokenRepo domain.TokenRepository,
+	resetRepo domain.ResetTokenRepository,
+	verificationRepo domain.VerificationTokenRepository,
+	mfaRepo domain.MFARepository,
+	throttleRepo domain.LoginThrottleRepository,
+) {
+	passwordService := infrastructure.NewPasswordService()
+	mailer := infrastructure.NewFileMailer(suite.mailDir, "no-reply@example.com")
 
 	// Initialize use cases
-	taskUsecase := usecases.NewTaskUsecase(taskRepo)
-	userUsecase := usecases.NewUserUsecase(userRepo)
+	taskUsecase := usecases.NewTaskUsecase(taskRepo, userRepo)
+	userUsecase := usecases.NewUserUsecase(userRepo, taskRepo, tokenRepo, mfaRepo, throttleRepo, suite.jwtService, passwordService, suite.totpService, suite.auditLog)
+	userUsecase.SetAdminSetupToken(e2eSetupToken)
+	suite.userUsecase = userUsecase
+	resetUsecase := usecases.NewPasswordResetUsecase(userRepo, resetRepo, tokenRepo, passwordService, mailer)
+	verificationUsecase := usecases.NewEmailVerificationUsecase(userRepo, verificationRepo, mailer)
+	mfaUsecase := usecases.NewMFAUsecase(userRepo, mfaRepo, suite.totpService, passwordService)
 
 	// Initialize controllers
-	controller := controllers.NewController(taskUsecase, userUsecase)
+	controller := controllers.NewController(taskUsecase, userUsecase, resetUsecase, verificationUsecase, mfaUsecase)
 
 	// Initialize middleware
-	authMiddleware := infrastructure.NewAuthMiddleware(jwtService)
+	authMiddleware := infrastructure.NewAuthMiddleware(suite.jwtService, tokenRepo, userRepo)
 
 	// Setup router
-	suite.router = routers.SetupRouter(controller, authMiddleware)
+	// Readiness checks as main registers them
+	health := infrastructure.NewHealthRegistry(2 * time.Second)
+	health.Register("jwt_keys", suite.jwtService.CheckKeys)
+	if suite.db != nil {
+		health.Register("mongodb", func(ctx context.Context) error { return suite.client.Ping(ctx, nil) })
+	}
+	suite.health = health
 
-	log.Println("✅ E2E Test Suite initialized successfully")
+	suite.router = routers.SetupRouter(controller, authMiddleware, suite.jwtService, health)
 }
 
 // TearDownSuite cleans up the test environment
@@ -124,17 +163,62 @@ func (suite *E2ETestSuite) TearDownSuite() {
 	log.Println("✅ E2E Test Suite cleaned up successfully")
 }
 
-// SetupTest cleans the database before each test
+// SetupTest starts every test from empty storage
 func (suite *E2ETestSuite) SetupTest() {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
-	// Clean collections
-	_, err := suite.taskColl.DeleteMany(ctx, bson.D{})
+	passwordService := infrastructure.NewPasswordService()
+	key, err := infrastructure.GenerateEd25519Key()
 	suite.Require().NoError(err)
-
-	_, err = suite.userColl.DeleteMany(ctx, bson.D{})
+	keys, err := infrastructure.NewKeySet(key)
 	suite.Require().NoError(err)
+	suite.jwtService = infrastructure.NewJWTService(keys)
+	suite.mailDir = suite.T().TempDir()
+	suite.totpService = infrastructure.NewTOTPService("Task Manager")
+	suite.auditBuf = &bytes.Buffer{}
+	suite.auditLog = infrastructure.NewWriterAuditLog(suite.auditBuf)
+
+	if suite.client == nil {
+		suite.setupRouter(
+			repositories.NewMemoryTaskRepository(),
+			repositories.NewMemoryUserRepository(passwordService),
+			repositories.NewMemoryTokenRepository(),
+			repositories.NewMemoryResetTokenRepository(),
+			repositories.NewMemoryVerificationTokenRepository(),
+			repositories.NewMemoryMFARepository(),
+			repositories.NewMemoryLoginThrottleRepository(),
+		)
+	} else {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		// Clean collections
+		_, err := suite.taskColl.DeleteMany(ctx, bson.D{})
+		suite.Require().NoError(err)
+
+		_, err = suite.userColl.DeleteMany(ctx, bson.D{})
+		suite.Require().NoError(err)
+
+		refreshTokens := suite.db.Collection("refresh_tokens")
+		revokedTokens := suite.db.Collection("revoked_tokens")
+		resetTokens := suite.db.Collection("password_reset_tokens")
+		verificationTokens := suite.db.Collection("email_verification_tokens")
+		mfaEnrollments := suite.db.Collection("mfa_enrollments")
+		mfaChallenges := suite.db.Collection("mfa_challenges")
+		loginThrottles := suite.db.Collection("login_throttles")
+		for _, coll := range []*mongo.Collection{refreshTokens, revokedTokens, resetTokens, verificationTokens, mfaEnrollments, mfaChallenges, loginThrottles} {
+			_, err = coll.DeleteMany(ctx, bson.D{})
+			suite.Require().NoError(err)
+		}
+
+		suite.setupRouter(
+			repositories.NewTaskRepository(suite.taskColl, repositories.DefaultTimeouts),
+			repositories.NewUserRepository(suite.userColl, passwordService, repositories.DefaultTimeouts),
+			repositories.NewTokenRepository(refreshTokens, revokedTokens, repositories.DefaultTimeouts),
+			repositories.NewResetTokenRepository(resetTokens, repositories.DefaultTimeouts),
+			repositories.NewVerificationTokenRepository(verificationTokens, repositories.DefaultTimeouts),
+			repositories.NewMFARepository(mfaEnrollments, mfaChallenges, repositories.DefaultTimeouts),
+			repositories.NewLoginThrottleRepository(loginThrottles, repositories.DefaultTimeouts),
+		)
+	}
 
 	// Reset tokens and IDs
 	suite.adminToken = ""
@@ -169,6 +253,15 @@ func (suite *E2ETestSuite) makeRequest(method, path string, body interface{}, to
 	return w
 }
 
+// setupAdmin creates the first admin through POST /setup
+func (suite *E2ETestSuite) setupAdmin(credentials map[string]string) *httptest.ResponseRecorder {
+	body := map[string]string{"token": e2eSetupToken}
+	for key, value := range credentials {
+		body[key] = value
+	}
+	return suite.makeRequest("POST", "/setup", body, "")
+}
+
 // Helper method to parse JSON response
 func (suite *E2ETestSuite) parseResponse(w *httptest.ResponseRecorder, target interface{}) {
 	err := json.Unmarshal(w.Body.Bytes(), target)
@@ -177,13 +270,18 @@ func (suite *E2ETestSuite) parseResponse(w *httptest.ResponseRecorder, target in
 
 // Test 1: Complete User Registration and Authentication Flow
 func (suite *E2ETestSuite) TestCompleteUserAuthenticationFlow() {
-	suite.Run("Register first user as admin", func() {
+	suite.Run("Set up the first admin with the setup token", func() {
 		adminUser := map[string]string{
 			"username": "admin",
-			"password": "admin123",
+			"password": "top-secret-1",
 		}
 
-		w := suite.makeRequest("POST", "/register", adminUser, "")
+		w := suite.makeRequest("POST", "/setup", map[string]string{
+			"token": "wrong-token", "username": "admin", "password": "top-secret-1",
+		}, "")
+		suite.Equal(http.StatusForbidden, w.Code)
+
+		w = suite.setupAdmin(adminUser)
 		suite.Equal(http.StatusCreated, w.Code)
 
 		var response domain.User
@@ -192,14 +290,14 @@ func (suite *E2ETestSuite) TestCompleteUserAuthenticationFlow() {
 		suite.Equal("admin", response.Username)
 		suite.Equal("admin", response.Role)
 		suite.NotEmpty(response.ID)
-		suite.Empty(response.Password) // Password should be cleared
-		suite.adminUserID = response.ID.Hex()
+		suite.NotContains(w.Body.String(), "password") // The response has no password field
+		suite.adminUserID = response.ID.String()
 	})
 
 	suite.Run("Register second user as regular user", func() {
 		regularUser := map[string]string{
 			"username": "user",
-			"password": "user123",
+			"password": "member-pass-1",
 		}
 
 		w := suite.makeRequest("POST", "/register", regularUser, "")
@@ -209,15 +307,24 @@ func (suite *E2ETestSuite) TestCompleteUserAuthenticationFlow() {
 		suite.parseResponse(w, &response)
 
 		suite.Equal("user", response.Username)
-		suite.Equal("user", response.Role)
+		suite.Equal(domain.RoleMember, response.Role)
 		suite.NotEmpty(response.ID)
-		suite.regularUserID = response.ID.Hex()
+		suite.regularUserID = response.ID.String()
+	})
+
+	suite.Run("Setup works only until an admin exists", func() {
+		w := suite.setupAdmin(map[string]string{"username": "second-admin", "password": "top-secret-2"})
+		suite.Equal(http.StatusConflict, w.Code)
+
+		var problem infrastructure.Problem
+		suite.parseResponse(w, &problem)
+		suite.Contains(problem.Detail, "an admin already exists")
 	})
 
 	suite.Run("Login admin user", func() {
 		loginData := map[string]string{
 			"username": "admin",
-			"password": "admin123",
+			"password": "top-secret-1",
 		}
 
 		w := suite.makeRequest("POST", "/login", loginData, "")
@@ -234,7 +341,7 @@ func (suite *E2ETestSuite) TestCompleteUserAuthenticationFlow() {
 	suite.Run("Login regular user", func() {
 		loginData := map[string]string{
 			"username": "user",
-			"password": "user123",
+			"password": "member-pass-1",
 		}
 
 		w := suite.makeRequest("POST", "/login", loginData, "")
@@ -255,11 +362,11 @@ func (suite *E2ETestSuite) TestCompleteUserAuthenticationFlow() {
 		}
 
 		w := suite.makeRequest("POST", "/register", duplicateUser, "")
-		suite.Equal(http.StatusBadRequest, w.Code)
+		suite.Equal(http.StatusConflict, w.Code)
 
-		var errorResponse map[string]string
+		var errorResponse infrastructure.Problem
 		suite.parseResponse(w, &errorResponse)
-		suite.Contains(errorResponse["error"], "username already taken")
+		suite.Contains(errorResponse.Detail, "username already taken")
 	})
 
 	suite.Run("Reject invalid login credentials", func() {
@@ -271,9 +378,9 @@ func (suite *E2ETestSuite) TestCompleteUserAuthenticationFlow() {
 		w := suite.makeRequest("POST", "/login", invalidLogin, "")
 		suite.Equal(http.StatusUnauthorized, w.Code)
 
-		var errorResponse map[string]string
+		var errorResponse infrastructure.Problem
 		suite.parseResponse(w, &errorResponse)
-		suite.Contains(errorResponse["error"], "invalid username or password")
+		suite.Contains(errorResponse.Detail, "invalid username or password")
 	})
 }
 
@@ -286,8 +393,9 @@ func (suite *E2ETestSuite) TestCompleteTaskManagementFlow() {
 		newTask := map[string]string{
 			"title":       "Complete E2E Tests",
 			"description": "Implement comprehensive end-to-end testing",
-			"due_date":    "2024-12-31",
-			"status":      "pending",
+			"due_date":    "2024-12-31T00:00:00Z",
+			"status":      "todo",
+			"assignee_id": suite.regularUserID,
 		}
 
 		w := suite.makeRequest("POST", "/tasks", newTask, suite.adminToken)
@@ -296,38 +404,107 @@ func (suite *E2ETestSuite) TestCompleteTaskManagementFlow() {
 		var response domain.Task
 		suite.parseResponse(w, &response)
 
+		suite.Equal(suite.adminUserID, response.CreatedBy.String())
+		suite.Equal(suite.regularUserID, response.AssigneeID.String())
 		suite.Equal("Complete E2E Tests", response.Title)
 		suite.Equal("Implement comprehensive end-to-end testing", response.Description)
-		suite.Equal("2024-12-31", response.DueDate)
-		suite.Equal("pending", response.Status)
+		suite.Require().NotNil(response.DueDate)
+		suite.Equal("2024-12-31T00:00:00Z", response.DueDate.Format(time.RFC3339))
+		suite.False(response.CreatedAt.IsZero())
+		suite.Equal(domain.StatusTodo, response.Status)
 		suite.NotEmpty(response.ID)
-		suite.testTaskID = response.ID.Hex()
+		suite.testTaskID = response.ID.String()
 	})
 
 	suite.Run("Regular user cannot create task", func() {
 		newTask := map[string]string{
 			"title":       "Unauthorized Task",
 			"description": "This should fail",
-			"status":      "pending",
+			"status":      "todo",
 		}
 
 		w := suite.makeRequest("POST", "/tasks", newTask, suite.userToken)
 		suite.Equal(http.StatusForbidden, w.Code)
 
-		var errorResponse map[string]string
+		var errorResponse infrastructure.Problem
 		suite.parseResponse(w, &errorResponse)
-		suite.Contains(errorResponse["error"], "Admin access required")
+		suite.Contains(errorResponse.Detail, "permission task:create required")
 	})
 
-	suite.Run("Get all tasks (authenticated user)", func() {
+	suite.Run("Get all tasks (assignee)", func() {
 		w := suite.makeRequest("GET", "/tasks", nil, suite.userToken)
 		suite.Equal(http.StatusOK, w.Code)
 
-		var response []domain.Task
+		var response domain.TaskPage
 		suite.parseResponse(w, &response)
 
-		suite.Len(response, 1)
-		suite.Equal("Complete E2E Tests", response[0].Title)
+		suite.Require().Len(response.Tasks, 1)
+		suite.Equal(int64(1), response.Total)
+		suite.Equal("Complete E2E Tests", response.Tasks[0].Title)
+	})
+
+	suite.Run("Regular user cannot see unrelated tasks", func() {
+		privateTask := map[string]string{
+			"title":  "Admin Only Task",
+			"status": "todo",
+		}
+
+		w := suite.makeRequest("POST", "/tasks", privateTask, suite.adminToken)
+		suite.Require().Equal(http.StatusCreated, w.Code)
+
+		var created domain.Task
+		suite.parseResponse(w, &created)
+
+		w = suite.makeRequest("GET", "/tasks", nil, suite.userToken)
+		suite.Equal(http.StatusOK, w.Code)
+
+		var visible domain.TaskPage
+		suite.parseResponse(w, &visible)
+		suite.Len(visible.Tasks, 1)
+
+		path := fmt.Sprintf("/tasks/%s", created.ID.String())
+		w = suite.makeRequest("GET", path, nil, suite.userToken)
+		suite.Equal(http.StatusNotFound, w.Code)
+
+		w = suite.makeRequest("GET", "/tasks", nil, suite.adminToken)
+		suite.Equal(http.StatusOK, w.Code)
+
+		var all domain.TaskPage
+		suite.parseResponse(w, &all)
+		suite.Len(all.Tasks, 2)
+	})
+
+	suite.Run("List tasks with filters and pagination", func() {
+		w := suite.makeRequest("GET", "/tasks?status=todo&sort=title&order=desc&limit=1", nil, suite.adminToken)
+		suite.Equal(http.StatusOK, w.Code)
+
+		var page domain.TaskPage
+		suite.parseResponse(w, &page)
+
+		suite.Equal(int64(2), page.Total)
+		suite.Require().Len(page.Tasks, 1)
+		suite.Equal("Complete E2E Tests", page.Tasks[0].Title)
+		suite.Contains(page.Next, "offset=1")
+
+		w = suite.makeRequest("GET", page.Next, nil, suite.adminToken)
+		suite.Equal(http.StatusOK, w.Code)
+
+		page = domain.TaskPage{}
+		suite.parseResponse(w, &page)
+		suite.Require().Len(page.Tasks, 1)
+		suite.Equal("Admin Only Task", page.Tasks[0].Title)
+		suite.Empty(page.Next)
+	})
+
+	suite.Run("Reject invalid listing parameters", func() {
+		w := suite.makeRequest("GET", "/tasks?sort=password", nil, suite.adminToken)
+		suite.Equal(http.StatusBadRequest, w.Code)
+
+		w = suite.makeRequest("GET", "/tasks?status=pending", nil, suite.adminToken)
+		suite.Equal(http.StatusBadRequest, w.Code)
+
+		w = suite.makeRequest("GET", "/tasks?limit=abc", nil, suite.adminToken)
+		suite.Equal(http.StatusBadRequest, w.Code)
 	})
 
 	suite.Run("Get task by ID", func() {
@@ -339,15 +516,15 @@ func (suite *E2ETestSuite) TestCompleteTaskManagementFlow() {
 		suite.parseResponse(w, &response)
 
 		suite.Equal("Complete E2E Tests", response.Title)
-		suite.Equal(suite.testTaskID, response.ID.Hex())
+		suite.Equal(suite.testTaskID, response.ID.String())
 	})
 
 	suite.Run("Admin updates task", func() {
 		updatedTask := map[string]string{
 			"title":       "Complete E2E Tests - Updated",
 			"description": "Implement comprehensive end-to-end testing with full coverage",
-			"due_date":    "2024-12-25",
-			"status":      "in-progress",
+			"due_date":    "2024-12-25T17:00:00Z",
+			"status":      "in_progress",
 		}
 
 		path := fmt.Sprintf("/tasks/%s", suite.testTaskID)
@@ -359,15 +536,127 @@ func (suite *E2ETestSuite) TestCompleteTaskManagementFlow() {
 
 		suite.Equal("Complete E2E Tests - Updated", response.Title)
 		suite.Equal("Implement comprehensive end-to-end testing with full coverage", response.Description)
-		suite.Equal("2024-12-25", response.DueDate)
-		suite.Equal("in-progress", response.Status)
-		suite.Equal(suite.testTaskID, response.ID.Hex())
+		suite.Require().NotNil(response.DueDate)
+		suite.Equal("2024-12-25T17:00:00Z", response.DueDate.Format(time.RFC3339))
+		suite.True(response.UpdatedAt.After(response.CreatedAt) || response.UpdatedAt.Equal(response.CreatedAt))
+		suite.Equal(domain.StatusInProgress, response.Status)
+		suite.Equal(suite.testTaskID, response.ID.String())
+	})
+
+	suite.Run("Admin patches only supplied fields", func() {
+		path := fmt.Sprintf("/tasks/%s", suite.testTaskID)
+		w := suite.makeRequest("PATCH", path, map[string]interface{}{"description": "Patched description"}, suite.adminToken)
+		suite.Equal(http.StatusOK, w.Code)
+
+		var response domain.Task
+		suite.parseResponse(w, &response)
+		suite.Equal("Complete E2E Tests - Updated", response.Title)
+		suite.Equal("Patched description", response.Description)
+		suite.Equal(domain.StatusInProgress, response.Status)
+		suite.NotNil(response.DueDate)
+
+		w = suite.makeRequest("PATCH", path, map[string]interface{}{"due_date": nil}, suite.adminToken)
+		suite.Equal(http.StatusOK, w.Code)
+
+		response = domain.Task{}
+		suite.parseResponse(w, &response)
+		suite.Nil(response.DueDate)
+	})
+
+	suite.Run("Patch rejects unknown and read-only fields", func() {
+		path := fmt.Sprintf("/tasks/%s", suite.testTaskID)
+		w := suite.makeRequest("PATCH", path, map[string]interface{}{"colour": "red"}, suite.adminToken)
+		suite.Equal(http.StatusBadRequest, w.Code)
+
+		w = suite.makeRequest("PATCH", path, map[string]interface{}{"created_by": suite.regularUserID}, suite.adminToken)
+		suite.Equal(http.StatusBadRequest, w.Code)
+
+		w = suite.makeRequest("PATCH", path, map[string]interface{}{"version": 99}, suite.adminToken)
+		suite.Equal(http.StatusBadRequest, w.Code)
+
+		var errorResponse infrastructure.Problem
+		suite.parseResponse(w, &errorResponse)
+		suite.Contains(errorResponse.Detail, "version is read-only")
+
+		w = suite.makeRequest("PATCH", path, map[string]interface{}{}, suite.adminToken)
+		suite.Equal(http.StatusBadRequest, w.Code)
+	})
+
+	suite.Run("Conditional writes honor If-Match", func() {
+		path := fmt.Sprintf("/tasks/%s", suite.testTaskID)
+		w := suite.makeRequest("GET", path, nil, suite.adminToken)
+		suite.Require().Equal(http.StatusOK, w.Code)
+
+		etag := w.Header().Get("ETag")
+		suite.Require().NotEmpty(etag)
+
+		body, err := json.Marshal(map[string]string{"title": "Complete E2E Tests - Updated"})
+		suite.Require().NoError(err)
+
+		conditionalPatch := func(ifMatch string) *httptest.ResponseRecorder {
+			req, err := http.NewRequest("PATCH", path, bytes.NewBuffer(body))
+			suite.Require().NoError(err)
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Authorization", "Bearer "+suite.adminToken)
+			req.Header.Set("If-Match", ifMatch)
+
+			rec := httptest.NewRecorder()
+			suite.router.ServeHTTP(rec, req)
+			return rec
+		}
+
+		w = conditionalPatch(etag)
+		suite.Equal(http.StatusOK, w.Code)
+		suite.NotEqual(etag, w.Header().Get("ETag"))
+
+		// The original ETag is now stale
+		w = conditionalPatch(etag)
+		suite.Equal(http.StatusPreconditionFailed, w.Code)
+
+		// If-Match uses strong comparison, so a weak tag never matches
+		w = suite.makeRequest("GET", path, nil, suite.adminToken)
+		current := w.Header().Get("ETag")
+		w = conditionalPatch("W/" + current)
+		suite.Equal(http.StatusPreconditionFailed, w.Code)
+
+		// Any tag in a list may match
+		w = conditionalPatch(etag + ", " + current)
+		suite.Equal(http.StatusOK, w.Code)
+
+		w = conditionalPatch("not-quoted")
+		suite.Equal(http.StatusBadRequest, w.Code)
+	})
+
+	suite.Run("Assignee transitions task status", func() {
+		path := fmt.Sprintf("/tasks/%s/transition", suite.testTaskID)
+		w := suite.makeRequest("POST", path, map[string]string{"status": "blocked"}, suite.userToken)
+		suite.Equal(http.StatusOK, w.Code)
+
+		var response domain.Task
+		suite.parseResponse(w, &response)
+		suite.Equal(domain.StatusBlocked, response.Status)
+	})
+
+	suite.Run("Illegal transition returns 409", func() {
+		path := fmt.Sprintf("/tasks/%s/transition", suite.testTaskID)
+		w := suite.makeRequest("POST", path, map[string]string{"status": "done"}, suite.userToken)
+		suite.Equal(http.StatusConflict, w.Code)
+
+		var errorResponse infrastructure.Problem
+		suite.parseResponse(w, &errorResponse)
+		suite.Contains(errorResponse.Detail, "cannot move task from blocked to done")
+	})
+
+	suite.Run("Unknown status returns 422", func() {
+		path := fmt.Sprintf("/tasks/%s/transition", suite.testTaskID)
+		w := suite.makeRequest("POST", path, map[string]string{"status": "someday"}, suite.userToken)
+		suite.Equal(http.StatusUnprocessableEntity, w.Code)
 	})
 
 	suite.Run("Regular user cannot update task", func() {
 		updatedTask := map[string]string{
 			"title":  "Unauthorized Update",
-			"status": "completed",
+			"status": "done",
 		}
 
 		path := fmt.Sprintf("/tasks/%s", suite.testTaskID)
@@ -379,9 +668,9 @@ func (suite *E2ETestSuite) TestCompleteTaskManagementFlow() {
 		w := suite.makeRequest("GET", "/tasks/507f1f77bcf86cd799439011", nil, suite.userToken)
 		suite.Equal(http.StatusNotFound, w.Code)
 
-		var errorResponse map[string]string
+		var errorResponse infrastructure.Problem
 		suite.parseResponse(w, &errorResponse)
-		suite.Contains(errorResponse["error"], "Task not found")
+		suite.Contains(errorResponse.Detail, "task not found")
 	})
 
 	suite.Run("Admin deletes task", func() {
@@ -401,7 +690,7 @@ func (suite *E2ETestSuite) TestCompleteTaskManagementFlow() {
 		// Create a new task first
 		newTask := map[string]string{
 			"title":  "Task to Delete",
-			"status": "pending",
+			"status": "todo",
 		}
 
 		w := suite.makeRequest("POST", "/tasks", newTask, suite.adminToken)
@@ -411,7 +700,7 @@ func (suite *E2ETestSuite) TestCompleteTaskManagementFlow() {
 		suite.parseResponse(w, &task)
 
 		// Try to delete with regular user
-		path := fmt.Sprintf("/tasks/%s", task.ID.Hex())
+		path := fmt.Sprintf("/tasks/%s", task.ID.String())
 		w = suite.makeRequest("DELETE", path, nil, suite.userToken)
 		suite.Equal(http.StatusForbidden, w.Code)
 	})
@@ -432,7 +721,7 @@ func (suite *E2ETestSuite) TestUserManagementFlow() {
 
 		suite.Equal("admin", response.Username)
 		suite.Equal("admin", response.Role)
-		suite.NotEmpty(response.Password) // Hashed password should be present
+		suite.NotContains(w.Body.String(), "password") // Hashes are never returned
 	})
 
 	suite.Run("Get non-existent user returns 404", func() {
@@ -440,9 +729,20 @@ func (suite *E2ETestSuite) TestUserManagementFlow() {
 		w := suite.makeRequest("GET", path, nil, suite.adminToken)
 		suite.Equal(http.StatusNotFound, w.Code)
 
-		var errorResponse map[string]string
+		var errorResponse infrastructure.Problem
+		suite.parseResponse(w, &errorResponse)
+		suite.Contains(errorResponse.Detail, "user not found")
+	})
+
+	// Runs before the promotion, which applies to the user's token at once
+	suite.Run("Regular user cannot promote others", func() {
+		path := fmt.Sprintf("/users/%s/promote", suite.adminUserID)
+		w := suite.makeRequest("POST", path, nil, suite.userToken)
+		suite.Equal(http.StatusForbidden, w.Code)
+
+		var errorResponse infrastructure.Problem
 		suite.parseResponse(w, &errorResponse)
-		suite.Contains(errorResponse["error"], "User not found")
+		suite.Contains(errorResponse.Detail, "permission user:promote required")
 	})
 
 	suite.Run("Admin promotes regular user", func() {
@@ -455,17 +755,7 @@ func (suite *E2ETestSuite) TestUserManagementFlow() {
 
 		suite.Equal("user", response.Username)
 		suite.Equal("admin", response.Role) // Should be promoted to admin
-		suite.Empty(response.Password)      // Password should be cleared in response
-	})
-
-	suite.Run("Regular user cannot promote others", func() {
-		path := fmt.Sprintf("/users/%s/promote", suite.adminUserID)
-		w := suite.makeRequest("POST", path, nil, suite.userToken)
-		suite.Equal(http.StatusForbidden, w.Code)
-
-		var errorResponse map[string]string
-		suite.parseResponse(w, &errorResponse)
-		suite.Contains(errorResponse["error"], "Admin access required")
+		suite.NotContains(w.Body.String(), "password")
 	})
 
 	suite.Run("Promote non-existent user returns 404", func() {
@@ -473,9 +763,9 @@ func (suite *E2ETestSuite) TestUserManagementFlow() {
 		w := suite.makeRequest("POST", path, nil, suite.adminToken)
 		suite.Equal(http.StatusNotFound, w.Code)
 
-		var errorResponse map[string]string
+		var errorResponse infrastructure.Problem
 		suite.parseResponse(w, &errorResponse)
-		suite.Contains(errorResponse["error"], "user not found")
+		suite.Contains(errorResponse.Detail, "user not found")
 	})
 }
 
@@ -488,32 +778,32 @@ func (suite *E2ETestSuite) TestAuthenticationAndAuthorizationEdgeCases() {
 		w := suite.makeRequest("GET", "/tasks", nil, "")
 		suite.Equal(http.StatusUnauthorized, w.Code)
 
-		var errorResponse map[string]string
+		var errorResponse infrastructure.Problem
 		suite.parseResponse(w, &errorResponse)
-		suite.Contains(errorResponse["error"], "Authorization header missing or invalid")
+		suite.Contains(errorResponse.Detail, "Authorization header missing or invalid")
 	})
 
 	suite.Run("Access protected endpoint with invalid token", func() {
 		w := suite.makeRequest("GET", "/tasks", nil, "invalid-token")
 		suite.Equal(http.StatusUnauthorized, w.Code)
 
-		var errorResponse map[string]string
+		var errorResponse infrastructure.Problem
 		suite.parseResponse(w, &errorResponse)
-		suite.Contains(errorResponse["error"], "invalid or expired token")
+		suite.Contains(errorResponse.Detail, "invalid or expired token")
 	})
 
 	suite.Run("Access admin endpoint with regular user token", func() {
 		newTask := map[string]string{
 			"title":  "Unauthorized Task",
-			"status": "pending",
+			"status": "todo",
 		}
 
 		w := suite.makeRequest("POST", "/tasks", newTask, suite.userToken)
 		suite.Equal(http.StatusForbidden, w.Code)
 
-		var errorResponse map[string]string
+		var errorResponse infrastructure.Problem
 		suite.parseResponse(w, &errorResponse)
-		suite.Contains(errorResponse["error"], "Admin access required")
+		suite.Contains(errorResponse.Detail, "permission task:create required")
 	})
 
 	suite.Run("Invalid request body format", func() {
@@ -536,31 +826,830 @@ func (suite *E2ETestSuite) TestAuthenticationAndAuthorizationEdgeCases() {
 		w := suite.makeRequest("POST", "/register", emptyUser, "")
 		suite.Equal(http.StatusBadRequest, w.Code)
 
-		var errorResponse map[string]string
+		var errorResponse infrastructure.Problem
 		suite.parseResponse(w, &errorResponse)
-		suite.Contains(errorResponse["error"], "fields cannot be empty")
+		suite.Contains(errorResponse.Detail, "fields cannot be empty")
+	})
+
+	suite.Run("Weak password in registration", func() {
+		w := suite.makeRequest("POST", "/register", map[string]string{"username": "weakling", "password": "weakling"}, "")
+		suite.Equal(http.StatusBadRequest, w.Code)
+
+		var errorResponse infrastructure.Problem
+		suite.parseResponse(w, &errorResponse)
+		suite.Contains(errorResponse.Detail, "password does not meet the policy")
+		var rules []string
+		for _, violation := range errorResponse.Violations {
+			rules = append(rules, violation.Rule)
+		}
+		suite.Equal([]string{domain.PasswordRuleClasses, domain.PasswordRuleUsername}, rules)
 	})
 
 	suite.Run("Invalid ObjectID format in task operations", func() {
 		w := suite.makeRequest("GET", "/tasks/invalid-id", nil, suite.userToken)
 		suite.Equal(http.StatusBadRequest, w.Code)
 
-		var errorResponse map[string]string
+		var errorResponse infrastructure.Problem
 		suite.parseResponse(w, &errorResponse)
-		suite.Contains(errorResponse["error"], "invalid id format")
+		suite.Contains(errorResponse.Detail, "invalid id format")
+	})
+}
+
+// Test: Token refresh, rotation and logout
+func (suite *E2ETestSuite) TestTokenRefreshAndLogout() {
+	credentials := map[string]string{
+		"username": "session",
+		"password": "keep-alive-1",
+	}
+	w := suite.makeRequest("POST", "/register", credentials, "")
+	suite.Require().Equal(http.StatusCreated, w.Code)
+
+	w = suite.makeRequest("POST", "/login", credentials, "")
+	suite.Require().Equal(http.StatusOK, w.Code)
+
+	var login domain.LoginResponse
+	suite.parseResponse(w, &login)
+	suite.Require().NotEmpty(login.RefreshToken, "Login should return a refresh token")
+
+	var refreshed domain.LoginResponse
+	suite.Run("Refresh returns a new token pair", func() {
+		w := suite.makeRequest("POST", "/auth/refresh", map[string]string{"refresh_token": login.RefreshToken}, "")
+		suite.Require().Equal(http.StatusOK, w.Code)
+
+		suite.parseResponse(w, &refreshed)
+		suite.NotEmpty(refreshed.Token)
+		suite.NotEqual(login.RefreshToken, refreshed.RefreshToken)
+
+		w = suite.makeRequest("GET", "/tasks", nil, refreshed.Token)
+		suite.Equal(http.StatusOK, w.Code)
+	})
+
+	suite.Run("Rotated refresh token is rejected", func() {
+		w := suite.makeRequest("POST", "/auth/refresh", map[string]string{"refresh_token": login.RefreshToken}, "")
+		suite.Equal(http.StatusUnauthorized, w.Code)
+
+		// Reuse revokes every token rotated from the same login
+		w = suite.makeRequest("POST", "/auth/refresh", map[string]string{"refresh_token": refreshed.RefreshToken}, "")
+		suite.Equal(http.StatusUnauthorized, w.Code)
+	})
+
+	suite.Run("Refresh requires a token", func() {
+		w := suite.makeRequest("POST", "/auth/refresh", map[string]string{}, "")
+		suite.Equal(http.StatusBadRequest, w.Code)
+	})
+
+	suite.Run("Logout revokes the access and refresh tokens", func() {
+		w := suite.makeRequest("POST", "/login", credentials, "")
+		suite.Require().Equal(http.StatusOK, w.Code)
+
+		var session domain.LoginResponse
+		suite.parseResponse(w, &session)
+
+		w = suite.makeRequest("POST", "/logout", map[string]string{"refresh_token": session.RefreshToken}, session.Token)
+		suite.Equal(http.StatusNoContent, w.Code)
+
+		w = suite.makeRequest("GET", "/tasks", nil, session.Token)
+		suite.Equal(http.StatusUnauthorized, w.Code)
+
+		var problem infrastructure.Problem
+		suite.parseResponse(w, &problem)
+		suite.Contains(problem.Detail, "revoked")
+
+		w = suite.makeRequest("POST", "/auth/refresh", map[string]string{"refresh_token": session.RefreshToken}, "")
+		suite.Equal(http.StatusUnauthorized, w.Code)
+	})
+
+	suite.Run("Logout requires authentication", func() {
+		w := suite.makeRequest("POST", "/logout", nil, "")
+		suite.Equal(http.StatusUnauthorized, w.Code)
+	})
+}
+
+// Test: Published signing keys verify issued tokens
+func (suite *E2ETestSuite) TestJWKSEndpoint() {
+	suite.setupUsersForTaskTests()
+
+	w := suite.makeRequest("GET", "/.well-known/jwks.json", nil, "")
+	suite.Require().Equal(http.StatusOK, w.Code)
+
+	var jwks infrastructure.JWKS
+	suite.parseResponse(w, &jwks)
+	suite.Require().NotEmpty(jwks.Keys)
+
+	token, _, err := new(jwt.Parser).ParseUnverified(suite.userToken, jwt.MapClaims{})
+	suite.Require().NoError(err)
+	suite.Equal(jwks.Keys[0].KeyID, token.Header["kid"], "tokens name the published key")
+	suite.Equal(jwks.Keys[0].Algorithm, token.Method.Alg())
+}
+
+func (suite *E2ETestSuite) TestHealthEndpoints() {
+	w := suite.makeRequest("GET", "/healthz", nil, "")
+	suite.Require().Equal(http.StatusOK, w.Code)
+
+	var report infrastructure.HealthReport
+	w = suite.makeRequest("GET", "/readyz", nil, "")
+	suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	suite.parseResponse(w, &report)
+	suite.Equal(infrastructure.HealthReady, report.Status)
+	suite.Equal(infrastructure.HealthOK, report.Checks["jwt_keys"].Status)
+
+	// A component going down takes the server out of rotation
+	suite.health.Register("mail", func(context.Context) error { return errors.New("SMTP server unreachable") })
+	w = suite.makeRequest("GET", "/readyz", nil, "")
+	suite.Require().Equal(http.StatusServiceUnavailable, w.Code)
+	suite.parseResponse(w, &report)
+	suite.Equal(infrastructure.HealthUnavailable, report.Status)
+	suite.Equal("SMTP server unreachable", report.Checks["mail"].Error)
+}
+
+// Test: Admins list, re-role, disable and delete users
+func (suite *E2ETestSuite) TestUserAdministration() {
+	suite.setupUsersForTaskTests()
+
+	login := func(username, password string) *httptest.ResponseRecorder {
+		return suite.makeRequest("POST", "/login", map[string]string{"username": username, "password": password}, "")
+	}
+	createTask := func(token, title, assignee string) *httptest.ResponseRecorder {
+		return suite.makeRequest("POST", "/tasks", map[string]interface{}{
+			"title": title, "description": "Owned by someone else soon", "status": "todo", "assignee_id": assignee,
+		}, token)
+	}
+
+	suite.Run("Admin lists users without password hashes", func() {
+		w := suite.makeRequest("GET", "/users", nil, suite.adminToken)
+		suite.Require().Equal(http.StatusOK, w.Code)
+		suite.NotContains(w.Body.String(), "password")
+
+		var page domain.UserPage
+		suite.parseResponse(w, &page)
+		suite.Equal(int64(2), page.Total)
+		suite.Len(page.Users, 2)
+
+		w = suite.makeRequest("GET", "/users?role=member&limit=1", nil, suite.adminToken)
+		suite.Require().Equal(http.StatusOK, w.Code)
+		suite.parseResponse(w, &page)
+		suite.Equal(int64(1), page.Total)
+		suite.Equal("user", page.Users[0].Username)
+		suite.Empty(page.Next)
+
+		w = suite.makeRequest("GET", "/users?limit=1", nil, suite.adminToken)
+		suite.parseResponse(w, &page)
+		suite.Contains(page.Next, "offset=1")
+	})
+
+	suite.Run("Listing rejects bad filters", func() {
+		w := suite.makeRequest("GET", "/users?disabled=maybe", nil, suite.adminToken)
+		suite.Equal(http.StatusBadRequest, w.Code)
+		w = suite.makeRequest("GET", "/users?role=superuser", nil, suite.adminToken)
+		suite.Equal(http.StatusBadRequest, w.Code)
+	})
+
+	suite.Run("Members cannot manage users", func() {
+		w := suite.makeRequest("GET", "/users", nil, suite.userToken)
+		suite.Equal(http.StatusForbidden, w.Code)
+
+		var problem infrastructure.Problem
+		suite.parseResponse(w, &problem)
+		suite.Contains(problem.Detail, "permission user:manage required")
+	})
+
+	suite.Run("Role changes apply to existing tokens", func() {
+		path := fmt.Sprintf("/users/%s/role", suite.regularUserID)
+		w := suite.makeRequest("PUT", path, map[string]string{"role": "maintainer"}, suite.adminToken)
+		suite.Require().Equal(http.StatusOK, w.Code)
+
+		var user domain.User
+		suite.parseResponse(w, &user)
+		suite.Equal("maintainer", user.Role)
+
+		w = createTask(suite.userToken, "Maintained", suite.regularUserID)
+		suite.Equal(http.StatusCreated, w.Code)
+
+		w = suite.makeRequest("PUT", path, map[string]string{"role": "viewer"}, suite.adminToken)
+		suite.Require().Equal(http.StatusOK, w.Code)
+
+		w = createTask(suite.userToken, "Viewed", suite.regularUserID)
+		suite.Equal(http.StatusForbidden, w.Code)
+
+		w = suite.makeRequest("PUT", path, map[string]string{"role": "superuser"}, suite.adminToken)
+		suite.Equal(http.StatusBadRequest, w.Code)
+	})
+
+	suite.Run("The last admin cannot be demoted, disabled or deleted", func() {
+		w := suite.makeRequest("PUT", fmt.Sprintf("/users/%s/role", suite.adminUserID), map[string]string{"role": "member"}, suite.adminToken)
+		suite.Equal(http.StatusConflict, w.Code)
+
+		w = suite.makeRequest("POST", fmt.Sprintf("/users/%s/disable", suite.adminUserID), nil, suite.adminToken)
+		suite.Equal(http.StatusConflict, w.Code)
+
+		w = suite.makeRequest("DELETE", fmt.Sprintf("/users/%s?reassign_to=%s", suite.adminUserID, suite.regularUserID), nil, suite.adminToken)
+		suite.Equal(http.StatusConflict, w.Code)
+
+		var problem infrastructure.Problem
+		suite.parseResponse(w, &problem)
+		suite.Contains(problem.Detail, "last admin")
+	})
+
+	suite.Run("Disabled users cannot log in or use their tokens", func() {
+		w := suite.makeRequest("POST", fmt.Sprintf("/users/%s/disable", suite.regularUserID), nil, suite.adminToken)
+		suite.Require().Equal(http.StatusOK, w.Code)
+
+		var user domain.User
+		suite.parseResponse(w, &user)
+		suite.True(user.Disabled)
+
+		w = suite.makeRequest("GET", "/tasks", nil, suite.userToken)
+		suite.Equal(http.StatusForbidden, w.Code)
+		w = login("user", "member-pass-1")
+		suite.Equal(http.StatusForbidden, w.Code)
+
+		var problem infrastructure.Problem
+		suite.parseResponse(w, &problem)
+		suite.Contains(problem.Detail, "account is disabled")
+
+		w = suite.makeRequest("GET", "/users?disabled=true", nil, suite.adminToken)
+		var page domain.UserPage
+		suite.parseResponse(w, &page)
+		suite.Equal(int64(1), page.Total)
+
+		w = suite.makeRequest("POST", fmt.Sprintf("/users/%s/enable", suite.regularUserID), nil, suite.adminToken)
+		suite.Require().Equal(http.StatusOK, w.Code)
+		w = suite.makeRequest("GET", "/tasks", nil, suite.userToken)
+		suite.Equal(http.StatusOK, w.Code)
+	})
+
+	suite.Run("Deleting a user reassigns their tasks to the caller", func() {
+		w := suite.makeRequest("PUT", fmt.Sprintf("/users/%s/role", suite.regularUserID), map[string]string{"role": "maintainer"}, suite.adminToken)
+		suite.Require().Equal(http.StatusOK, w.Code)
+
+		w = createTask(suite.userToken, "Left behind", suite.regularUserID)
+		suite.Require().Equal(http.StatusCreated, w.Code)
+		var created domain.Task
+		suite.parseResponse(w, &created)
+
+		w = createTask(suite.adminToken, "Assigned away", suite.regularUserID)
+		suite.Require().Equal(http.StatusCreated, w.Code)
+		var assigned domain.Task
+		suite.parseResponse(w, &assigned)
+
+		w = suite.makeRequest("DELETE", "/users/"+suite.regularUserID, nil, suite.adminToken)
+		suite.Require().Equal(http.StatusNoContent, w.Code)
+
+		for _, task := range []domain.Task{created, assigned} {
+			w = suite.makeRequest("GET", "/tasks/"+task.ID.String(), nil, suite.adminToken)
+			suite.Require().Equal(http.StatusOK, w.Code)
+			var fetched domain.Task
+			suite.parseResponse(w, &fetched)
+			suite.Equal(suite.adminUserID, fetched.CreatedBy.String(), task.Title)
+			suite.Equal(suite.adminUserID, fetched.AssigneeID.String(), task.Title)
+		}
+
+		w = suite.makeRequest("GET", "/tasks", nil, suite.userToken)
+		suite.Equal(http.StatusUnauthorized, w.Code)
+		w = login("user", "member-pass-1")
+		suite.Equal(http.StatusUnauthorized, w.Code)
+	})
+
+	suite.Run("Deleting a user can delete their tasks", func() {
+		w := suite.makeRequest("POST", "/register", map[string]string{"username": "temp", "password": "throwaway-9"}, "")
+		suite.Require().Equal(http.StatusCreated, w.Code)
+		var temp domain.User
+		suite.parseResponse(w, &temp)
+
+		w = suite.makeRequest("PUT", fmt.Sprintf("/users/%s/role", temp.ID), map[string]string{"role": "maintainer"}, suite.adminToken)
+		suite.Require().Equal(http.StatusOK, w.Code)
+
+		w = login("temp", "throwaway-9")
+		suite.Require().Equal(http.StatusOK, w.Code)
+		var session domain.LoginResponse
+		suite.parseResponse(w, &session)
+
+		w = createTask(session.Token, "Temporary", temp.ID.String())
+		suite.Require().Equal(http.StatusCreated, w.Code)
+		var created domain.Task
+		suite.parseResponse(w, &created)
+
+		w = createTask(suite.adminToken, "Handed back", temp.ID.String())
+		suite.Require().Equal(http.StatusCreated, w.Code)
+		var assigned domain.Task
+		suite.parseResponse(w, &assigned)
+
+		w = suite.makeRequest("DELETE", fmt.Sprintf("/users/%s?tasks=archive", temp.ID), nil, suite.adminToken)
+		suite.Equal(http.StatusBadRequest, w.Code)
+
+		w = suite.makeRequest("DELETE", fmt.Sprintf("/users/%s?tasks=delete", temp.ID), nil, suite.adminToken)
+		suite.Require().Equal(http.StatusNoContent, w.Code)
+
+		w = suite.makeRequest("GET", "/tasks/"+created.ID.String(), nil, suite.adminToken)
+		suite.Equal(http.StatusNotFound, w.Code)
+
+		w = suite.makeRequest("GET", "/tasks/"+assigned.ID.String(), nil, suite.adminToken)
+		suite.Require().Equal(http.StatusOK, w.Code)
+		var fetched domain.Task
+		suite.parseResponse(w, &fetched)
+		suite.Equal(suite.adminUserID, fetched.AssigneeID.String())
+
+		w = suite.makeRequest("DELETE", "/users/"+temp.ID.String(), nil, suite.adminToken)
+		suite.Equal(http.StatusNotFound, w.Code)
+	})
+}
+
+// Test: Users view and edit their own account
+func (suite *E2ETestSuite) TestSelfService() {
+	suite.setupUsersForTaskTests()
+
+	suite.Run("Get own profile", func() {
+		w := suite.makeRequest("GET", "/me", nil, suite.userToken)
+		suite.Require().Equal(http.StatusOK, w.Code)
+		suite.NotContains(w.Body.String(), "password")
+
+		var me domain.PublicUser
+		suite.parseResponse(w, &me)
+		suite.Equal(suite.regularUserID, me.ID.String())
+		suite.Equal("user", me.Username)
+		suite.Equal(domain.RoleMember, me.Role)
+
+		w = suite.makeRequest("GET", "/me", nil, "")
+		suite.Equal(http.StatusUnauthorized, w.Code)
+	})
+
+	suite.Run("Viewers can use their account too", func() {
+		w := suite.makeRequest("PUT", fmt.Sprintf("/users/%s/role", suite.regularUserID), map[string]string{"role": "viewer"}, suite.adminToken)
+		suite.Require().Equal(http.StatusOK, w.Code)
+
+		w = suite.makeRequest("GET", "/me", nil, suite.userToken)
+		suite.Equal(http.StatusOK, w.Code)
+	})
+
+	suite.Run("Rename own account", func() {
+		w := suite.makeRequest("PATCH", "/me", map[string]string{"username": "renamed"}, suite.userToken)
+		suite.Require().Equal(http.StatusOK, w.Code)
+
+		var me domain.PublicUser
+		suite.parseResponse(w, &me)
+		suite.Equal("renamed", me.Username)
+
+		w = suite.makeRequest("POST", "/login", map[string]string{"username": "renamed", "password": "member-pass-1"}, "")
+		suite.Equal(http.StatusOK, w.Code)
+	})
+
+	suite.Run("Profile patch rejects taken names and account fields", func() {
+		w := suite.makeRequest("PATCH", "/me", map[string]string{"username": "admin"}, suite.userToken)
+		suite.Equal(http.StatusConflict, w.Code)
+
+		for _, body := range []map[string]interface{}{
+			{"role": "admin"},
+			{"password": "sneaky"},
+			{"disabled": false},
+			{"nickname": "jj"},
+			{"username": ""},
+			{},
+		} {
+			w = suite.makeRequest("PATCH", "/me", body, suite.userToken)
+			suite.Equal(http.StatusBadRequest, w.Code, "%v", body)
+		}
+
+		w = suite.makeRequest("GET", "/me", nil, suite.userToken)
+		var me domain.PublicUser
+		suite.parseResponse(w, &me)
+		suite.Equal(domain.RoleViewer, me.Role)
+	})
+
+	suite.Run("Change password requires the current one", func() {
+		w := suite.makeRequest("POST", "/me/password", map[string]string{
+			"current_password": "wrong", "new_password": "changed123",
+		}, suite.userToken)
+		suite.Equal(http.StatusForbidden, w.Code)
+
+		var problem infrastructure.Problem
+		suite.parseResponse(w, &problem)
+		suite.Contains(problem.Detail, "current password is incorrect")
+
+		w = suite.makeRequest("POST", "/me/password", map[string]string{"new_password": "changed123"}, suite.userToken)
+		suite.Equal(http.StatusBadRequest, w.Code)
+	})
+
+	suite.Run("Change password", func() {
+		w := suite.makeRequest("POST", "/me/password", map[string]string{
+			"current_password": "member-pass-1", "new_password": "changed123",
+		}, suite.userToken)
+		suite.Require().Equal(http.StatusNoContent, w.Code)
+
+		w = suite.makeRequest("POST", "/login", map[string]string{"username": "renamed", "password": "member-pass-1"}, "")
+		suite.Equal(http.StatusUnauthorized, w.Code)
+		w = suite.makeRequest("POST", "/login", map[string]string{"username": "renamed", "password": "changed123"}, "")
+		suite.Equal(http.StatusOK, w.Code)
+	})
+}
+
+// mailedTokenLine matches the line of a reset or verification mail holding
+// the token
+var mailedTokenLine = regexp.MustCompile(`(?m)^[A-Za-z0-9_-]{43}\r?$`)
+
+// readMailedTokens returns the tokens from the mail sent so far and clears
+// the mail directory
+func (suite *E2ETestSuite) readMailedTokens() []string {
+	files, err := filepath.Glob(filepath.Join(suite.mailDir, "*.eml"))
+	suite.Require().NoError(err)
+
+	var tokens []string
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		suite.Require().NoError(err)
+		suite.Require().NoError(os.Remove(file))
+
+		token := mailedTokenLine.Find(data)
+		suite.Require().NotNil(token, "mail contains a token")
+		tokens = append(tokens, strings.TrimSpace(string(token)))
+	}
+	return tokens
+}
+
+// verifyEmail gives the user behind token the email address and verifies it
+func (suite *E2ETestSuite) verifyEmail(token, email string) {
+	w := suite.makeRequest("PATCH", "/me", map[string]string{"email": email}, token)
+	suite.Require().Equal(http.StatusOK, w.Code)
+
+	tokens := suite.readMailedTokens()
+	suite.Require().Len(tokens, 1)
+	w = suite.makeRequest("POST", "/email/verify", map[string]string{"token": tokens[0]}, "")
+	suite.Require().Equal(http.StatusNoContent, w.Code)
+}
+
+// Test 12: Password Reset
+func (suite *E2ETestSuite) TestPasswordReset() {
+	suite.setupUsersForTaskTests()
+
+	suite.Run("Unknown users look the same as known ones", func() {
+		w := suite.makeRequest("POST", "/password-reset", map[string]string{"username": "nobody"}, "")
+		suite.Equal(http.StatusAccepted, w.Code)
+		suite.Empty(suite.readMailedTokens())
+
+		// Without a verified address there is nowhere to send the token
+		w = suite.makeRequest("POST", "/password-reset", map[string]string{"username": "user"}, "")
+		suite.Equal(http.StatusAccepted, w.Code)
+		suite.Empty(suite.readMailedTokens())
+
+		w = suite.makeRequest("POST", "/password-reset", map[string]string{}, "")
+		suite.Equal(http.StatusBadRequest, w.Code)
+	})
+
+	var token string
+	suite.Run("Request a reset", func() {
+		suite.verifyEmail(suite.userToken, "user@example.com")
+
+		w := suite.makeRequest("POST", "/password-reset", map[string]string{"username": "user"}, "")
+		suite.Require().Equal(http.StatusAccepted, w.Code)
+		tokens := suite.readMailedTokens()
+		suite.Require().Len(tokens, 1)
+		token = tokens[0]
+	})
+
+	suite.Run("Reset rejects bad requests", func() {
+		w := suite.makeRequest("POST", "/password-reset/confirm", map[string]string{
+			"token": "not-a-token", "new_password": "reset123",
+		}, "")
+		suite.Equal(http.StatusBadRequest, w.Code)
+
+		var problem infrastructure.Problem
+		suite.parseResponse(w, &problem)
+		suite.Contains(problem.Detail, "invalid or expired reset token")
+
+		w = suite.makeRequest("POST", "/password-reset/confirm", map[string]string{"token": token}, "")
+		suite.Equal(http.StatusBadRequest, w.Code)
+	})
+
+	suite.Run("Reset the password", func() {
+		w := suite.makeRequest("POST", "/password-reset/confirm", map[string]string{
+			"token": token, "new_password": "reset123",
+		}, "")
+		suite.Require().Equal(http.StatusNoContent, w.Code)
+
+		w = suite.makeRequest("POST", "/login", map[string]string{"username": "user", "password": "member-pass-1"}, "")
+		suite.Equal(http.StatusUnauthorized, w.Code)
+		w = suite.makeRequest("POST", "/login", map[string]string{"username": "user", "password": "reset123"}, "")
+		suite.Equal(http.StatusOK, w.Code)
+	})
+
+	suite.Run("Tokens work once", func() {
+		w := suite.makeRequest("POST", "/password-reset/confirm", map[string]string{
+			"token": token, "new_password": "again123",
+		}, "")
+		suite.Equal(http.StatusBadRequest, w.Code)
+	})
+
+	suite.Run("A reset cancels the other tokens", func() {
+		for i := 0; i < 2; i++ {
+			w := suite.makeRequest("POST", "/password-reset", map[string]string{"username": "user"}, "")
+			suite.Require().Equal(http.StatusAccepted, w.Code)
+		}
+		tokens := suite.readMailedTokens()
+		suite.Require().Len(tokens, 2)
+
+		w := suite.makeRequest("POST", "/password-reset/confirm", map[string]string{
+			"token": tokens[0], "new_password": "first123",
+		}, "")
+		suite.Require().Equal(http.StatusNoContent, w.Code)
+		w = suite.makeRequest("POST", "/password-reset/confirm", map[string]string{
+			"token": tokens[1], "new_password": "second123",
+		}, "")
+		suite.Equal(http.StatusBadRequest, w.Code)
+	})
+
+	suite.Run("Disabled users get no mail", func() {
+		w := suite.makeRequest("POST", fmt.Sprintf("/users/%s/disable", suite.regularUserID), nil, suite.adminToken)
+		suite.Require().Equal(http.StatusOK, w.Code)
+
+		w = suite.makeRequest("POST", "/password-reset", map[string]string{"username": "user"}, "")
+		suite.Equal(http.StatusAccepted, w.Code)
+		suite.Empty(suite.readMailedTokens())
+	})
+}
+
+// Test 13: Email Verification
+func (suite *E2ETestSuite) TestEmailVerification() {
+	suite.setupUsersForTaskTests()
+
+	var token string
+	suite.Run("Registering with an email sends a verification mail", func() {
+		w := suite.makeRequest("POST", "/register", map[string]string{
+			"username": "jane", "password": "sunflower-7", "email": "Jane@Example.com",
+		}, "")
+		suite.Require().Equal(http.StatusCreated, w.Code)
+
+		var jane domain.PublicUser
+		suite.parseResponse(w, &jane)
+		suite.Equal("Jane@Example.com", jane.Email)
+		suite.False(jane.EmailVerified)
+
+		tokens := suite.readMailedTokens()
+		suite.Require().Len(tokens, 1)
+		token = tokens[0]
+	})
+
+	suite.Run("Emails are validated and unique regardless of case", func() {
+		for _, email := range []string{"not-an-email", "Jane <jane@example.com>", "jane@localhost"} {
+			w := suite.makeRequest("POST", "/register", map[string]string{
+				"username": "bad-" + email, "password": "tulip-bulb-7", "email": email,
+			}, "")
+			suite.Equal(http.StatusBadRequest, w.Code, email)
+		}
+
+		w := suite.makeRequest("POST", "/register", map[string]string{
+			"username": "janet", "password": "marigold-7", "email": "jane@example.com",
+		}, "")
+		suite.Equal(http.StatusConflict, w.Code)
+
+		w = suite.makeRequest("PATCH", "/me", map[string]string{"email": "JANE@example.com"}, suite.userToken)
+		suite.Equal(http.StatusConflict, w.Code)
+		suite.Empty(suite.readMailedTokens())
+	})
+
+	suite.Run("Verify the email", func() {
+		w := suite.makeRequest("POST", "/email/verify", map[string]string{"token": "not-a-token"}, "")
+		suite.Equal(http.StatusBadRequest, w.Code)
+
+		w = suite.makeRequest("POST", "/email/verify", map[string]string{"token": token}, "")
+		suite.Require().Equal(http.StatusNoContent, w.Code)
+
+		w = suite.makeRequest("GET", "/users/jane", nil, suite.adminToken)
+		var jane domain.PublicUser
+		suite.parseResponse(w, &jane)
+		suite.True(jane.EmailVerified)
+
+		w = suite.makeRequest("POST", "/email/verify", map[string]string{"token": token}, "")
+		suite.Equal(http.StatusBadRequest, w.Code, "tokens work once")
+	})
+
+	suite.Run("Changing the email needs a new verification", func() {
+		w := suite.makeRequest("PATCH", "/me", map[string]string{"email": "user@example.com"}, suite.userToken)
+		suite.Require().Equal(http.StatusOK, w.Code)
+		first := suite.readMailedTokens()
+		suite.Require().Len(first, 1)
+
+		w = suite.makeRequest("PATCH", "/me", map[string]string{"email": "user@example.org"}, suite.userToken)
+		suite.Require().Equal(http.StatusOK, w.Code)
+		second := suite.readMailedTokens()
+		suite.Require().Len(second, 1)
+
+		// The first token was for an address the user no longer has
+		w = suite.makeRequest("POST", "/email/verify", map[string]string{"token": first[0]}, "")
+		suite.Equal(http.StatusBadRequest, w.Code)
+
+		w = suite.makeRequest("POST", "/email/verification", map[string]string{"username": "user"}, "")
+		suite.Equal(http.StatusAccepted, w.Code)
+		resent := suite.readMailedTokens()
+		suite.Require().Len(resent, 1)
+
+		w = suite.makeRequest("POST", "/email/verify", map[string]string{"token": resent[0]}, "")
+		suite.Require().Equal(http.StatusNoContent, w.Code)
+
+		w = suite.makeRequest("POST", "/email/verify", map[string]string{"token": second[0]}, "")
+		suite.Equal(http.StatusBadRequest, w.Code, "verifying cancels the other tokens")
+
+		w = suite.makeRequest("GET", "/me", nil, suite.userToken)
+		var me domain.PublicUser
+		suite.parseResponse(w, &me)
+		suite.Equal("user@example.org", me.Email)
+		suite.True(me.EmailVerified)
+	})
+
+	suite.Run("Verification requests don't reveal users", func() {
+		for _, username := range []string{"nobody", "admin", "user"} {
+			w := suite.makeRequest("POST", "/email/verification", map[string]string{"username": username}, "")
+			suite.Equal(http.StatusAccepted, w.Code, username)
+		}
+		suite.Empty(suite.readMailedTokens(), "nobody has no account, admin no address and user a verified one")
+	})
+
+	suite.Run("Login policy requires a verified email", func() {
+		suite.userUsecase.SetLoginPolicy(usecases.LoginPolicy{RequireVerifiedEmail: true})
+		defer suite.userUsecase.SetLoginPolicy(usecases.LoginPolicy{})
+
+		w := suite.makeRequest("POST", "/login", map[string]string{"username": "admin", "password": "top-secret-1"}, "")
+		suite.Equal(http.StatusForbidden, w.Code)
+		var problem infrastructure.Problem
+		suite.parseResponse(w, &problem)
+		suite.Contains(problem.Detail, "email address is not verified")
+
+		w = suite.makeRequest("POST", "/login", map[string]string{"username": "jane", "password": "sunflower-7"}, "")
+		suite.Equal(http.StatusOK, w.Code)
+	})
+}
+
+// totpCode returns the authenticator code for secret steps time steps from
+// now
+func (suite *E2ETestSuite) totpCode(secret string, steps int) string {
+	code, err := suite.totpService.Code(secret, time.Now().Add(time.Duration(steps)*30*time.Second))
+	suite.Require().NoError(err)
+	return code
+}
+
+// Test 14: Two-Factor Authentication
+func (suite *E2ETestSuite) TestTwoFactorAuthentication() {
+	suite.setupUsersForTaskTests()
+	adminLogin := map[string]string{"username": "admin", "password": "top-secret-1"}
+
+	var secret, confirmCode string
+	var recoveryCodes []string
+	suite.Run("Enroll and confirm", func() {
+		w := suite.makeRequest("POST", "/me/mfa", map[string]string{"password": "wrong"}, suite.adminToken)
+		suite.Equal(http.StatusForbidden, w.Code)
+
+		w = suite.makeRequest("POST", "/me/mfa", map[string]string{"password": "top-secret-1"}, suite.adminToken)
+		suite.Require().Equal(http.StatusCreated, w.Code)
+		var setup domain.MFASetup
+		suite.parseResponse(w, &setup)
+		suite.True(strings.HasPrefix(setup.URI, "otpauth://totp/"), setup.URI)
+		secret = setup.Secret
+
+		w = suite.makeRequest("POST", "/me/mfa/confirm", map[string]string{"code": "000000"}, suite.adminToken)
+		suite.Equal(http.StatusForbidden, w.Code)
+
+		confirmCode = suite.totpCode(secret, 0)
+		w = suite.makeRequest("POST", "/me/mfa/confirm", map[string]string{"code": confirmCode}, suite.adminToken)
+		suite.Require().Equal(http.StatusOK, w.Code)
+		var confirmed struct {
+			RecoveryCodes []string `json:"recovery_codes"`
+		}
+		suite.parseResponse(w, &confirmed)
+		suite.Len(confirmed.RecoveryCodes, usecases.RecoveryCodeCount)
+		recoveryCodes = confirmed.RecoveryCodes
+
+		w = suite.makeRequest("GET", "/me/mfa", nil, suite.adminToken)
+		var status domain.MFAStatus
+		suite.parseResponse(w, &status)
+		suite.Equal(domain.MFAStatus{Enabled: true, RecoveryCodesRemaining: usecases.RecoveryCodeCount}, status)
+	})
+
+	suite.Run("The password alone gets no token", func() {
+		w := suite.makeRequest("POST", "/login", adminLogin, "")
+		suite.Require().Equal(http.StatusOK, w.Code)
+		suite.NotContains(w.Body.String(), `"token"`)
+		var login domain.LoginResponse
+		suite.parseResponse(w, &login)
+		suite.True(login.MFARequired)
+		suite.NotEmpty(login.MFAToken)
+
+		// The MFA token is not an access token
+		w = suite.makeRequest("GET", "/me", nil, login.MFAToken)
+		suite.Equal(http.StatusUnauthorized, w.Code)
+
+		w = suite.makeRequest("POST", "/login/mfa", map[string]string{"mfa_token": login.MFAToken, "code": confirmCode}, "")
+		suite.Equal(http.StatusUnauthorized, w.Code, "the confirmation code is used up")
+
+		w = suite.makeRequest("POST", "/login/mfa", map[string]string{"mfa_token": login.MFAToken, "code": suite.totpCode(secret, 1)}, "")
+		suite.Require().Equal(http.StatusOK, w.Code)
+		var full domain.LoginResponse
+		suite.parseResponse(w, &full)
+		suite.NotEmpty(full.Token)
+		suite.NotEmpty(full.RefreshToken)
+
+		w = suite.makeRequest("GET", "/me", nil, full.Token)
+		suite.Equal(http.StatusOK, w.Code)
+	})
+
+	suite.Run("Recovery codes work once", func() {
+		w := suite.makeRequest("POST", "/login", adminLogin, "")
+		var login domain.LoginResponse
+		suite.parseResponse(w, &login)
+
+		w = suite.makeRequest("POST", "/login/mfa", map[string]string{"mfa_token": login.MFAToken, "code": recoveryCodes[0]}, "")
+		suite.Require().Equal(http.StatusOK, w.Code)
+
+		w = suite.makeRequest("POST", "/login", adminLogin, "")
+		suite.parseResponse(w, &login)
+		w = suite.makeRequest("POST", "/login/mfa", map[string]string{"mfa_token": login.MFAToken, "code": recoveryCodes[0]}, "")
+		suite.Equal(http.StatusUnauthorized, w.Code)
+
+		w = suite.makeRequest("POST", "/login/mfa", map[string]string{"code": recoveryCodes[1]}, "")
+		suite.Equal(http.StatusBadRequest, w.Code)
+	})
+
+	suite.Run("Admins can reset another user's MFA", func() {
+		w := suite.makeRequest("DELETE", fmt.Sprintf("/users/%s/mfa", suite.adminUserID), nil, suite.userToken)
+		suite.Equal(http.StatusForbidden, w.Code)
+
+		w = suite.makeRequest("DELETE", fmt.Sprintf("/users/%s/mfa", suite.adminUserID), nil, suite.adminToken)
+		suite.Require().Equal(http.StatusNoContent, w.Code)
+
+		w = suite.makeRequest("POST", "/login", adminLogin, "")
+		var login domain.LoginResponse
+		suite.parseResponse(w, &login)
+		suite.False(login.MFARequired)
+		suite.NotEmpty(login.Token)
+	})
+
+	suite.Run("Disable with the password and a code", func() {
+		w := suite.makeRequest("POST", "/me/mfa", map[string]string{"password": "member-pass-1"}, suite.userToken)
+		suite.Require().Equal(http.StatusCreated, w.Code)
+		var setup domain.MFASetup
+		suite.parseResponse(w, &setup)
+		w = suite.makeRequest("POST", "/me/mfa/confirm", map[string]string{"code": suite.totpCode(setup.Secret, 0)}, suite.userToken)
+		suite.Require().Equal(http.StatusOK, w.Code)
+
+		w = suite.makeRequest("DELETE", "/me/mfa", map[string]string{"password": "member-pass-1", "code": "000000"}, suite.userToken)
+		suite.Equal(http.StatusForbidden, w.Code)
+
+		w = suite.makeRequest("DELETE", "/me/mfa", map[string]string{"password": "member-pass-1", "code": suite.totpCode(setup.Secret, 1)}, suite.userToken)
+		suite.Require().Equal(http.StatusNoContent, w.Code)
+
+		w = suite.makeRequest("POST", "/login", map[string]string{"username": "user", "password": "member-pass-1"}, "")
+		var login domain.LoginResponse
+		suite.parseResponse(w, &login)
+		suite.NotEmpty(login.Token)
+	})
+}
+
+// Test 15: Login Lockout
+func (suite *E2ETestSuite) TestLoginLockout() {
+	suite.setupUsersForTaskTests()
+	wrong := map[string]string{"username": "user", "password": "wrong"}
+	right := map[string]string{"username": "user", "password": "member-pass-1"}
+
+	suite.Run("Repeated failures lock the username", func() {
+		for i := 0; i < usecases.DefaultLockoutPolicy.MaxFailures; i++ {
+			w := suite.makeRequest("POST", "/login", wrong, "")
+			suite.Require().Equal(http.StatusUnauthorized, w.Code)
+		}
+
+		w := suite.makeRequest("POST", "/login", right, "")
+		suite.Equal(http.StatusTooManyRequests, w.Code)
+		suite.NotEmpty(w.Header().Get("Retry-After"))
+		var problem infrastructure.Problem
+		suite.parseResponse(w, &problem)
+		suite.Equal("too many failed login attempts, try again later", problem.Detail)
+
+		// Tokens issued before keep working
+		w = suite.makeRequest("GET", "/me", nil, suite.userToken)
+		suite.Equal(http.StatusOK, w.Code)
+
+		suite.Contains(suite.auditBuf.String(), `"event":"login.locked","username":"user"`)
+	})
+
+	suite.Run("Admins unlock users", func() {
+		w := suite.makeRequest("POST", fmt.Sprintf("/users/%s/unlock", suite.regularUserID), nil, suite.userToken)
+		suite.Equal(http.StatusForbidden, w.Code)
+
+		w = suite.makeRequest("POST", fmt.Sprintf("/users/%s/unlock", suite.regularUserID), nil, suite.adminToken)
+		suite.Require().Equal(http.StatusNoContent, w.Code)
+		suite.Contains(suite.auditBuf.String(), `"event":"login.unlocked","actor_id":"`+suite.adminUserID+`","username":"user"`)
+
+		w = suite.makeRequest("POST", "/login", right, "")
+		suite.Equal(http.StatusOK, w.Code)
 	})
 }
 
 // Test 5: Complete Application Workflow
 func (suite *E2ETestSuite) TestCompleteApplicationWorkflow() {
 	suite.Run("Complete workflow from registration to task management", func() {
-		// Step 1: Register admin user
+		// Step 1: Set up the admin user
 		adminUser := map[string]string{
 			"username": "workflow_admin",
-			"password": "admin123",
+			"password": "top-secret-1",
 		}
 
-		w := suite.makeRequest("POST", "/register", adminUser, "")
+		w := suite.setupAdmin(adminUser)
 		suite.Equal(http.StatusCreated, w.Code)
 
 		var adminResponse domain.User
@@ -570,7 +1659,7 @@ func (suite *E2ETestSuite) TestCompleteApplicationWorkflow() {
 		// Step 2: Register regular user
 		regularUser := map[string]string{
 			"username": "workflow_user",
-			"password": "user123",
+			"password": "member-pass-1",
 		}
 
 		w = suite.makeRequest("POST", "/register", regularUser, "")
@@ -578,7 +1667,7 @@ func (suite *E2ETestSuite) TestCompleteApplicationWorkflow() {
 
 		var userResponse domain.User
 		suite.parseResponse(w, &userResponse)
-		suite.Equal("user", userResponse.Role)
+		suite.Equal(domain.RoleMember, userResponse.Role)
 
 		// Step 3: Login both users
 		w = suite.makeRequest("POST", "/login", adminUser, "")
@@ -600,17 +1689,18 @@ func (suite *E2ETestSuite) TestCompleteApplicationWorkflow() {
 			{
 				"title":       "Task 1",
 				"description": "First task",
-				"status":      "pending",
+				"status":      "todo",
+				"assignee_id": userResponse.ID.String(),
 			},
 			{
 				"title":       "Task 2",
 				"description": "Second task",
-				"status":      "in-progress",
+				"status":      "in_progress",
 			},
 			{
 				"title":       "Task 3",
 				"description": "Third task",
-				"status":      "completed",
+				"status":      "done",
 			},
 		}
 
@@ -624,32 +1714,32 @@ func (suite *E2ETestSuite) TestCompleteApplicationWorkflow() {
 			createdTasks = append(createdTasks, createdTask)
 		}
 
-		// Step 5: Regular user views all tasks
+		// Step 5: Regular user only sees tasks assigned to them
 		w = suite.makeRequest("GET", "/tasks", nil, userToken)
 		suite.Equal(http.StatusOK, w.Code)
 
-		var allTasks []domain.Task
+		var allTasks domain.TaskPage
 		suite.parseResponse(w, &allTasks)
-		suite.Len(allTasks, 3)
+		suite.Len(allTasks.Tasks, 1)
 
 		// Step 6: Admin updates a task
 		updatedTask := map[string]string{
 			"title":       "Task 1 - Updated",
 			"description": "First task - updated description",
-			"status":      "completed",
+			"status":      "done",
 		}
 
-		path := fmt.Sprintf("/tasks/%s", createdTasks[0].ID.Hex())
+		path := fmt.Sprintf("/tasks/%s", createdTasks[0].ID.String())
 		w = suite.makeRequest("PUT", path, updatedTask, adminToken)
 		suite.Equal(http.StatusOK, w.Code)
 
 		var updated domain.Task
 		suite.parseResponse(w, &updated)
 		suite.Equal("Task 1 - Updated", updated.Title)
-		suite.Equal("completed", updated.Status)
+		suite.Equal(domain.StatusDone, updated.Status)
 
 		// Step 7: Admin promotes regular user
-		path = fmt.Sprintf("/users/%s/promote", userResponse.ID.Hex())
+		path = fmt.Sprintf("/users/%s/promote", userResponse.ID.String())
 		w = suite.makeRequest("POST", path, nil, adminToken)
 		suite.Equal(http.StatusOK, w.Code)
 
@@ -673,7 +1763,7 @@ func (suite *E2ETestSuite) TestCompleteApplicationWorkflow() {
 		newAdminTask := map[string]string{
 			"title":       "Task by Promoted Admin",
 			"description": "Task created by newly promoted admin",
-			"status":      "pending",
+			"status":      "todo",
 		}
 
 		w = suite.makeRequest("POST", "/tasks", newAdminTask, promotedUserToken)
@@ -683,12 +1773,12 @@ func (suite *E2ETestSuite) TestCompleteApplicationWorkflow() {
 		w = suite.makeRequest("GET", "/tasks", nil, adminToken)
 		suite.Equal(http.StatusOK, w.Code)
 
-		var finalTasks []domain.Task
+		var finalTasks domain.TaskPage
 		suite.parseResponse(w, &finalTasks)
-		suite.Len(finalTasks, 4) // 3 original + 1 new
+		suite.Len(finalTasks.Tasks, 4) // 3 original + 1 new
 
 		// Step 10: Clean up by deleting a task
-		path = fmt.Sprintf("/tasks/%s", createdTasks[2].ID.Hex())
+		path = fmt.Sprintf("/tasks/%s", createdTasks[2].ID.String())
 		w = suite.makeRequest("DELETE", path, nil, adminToken)
 		suite.Equal(http.StatusNoContent, w.Code)
 
@@ -696,31 +1786,32 @@ func (suite *E2ETestSuite) TestCompleteApplicationWorkflow() {
 		w = suite.makeRequest("GET", "/tasks", nil, adminToken)
 		suite.Equal(http.StatusOK, w.Code)
 
+		finalTasks = domain.TaskPage{}
 		suite.parseResponse(w, &finalTasks)
-		suite.Len(finalTasks, 3) // Should be 3 after deletion
+		suite.Len(finalTasks.Tasks, 3) // Should be 3 after deletion
 	})
 }
 
 // Helper method to setup users for task tests
 func (suite *E2ETestSuite) setupUsersForTaskTests() {
 	if suite.adminToken == "" || suite.userToken == "" {
-		// Register admin user
+		// Set up the admin user
 		adminUser := map[string]string{
 			"username": "admin",
-			"password": "admin123",
+			"password": "top-secret-1",
 		}
 
-		w := suite.makeRequest("POST", "/register", adminUser, "")
+		w := suite.setupAdmin(adminUser)
 		suite.Require().Equal(http.StatusCreated, w.Code)
 
 		var adminResponse domain.User
 		suite.parseResponse(w, &adminResponse)
-		suite.adminUserID = adminResponse.ID.Hex()
+		suite.adminUserID = adminResponse.ID.String()
 
 		// Register regular user
 		regularUser := map[string]string{
 			"username": "user",
-			"password": "user123",
+			"password": "member-pass-1",
 		}
 
 		w = suite.makeRequest("POST", "/register", regularUser, "")
@@ -728,7 +1819,7 @@ func (suite *E2ETestSuite) setupUsersForTaskTests() {
 
 		var userResponse domain.User
 		suite.parseResponse(w, &userResponse)
-		suite.regularUserID = userResponse.ID.Hex()
+		suite.regularUserID = userResponse.ID.String()
 
 		// Login admin
 		w = suite.makeRequest("POST", "/login", adminUser, "")
@@ -763,7 +1854,7 @@ func (suite *E2ETestSuite) TestPerformanceAndStress() {
 				task := map[string]string{
 					"title":       fmt.Sprintf("Concurrent Task %d", taskNum),
 					"description": fmt.Sprintf("Task created concurrently - %d", taskNum),
-					"status":      "pending",
+					"status":      "todo",
 				}
 
 				w := suite.makeRequest("POST", "/tasks", task, suite.adminToken)
@@ -805,9 +1896,9 @@ func (suite *E2ETestSuite) TestPerformanceAndStress() {
 		w := suite.makeRequest("GET", "/tasks", nil, suite.adminToken)
 		suite.Equal(http.StatusOK, w.Code)
 
-		var allTasks []domain.Task
+		var allTasks domain.TaskPage
 		suite.parseResponse(w, &allTasks)
-		suite.GreaterOrEqual(len(allTasks), numTasks, "Should have at least the created tasks")
+		suite.GreaterOrEqual(int(allTasks.Total), numTasks, "Should have at least the created tasks")
 	})
 
 	suite.Run("Rapid authentication requests", func() {
@@ -817,7 +1908,7 @@ func (suite *E2ETestSuite) TestPerformanceAndStress() {
 
 		loginData := map[string]string{
 			"username": "admin",
-			"password": "admin123",
+			"password": "top-secret-1",
 		}
 
 		// Make rapid login requests
@@ -880,17 +1971,26 @@ func (suite *E2ETestSuite) TestDataValidationAndEdgeCases() {
 				task: map[string]interface{}{
 					"title":       "Valid Task",
 					"description": "This is a valid task",
-					"due_date":    "2024-12-31",
-					"status":      "pending",
+					"due_date":    "2024-12-31T00:00:00Z",
+					"status":      "todo",
 				},
 				expectedCode: http.StatusCreated,
 			},
+			{
+				name: "Task with free-form due date",
+				task: map[string]interface{}{
+					"title":    "Vague Task",
+					"due_date": "tomorrow-ish",
+					"status":   "todo",
+				},
+				expectedCode: http.StatusBadRequest,
+			},
 			{
 				name: "Task with empty title",
 				task: map[string]interface{}{
 					"title":       "",
 					"description": "Task with empty title",
-					"status":      "pending",
+					"status":      "todo",
 				},
 				expectedCode: http.StatusCreated, // API doesn't validate empty title
 			},
@@ -899,7 +1999,7 @@ func (suite *E2ETestSuite) TestDataValidationAndEdgeCases() {
 				task: map[string]interface{}{
 					"title":       string(make([]byte, 1000)), // Very long title
 					"description": "Task with long title",
-					"status":      "pending",
+					"status":      "todo",
 				},
 				expectedCode: http.StatusCreated,
 			},
@@ -908,7 +2008,7 @@ func (suite *E2ETestSuite) TestDataValidationAndEdgeCases() {
 				task: map[string]interface{}{
 					"title":       "Task with special chars: !@#$%^&*()",
 					"description": "Description with unicode: 你好世界 🌍",
-					"status":      "pending",
+					"status":      "todo",
 				},
 				expectedCode: http.StatusCreated,
 			},
@@ -938,7 +2038,7 @@ func (suite *E2ETestSuite) TestDataValidationAndEdgeCases() {
 				name: "Username with special characters",
 				user: map[string]interface{}{
 					"username": "user@domain.com",
-					"password": "password123",
+					"password": "correct-horse-1",
 				},
 				expectedCode: http.StatusCreated,
 			},
@@ -946,7 +2046,7 @@ func (suite *E2ETestSuite) TestDataValidationAndEdgeCases() {
 				name: "Very long username",
 				user: map[string]interface{}{
 					"username": string(make([]byte, 100)),
-					"password": "password123",
+					"password": "correct-horse-1",
 				},
 				expectedCode: http.StatusCreated,
 			},
@@ -954,7 +2054,7 @@ func (suite *E2ETestSuite) TestDataValidationAndEdgeCases() {
 				name: "Username with unicode",
 				user: map[string]interface{}{
 					"username": "用户名",
-					"password": "password123",
+					"password": "correct-horse-1",
 				},
 				expectedCode: http.StatusCreated,
 			},
@@ -985,6 +2085,9 @@ func (suite *E2ETestSuite) TestDataValidationAndEdgeCases() {
 
 // Test 8: Database State Consistency
 func (suite *E2ETestSuite) TestDatabaseStateConsistency() {
+	if suite.client == nil {
+		suite.T().Skip("inspects MongoDB directly, requires MONGODB_URI")
+	}
 	suite.setupUsersForTaskTests()
 
 	suite.Run("Verify database state after operations", func() {
@@ -992,7 +2095,7 @@ func (suite *E2ETestSuite) TestDatabaseStateConsistency() {
 		newTask := map[string]string{
 			"title":       "Consistency Test Task",
 			"description": "Testing database consistency",
-			"status":      "pending",
+			"status":      "todo",
 		}
 
 		w := suite.makeRequest("POST", "/tasks", newTask, suite.adminToken)
@@ -1005,34 +2108,37 @@ func (suite *E2ETestSuite) TestDatabaseStateConsistency() {
 		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
 
-		var dbTask domain.Task
-		err := suite.taskColl.FindOne(ctx, bson.M{"_id": createdTask.ID}).Decode(&dbTask)
+		taskID, err := primitive.ObjectIDFromHex(createdTask.ID.String())
+		suite.Require().NoError(err, "Task IDs should be stored as ObjectIDs")
+
+		var dbTask bson.M
+		err = suite.taskColl.FindOne(ctx, bson.M{"_id": taskID}).Decode(&dbTask)
 		suite.NoError(err, "Task should exist in database")
-		suite.Equal(createdTask.Title, dbTask.Title)
+		suite.Equal(createdTask.Title, dbTask["title"])
 
 		// Update the task
 		updatedTask := map[string]string{
 			"title":       "Updated Consistency Test Task",
 			"description": "Updated description",
-			"status":      "completed",
+			"status":      "done",
 		}
 
-		path := fmt.Sprintf("/tasks/%s", createdTask.ID.Hex())
+		path := fmt.Sprintf("/tasks/%s", createdTask.ID.String())
 		w = suite.makeRequest("PUT", path, updatedTask, suite.adminToken)
 		suite.Equal(http.StatusOK, w.Code)
 
 		// Verify update in database
-		err = suite.taskColl.FindOne(ctx, bson.M{"_id": createdTask.ID}).Decode(&dbTask)
+		err = suite.taskColl.FindOne(ctx, bson.M{"_id": taskID}).Decode(&dbTask)
 		suite.NoError(err, "Updated task should exist in database")
-		suite.Equal("Updated Consistency Test Task", dbTask.Title)
-		suite.Equal("completed", dbTask.Status)
+		suite.Equal("Updated Consistency Test Task", dbTask["title"])
+		suite.Equal(string(domain.StatusDone), dbTask["status"])
 
 		// Delete the task
 		w = suite.makeRequest("DELETE", path, nil, suite.adminToken)
 		suite.Equal(http.StatusNoContent, w.Code)
 
 		// Verify deletion in database
-		err = suite.taskColl.FindOne(ctx, bson.M{"_id": createdTask.ID}).Decode(&dbTask)
+		err = suite.taskColl.FindOne(ctx, bson.M{"_id": taskID}).Decode(&dbTask)
 		suite.Error(err, "Deleted task should not exist in database")
 		suite.Equal(mongo.ErrNoDocuments, err)
 	})
@@ -1048,7 +2154,7 @@ func (suite *E2ETestSuite) TestDatabaseStateConsistency() {
 		// Register a new user
 		newUser := map[string]string{
 			"username": "consistency_user",
-			"password": "password123",
+			"password": "correct-horse-1",
 		}
 
 		w := suite.makeRequest("POST", "/register", newUser, "")
@@ -1063,21 +2169,24 @@ func (suite *E2ETestSuite) TestDatabaseStateConsistency() {
 		suite.Equal(initialCount+1, newCount)
 
 		// Verify user exists in database with hashed password
-		var dbUser domain.User
-		err = suite.userColl.FindOne(ctx, bson.M{"_id": createdUser.ID}).Decode(&dbUser)
+		userID, err := primitive.ObjectIDFromHex(createdUser.ID.String())
+		suite.Require().NoError(err, "User IDs should be stored as ObjectIDs")
+
+		var dbUser bson.M
+		err = suite.userColl.FindOne(ctx, bson.M{"_id": userID}).Decode(&dbUser)
 		suite.NoError(err)
-		suite.Equal(createdUser.Username, dbUser.Username)
-		suite.NotEqual("password123", dbUser.Password) // Should be hashed
-		suite.NotEmpty(dbUser.Password)
+		suite.Equal(createdUser.Username, dbUser["username"])
+		suite.NotEqual("correct-horse-1", dbUser["password"]) // Should be hashed
+		suite.NotEmpty(dbUser["password"])
 
 		// Promote the user
-		path := fmt.Sprintf("/users/%s/promote", createdUser.ID.Hex())
+		path := fmt.Sprintf("/users/%s/promote", createdUser.ID.String())
 		w = suite.makeRequest("POST", path, nil, suite.adminToken)
 		suite.Equal(http.StatusOK, w.Code)
 
 		// Verify promotion in database
-		err = suite.userColl.FindOne(ctx, bson.M{"_id": createdUser.ID}).Decode(&dbUser)
+		err = suite.userColl.FindOne(ctx, bson.M{"_id": userID}).Decode(&dbUser)
 		suite.NoError(err)
-		suite.Equal("admin", dbUser.Role)
+		suite.Equal("admin", dbUser["role"])
 	})
 }
\ No newline at end of file
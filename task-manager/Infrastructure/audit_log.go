@@ -0,0 +1,46 @@
+package infrastructure
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+	"task-manager/Domain"
+)
+
+// WriterAuditLog writes each audit entry as one line of JSON, which log
+// shippers can pick up without further parsing
+type WriterAuditLog struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func NewWriterAuditLog(w io.Writer) *WriterAuditLog {
+	return &WriterAuditLog{w: w}
+}
+
+func (l *WriterAuditLog) Record(entry domain.AuditEntry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, err = l.w.Write(append(line, '\n'))
+	return err
+}
+
+// OpenAuditLog appends audit entries to the file at path, or writes them to
+// standard error when path is empty
+func OpenAuditLog(path string) (domain.AuditLog, error) {
+	if path == "" {
+		return NewWriterAuditLog(os.Stderr), nil
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	return NewWriterAuditLog(file), nil
+}
@@ -1,20 +1,31 @@
 package infrastructure
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
 	"strings"
 	"task-manager/Domain"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
 type AuthMiddleware struct {
 	jwtService domain.JWTService
+	tokenRepo  domain.TokenRepository
+	userRepo   domain.UserRepository
 }
 
-func NewAuthMiddleware(jwtService domain.JWTService) *AuthMiddleware {
+func NewAuthMiddleware(
+	jwtService domain.JWTService,
+	tokenRepo domain.TokenRepository,
+	userRepo domain.UserRepository,
+) *AuthMiddleware {
 	return &AuthMiddleware{
 		jwtService: jwtService,
+		tokenRepo:  tokenRepo,
+		userRepo:   userRepo,
 	}
 }
 
@@ -22,34 +33,69 @@ func (am *AuthMiddleware) AuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		header := c.GetHeader("Authorization")
 		if header == "" || !strings.HasPrefix(header, "Bearer ") {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header missing or invalid"})
-			c.Abort()
+			AbortWithProblem(c, http.StatusUnauthorized, "Authorization header missing or invalid")
 			return
 		}
 
 		tokenString := strings.TrimPrefix(header, "Bearer ")
 		claims, err := am.jwtService.ValidateToken(tokenString)
 		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
-			c.Abort()
+			AbortWithProblem(c, http.StatusUnauthorized, err.Error())
 			return
 		}
 
-		c.Set("user_id", claims["_id"])
-		c.Set("username", claims["username"])
-		c.Set("role", claims["role"])
+		// Logged-out tokens stay valid until they expire unless checked here
+		tokenID, _ := claims["jti"].(string)
+		revoked, err := am.tokenRepo.IsAccessTokenRevoked(c.Request.Context(), tokenID)
+		if err != nil {
+			AbortWithError(c, err)
+			return
+		}
+		if revoked {
+			AbortWithProblem(c, http.StatusUnauthorized, "token has been revoked")
+			return
+		}
+
+		// The stored user decides the role, so role changes, disabling and
+		// deletion take effect without waiting for the token to expire
+		userID, _ := claims["_id"].(string)
+		user, err := am.userRepo.GetUserByID(c.Request.Context(), userID)
+		if errors.Is(err, domain.ErrNotFound) || errors.Is(err, domain.ErrInvalidID) {
+			AbortWithProblem(c, http.StatusUnauthorized, "user no longer exists")
+			return
+		}
+		if err != nil {
+			AbortWithError(c, err)
+			return
+		}
+		if user.Disabled {
+			AbortWithError(c, domain.ErrAccountDisabled)
+			return
+		}
+
+		c.Set("user_id", user.ID.String())
+		c.Set("username", user.Username)
+		c.Set("role", user.Role)
+		c.Set("token_id", tokenID)
+		if exp, ok := claims["exp"].(float64); ok {
+			c.Set("token_expires_at", time.Unix(int64(exp), 0).UTC())
+		}
 		c.Next()
 	}
 }
 
-func (am *AuthMiddleware) AdminOnly() gin.HandlerFunc {
+// RequirePermission lets the request through when the caller's role grants
+// any of the permissions. Usecases still check ":own" permissions against
+// the task, so passing here only means the action may be allowed.
+func (am *AuthMiddleware) RequirePermission(permissions ...domain.Permission) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		role, exists := c.Get("role")
-		if !exists || role != "admin" {
-			c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
-			c.Abort()
-			return
+		role := c.GetString("role")
+		for _, permission := range permissions {
+			if domain.HasPermission(role, permission) {
+				c.Next()
+				return
+			}
 		}
-		c.Next()
+		AbortWithProblem(c, http.StatusForbidden, fmt.Sprintf("permission %s required", permissions[0]))
 	}
 }
\ No newline at end of file
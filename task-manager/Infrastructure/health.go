@@ -0,0 +1,128 @@
+package infrastructure
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Health statuses reported by /readyz
+const (
+	HealthOK          = "ok"
+	HealthFailed      = "failed"
+	HealthReady       = "ready"
+	HealthUnavailable = "unavailable"
+)
+
+// HealthCheck returns nil when a component can serve requests, or why not
+type HealthCheck func(ctx context.Context) error
+
+// CheckResult is the outcome of one check
+type CheckResult struct {
+	Status     string `json:"status"`
+	Error      string `json:"error,omitempty"`
+	DurationMS int64  `json:"duration_ms"`
+}
+
+// HealthReport is the body of /readyz
+type HealthReport struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks"`
+}
+
+// HealthRegistry holds the readiness checks components register, such as
+// a database ping. /healthz only says the process is up, while /readyz runs
+// every check and is ready when all of them pass.
+type HealthRegistry struct {
+	timeout time.Duration
+
+	mu     sync.RWMutex
+	checks map[string]HealthCheck
+}
+
+// NewHealthRegistry gives each check timeout to finish
+func NewHealthRegistry(timeout time.Duration) *HealthRegistry {
+	return &HealthRegistry{timeout: timeout, checks: map[string]HealthCheck{}}
+}
+
+// Register adds a check under name, replacing one registered before
+func (h *HealthRegistry) Register(name string, check HealthCheck) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.checks[name] = check
+}
+
+// Check runs every check at once, each under the registry's timeout
+func (h *HealthRegistry) Check(ctx context.Context) HealthReport {
+	h.mu.RLock()
+	checks := make(map[string]HealthCheck, len(h.checks))
+	for name, check := range h.checks {
+		checks[name] = check
+	}
+	h.mu.RUnlock()
+
+	report := HealthReport{Status: HealthReady, Checks: make(map[string]CheckResult, len(checks))}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, check := range checks {
+		wg.Add(1)
+		go func(name string, check HealthCheck) {
+			defer wg.Done()
+			result := h.run(ctx, check)
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.Checks[name] = result
+			if result.Status != HealthOK {
+				report.Status = HealthUnavailable
+			}
+		}(name, check)
+	}
+	wg.Wait()
+
+	return report
+}
+
+// run runs one check, reporting a check that doesn't return in time as
+// failed without waiting for it
+func (h *HealthRegistry) run(ctx context.Context, check HealthCheck) CheckResult {
+	ctx, cancel := context.WithTimeout(ctx, h.timeout)
+	defer cancel()
+
+	started := time.Now()
+	done := make(chan error, 1)
+	go func() { done <- check(ctx) }()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	result := CheckResult{Status: HealthOK, DurationMS: time.Since(started).Milliseconds()}
+	if err != nil {
+		result.Status = HealthFailed
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// Healthz answers as long as the process can serve HTTP
+func (h *HealthRegistry) Healthz(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": HealthOK})
+}
+
+// Readyz reports every check, with 503 Service Unavailable unless all pass
+func (h *HealthRegistry) Readyz(c *gin.Context) {
+	report := h.Check(c.Request.Context())
+	status := http.StatusOK
+	if report.Status != HealthReady {
+		status = http.StatusServiceUnavailable
+	}
+	c.Header("Cache-Control", "no-store")
+	c.JSON(status, report)
+}
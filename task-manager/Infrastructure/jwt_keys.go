@@ -0,0 +1,278 @@
+package infrastructure
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// ErrNoSigningKey is returned by LoadKeySet when no key is configured
+var ErrNoSigningKey = errors.New("no JWT signing key configured")
+
+// minHMACSecret is the shortest HS256 secret accepted, the size of the hash
+const minHMACSecret = 32
+
+// minRSABits is the smallest RSA modulus accepted for RS256
+const minRSABits = 2048
+
+// SigningKey is a key tokens are signed or verified with. Its ID is the
+// RFC 7638 thumbprint of the key, so it stays the same wherever the key is
+// loaded. Keys kept only to verify tokens after a rotation can't sign.
+type SigningKey struct {
+	ID      string
+	Method  jwt.SigningMethod
+	private interface{}
+	public  interface{}
+	jwk     JWK
+}
+
+// CanSign reports whether the key has a private part
+func (k SigningKey) CanSign() bool {
+	return k.private != nil
+}
+
+// NewHMACKey returns an HS256 key. The secret is shared by everyone who
+// verifies tokens, so it is never published in the JWKS.
+func NewHMACKey(secret []byte) (SigningKey, error) {
+	if len(secret) < minHMACSecret {
+		return SigningKey{}, fmt.Errorf("JWT secret must be at least %d bytes", minHMACSecret)
+	}
+
+	thumbprint := `{"k":"` + base64.RawURLEncoding.EncodeToString(secret) + `","kty":"oct"}`
+	return SigningKey{
+		ID:      keyID(thumbprint),
+		Method:  jwt.SigningMethodHS256,
+		private: secret,
+		public:  secret,
+	}, nil
+}
+
+// GenerateEd25519Key returns a new random EdDSA key
+func GenerateEd25519Key() (SigningKey, error) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return SigningKey{}, err
+	}
+	return newAsymmetricKey(private, public)
+}
+
+// ParseKeyPEM reads an RSA or Ed25519 key. Private keys may be PKCS#1 or
+// PKCS#8; public keys, which can only verify, may be PKCS#1 or PKIX.
+func ParseKeyPEM(data []byte) (SigningKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return SigningKey{}, errors.New("no PEM block found")
+	}
+
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		private, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+		if err != nil {
+			return SigningKey{}, err
+		}
+		return newAsymmetricKey(private, &private.PublicKey)
+	case "PRIVATE KEY":
+		parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return SigningKey{}, err
+		}
+		switch private := parsed.(type) {
+		case *rsa.PrivateKey:
+			return newAsymmetricKey(private, &private.PublicKey)
+		case ed25519.PrivateKey:
+			return newAsymmetricKey(private, private.Public())
+		}
+		return SigningKey{}, fmt.Errorf("unsupported private key type %T", parsed)
+	case "RSA PUBLIC KEY":
+		public, err := x509.ParsePKCS1PublicKey(block.Bytes)
+		if err != nil {
+			return SigningKey{}, err
+		}
+		return newAsymmetricKey(nil, public)
+	case "PUBLIC KEY":
+		public, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return SigningKey{}, err
+		}
+		return newAsymmetricKey(nil, public)
+	}
+
+	return SigningKey{}, fmt.Errorf("unsupported PEM block %q", block.Type)
+}
+
+// newAsymmetricKey picks the algorithm from the public key type. A nil
+// private key makes a verification-only key.
+func newAsymmetricKey(private, public interface{}) (SigningKey, error) {
+	var key SigningKey
+	var thumbprint string
+
+	switch public := public.(type) {
+	case *rsa.PublicKey:
+		if public.N.BitLen() < minRSABits {
+			return SigningKey{}, fmt.Errorf("RSA key must be at least %d bits", minRSABits)
+		}
+		n := base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+		e := base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+		key.Method = jwt.SigningMethodRS256
+		key.jwk = JWK{KeyType: "RSA", N: n, E: e}
+		thumbprint = `{"e":"` + e + `","kty":"RSA","n":"` + n + `"}`
+	case ed25519.PublicKey:
+		x := base64.RawURLEncoding.EncodeToString(public)
+		key.Method = jwt.SigningMethodEdDSA
+		key.jwk = JWK{KeyType: "OKP", Curve: "Ed25519", X: x}
+		thumbprint = `{"crv":"Ed25519","kty":"OKP","x":"` + x + `"}`
+	default:
+		return SigningKey{}, fmt.Errorf("unsupported public key type %T", public)
+	}
+
+	key.ID = keyID(thumbprint)
+	key.private = private
+	key.public = public
+	key.jwk.KeyID = key.ID
+	key.jwk.Use = "sig"
+	key.jwk.Algorithm = key.Method.Alg()
+	return key, nil
+}
+
+// keyID hashes the canonical JWK members of a key as in RFC 7638
+func keyID(thumbprint string) string {
+	sum := sha256.Sum256([]byte(thumbprint))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// KeySet holds the key new tokens are signed with and the other keys tokens
+// are accepted from. Rotating a key means publishing the next key for
+// verification, signing with it once caches have picked it up, and keeping
+// the old key for verification until the tokens it signed have expired.
+type KeySet struct {
+	signing SigningKey
+	keys    map[string]SigningKey
+}
+
+func NewKeySet(signing SigningKey, verification ...SigningKey) (*KeySet, error) {
+	if !signing.CanSign() {
+		return nil, errors.New("JWT signing key has no private key")
+	}
+
+	keys := map[string]SigningKey{signing.ID: signing}
+	for _, key := range verification {
+		if _, ok := keys[key.ID]; !ok {
+			keys[key.ID] = key
+		}
+	}
+
+	return &KeySet{signing: signing, keys: keys}, nil
+}
+
+// KeySources says where the JWT keys come from
+type KeySources struct {
+	// PrivateKeyFile, or PrivateKey holding the PEM itself, is the RSA
+	// (RS256) or Ed25519 (EdDSA) key signing new tokens
+	PrivateKeyFile string
+	PrivateKey     string
+	// Secret is the HS256 secret, used when no private key is set
+	Secret string
+	// VerificationKeyFiles are PEM files of upcoming or rotated-out keys,
+	// accepted and published but not signed with. Public keys are enough.
+	VerificationKeyFiles []string
+}
+
+// LoadKeySet reads the keys the sources name. It returns ErrNoSigningKey
+// when neither a private key nor a secret is set.
+func LoadKeySet(sources KeySources) (*KeySet, error) {
+	var signing SigningKey
+	var err error
+
+	switch {
+	case sources.PrivateKeyFile != "":
+		signing, err = readKeyFile(sources.PrivateKeyFile)
+	case sources.PrivateKey != "":
+		signing, err = ParseKeyPEM([]byte(sources.PrivateKey))
+		if err != nil {
+			err = fmt.Errorf("private key: %w", err)
+		}
+	case sources.Secret != "":
+		signing, err = NewHMACKey([]byte(sources.Secret))
+	default:
+		return nil, ErrNoSigningKey
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var verification []SigningKey
+	for _, path := range sources.VerificationKeyFiles {
+		if path = strings.TrimSpace(path); path == "" {
+			continue
+		}
+		key, err := readKeyFile(path)
+		if err != nil {
+			return nil, err
+		}
+		verification = append(verification, key)
+	}
+
+	return NewKeySet(signing, verification...)
+}
+
+func readKeyFile(path string) (SigningKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return SigningKey{}, err
+	}
+
+	key, err := ParseKeyPEM(data)
+	if err != nil {
+		return SigningKey{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return key, nil
+}
+
+// JWK is the public part of a signing key in RFC 7517 form
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+	Curve     string `json:"crv,omitempty"`
+	X         string `json:"x,omitempty"`
+}
+
+// JWKS is the document served at /.well-known/jwks.json
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS lists the public keys tokens are accepted from, the signing key
+// first. HMAC keys are secret and left out.
+func (ks *KeySet) JWKS() JWKS {
+	jwks := JWKS{Keys: []JWK{}}
+	if ks.signing.jwk.KeyType != "" {
+		jwks.Keys = append(jwks.Keys, ks.signing.jwk)
+	}
+
+	var previous []JWK
+	for id, key := range ks.keys {
+		if id != ks.signing.ID && key.jwk.KeyType != "" {
+			previous = append(previous, key.jwk)
+		}
+	}
+	sort.Slice(previous, func(i, j int) bool { return previous[i].KeyID < previous[j].KeyID })
+
+	jwks.Keys = append(jwks.Keys, previous...)
+	return jwks
+}
@@ -1,28 +1,57 @@
 package infrastructure
 
 import (
+	"context"
 	"errors"
+	"net/http"
+	"task-manager/Domain"
+	"time"
 
+	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v4"
 )
 
-// For development only. In production, use a secure secret management approach.
-var jwtSecret = []byte("your_dev_secret_key")
+// AccessTokenTTL is how long an access token is accepted after it is issued.
+// Clients use their refresh token to get a new one.
+const AccessTokenTTL = 15 * time.Minute
 
-type JWTService struct{}
+type JWTService struct {
+	keys *KeySet
+	ttl  time.Duration
+}
+
+func NewJWTService(keys *KeySet) *JWTService {
+	return &JWTService{keys: keys, ttl: AccessTokenTTL}
+}
 
-func NewJWTService() *JWTService {
-	return &JWTService{}
+// NewJWTServiceWithTTL issues access tokens that expire after ttl
+func NewJWTServiceWithTTL(keys *KeySet, ttl time.Duration) *JWTService {
+	return &JWTService{keys: keys, ttl: ttl}
+}
+
+// CheckKeys is a readiness check that a key new tokens can be signed with
+// is loaded
+func (j *JWTService) CheckKeys(_ context.Context) error {
+	if j.keys == nil || !j.keys.signing.CanSign() {
+		return errors.New("no JWT signing key loaded")
+	}
+	return nil
 }
 
 func (j *JWTService) GenerateToken(userID, username, role string) (string, error) {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+	issuedAt := time.Now()
+	signing := j.keys.signing
+	token := jwt.NewWithClaims(signing.Method, jwt.MapClaims{
 		"_id":      userID,
 		"username": username,
 		"role":     role,
+		"jti":      domain.NewID().String(),
+		"iat":      issuedAt.Unix(),
+		"exp":      issuedAt.Add(j.ttl).Unix(),
 	})
+	token.Header["kid"] = signing.ID
 
-	tokenString, err := token.SignedString(jwtSecret)
+	tokenString, err := token.SignedString(signing.private)
 	if err != nil {
 		return "", err
 	}
@@ -30,22 +59,42 @@ func (j *JWTService) GenerateToken(userID, username, role string) (string, error
 	return tokenString, nil
 }
 
+// ValidateToken checks the signature and expiry. The key is picked by the
+// token's kid and must match the token's algorithm, so a public key can't
+// be used as an HMAC secret. Tokens without an expiry or ID, issued before
+// tokens expired, are rejected so they cannot live forever.
 func (j *JWTService) ValidateToken(tokenString string) (map[string]interface{}, error) {
 	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+		kid, _ := token.Header["kid"].(string)
+		key, ok := j.keys.keys[kid]
+		if !ok || token.Method.Alg() != key.Method.Alg() {
 			return nil, jwt.ErrSignatureInvalid
 		}
-		return jwtSecret, nil
+		return key.public, nil
 	})
 
 	if err != nil || !token.Valid {
-		return nil, errors.New("invalid or expired token")
+		return nil, domain.Unauthorized("invalid or expired token")
 	}
 
 	claims, ok := token.Claims.(jwt.MapClaims)
 	if !ok {
-		return nil, errors.New("invalid token claims")
+		return nil, domain.Unauthorized("invalid token claims")
+	}
+
+	if !claims.VerifyExpiresAt(time.Now().Unix(), true) {
+		return nil, domain.Unauthorized("invalid or expired token")
+	}
+	if jti, _ := claims["jti"].(string); jti == "" {
+		return nil, domain.Unauthorized("invalid token claims")
 	}
 
 	return claims, nil
-}
\ No newline at end of file
+}
+
+// JWKS serves the public keys other services verify tokens with. Caches
+// may keep it for a few minutes, so publish a new key before signing with it.
+func (j *JWTService) JWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, j.keys.JWKS())
+}
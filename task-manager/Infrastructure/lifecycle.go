@@ -0,0 +1,138 @@
+package infrastructure
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Lifecycle runs the HTTP server alongside background workers and shuts
+// them down in order: the server stops accepting connections and drains the
+// ones in flight, then the workers are stopped, then the resources
+// registered with OnStop are closed, last registered first. Everything has
+// to be done within the shutdown timeout.
+type Lifecycle struct {
+	ctx     context.Context
+	cancel  context.CancelFunc
+	workers sync.WaitGroup
+
+	mu      sync.Mutex
+	errs    []error
+	closers []closer
+}
+
+type closer struct {
+	name  string
+	close func(ctx context.Context) error
+}
+
+func NewLifecycle() *Lifecycle {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Lifecycle{ctx: ctx, cancel: cancel}
+}
+
+// Go runs worker in the background until shutdown cancels its context. A
+// worker returning an error before then shuts everything down, and Run
+// reports the error.
+func (l *Lifecycle) Go(name string, worker func(ctx context.Context) error) {
+	l.workers.Add(1)
+	go func() {
+		defer l.workers.Done()
+		if err := worker(l.ctx); err != nil && l.ctx.Err() == nil {
+			l.fail(fmt.Errorf("%s: %w", name, err))
+		}
+	}()
+}
+
+// Every runs task in the background once per interval until shutdown. A
+// failing run is logged and retried at the next interval rather than
+// shutting the server down.
+func (l *Lifecycle) Every(name string, interval time.Duration, task func(ctx context.Context) error) {
+	l.Go(name, func(ctx context.Context) error {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-ticker.C:
+				if err := task(ctx); err != nil && ctx.Err() == nil {
+					log.Printf("%s: %v", name, err)
+				}
+			}
+		}
+	})
+}
+
+// OnStop registers a resource to close once the server and workers have
+// stopped, such as a database client. Resources are closed in the reverse
+// order of registration, so register them as they are opened.
+func (l *Lifecycle) OnStop(name string, close func(ctx context.Context) error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.closers = append(l.closers, closer{name: name, close: close})
+}
+
+// fail records an error and starts the shutdown
+func (l *Lifecycle) fail(err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.errs = append(l.errs, err)
+	l.cancel()
+}
+
+// Run serves HTTP on listener until ctx is done, such as on a signal, or
+// the server or a worker fails, then shuts everything down within
+// shutdownTimeout. It returns every error met on the way, so callers can
+// exit with a non-zero status.
+func (l *Lifecycle) Run(ctx context.Context, server *http.Server, listener net.Listener, shutdownTimeout time.Duration) error {
+	go func() {
+		if err := server.Serve(listener); !errors.Is(err, http.ErrServerClosed) {
+			l.fail(fmt.Errorf("http server: %w", err))
+		}
+	}()
+
+	select {
+	case <-ctx.Done():
+	case <-l.ctx.Done():
+	}
+	return l.shutdown(server, shutdownTimeout)
+}
+
+func (l *Lifecycle) shutdown(server *http.Server, timeout time.Duration) error {
+	log.Printf("Shutting down, waiting up to %s for requests in flight", timeout)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var errs []error
+	if err := server.Shutdown(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("draining connections: %w", err))
+		server.Close()
+	}
+
+	l.cancel()
+	stopped := make(chan struct{})
+	go func() {
+		l.workers.Wait()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		errs = append(errs, fmt.Errorf("stopping background workers: %w", ctx.Err()))
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for i := len(l.closers) - 1; i >= 0; i-- {
+		if err := l.closers[i].close(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("closing %s: %w", l.closers[i].name, err))
+		}
+	}
+	return errors.Join(append(l.errs, errs...)...)
+}
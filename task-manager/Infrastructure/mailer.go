@@ -0,0 +1,159 @@
+package infrastructure
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/smtp"
+	"os"
+	"strconv"
+	"strings"
+	"task-manager/Domain"
+	"time"
+)
+
+// MailSettings configures how mail is sent
+type MailSettings struct {
+	// SMTPHost is the server mail is sent through. Without one, mail is
+	// written to files in Dir, which is only meant for local runs.
+	SMTPHost string
+	SMTPPort string
+	// SMTPUsername and SMTPPassword are the credentials, if the server
+	// needs them
+	SMTPUsername string
+	SMTPPassword string
+	// From is the sender address
+	From string
+	Dir  string
+}
+
+// DefaultMailSettings write mail to files, and send through the mail
+// submission port, which upgrades to TLS, once a host is set
+var DefaultMailSettings = MailSettings{
+	SMTPPort: "587",
+	From:     "no-reply@localhost",
+	Dir:      "mail",
+}
+
+// Validate checks the sender is set and the port is a port number
+func (s MailSettings) Validate() error {
+	if s.From == "" {
+		return errors.New("sender address is required")
+	}
+	if s.SMTPHost == "" {
+		if s.Dir == "" {
+			return errors.New("mail directory is required without an SMTP host")
+		}
+		return nil
+	}
+	if port, err := strconv.Atoi(s.SMTPPort); err != nil || port < 1 || port > 65535 {
+		return fmt.Errorf("SMTP port %q is not a number from 1 to 65535", s.SMTPPort)
+	}
+	return nil
+}
+
+// NewMailer sends mail through the SMTP host, or writes it to files in the
+// directory when no host is set
+func NewMailer(settings MailSettings) (domain.Mailer, error) {
+	if settings.SMTPHost == "" {
+		return NewFileMailer(settings.Dir, settings.From), nil
+	}
+	return NewSMTPMailer(net.JoinHostPort(settings.SMTPHost, settings.SMTPPort), settings.SMTPUsername, settings.SMTPPassword, settings.From)
+}
+
+// SMTPMailer sends mail through an SMTP server. The connection is upgraded
+// with STARTTLS when the server offers it; credentials are only sent over
+// TLS or to localhost.
+type SMTPMailer struct {
+	addr string
+	from string
+	auth smtp.Auth
+}
+
+// NewSMTPMailer sends mail from the given address through the server at
+// addr, in host:port form. An empty username sends without logging in.
+func NewSMTPMailer(addr, username, password, from string) (*SMTPMailer, error) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, fmt.Errorf("SMTP address %q: %w", addr, err)
+	}
+	if from == "" {
+		return nil, errors.New("mail sender address is required")
+	}
+
+	mailer := &SMTPMailer{addr: addr, from: from}
+	if username != "" {
+		mailer.auth = smtp.PlainAuth("", username, password, host)
+	}
+	return mailer, nil
+}
+
+func (m *SMTPMailer) Send(message domain.Message) error {
+	data, err := formatMessage(m.from, message)
+	if err != nil {
+		return err
+	}
+	return smtp.SendMail(m.addr, m.auth, m.from, []string{message.To}, data)
+}
+
+// FileMailer writes each message to its own .eml file in a directory
+// instead of sending it, for development and tests
+type FileMailer struct {
+	dir  string
+	from string
+}
+
+func NewFileMailer(dir, from string) *FileMailer {
+	return &FileMailer{dir: dir, from: from}
+}
+
+func (m *FileMailer) Send(message domain.Message) error {
+	data, err := formatMessage(m.from, message)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(m.dir, 0o700); err != nil {
+		return err
+	}
+	file, err := os.CreateTemp(m.dir, time.Now().UTC().Format("20060102T150405")+"-*.eml")
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if _, err := file.Write(data); err != nil {
+		return err
+	}
+	log.Printf("mail to %s written to %s", message.To, file.Name())
+	return nil
+}
+
+// formatMessage renders a plain text RFC 5322 message. Header values with
+// line breaks are rejected so they can't add headers or recipients.
+func formatMessage(from string, message domain.Message) ([]byte, error) {
+	for _, value := range []string{from, message.To, message.Subject} {
+		if strings.ContainsAny(value, "\r\n") {
+			return nil, errors.New("mail header contains a line break")
+		}
+	}
+	if message.To == "" {
+		return nil, errors.New("mail recipient is required")
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", message.To)
+	fmt.Fprintf(&buf, "Subject: %s\r\n", message.Subject)
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: 8bit\r\n")
+	buf.WriteString("\r\n")
+
+	body := strings.ReplaceAll(message.Body, "\r\n", "\n")
+	buf.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+	return buf.Bytes(), nil
+}
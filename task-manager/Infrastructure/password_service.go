@@ -1,17 +1,110 @@
 package infrastructure
 
 import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
 	"golang.org/x/crypto/bcrypt"
 )
 
-type PasswordService struct{}
+// Password hashing algorithms
+const (
+	AlgorithmBcrypt   = "bcrypt"
+	AlgorithmArgon2id = "argon2id"
+)
+
+// ErrPasswordMismatch is returned when a password doesn't match its hash
+var ErrPasswordMismatch = errors.New("password does not match")
+
+// Argon2Params are the Argon2id parameters. Every hash records them, so
+// changing them leaves existing hashes working.
+type Argon2Params struct {
+	// Time is the number of passes over the memory
+	Time uint32
+	// Memory is the memory used in KiB
+	Memory  uint32
+	Threads uint8
+	SaltLen uint32
+	KeyLen  uint32
+}
+
+// DefaultArgon2Params follow the second recommended option of RFC 9106 with
+// fewer threads
+var DefaultArgon2Params = Argon2Params{
+	Time:    3,
+	Memory:  64 * 1024,
+	Threads: 2,
+	SaltLen: 16,
+	KeyLen:  32,
+}
+
+// PasswordHashing sets how new passwords are hashed
+type PasswordHashing struct {
+	// Algorithm is AlgorithmBcrypt or AlgorithmArgon2id
+	Algorithm  string
+	BcryptCost int
+	Argon2     Argon2Params
+}
 
+// PasswordService hashes new passwords with the configured algorithm and
+// checks passwords against hashes of either algorithm
+type PasswordService struct {
+	hashing PasswordHashing
+}
+
+// NewPasswordService hashes with bcrypt at its default cost
 func NewPasswordService() *PasswordService {
-	return &PasswordService{}
+	return &PasswordService{hashing: DefaultPasswordHashing}
+}
+
+// Validate checks the algorithm is known and its parameters are in range
+func (h PasswordHashing) Validate() error {
+	switch h.Algorithm {
+	case AlgorithmBcrypt:
+		if h.BcryptCost < bcrypt.MinCost || h.BcryptCost > bcrypt.MaxCost {
+			return fmt.Errorf("bcrypt cost %d is outside %d to %d", h.BcryptCost, bcrypt.MinCost, bcrypt.MaxCost)
+		}
+	case AlgorithmArgon2id:
+		params := h.Argon2
+		if params.Time == 0 || params.Memory < 8*uint32(params.Threads) || params.Threads == 0 {
+			return errors.New("argon2id needs at least one pass and thread, and 8 KiB of memory per thread")
+		}
+		if params.SaltLen < 8 || params.KeyLen < 16 {
+			return errors.New("argon2id needs salts of at least 8 bytes and keys of at least 16")
+		}
+	default:
+		return fmt.Errorf("unknown password hashing algorithm %q, expected %s or %s", h.Algorithm, AlgorithmBcrypt, AlgorithmArgon2id)
+	}
+	return nil
+}
+
+// DefaultPasswordHashing is bcrypt at its default cost, with the Argon2id
+// defaults ready for when the algorithm is switched
+var DefaultPasswordHashing = PasswordHashing{
+	Algorithm:  AlgorithmBcrypt,
+	BcryptCost: bcrypt.DefaultCost,
+	Argon2:     DefaultArgon2Params,
+}
+
+// NewPasswordServiceWithHashing hashes new passwords as set by hashing
+func NewPasswordServiceWithHashing(hashing PasswordHashing) (*PasswordService, error) {
+	if err := hashing.Validate(); err != nil {
+		return nil, err
+	}
+	return &PasswordService{hashing: hashing}, nil
 }
 
 func (ps *PasswordService) HashPassword(password string) (string, error) {
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if ps.hashing.Algorithm == AlgorithmArgon2id {
+		return hashArgon2id(password, ps.hashing.Argon2)
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), ps.hashing.BcryptCost)
 	if err != nil {
 		return "", err
 	}
@@ -19,5 +112,84 @@ func (ps *PasswordService) HashPassword(password string) (string, error) {
 }
 
 func (ps *PasswordService) ComparePassword(hashedPassword, password string) error {
-	return bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password))
-}
\ No newline at end of file
+	if strings.HasPrefix(hashedPassword, "$"+AlgorithmArgon2id+"$") {
+		params, salt, key, err := parseArgon2id(hashedPassword)
+		if err != nil {
+			return err
+		}
+		candidate := argon2.IDKey([]byte(password), salt, params.Time, params.Memory, params.Threads, params.KeyLen)
+		if subtle.ConstantTimeCompare(key, candidate) != 1 {
+			return ErrPasswordMismatch
+		}
+		return nil
+	}
+
+	err := bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return ErrPasswordMismatch
+	}
+	return err
+}
+
+// NeedsRehash reports whether a hash was made with another algorithm or
+// other parameters than new passwords get, including unreadable hashes
+func (ps *PasswordService) NeedsRehash(hashedPassword string) bool {
+	if strings.HasPrefix(hashedPassword, "$"+AlgorithmArgon2id+"$") {
+		if ps.hashing.Algorithm != AlgorithmArgon2id {
+			return true
+		}
+		params, _, _, err := parseArgon2id(hashedPassword)
+		return err != nil || params != ps.hashing.Argon2
+	}
+
+	if ps.hashing.Algorithm != AlgorithmBcrypt {
+		return true
+	}
+	cost, err := bcrypt.Cost([]byte(hashedPassword))
+	return err != nil || cost != ps.hashing.BcryptCost
+}
+
+// hashArgon2id returns a hash in the PHC string format, such as
+// $argon2id$v=19$m=65536,t=3,p=2$<salt>$<key> in unpadded base64
+func hashArgon2id(password string, params Argon2Params) (string, error) {
+	salt := make([]byte, params.SaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, params.Time, params.Memory, params.Threads, params.KeyLen)
+
+	return fmt.Sprintf("$%s$v=%d$m=%d,t=%d,p=%d$%s$%s", AlgorithmArgon2id, argon2.Version,
+		params.Memory, params.Time, params.Threads,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// parseArgon2id reads the parameters, salt and key of a hash made by
+// hashArgon2id
+func parseArgon2id(hash string) (Argon2Params, []byte, []byte, error) {
+	var params Argon2Params
+	invalid := errors.New("invalid argon2id hash")
+
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != AlgorithmArgon2id {
+		return params, nil, nil, invalid
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return params, nil, nil, invalid
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Time, &params.Threads); err != nil {
+		return params, nil, nil, invalid
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return params, nil, nil, invalid
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return params, nil, nil, invalid
+	}
+	params.SaltLen = uint32(len(salt))
+	params.KeyLen = uint32(len(key))
+	return params, salt, key, nil
+}
@@ -0,0 +1,100 @@
+package infrastructure
+
+import (
+	"errors"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"task-manager/Domain"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Problem is an RFC 7807 problem details body
+type Problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+	// Violations lists the rules a rejected value failed
+	Violations []domain.RuleViolation `json:"violations,omitempty"`
+}
+
+const problemContentType = "application/problem+json"
+
+// errorStatuses maps domain error kinds to HTTP statuses. Anything not
+// listed is treated as an internal error.
+var errorStatuses = []struct {
+	kind   error
+	status int
+}{
+	{domain.ErrNotFound, http.StatusNotFound},
+	{domain.ErrInvalidID, http.StatusBadRequest},
+	{domain.ErrValidation, http.StatusBadRequest},
+	{domain.ErrConflict, http.StatusConflict},
+	{domain.ErrUnauthorized, http.StatusUnauthorized},
+	{domain.ErrForbidden, http.StatusForbidden},
+	{domain.ErrPreconditionFailed, http.StatusPreconditionFailed},
+	{domain.ErrUnprocessable, http.StatusUnprocessableEntity},
+	{domain.ErrTooManyRequests, http.StatusTooManyRequests},
+}
+
+// StatusForError returns the HTTP status for an error returned by a usecase
+func StatusForError(err error) int {
+	for _, mapping := range errorStatuses {
+		if errors.Is(err, mapping.kind) {
+			return mapping.status
+		}
+	}
+	return http.StatusInternalServerError
+}
+
+// AbortWithError writes err as a problem+json response and stops the handler
+// chain. Internal errors are logged and their details hidden from clients.
+// Lockouts tell clients when to retry with a Retry-After header, and
+// rejected passwords list the rules they fail.
+func AbortWithError(c *gin.Context, err error) {
+	status := StatusForError(err)
+
+	var locked *domain.LoginLockedError
+	if errors.As(err, &locked) {
+		seconds := math.Ceil(time.Until(locked.Until).Seconds())
+		c.Header("Retry-After", strconv.Itoa(int(math.Max(seconds, 1))))
+	}
+
+	detail := err.Error()
+	if status == http.StatusInternalServerError {
+		log.Printf("internal error on %s %s: %v", c.Request.Method, c.Request.URL.Path, err)
+		detail = "an unexpected error occurred"
+	}
+
+	problem := newProblem(c, status, detail)
+	var policyErr *domain.PasswordPolicyError
+	if errors.As(err, &policyErr) {
+		problem.Violations = policyErr.Violations
+	}
+	abortWithProblem(c, problem)
+}
+
+// AbortWithProblem writes a problem+json response with the given status
+func AbortWithProblem(c *gin.Context, status int, detail string) {
+	abortWithProblem(c, newProblem(c, status, detail))
+}
+
+func newProblem(c *gin.Context, status int, detail string) Problem {
+	return Problem{
+		Type:     "about:blank",
+		Title:    http.StatusText(status),
+		Status:   status,
+		Detail:   detail,
+		Instance: c.Request.URL.Path,
+	}
+}
+
+func abortWithProblem(c *gin.Context, problem Problem) {
+	c.Header("Content-Type", problemContentType)
+	c.AbortWithStatusJSON(problem.Status, problem)
+}
@@ -0,0 +1,30 @@
+package repositories
+
+// CollectionNames names the MongoDB collections the repositories and
+// migrations use
+type CollectionNames struct {
+	Tasks                   string
+	Users                   string
+	RefreshTokens           string
+	RevokedTokens           string
+	PasswordResetTokens     string
+	EmailVerificationTokens string
+	MFAEnrollments          string
+	MFAChallenges           string
+	LoginThrottles          string
+	// Migrations records the migrations applied
+	Migrations string
+}
+
+var DefaultCollectionNames = CollectionNames{
+	Tasks:                   "tasks",
+	Users:                   "users",
+	RefreshTokens:           "refresh_tokens",
+	RevokedTokens:           "revoked_tokens",
+	PasswordResetTokens:     "password_reset_tokens",
+	EmailVerificationTokens: "email_verification_tokens",
+	MFAEnrollments:          "mfa_enrollments",
+	MFAChallenges:           "mfa_challenges",
+	LoginThrottles:          "login_throttles",
+	Migrations:              "migrations",
+}
@@ -0,0 +1,92 @@
+package repositories
+
+import (
+	"context"
+	"task-manager/Domain"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// LoginThrottleRepository stores failed login counts in MongoDB. Expired
+// documents are removed by a TTL index on expires_at.
+type LoginThrottleRepository struct {
+	collection *mongo.Collection
+	timeouts   Timeouts
+}
+
+func NewLoginThrottleRepository(collection *mongo.Collection, timeouts Timeouts) domain.LoginThrottleRepository {
+	return &LoginThrottleRepository{collection: collection, timeouts: timeouts}
+}
+
+// loginThrottleDocument is the MongoDB representation of a failed login
+// count, keyed by the throttle key
+type loginThrottleDocument struct {
+	Key         string    `bson:"_id"`
+	Failures    int       `bson:"failures"`
+	LastFailure time.Time `bson:"last_failure"`
+	ExpiresAt   time.Time `bson:"expires_at"`
+}
+
+func (d loginThrottleDocument) toDomain() domain.LoginThrottle {
+	return domain.LoginThrottle{
+		Key:         d.Key,
+		Failures:    d.Failures,
+		LastFailure: d.LastFailure.UTC(),
+		ExpiresAt:   d.ExpiresAt.UTC(),
+	}
+}
+
+func (lr *LoginThrottleRepository) GetLoginThrottle(ctx context.Context, key string) (domain.LoginThrottle, error) {
+	ctx, cancel := lr.timeouts.read(ctx)
+	defer cancel()
+
+	var doc loginThrottleDocument
+	err := lr.collection.FindOne(ctx, bson.M{"_id": key}).Decode(&doc)
+	if err == mongo.ErrNoDocuments {
+		return domain.LoginThrottle{}, domain.ErrLoginThrottleNotFound
+	}
+	if err != nil {
+		return domain.LoginThrottle{}, err
+	}
+	return doc.toDomain(), nil
+}
+
+// RecordLoginFailure counts the failure with a single pipeline update.
+// The TTL monitor only runs every minute, so expired counts are also
+// restarted here.
+func (lr *LoginThrottleRepository) RecordLoginFailure(ctx context.Context, key string, at time.Time, window time.Duration) (domain.LoginThrottle, error) {
+	ctx, cancel := lr.timeouts.write(ctx)
+	defer cancel()
+
+	update := mongo.Pipeline{{{Key: "$set", Value: bson.M{
+		"failures": bson.M{"$cond": bson.A{
+			bson.M{"$gte": bson.A{"$expires_at", at}},
+			bson.M{"$add": bson.A{"$failures", 1}},
+			1,
+		}},
+		"last_failure": at,
+		"expires_at":   at.Add(window),
+	}}}}
+
+	var doc loginThrottleDocument
+	err := lr.collection.FindOneAndUpdate(ctx,
+		bson.M{"_id": key},
+		update,
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(&doc)
+	if err != nil {
+		return domain.LoginThrottle{}, err
+	}
+	return doc.toDomain(), nil
+}
+
+func (lr *LoginThrottleRepository) ClearLoginThrottle(ctx context.Context, key string) error {
+	ctx, cancel := lr.timeouts.write(ctx)
+	defer cancel()
+
+	_, err := lr.collection.DeleteOne(ctx, bson.M{"_id": key})
+	return err
+}
@@ -0,0 +1,60 @@
+package repositories
+
+import (
+	"context"
+	"sync"
+	"task-manager/Domain"
+	"time"
+)
+
+// MemoryLoginThrottleRepository keeps failed login counts in a map. Expired
+// counts are dropped whenever a failure is recorded.
+type MemoryLoginThrottleRepository struct {
+	mu        sync.Mutex
+	throttles map[string]domain.LoginThrottle
+}
+
+func NewMemoryLoginThrottleRepository() domain.LoginThrottleRepository {
+	return &MemoryLoginThrottleRepository{throttles: make(map[string]domain.LoginThrottle)}
+}
+
+func (mr *MemoryLoginThrottleRepository) GetLoginThrottle(_ context.Context, key string) (domain.LoginThrottle, error) {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	throttle, ok := mr.throttles[key]
+	if !ok {
+		return domain.LoginThrottle{}, domain.ErrLoginThrottleNotFound
+	}
+	return throttle, nil
+}
+
+func (mr *MemoryLoginThrottleRepository) RecordLoginFailure(_ context.Context, key string, at time.Time, window time.Duration) (domain.LoginThrottle, error) {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	at = at.UTC().Truncate(time.Millisecond)
+	for stored, throttle := range mr.throttles {
+		if throttle.ExpiresAt.Before(at) {
+			delete(mr.throttles, stored)
+		}
+	}
+
+	throttle, ok := mr.throttles[key]
+	if !ok {
+		throttle = domain.LoginThrottle{Key: key}
+	}
+	throttle.Failures++
+	throttle.LastFailure = at
+	throttle.ExpiresAt = at.Add(window)
+	mr.throttles[key] = throttle
+	return throttle, nil
+}
+
+func (mr *MemoryLoginThrottleRepository) ClearLoginThrottle(_ context.Context, key string) error {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	delete(mr.throttles, key)
+	return nil
+}
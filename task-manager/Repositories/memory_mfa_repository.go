@@ -0,0 +1,111 @@
+package repositories
+
+import (
+	"context"
+	"sync"
+	"task-manager/Domain"
+	"time"
+)
+
+// MemoryMFARepository keeps TOTP enrollments and MFA challenges in maps.
+// Expired challenges are dropped whenever a challenge is saved.
+type MemoryMFARepository struct {
+	mu          sync.Mutex
+	enrollments map[domain.ID]domain.MFAEnrollment
+	challenges  map[string]domain.MFAChallenge
+}
+
+func NewMemoryMFARepository() domain.MFARepository {
+	return &MemoryMFARepository{
+		enrollments: make(map[domain.ID]domain.MFAEnrollment),
+		challenges:  make(map[string]domain.MFAChallenge),
+	}
+}
+
+func (mr *MemoryMFARepository) SaveEnrollment(_ context.Context, enrollment domain.MFAEnrollment) error {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	enrollment.RecoveryCodes = append([]string(nil), enrollment.RecoveryCodes...)
+	mr.enrollments[enrollment.UserID] = enrollment
+	return nil
+}
+
+func (mr *MemoryMFARepository) GetEnrollment(_ context.Context, userID domain.ID) (domain.MFAEnrollment, error) {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	enrollment, ok := mr.enrollments[userID]
+	if !ok {
+		return domain.MFAEnrollment{}, domain.ErrMFAEnrollmentNotFound
+	}
+	enrollment.RecoveryCodes = append([]string(nil), enrollment.RecoveryCodes...)
+	return enrollment, nil
+}
+
+func (mr *MemoryMFARepository) DeleteEnrollment(_ context.Context, userID domain.ID) error {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	delete(mr.enrollments, userID)
+	return nil
+}
+
+func (mr *MemoryMFARepository) UseTOTPStep(_ context.Context, userID domain.ID, step int64) error {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	enrollment, ok := mr.enrollments[userID]
+	if !ok || enrollment.LastStep >= step {
+		return domain.ErrMFACodeReused
+	}
+	enrollment.LastStep = step
+	mr.enrollments[userID] = enrollment
+	return nil
+}
+
+func (mr *MemoryMFARepository) UseRecoveryCode(_ context.Context, userID domain.ID, codeHash string) error {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	enrollment, ok := mr.enrollments[userID]
+	if !ok {
+		return domain.ErrRecoveryCodeNotFound
+	}
+	for i, hash := range enrollment.RecoveryCodes {
+		if hash == codeHash {
+			enrollment.RecoveryCodes = append(enrollment.RecoveryCodes[:i:i], enrollment.RecoveryCodes[i+1:]...)
+			mr.enrollments[userID] = enrollment
+			return nil
+		}
+	}
+	return domain.ErrRecoveryCodeNotFound
+}
+
+func (mr *MemoryMFARepository) SaveMFAChallenge(_ context.Context, challenge domain.MFAChallenge) error {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	ts := now()
+	for hash, stored := range mr.challenges {
+		if stored.ExpiresAt.Before(ts) {
+			delete(mr.challenges, hash)
+		}
+	}
+
+	challenge.ExpiresAt = challenge.ExpiresAt.UTC().Truncate(time.Millisecond)
+	mr.challenges[challenge.TokenHash] = challenge
+	return nil
+}
+
+func (mr *MemoryMFARepository) ConsumeMFAChallenge(_ context.Context, tokenHash string) (domain.MFAChallenge, error) {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	challenge, ok := mr.challenges[tokenHash]
+	if !ok {
+		return domain.MFAChallenge{}, domain.ErrMFAChallengeNotFound
+	}
+	delete(mr.challenges, tokenHash)
+	return challenge, nil
+}
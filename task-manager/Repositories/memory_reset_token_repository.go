@@ -0,0 +1,59 @@
+package repositories
+
+import (
+	"context"
+	"sync"
+	"task-manager/Domain"
+	"time"
+)
+
+// MemoryResetTokenRepository keeps password reset tokens in a map. Expired
+// tokens are dropped whenever a token is saved.
+type MemoryResetTokenRepository struct {
+	mu     sync.Mutex
+	tokens map[string]domain.PasswordResetToken
+}
+
+func NewMemoryResetTokenRepository() domain.ResetTokenRepository {
+	return &MemoryResetTokenRepository{tokens: make(map[string]domain.PasswordResetToken)}
+}
+
+func (mr *MemoryResetTokenRepository) SaveResetToken(_ context.Context, token domain.PasswordResetToken) error {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	ts := now()
+	for hash, stored := range mr.tokens {
+		if stored.ExpiresAt.Before(ts) {
+			delete(mr.tokens, hash)
+		}
+	}
+
+	token.ExpiresAt = token.ExpiresAt.UTC().Truncate(time.Millisecond)
+	mr.tokens[token.TokenHash] = token
+	return nil
+}
+
+func (mr *MemoryResetTokenRepository) ConsumeResetToken(_ context.Context, tokenHash string) (domain.PasswordResetToken, error) {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	token, ok := mr.tokens[tokenHash]
+	if !ok {
+		return domain.PasswordResetToken{}, domain.ErrResetTokenNotFound
+	}
+	delete(mr.tokens, tokenHash)
+	return token, nil
+}
+
+func (mr *MemoryResetTokenRepository) DeleteResetTokens(_ context.Context, userID domain.ID) error {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	for hash, token := range mr.tokens {
+		if token.UserID == userID {
+			delete(mr.tokens, hash)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,331 @@
+package repositories
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"task-manager/Domain"
+	"time"
+)
+
+// MemoryTaskRepository keeps tasks in a map. It mirrors the semantics of
+// TaskRepository so it can stand in for MongoDB in tests and local runs.
+type MemoryTaskRepository struct {
+	mu    sync.RWMutex
+	tasks map[domain.ID]domain.Task
+}
+
+func NewMemoryTaskRepository() domain.TaskRepository {
+	return &MemoryTaskRepository{
+		tasks: make(map[domain.ID]domain.Task),
+	}
+}
+
+func (mr *MemoryTaskRepository) QueryTasks(_ context.Context, query domain.TaskQuery) ([]domain.Task, int64, error) {
+	visibleTo := domain.ID(query.VisibleTo)
+	if !visibleTo.IsZero() && !visibleTo.Valid() {
+		return nil, 0, domain.InvalidID("invalid user ID")
+	}
+
+	mr.mu.RLock()
+	matched := []domain.Task{}
+	for _, task := range mr.tasks {
+		if matchesTaskQuery(task, query, visibleTo) {
+			matched = append(matched, cloneTask(task))
+		}
+	}
+	mr.mu.RUnlock()
+
+	sort.Slice(matched, func(i, j int) bool {
+		c := compareTasks(matched[i], matched[j], query.SortBy)
+		if query.SortDesc {
+			return c > 0
+		}
+		return c < 0
+	})
+
+	total := int64(len(matched))
+	start := query.Offset
+	if start > len(matched) {
+		start = len(matched)
+	}
+	end := len(matched)
+	if query.Limit > 0 && start+query.Limit < end {
+		end = start + query.Limit
+	}
+
+	return matched[start:end], total, nil
+}
+
+func matchesTaskQuery(task domain.Task, query domain.TaskQuery, visibleTo domain.ID) bool {
+	if !visibleTo.IsZero() && task.CreatedBy != visibleTo && task.AssigneeID != visibleTo {
+		return false
+	}
+	if query.Status != "" && task.Status != query.Status {
+		return false
+	}
+	if query.Title != "" && !strings.Contains(strings.ToLower(task.Title), strings.ToLower(query.Title)) {
+		return false
+	}
+	if !query.DueFrom.IsZero() && (task.DueDate == nil || task.DueDate.Before(query.DueFrom)) {
+		return false
+	}
+	if !query.DueTo.IsZero() && (task.DueDate == nil || task.DueDate.After(query.DueTo)) {
+		return false
+	}
+	return true
+}
+
+// compareTasks orders two tasks by field the way MongoDB would, with the ID
+// breaking ties so pages stay stable between requests
+func compareTasks(a, b domain.Task, field string) int {
+	var c int
+	switch field {
+	case "title":
+		c = strings.Compare(a.Title, b.Title)
+	case "status":
+		c = strings.Compare(string(a.Status), string(b.Status))
+	case "due_date":
+		c = compareDueDates(a.DueDate, b.DueDate)
+	case "created_at":
+		c = compareTimes(a.CreatedAt, b.CreatedAt)
+	case "updated_at":
+		c = compareTimes(a.UpdatedAt, b.UpdatedAt)
+	}
+	if c != 0 {
+		return c
+	}
+	return strings.Compare(a.ID.String(), b.ID.String())
+}
+
+// compareDueDates sorts tasks without a due date first, as MongoDB does
+func compareDueDates(a, b *time.Time) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return -1
+	case b == nil:
+		return 1
+	}
+	return compareTimes(*a, *b)
+}
+
+func compareTimes(a, b time.Time) int {
+	switch {
+	case a.Before(b):
+		return -1
+	case a.After(b):
+		return 1
+	}
+	return 0
+}
+
+func (mr *MemoryTaskRepository) GetTaskByID(_ context.Context, id string) (domain.Task, error) {
+	taskID := domain.ID(id)
+	if !taskID.Valid() {
+		return domain.Task{}, domain.InvalidID("invalid id format")
+	}
+
+	mr.mu.RLock()
+	defer mr.mu.RUnlock()
+
+	task, ok := mr.tasks[taskID]
+	if !ok {
+		return domain.Task{}, domain.ErrTaskNotFound
+	}
+	return cloneTask(task), nil
+}
+
+func (mr *MemoryTaskRepository) CreateTask(_ context.Context, task domain.Task) (domain.Task, error) {
+	ts := now()
+	task = cloneTask(task)
+	task.ID = domain.NewID()
+	task.CreatedAt = ts
+	task.UpdatedAt = ts
+	task.Version = 1
+
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	mr.tasks[task.ID] = task
+	return cloneTask(task), nil
+}
+
+func (mr *MemoryTaskRepository) UpdateTask(_ context.Context, id string, updated domain.Task, version int64) (domain.Task, error) {
+	return mr.write(id, version, func(task *domain.Task) {
+		task.Title = updated.Title
+		task.Description = updated.Description
+		task.DueDate = cloneTime(updated.DueDate)
+		task.Status = updated.Status
+		if !updated.AssigneeID.IsZero() {
+			task.AssigneeID = updated.AssigneeID
+		}
+	})
+}
+
+func (mr *MemoryTaskRepository) PatchTask(_ context.Context, id string, patch domain.TaskPatch, version int64) (domain.Task, error) {
+	return mr.write(id, version, func(task *domain.Task) {
+		if patch.Title != nil {
+			task.Title = *patch.Title
+		}
+		if patch.Description != nil {
+			task.Description = *patch.Description
+		}
+		if patch.DueDate != nil {
+			task.DueDate = cloneTime(patch.DueDate)
+		}
+		if patch.ClearDueDate {
+			task.DueDate = nil
+		}
+		if patch.Status != nil {
+			task.Status = *patch.Status
+		}
+		if patch.AssigneeID != nil {
+			task.AssigneeID = *patch.AssigneeID
+		}
+	})
+}
+
+// TransitionTask moves a task to a new status only if it is still in the
+// status the caller validated against
+func (mr *MemoryTaskRepository) TransitionTask(_ context.Context, id string, from, to domain.TaskStatus) (domain.Task, error) {
+	taskID := domain.ID(id)
+	if !taskID.Valid() {
+		return domain.Task{}, domain.InvalidID("invalid id format")
+	}
+
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	task, ok := mr.tasks[taskID]
+	if !ok {
+		return domain.Task{}, domain.ErrTaskNotFound
+	}
+	if task.Status != from {
+		return domain.Task{}, &domain.TransitionError{From: task.Status, To: to}
+	}
+
+	task.Status = to
+	task.UpdatedAt = now()
+	task.Version++
+	mr.tasks[taskID] = task
+	return cloneTask(task), nil
+}
+
+func (mr *MemoryTaskRepository) DeleteTask(_ context.Context, id string, version int64) error {
+	taskID := domain.ID(id)
+	if !taskID.Valid() {
+		return domain.InvalidID("invalid id format")
+	}
+
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	task, ok := mr.tasks[taskID]
+	if !ok {
+		return domain.ErrTaskNotFound
+	}
+	if version != 0 && task.Version != version {
+		return domain.ErrVersionConflict
+	}
+
+	delete(mr.tasks, taskID)
+	return nil
+}
+
+func (mr *MemoryTaskRepository) ReassignTasks(_ context.Context, from, to domain.ID) error {
+	return mr.writeAll(func(task *domain.Task) bool {
+		if task.CreatedBy != from && task.AssigneeID != from {
+			return false
+		}
+		if task.CreatedBy == from {
+			task.CreatedBy = to
+		}
+		if task.AssigneeID == from {
+			task.AssigneeID = to
+		}
+		return true
+	})
+}
+
+func (mr *MemoryTaskRepository) DeleteTasksCreatedBy(_ context.Context, userID domain.ID) error {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	for id, task := range mr.tasks {
+		if task.CreatedBy == userID {
+			delete(mr.tasks, id)
+		}
+	}
+	return nil
+}
+
+func (mr *MemoryTaskRepository) UnassignTasks(_ context.Context, userID domain.ID) error {
+	return mr.writeAll(func(task *domain.Task) bool {
+		if task.AssigneeID != userID {
+			return false
+		}
+		task.AssigneeID = task.CreatedBy
+		return true
+	})
+}
+
+// writeAll applies change to every task under the write lock, bumping the
+// version of the tasks it reports as changed
+func (mr *MemoryTaskRepository) writeAll(change func(*domain.Task) bool) error {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	ts := now()
+	for id, task := range mr.tasks {
+		if change(&task) {
+			task.UpdatedAt = ts
+			task.Version++
+			mr.tasks[id] = task
+		}
+	}
+	return nil
+}
+
+// write applies change to a task under the write lock, enforcing the same
+// version check as versionFilter
+func (mr *MemoryTaskRepository) write(id string, version int64, change func(*domain.Task)) (domain.Task, error) {
+	taskID := domain.ID(id)
+	if !taskID.Valid() {
+		return domain.Task{}, domain.InvalidID("invalid id format")
+	}
+
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	task, ok := mr.tasks[taskID]
+	if !ok {
+		return domain.Task{}, domain.ErrTaskNotFound
+	}
+	if version != 0 && task.Version != version {
+		return domain.Task{}, domain.ErrVersionConflict
+	}
+
+	change(&task)
+	task.UpdatedAt = now()
+	task.Version++
+	mr.tasks[taskID] = task
+	return cloneTask(task), nil
+}
+
+// cloneTask copies the due date so callers cannot modify stored tasks
+func cloneTask(task domain.Task) domain.Task {
+	task.DueDate = cloneTime(task.DueDate)
+	return task
+}
+
+// cloneTime copies t at the precision MongoDB would store it
+func cloneTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	copied := t.UTC().Truncate(time.Millisecond)
+	return &copied
+}
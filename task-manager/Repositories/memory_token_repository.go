@@ -0,0 +1,115 @@
+package repositories
+
+import (
+	"context"
+	"sync"
+	"task-manager/Domain"
+	"time"
+)
+
+// MemoryTokenRepository keeps refresh tokens and revoked access tokens in
+// maps. Expired entries stay until DeleteExpiredTokens drops them.
+type MemoryTokenRepository struct {
+	mu            sync.RWMutex
+	refreshTokens map[string]domain.RefreshToken
+	revoked       map[string]time.Time
+}
+
+func NewMemoryTokenRepository() domain.TokenRepository {
+	return &MemoryTokenRepository{
+		refreshTokens: make(map[string]domain.RefreshToken),
+		revoked:       make(map[string]time.Time),
+	}
+}
+
+func (mr *MemoryTokenRepository) SaveRefreshToken(_ context.Context, token domain.RefreshToken) error {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	token.ExpiresAt = token.ExpiresAt.UTC().Truncate(time.Millisecond)
+	mr.refreshTokens[token.TokenHash] = token
+	return nil
+}
+
+func (mr *MemoryTokenRepository) GetRefreshToken(_ context.Context, tokenHash string) (domain.RefreshToken, error) {
+	mr.mu.RLock()
+	defer mr.mu.RUnlock()
+
+	token, ok := mr.refreshTokens[tokenHash]
+	if !ok {
+		return domain.RefreshToken{}, domain.ErrRefreshTokenNotFound
+	}
+	return token, nil
+}
+
+func (mr *MemoryTokenRepository) RevokeRefreshToken(_ context.Context, tokenHash string) error {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	token, ok := mr.refreshTokens[tokenHash]
+	if !ok || token.Revoked {
+		return domain.ErrRefreshTokenNotFound
+	}
+	token.Revoked = true
+	mr.refreshTokens[tokenHash] = token
+	return nil
+}
+
+func (mr *MemoryTokenRepository) RevokeTokenFamily(_ context.Context, familyID domain.ID) error {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	for hash, token := range mr.refreshTokens {
+		if token.FamilyID == familyID {
+			token.Revoked = true
+			mr.refreshTokens[hash] = token
+		}
+	}
+	return nil
+}
+
+func (mr *MemoryTokenRepository) RevokeUserTokens(_ context.Context, userID domain.ID) error {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	for hash, token := range mr.refreshTokens {
+		if token.UserID == userID {
+			token.Revoked = true
+			mr.refreshTokens[hash] = token
+		}
+	}
+	return nil
+}
+
+func (mr *MemoryTokenRepository) RevokeAccessToken(_ context.Context, tokenID string, expiresAt time.Time) error {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	mr.revoked[tokenID] = expiresAt
+	return nil
+}
+
+func (mr *MemoryTokenRepository) IsAccessTokenRevoked(_ context.Context, tokenID string) (bool, error) {
+	mr.mu.RLock()
+	defer mr.mu.RUnlock()
+
+	_, ok := mr.revoked[tokenID]
+	return ok, nil
+}
+
+func (mr *MemoryTokenRepository) DeleteExpiredTokens(_ context.Context, before time.Time) error {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	for id, expiry := range mr.revoked {
+		if expiry.Before(before) {
+			delete(mr.revoked, id)
+		}
+	}
+	for hash, token := range mr.refreshTokens {
+		if token.ExpiresAt.Before(before) {
+			delete(mr.refreshTokens, hash)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,315 @@
+package repositories
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"task-manager/Domain"
+)
+
+// MemoryUserRepository keeps users in a map. It mirrors the semantics of
+// UserRepository, including registering users as members unless a role is
+// given.
+type MemoryUserRepository struct {
+	mu              sync.RWMutex
+	users           map[domain.ID]domain.User
+	passwordService domain.PasswordService
+}
+
+func NewMemoryUserRepository(
+	passwordService domain.PasswordService,
+) *MemoryUserRepository {
+	return &MemoryUserRepository{
+		users:           make(map[domain.ID]domain.User),
+		passwordService: passwordService,
+	}
+}
+
+func (mr *MemoryUserRepository) RegisterUser(_ context.Context, user domain.User) (domain.User, error) {
+	return mr.register(user, nil)
+}
+
+func (mr *MemoryUserRepository) RegisterFirstAdmin(_ context.Context, user domain.User) (domain.User, error) {
+	user.Role = domain.RoleAdmin
+	return mr.register(user, func() error {
+		for _, existing := range mr.users {
+			if existing.Role == domain.RoleAdmin {
+				return domain.ErrSetupComplete
+			}
+		}
+		return nil
+	})
+}
+
+// register stores a new user once check, called under the lock, passes
+func (mr *MemoryUserRepository) register(user domain.User, check func() error) (domain.User, error) {
+	if user.Username == "" || user.Password == "" {
+		return domain.User{}, domain.Validation("fields cannot be empty")
+	}
+
+	hashedPassword, err := mr.passwordService.HashPassword(user.Password)
+	if err != nil {
+		return domain.User{}, err
+	}
+
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	if check != nil {
+		if err := check(); err != nil {
+			return domain.User{}, err
+		}
+	}
+	if _, ok := mr.findByUsername(user.Username); ok {
+		return domain.User{}, domain.Conflict("username already taken")
+	}
+	if _, ok := mr.findByEmail(user.Email); ok {
+		return domain.User{}, domain.ErrEmailTaken
+	}
+
+	if user.Role == "" {
+		user.Role = domain.RoleMember
+	}
+	user.Password = hashedPassword
+	user.ID = domain.NewID()
+	user.Disabled = false
+	user.EmailVerified = false
+	mr.users[user.ID] = user
+
+	user.Password = ""
+	return user, nil
+}
+
+func (mr *MemoryUserRepository) PromoteUser(_ context.Context, id string) (domain.User, error) {
+	userID := domain.ID(id)
+	if !userID.Valid() {
+		return domain.User{}, domain.InvalidID("invalid user ID")
+	}
+
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	user, ok := mr.users[userID]
+	if !ok {
+		return domain.User{}, domain.ErrUserNotFound
+	}
+	user.Role = domain.RoleAdmin
+	mr.users[userID] = user
+
+	user.Password = ""
+	return user, nil
+}
+
+func (mr *MemoryUserRepository) GetUserByUsername(_ context.Context, username string) (domain.User, error) {
+	mr.mu.RLock()
+	defer mr.mu.RUnlock()
+
+	user, ok := mr.findByUsername(username)
+	if !ok {
+		return domain.User{}, domain.ErrUserNotFound
+	}
+	return user, nil
+}
+
+func (mr *MemoryUserRepository) GetUserByID(_ context.Context, id string) (domain.User, error) {
+	userID := domain.ID(id)
+	if !userID.Valid() {
+		return domain.User{}, domain.InvalidID("invalid user ID")
+	}
+
+	mr.mu.RLock()
+	defer mr.mu.RUnlock()
+
+	user, ok := mr.users[userID]
+	if !ok {
+		return domain.User{}, domain.ErrUserNotFound
+	}
+	return user, nil
+}
+
+func (mr *MemoryUserRepository) ListUsers(_ context.Context, query domain.UserQuery) ([]domain.User, int64, error) {
+	mr.mu.RLock()
+	matched := []domain.User{}
+	for _, user := range mr.users {
+		if query.Role != "" && user.Role != query.Role {
+			continue
+		}
+		if query.Disabled != nil && user.Disabled != *query.Disabled {
+			continue
+		}
+		user.Password = ""
+		matched = append(matched, user)
+	}
+	mr.mu.RUnlock()
+
+	sort.Slice(matched, func(i, j int) bool { return matched[i].ID < matched[j].ID })
+
+	total := int64(len(matched))
+	start := query.Offset
+	if start > len(matched) {
+		start = len(matched)
+	}
+	end := len(matched)
+	if query.Limit > 0 && start+query.Limit < end {
+		end = start + query.Limit
+	}
+
+	return matched[start:end], total, nil
+}
+
+func (mr *MemoryUserRepository) SetUserRole(_ context.Context, id string, role string) (domain.User, error) {
+	return mr.update(id, func(user *domain.User) { user.Role = role })
+}
+
+func (mr *MemoryUserRepository) SetUserDisabled(_ context.Context, id string, disabled bool) (domain.User, error) {
+	return mr.update(id, func(user *domain.User) { user.Disabled = disabled })
+}
+
+func (mr *MemoryUserRepository) DeleteUser(_ context.Context, id string) error {
+	userID := domain.ID(id)
+	if !userID.Valid() {
+		return domain.InvalidID("invalid user ID")
+	}
+
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	user, ok := mr.users[userID]
+	if !ok {
+		return domain.ErrUserNotFound
+	}
+	if mr.lastAdmin(user) {
+		return domain.ErrLastAdmin
+	}
+	delete(mr.users, userID)
+	return nil
+}
+
+func (mr *MemoryUserRepository) UpdateProfile(_ context.Context, id string, patch domain.ProfilePatch) (domain.User, error) {
+	userID := domain.ID(id)
+	if !userID.Valid() {
+		return domain.User{}, domain.InvalidID("invalid user ID")
+	}
+
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	user, ok := mr.users[userID]
+	if !ok {
+		return domain.User{}, domain.ErrUserNotFound
+	}
+	if patch.Username != nil {
+		if existing, ok := mr.findByUsername(*patch.Username); ok && existing.ID != userID {
+			return domain.User{}, domain.Conflict("username already taken")
+		}
+		user.Username = *patch.Username
+	}
+	if patch.Email != nil {
+		if existing, ok := mr.findByEmail(*patch.Email); ok && existing.ID != userID {
+			return domain.User{}, domain.ErrEmailTaken
+		}
+		if !strings.EqualFold(*patch.Email, user.Email) {
+			user.EmailVerified = false
+		}
+		user.Email = *patch.Email
+	}
+	mr.users[userID] = user
+
+	user.Password = ""
+	return user, nil
+}
+
+func (mr *MemoryUserRepository) SetPassword(_ context.Context, id string, hash string) error {
+	_, err := mr.update(id, func(user *domain.User) { user.Password = hash })
+	return err
+}
+
+func (mr *MemoryUserRepository) MarkEmailVerified(_ context.Context, id string, email string) (domain.User, error) {
+	userID := domain.ID(id)
+	if !userID.Valid() {
+		return domain.User{}, domain.InvalidID("invalid user ID")
+	}
+
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	user, ok := mr.users[userID]
+	if !ok || email == "" || user.Email != email {
+		return domain.User{}, domain.ErrUserNotFound
+	}
+	user.EmailVerified = true
+	mr.users[userID] = user
+
+	user.Password = ""
+	return user, nil
+}
+
+// update applies change to a user under the write lock and returns the user
+// without its password
+func (mr *MemoryUserRepository) update(id string, change func(*domain.User)) (domain.User, error) {
+	userID := domain.ID(id)
+	if !userID.Valid() {
+		return domain.User{}, domain.InvalidID("invalid user ID")
+	}
+
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	user, ok := mr.users[userID]
+	if !ok {
+		return domain.User{}, domain.ErrUserNotFound
+	}
+	changed := user
+	change(&changed)
+	if !enabledAdmin(changed) && mr.lastAdmin(user) {
+		return domain.User{}, domain.ErrLastAdmin
+	}
+	user = changed
+	mr.users[userID] = user
+
+	user.Password = ""
+	return user, nil
+}
+
+// lastAdmin reports whether user is the only enabled admin. It must be
+// called with mu held.
+func (mr *MemoryUserRepository) lastAdmin(user domain.User) bool {
+	if !enabledAdmin(user) {
+		return false
+	}
+	for _, other := range mr.users {
+		if other.ID != user.ID && enabledAdmin(other) {
+			return false
+		}
+	}
+	return true
+}
+
+func enabledAdmin(user domain.User) bool {
+	return user.Role == domain.RoleAdmin && !user.Disabled
+}
+
+// findByUsername must be called with mu held
+func (mr *MemoryUserRepository) findByUsername(username string) (domain.User, bool) {
+	for _, user := range mr.users {
+		if user.Username == username {
+			return user, true
+		}
+	}
+	return domain.User{}, false
+}
+
+// findByEmail matches addresses regardless of case. No user has the empty
+// address. It must be called with mu held.
+func (mr *MemoryUserRepository) findByEmail(email string) (domain.User, bool) {
+	if email == "" {
+		return domain.User{}, false
+	}
+	for _, user := range mr.users {
+		if strings.EqualFold(user.Email, email) {
+			return user, true
+		}
+	}
+	return domain.User{}, false
+}
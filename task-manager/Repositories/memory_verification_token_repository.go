@@ -0,0 +1,59 @@
+package repositories
+
+import (
+	"context"
+	"sync"
+	"task-manager/Domain"
+	"time"
+)
+
+// MemoryVerificationTokenRepository keeps email verification tokens in a
+// map. Expired tokens are dropped whenever a token is saved.
+type MemoryVerificationTokenRepository struct {
+	mu     sync.Mutex
+	tokens map[string]domain.EmailVerificationToken
+}
+
+func NewMemoryVerificationTokenRepository() domain.VerificationTokenRepository {
+	return &MemoryVerificationTokenRepository{tokens: make(map[string]domain.EmailVerificationToken)}
+}
+
+func (mr *MemoryVerificationTokenRepository) SaveVerificationToken(_ context.Context, token domain.EmailVerificationToken) error {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	ts := now()
+	for hash, stored := range mr.tokens {
+		if stored.ExpiresAt.Before(ts) {
+			delete(mr.tokens, hash)
+		}
+	}
+
+	token.ExpiresAt = token.ExpiresAt.UTC().Truncate(time.Millisecond)
+	mr.tokens[token.TokenHash] = token
+	return nil
+}
+
+func (mr *MemoryVerificationTokenRepository) ConsumeVerificationToken(_ context.Context, tokenHash string) (domain.EmailVerificationToken, error) {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	token, ok := mr.tokens[tokenHash]
+	if !ok {
+		return domain.EmailVerificationToken{}, domain.ErrVerificationTokenNotFound
+	}
+	delete(mr.tokens, tokenHash)
+	return token, nil
+}
+
+func (mr *MemoryVerificationTokenRepository) DeleteVerificationTokens(_ context.Context, userID domain.ID) error {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	for hash, token := range mr.tokens {
+		if token.UserID == userID {
+			delete(mr.tokens, hash)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,202 @@
+package repositories
+
+import (
+	"context"
+	"task-manager/Domain"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MFARepository stores TOTP enrollments, keyed by user, and MFA challenges
+// in MongoDB. Expired challenges are removed by a TTL index on expires_at.
+type MFARepository struct {
+	enrollments *mongo.Collection
+	challenges  *mongo.Collection
+	timeouts    Timeouts
+}
+
+func NewMFARepository(enrollments, challenges *mongo.Collection, timeouts Timeouts) domain.MFARepository {
+	return &MFARepository{
+		enrollments: enrollments,
+		challenges:  challenges,
+		timeouts:    timeouts,
+	}
+}
+
+// mfaEnrollmentDocument is the MongoDB representation of an enrollment
+type mfaEnrollmentDocument struct {
+	UserID        primitive.ObjectID `bson:"_id"`
+	Secret        string             `bson:"secret"`
+	Confirmed     bool               `bson:"confirmed"`
+	RecoveryCodes []string           `bson:"recovery_codes"`
+	LastStep      int64              `bson:"last_step"`
+}
+
+// mfaChallengeDocument is the MongoDB representation of an MFA challenge,
+// keyed by the token hash
+type mfaChallengeDocument struct {
+	TokenHash string             `bson:"_id"`
+	UserID    primitive.ObjectID `bson:"user_id"`
+	Username  string             `bson:"username"`
+	ClientIP  string             `bson:"client_ip"`
+	ExpiresAt time.Time          `bson:"expires_at"`
+	Attempts  int                `bson:"attempts"`
+}
+
+func (mr *MFARepository) SaveEnrollment(ctx context.Context, enrollment domain.MFAEnrollment) error {
+	ctx, cancel := mr.timeouts.write(ctx)
+	defer cancel()
+
+	userID, err := objectID(enrollment.UserID)
+	if err != nil {
+		return err
+	}
+
+	codes := enrollment.RecoveryCodes
+	if codes == nil {
+		codes = []string{}
+	}
+	_, err = mr.enrollments.ReplaceOne(ctx,
+		bson.M{"_id": userID},
+		mfaEnrollmentDocument{
+			UserID:        userID,
+			Secret:        enrollment.Secret,
+			Confirmed:     enrollment.Confirmed,
+			RecoveryCodes: codes,
+			LastStep:      enrollment.LastStep,
+		},
+		options.Replace().SetUpsert(true),
+	)
+	return err
+}
+
+func (mr *MFARepository) GetEnrollment(ctx context.Context, userID domain.ID) (domain.MFAEnrollment, error) {
+	ctx, cancel := mr.timeouts.read(ctx)
+	defer cancel()
+
+	objID, err := objectID(userID)
+	if err != nil {
+		return domain.MFAEnrollment{}, err
+	}
+
+	var doc mfaEnrollmentDocument
+	err = mr.enrollments.FindOne(ctx, bson.M{"_id": objID}).Decode(&doc)
+	if err == mongo.ErrNoDocuments {
+		return domain.MFAEnrollment{}, domain.ErrMFAEnrollmentNotFound
+	}
+	if err != nil {
+		return domain.MFAEnrollment{}, err
+	}
+
+	return domain.MFAEnrollment{
+		UserID:        domainID(doc.UserID),
+		Secret:        doc.Secret,
+		Confirmed:     doc.Confirmed,
+		RecoveryCodes: doc.RecoveryCodes,
+		LastStep:      doc.LastStep,
+	}, nil
+}
+
+func (mr *MFARepository) DeleteEnrollment(ctx context.Context, userID domain.ID) error {
+	ctx, cancel := mr.timeouts.write(ctx)
+	defer cancel()
+
+	objID, err := objectID(userID)
+	if err != nil {
+		return err
+	}
+
+	_, err = mr.enrollments.DeleteOne(ctx, bson.M{"_id": objID})
+	return err
+}
+
+func (mr *MFARepository) UseTOTPStep(ctx context.Context, userID domain.ID, step int64) error {
+	ctx, cancel := mr.timeouts.write(ctx)
+	defer cancel()
+
+	objID, err := objectID(userID)
+	if err != nil {
+		return err
+	}
+
+	res, err := mr.enrollments.UpdateOne(ctx,
+		bson.M{"_id": objID, "last_step": bson.M{"$lt": step}},
+		bson.M{"$set": bson.M{"last_step": step}},
+	)
+	if err != nil {
+		return err
+	}
+	if res.ModifiedCount == 0 {
+		return domain.ErrMFACodeReused
+	}
+	return nil
+}
+
+func (mr *MFARepository) UseRecoveryCode(ctx context.Context, userID domain.ID, codeHash string) error {
+	ctx, cancel := mr.timeouts.write(ctx)
+	defer cancel()
+
+	objID, err := objectID(userID)
+	if err != nil {
+		return err
+	}
+
+	res, err := mr.enrollments.UpdateOne(ctx,
+		bson.M{"_id": objID, "recovery_codes": codeHash},
+		bson.M{"$pull": bson.M{"recovery_codes": codeHash}},
+	)
+	if err != nil {
+		return err
+	}
+	if res.ModifiedCount == 0 {
+		return domain.ErrRecoveryCodeNotFound
+	}
+	return nil
+}
+
+func (mr *MFARepository) SaveMFAChallenge(ctx context.Context, challenge domain.MFAChallenge) error {
+	ctx, cancel := mr.timeouts.write(ctx)
+	defer cancel()
+
+	userID, err := objectID(challenge.UserID)
+	if err != nil {
+		return err
+	}
+
+	_, err = mr.challenges.InsertOne(ctx, mfaChallengeDocument{
+		TokenHash: challenge.TokenHash,
+		UserID:    userID,
+		Username:  challenge.Username,
+		ClientIP:  challenge.ClientIP,
+		ExpiresAt: challenge.ExpiresAt,
+		Attempts:  challenge.Attempts,
+	})
+	return err
+}
+
+func (mr *MFARepository) ConsumeMFAChallenge(ctx context.Context, tokenHash string) (domain.MFAChallenge, error) {
+	ctx, cancel := mr.timeouts.write(ctx)
+	defer cancel()
+
+	var doc mfaChallengeDocument
+	err := mr.challenges.FindOneAndDelete(ctx, bson.M{"_id": tokenHash}).Decode(&doc)
+	if err == mongo.ErrNoDocuments {
+		return domain.MFAChallenge{}, domain.ErrMFAChallengeNotFound
+	}
+	if err != nil {
+		return domain.MFAChallenge{}, err
+	}
+
+	return domain.MFAChallenge{
+		TokenHash: doc.TokenHash,
+		UserID:    domainID(doc.UserID),
+		Username:  doc.Username,
+		ClientIP:  doc.ClientIP,
+		ExpiresAt: doc.ExpiresAt,
+		Attempts:  doc.Attempts,
+	}, nil
+}
@@ -0,0 +1,423 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"task-manager/Domain"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Migration is a one-time change to stored data. Applied migrations are
+// recorded in the Migrations collection so each one only ever runs once.
+// One that returns errMigrationDeferred is recorded as deferred instead, and
+// runs again on every start until it completes.
+type Migration struct {
+	ID string
+	Up func(ctx context.Context, db *mongo.Database, names CollectionNames) error
+}
+
+var Migrations = []Migration{
+	{ID: "0001_task_due_dates_and_timestamps", Up: migrateTaskDueDates},
+	{ID: "0002_task_status_values", Up: migrateTaskStatuses},
+	{ID: "0003_task_versions", Up: migrateTaskVersions},
+	{ID: "0004_task_owners", Up: migrateTaskOwners},
+	{ID: "0005_token_indexes", Up: createTokenIndexes},
+	{ID: "0006_member_role", Up: migrateMemberRole},
+	{ID: "0007_reset_token_indexes", Up: createResetTokenIndexes},
+	{ID: "0008_user_email", Up: createUserEmailIndexes},
+	{ID: "0009_mfa_challenge_indexes", Up: createMFAChallengeIndexes},
+	{ID: "0010_login_throttle_indexes", Up: createLoginThrottleIndexes},
+	{ID: "0011_unique_usernames", Up: createUsernameIndex},
+	{ID: "0012_setup_admin_index", Up: createSetupAdminIndex},
+	{ID: "0013_refresh_token_user_index", Up: createRefreshTokenUserIndex},
+}
+
+// errMigrationDeferred is returned by migrations that can't complete until
+// other data exists, such as an admin
+var errMigrationDeferred = errors.New("deferred")
+
+// migrationRecord is how an applied or deferred migration is recorded
+type migrationRecord struct {
+	ID        string    `bson:"_id"`
+	AppliedAt time.Time `bson:"applied_at"`
+	Deferred  bool      `bson:"deferred,omitempty"`
+}
+
+// RunMigrations applies the pending and deferred migrations to the named
+// collections of db
+func RunMigrations(ctx context.Context, db *mongo.Database, names CollectionNames) error {
+	applied := db.Collection(names.Migrations)
+
+	for _, migration := range Migrations {
+		var record migrationRecord
+		err := applied.FindOne(ctx, bson.M{"_id": migration.ID}).Decode(&record)
+		if err == nil && !record.Deferred {
+			continue
+		}
+		if err != nil && err != mongo.ErrNoDocuments {
+			return err
+		}
+
+		record = migrationRecord{ID: migration.ID, AppliedAt: now()}
+		if err := migration.Up(ctx, db, names); errors.Is(err, errMigrationDeferred) {
+			log.Printf("Migration %s deferred until the next start: %v", migration.ID, err)
+			record.Deferred = true
+		} else if err != nil {
+			return fmt.Errorf("migration %s failed: %w", migration.ID, err)
+		}
+
+		_, err = applied.ReplaceOne(ctx, bson.M{"_id": migration.ID}, record, options.Replace().SetUpsert(true))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// PendingMigrations lists the IDs of the migrations not yet applied to db.
+// Deferred migrations have run as far as they can and aren't listed.
+func PendingMigrations(ctx context.Context, db *mongo.Database, names CollectionNames) ([]string, error) {
+	applied := db.Collection(names.Migrations)
+
+	var pending []string
+	for _, migration := range Migrations {
+		err := applied.FindOne(ctx, bson.M{"_id": migration.ID}).Err()
+		if err == mongo.ErrNoDocuments {
+			pending = append(pending, migration.ID)
+		} else if err != nil {
+			return nil, err
+		}
+	}
+
+	return pending, nil
+}
+
+// legacyDueDateLayouts are the free-form formats clients used to send before
+// due dates were typed
+var legacyDueDateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02",
+	"2006/01/02",
+	"January 2, 2006",
+	"Jan 2, 2006",
+	"2 January 2006",
+}
+
+// migrateTaskDueDates converts string due dates to BSON dates and backfills
+// created_at/updated_at from the ObjectID timestamp. Values that cannot be
+// parsed are kept in legacy_due_date so nothing is silently lost.
+func migrateTaskDueDates(ctx context.Context, db *mongo.Database, names CollectionNames) error {
+	tasks := db.Collection(names.Tasks)
+
+	cur, err := tasks.Find(ctx, bson.M{
+		"$or": []bson.M{
+			{"due_date": bson.M{"$type": "string"}},
+			{"created_at": bson.M{"$exists": false}},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	defer cur.Close(ctx)
+
+	for cur.Next(ctx) {
+		var doc struct {
+			ID        primitive.ObjectID `bson:"_id"`
+			DueDate   interface{}        `bson:"due_date"`
+			CreatedAt *time.Time         `bson:"created_at"`
+		}
+		if err := cur.Decode(&doc); err != nil {
+			return err
+		}
+
+		set := bson.M{}
+		unset := bson.M{}
+
+		if raw, ok := doc.DueDate.(string); ok {
+			if due, ok := parseLegacyDueDate(raw); ok {
+				set["due_date"] = due
+			} else {
+				unset["due_date"] = ""
+				if strings.TrimSpace(raw) != "" {
+					set["legacy_due_date"] = raw
+				}
+			}
+		}
+
+		if doc.CreatedAt == nil {
+			created := doc.ID.Timestamp().UTC()
+			set["created_at"] = created
+			set["updated_at"] = created
+		}
+
+		update := bson.M{}
+		if len(set) > 0 {
+			update["$set"] = set
+		}
+		if len(unset) > 0 {
+			update["$unset"] = unset
+		}
+		if len(update) == 0 {
+			continue
+		}
+
+		if _, err := tasks.UpdateByID(ctx, doc.ID, update); err != nil {
+			return err
+		}
+	}
+
+	return cur.Err()
+}
+
+func parseLegacyDueDate(raw string) (time.Time, bool) {
+	raw = strings.TrimSpace(raw)
+	for _, layout := range legacyDueDateLayouts {
+		if due, err := time.Parse(layout, raw); err == nil {
+			return due.UTC(), true
+		}
+	}
+	return time.Time{}, false
+}
+
+// legacyStatuses maps the free-form statuses used before the state machine
+// to their defined equivalents
+var legacyStatuses = map[string]domain.TaskStatus{
+	"":            domain.StatusTodo,
+	"pending":     domain.StatusTodo,
+	"in-progress": domain.StatusInProgress,
+	"completed":   domain.StatusDone,
+}
+
+// migrateTaskStatuses rewrites legacy statuses. Anything unrecognised
+// becomes todo and the original value is kept in legacy_status.
+func migrateTaskStatuses(ctx context.Context, db *mongo.Database, names CollectionNames) error {
+	tasks := db.Collection(names.Tasks)
+
+	for legacy, status := range legacyStatuses {
+		_, err := tasks.UpdateMany(ctx,
+			bson.M{"status": legacy},
+			bson.M{"$set": bson.M{"status": status}},
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	known := []domain.TaskStatus{
+		domain.StatusTodo, domain.StatusInProgress, domain.StatusBlocked, domain.StatusDone, domain.StatusCancelled,
+	}
+	_, err := tasks.UpdateMany(ctx,
+		bson.M{"status": bson.M{"$nin": known}},
+		mongo.Pipeline{
+			{{Key: "$set", Value: bson.M{"legacy_status": "$status", "status": domain.StatusTodo}}},
+		},
+	)
+	return err
+}
+
+// migrateTaskVersions starts existing tasks at version 1 so If-Match checks
+// work for tasks created before versioning
+func migrateTaskVersions(ctx context.Context, db *mongo.Database, names CollectionNames) error {
+	_, err := db.Collection(names.Tasks).UpdateMany(ctx,
+		bson.M{"version": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"version": int64(1)}},
+	)
+	return err
+}
+
+// migrateTaskOwners gives tasks created before ownership was tracked to the
+// first admin, so they stay reachable and can be reassigned. Without an admin
+// the tasks are left as they are, visible to admins only, and the migration
+// is deferred until a start after one exists.
+func migrateTaskOwners(ctx context.Context, db *mongo.Database, names CollectionNames) error {
+	ownerless := bson.M{"created_by": bson.M{"$exists": false}}
+
+	var admin userDocument
+	err := db.Collection(names.Users).FindOne(ctx,
+		bson.M{"role": domain.RoleAdmin},
+		options.FindOne().SetSort(bson.D{{Key: "_id", Value: 1}}),
+	).Decode(&admin)
+	if err == mongo.ErrNoDocuments {
+		count, err := db.Collection(names.Tasks).CountDocuments(ctx, ownerless)
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			return fmt.Errorf("no admin to own %d tasks without a creator: %w", count, errMigrationDeferred)
+		}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Collection(names.Tasks).UpdateMany(ctx, ownerless, bson.M{"$set": bson.M{"created_by": admin.ID}})
+	return err
+}
+
+// createTokenIndexes lets MongoDB delete refresh tokens and revoked access
+// tokens once they expire, and indexes refresh tokens by family for revocation
+func createTokenIndexes(ctx context.Context, db *mongo.Database, names CollectionNames) error {
+	expiry := mongo.IndexModel{
+		Keys:    bson.D{{Key: "expires_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	}
+
+	_, err := db.Collection(names.RefreshTokens).Indexes().CreateMany(ctx, []mongo.IndexModel{
+		expiry,
+		{Keys: bson.D{{Key: "family_id", Value: 1}}},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Collection(names.RevokedTokens).Indexes().CreateOne(ctx, expiry)
+	return err
+}
+
+// migrateMemberRole renames the "user" role to "member", the role with the
+// same permissions since roles were split up
+func migrateMemberRole(ctx context.Context, db *mongo.Database, names CollectionNames) error {
+	_, err := db.Collection(names.Users).UpdateMany(ctx,
+		bson.M{"role": "user"},
+		bson.M{"$set": bson.M{"role": domain.RoleMember}},
+	)
+	return err
+}
+
+// createResetTokenIndexes lets MongoDB delete password reset tokens once
+// they expire, and indexes them by user so a reset can delete the rest
+func createResetTokenIndexes(ctx context.Context, db *mongo.Database, names CollectionNames) error {
+	_, err := db.Collection(names.PasswordResetTokens).Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "expires_at", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+		{Keys: bson.D{{Key: "user_id", Value: 1}}},
+	})
+	return err
+}
+
+// emailCollation compares email addresses regardless of case. Queries must
+// use it to match the unique index on users.email.
+var emailCollation = &options.Collation{Locale: "en", Strength: 2}
+
+// createUserEmailIndexes makes email addresses unique regardless of case and
+// lets MongoDB delete email verification tokens once they expire. Users
+// without an address are left out of the unique index.
+func createUserEmailIndexes(ctx context.Context, db *mongo.Database, names CollectionNames) error {
+	_, err := db.Collection(names.Users).Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "email", Value: 1}},
+		Options: options.Index().
+			SetUnique(true).
+			SetCollation(emailCollation).
+			SetPartialFilterExpression(bson.M{"email": bson.M{"$type": "string"}}),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Collection(names.EmailVerificationTokens).Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "expires_at", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+		{Keys: bson.D{{Key: "user_id", Value: 1}}},
+	})
+	return err
+}
+
+// createMFAChallengeIndexes lets MongoDB delete MFA challenges once they
+// expire. Enrollments are keyed by user ID and need no index.
+func createMFAChallengeIndexes(ctx context.Context, db *mongo.Database, names CollectionNames) error {
+	_, err := db.Collection(names.MFAChallenges).Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "expires_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	return err
+}
+
+// createLoginThrottleIndexes lets MongoDB forget failed logins once they
+// expire
+func createLoginThrottleIndexes(ctx context.Context, db *mongo.Database, names CollectionNames) error {
+	_, err := db.Collection(names.LoginThrottles).Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "expires_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	return err
+}
+
+// usernameIndex is the name of the unique index on usernames, which
+// duplicate key errors mention
+const usernameIndex = "username_1"
+
+// createUsernameIndex makes usernames unique, so concurrent registrations
+// can't create two users with the same one. Duplicates registered before
+// are listed for an operator to rename, as the index can't be built
+// around them.
+func createUsernameIndex(ctx context.Context, db *mongo.Database, names CollectionNames) error {
+	users := db.Collection(names.Users)
+
+	cur, err := users.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$group", Value: bson.M{"_id": "$username", "count": bson.M{"$sum": 1}}}},
+		{{Key: "$match", Value: bson.M{"count": bson.M{"$gt": 1}}}},
+	})
+	if err != nil {
+		return err
+	}
+	var duplicates []struct {
+		Username string `bson:"_id"`
+	}
+	if err := cur.All(ctx, &duplicates); err != nil {
+		return err
+	}
+	if len(duplicates) > 0 {
+		names := make([]string, len(duplicates))
+		for i, duplicate := range duplicates {
+			names[i] = fmt.Sprintf("%q", duplicate.Username)
+		}
+		return fmt.Errorf("usernames used by more than one user: %s", strings.Join(names, ", "))
+	}
+
+	_, err = users.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "username", Value: 1}},
+		Options: options.Index().SetUnique(true).SetName(usernameIndex),
+	})
+	return err
+}
+
+// setupAdminIndex is the name of the unique index on admins created by
+// admin setup, which duplicate key errors mention
+const setupAdminIndex = "setup_admin_1"
+
+// createSetupAdminIndex lets at most one admin created by setup exist, so
+// concurrent setups can't both create one. Admins demoted since leave the
+// index.
+func createSetupAdminIndex(ctx context.Context, db *mongo.Database, names CollectionNames) error {
+	_, err := db.Collection(names.Users).Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "setup_admin", Value: 1}},
+		Options: options.Index().
+			SetUnique(true).
+			SetName(setupAdminIndex).
+			SetPartialFilterExpression(bson.M{"setup_admin": true, "role": domain.RoleAdmin}),
+	})
+	return err
+}
+
+// createRefreshTokenUserIndex indexes refresh tokens by user, so changing a
+// password can revoke all of them
+func createRefreshTokenUserIndex(ctx context.Context, db *mongo.Database, names CollectionNames) error {
+	_, err := db.Collection(names.RefreshTokens).Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "user_id", Value: 1}},
+	})
+	return err
+}
@@ -0,0 +1,125 @@
+package repositories
+
+import (
+	"task-manager/Domain"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// taskDocument is the MongoDB representation of a task. IDs stay stored as
+// ObjectIDs so documents written before domain.ID existed keep matching.
+type taskDocument struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty"`
+	Title       string             `bson:"title"`
+	Description string             `bson:"description"`
+	DueDate     *time.Time         `bson:"due_date,omitempty"`
+	Status      domain.TaskStatus  `bson:"status"`
+	CreatedBy   primitive.ObjectID `bson:"created_by,omitempty"`
+	AssigneeID  primitive.ObjectID `bson:"assignee_id,omitempty"`
+	CreatedAt   time.Time          `bson:"created_at"`
+	UpdatedAt   time.Time          `bson:"updated_at"`
+	Version     int64              `bson:"version"`
+}
+
+func newTaskDocument(task domain.Task) (taskDocument, error) {
+	doc := taskDocument{
+		Title:       task.Title,
+		Description: task.Description,
+		DueDate:     task.DueDate,
+		Status:      task.Status,
+		CreatedAt:   task.CreatedAt,
+		UpdatedAt:   task.UpdatedAt,
+		Version:     task.Version,
+	}
+
+	var err error
+	if doc.ID, err = objectID(task.ID); err != nil {
+		return taskDocument{}, err
+	}
+	if doc.CreatedBy, err = objectID(task.CreatedBy); err != nil {
+		return taskDocument{}, err
+	}
+	if doc.AssigneeID, err = objectID(task.AssigneeID); err != nil {
+		return taskDocument{}, err
+	}
+	return doc, nil
+}
+
+func (doc taskDocument) toDomain() domain.Task {
+	return domain.Task{
+		ID:          domainID(doc.ID),
+		Title:       doc.Title,
+		Description: doc.Description,
+		DueDate:     doc.DueDate,
+		Status:      doc.Status,
+		CreatedBy:   domainID(doc.CreatedBy),
+		AssigneeID:  domainID(doc.AssigneeID),
+		CreatedAt:   doc.CreatedAt,
+		UpdatedAt:   doc.UpdatedAt,
+		Version:     doc.Version,
+	}
+}
+
+// userDocument is the MongoDB representation of a user
+type userDocument struct {
+	ID       primitive.ObjectID `bson:"_id,omitempty"`
+	Username string             `bson:"username"`
+	Password string             `bson:"password"`
+	Role     string             `bson:"role"`
+	Disabled bool               `bson:"disabled,omitempty"`
+	// Users without an address have no email field, which keeps them out
+	// of the unique index
+	Email         string `bson:"email,omitempty"`
+	EmailVerified bool   `bson:"email_verified,omitempty"`
+	// SetupAdmin marks the admin created by admin setup
+	SetupAdmin bool `bson:"setup_admin,omitempty"`
+}
+
+func newUserDocument(user domain.User) (userDocument, error) {
+	id, err := objectID(user.ID)
+	if err != nil {
+		return userDocument{}, err
+	}
+	return userDocument{
+		ID:            id,
+		Username:      user.Username,
+		Password:      user.Password,
+		Role:          user.Role,
+		Disabled:      user.Disabled,
+		Email:         user.Email,
+		EmailVerified: user.EmailVerified,
+	}, nil
+}
+
+func (doc userDocument) toDomain() domain.User {
+	return domain.User{
+		ID:            domainID(doc.ID),
+		Username:      doc.Username,
+		Password:      doc.Password,
+		Role:          doc.Role,
+		Disabled:      doc.Disabled,
+		Email:         doc.Email,
+		EmailVerified: doc.EmailVerified,
+	}
+}
+
+// objectID converts a domain ID to an ObjectID. The empty ID maps to the zero
+// ObjectID, which omitempty fields leave out of the document.
+func objectID(id domain.ID) (primitive.ObjectID, error) {
+	if id.IsZero() {
+		return primitive.NilObjectID, nil
+	}
+	objID, err := primitive.ObjectIDFromHex(id.String())
+	if err != nil {
+		return primitive.NilObjectID, domain.InvalidID("invalid id %q", id)
+	}
+	return objID, nil
+}
+
+func domainID(objID primitive.ObjectID) domain.ID {
+	if objID.IsZero() {
+		return ""
+	}
+	return domain.ID(objID.Hex())
+}
@@ -0,0 +1,80 @@
+package repositories
+
+import (
+	"context"
+	"task-manager/Domain"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ResetTokenRepository stores password reset tokens in MongoDB. Expired
+// documents are removed by a TTL index on expires_at.
+type ResetTokenRepository struct {
+	collection *mongo.Collection
+	timeouts   Timeouts
+}
+
+func NewResetTokenRepository(collection *mongo.Collection, timeouts Timeouts) domain.ResetTokenRepository {
+	return &ResetTokenRepository{collection: collection, timeouts: timeouts}
+}
+
+// resetTokenDocument is the MongoDB representation of a password reset
+// token, keyed by the token hash
+type resetTokenDocument struct {
+	TokenHash string             `bson:"_id"`
+	UserID    primitive.ObjectID `bson:"user_id"`
+	ExpiresAt time.Time          `bson:"expires_at"`
+}
+
+func (rr *ResetTokenRepository) SaveResetToken(ctx context.Context, token domain.PasswordResetToken) error {
+	ctx, cancel := rr.timeouts.write(ctx)
+	defer cancel()
+
+	userID, err := objectID(token.UserID)
+	if err != nil {
+		return err
+	}
+
+	_, err = rr.collection.InsertOne(ctx, resetTokenDocument{
+		TokenHash: token.TokenHash,
+		UserID:    userID,
+		ExpiresAt: token.ExpiresAt,
+	})
+	return err
+}
+
+func (rr *ResetTokenRepository) ConsumeResetToken(ctx context.Context, tokenHash string) (domain.PasswordResetToken, error) {
+	ctx, cancel := rr.timeouts.write(ctx)
+	defer cancel()
+
+	var doc resetTokenDocument
+	err := rr.collection.FindOneAndDelete(ctx, bson.M{"_id": tokenHash}).Decode(&doc)
+	if err == mongo.ErrNoDocuments {
+		return domain.PasswordResetToken{}, domain.ErrResetTokenNotFound
+	}
+	if err != nil {
+		return domain.PasswordResetToken{}, err
+	}
+
+	return domain.PasswordResetToken{
+		TokenHash: doc.TokenHash,
+		UserID:    domainID(doc.UserID),
+		ExpiresAt: doc.ExpiresAt,
+	}, nil
+}
+
+func (rr *ResetTokenRepository) DeleteResetTokens(ctx context.Context, userID domain.ID) error {
+	ctx, cancel := rr.timeouts.write(ctx)
+	defer cancel()
+
+	objID, err := objectID(userID)
+	if err != nil {
+		return err
+	}
+
+	_, err = rr.collection.DeleteMany(ctx, bson.M{"user_id": objID})
+	return err
+}
@@ -0,0 +1,103 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/lib/pq"
+	"github.com/mattn/go-sqlite3"
+)
+
+// SQLDialect captures the differences between the SQL databases the
+// repositories support. Queries are written with ? placeholders and
+// rewritten by Rebind.
+type SQLDialect struct {
+	Name   string
+	Driver string
+
+	// numbered placeholders ($1, $2, ...) instead of ?
+	numbered bool
+	// collation used when sorting text so ordering matches the other backends
+	binaryCollation string
+	// LIMIT value that returns every row, since OFFSET needs a LIMIT in SQLite
+	noLimit string
+	// clause locking the rows a SELECT returns until the transaction ends.
+	// SQLite has none and needs none, since it runs one transaction at a time.
+	forUpdate         string
+	isUniqueViolation func(error) bool
+}
+
+var (
+	SQLite = SQLDialect{
+		Name:    "sqlite",
+		Driver:  "sqlite3",
+		noLimit: "-1",
+		isUniqueViolation: func(err error) bool {
+			var sqliteErr sqlite3.Error
+			return errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique
+		},
+	}
+
+	Postgres = SQLDialect{
+		Name:            "postgres",
+		Driver:          "postgres",
+		numbered:        true,
+		binaryCollation: ` COLLATE "C"`,
+		noLimit:         "ALL",
+		forUpdate:       " FOR UPDATE",
+		isUniqueViolation: func(err error) bool {
+			var pqErr *pq.Error
+			return errors.As(err, &pqErr) && pqErr.Code == "23505"
+		},
+	}
+)
+
+// SQLDialectByName returns the dialect for a STORAGE_BACKEND value
+func SQLDialectByName(name string) (SQLDialect, error) {
+	switch name {
+	case SQLite.Name:
+		return SQLite, nil
+	case Postgres.Name:
+		return Postgres, nil
+	}
+	return SQLDialect{}, fmt.Errorf("unknown SQL dialect %q", name)
+}
+
+// OpenSQL opens a database with the dialect's driver and checks it is reachable
+func OpenSQL(dialect SQLDialect, dsn string) (*sql.DB, error) {
+	db, err := sql.Open(dialect.Driver, dsn)
+	if err != nil {
+		return nil, err
+	}
+	if dialect.Name == SQLite.Name {
+		// SQLite allows a single writer; one connection avoids "database is locked"
+		db.SetMaxOpenConns(1)
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// Rebind rewrites ? placeholders for dialects that number their parameters
+func (d SQLDialect) Rebind(query string) string {
+	if !d.numbered {
+		return query
+	}
+
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
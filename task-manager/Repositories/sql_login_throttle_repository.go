@@ -0,0 +1,86 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"task-manager/Domain"
+	"time"
+)
+
+// SQLLoginThrottleRepository stores failed login counts in a SQL database.
+// Expired rows are deleted whenever a failure is recorded.
+type SQLLoginThrottleRepository struct {
+	db       *sql.DB
+	dialect  SQLDialect
+	timeouts Timeouts
+}
+
+func NewSQLLoginThrottleRepository(db *sql.DB, dialect SQLDialect, timeouts Timeouts) domain.LoginThrottleRepository {
+	return &SQLLoginThrottleRepository{
+		db:       db,
+		dialect:  dialect,
+		timeouts: timeouts,
+	}
+}
+
+func (lr *SQLLoginThrottleRepository) GetLoginThrottle(ctx context.Context, key string) (domain.LoginThrottle, error) {
+	ctx, cancel := lr.timeouts.read(ctx)
+	defer cancel()
+
+	return lr.scanThrottle(lr.db.QueryRowContext(ctx,
+		lr.dialect.Rebind("SELECT throttle_key, failures, last_failure, expires_at FROM login_throttles WHERE throttle_key = ?"),
+		key,
+	))
+}
+
+// RecordLoginFailure counts the failure with a single upsert, so concurrent
+// failures are all counted
+func (lr *SQLLoginThrottleRepository) RecordLoginFailure(ctx context.Context, key string, at time.Time, window time.Duration) (domain.LoginThrottle, error) {
+	ctx, cancel := lr.timeouts.write(ctx)
+	defer cancel()
+
+	_, err := lr.db.ExecContext(ctx,
+		lr.dialect.Rebind("DELETE FROM login_throttles WHERE expires_at < ?"),
+		toMillis(at),
+	)
+	if err != nil {
+		return domain.LoginThrottle{}, err
+	}
+
+	return lr.scanThrottle(lr.db.QueryRowContext(ctx,
+		lr.dialect.Rebind(`INSERT INTO login_throttles (throttle_key, failures, last_failure, expires_at) VALUES (?, 1, ?, ?)
+			ON CONFLICT (throttle_key) DO UPDATE SET
+				failures = login_throttles.failures + 1,
+				last_failure = excluded.last_failure,
+				expires_at = excluded.expires_at
+			RETURNING throttle_key, failures, last_failure, expires_at`),
+		key, toMillis(at), toMillis(at.Add(window)),
+	))
+}
+
+func (lr *SQLLoginThrottleRepository) ClearLoginThrottle(ctx context.Context, key string) error {
+	ctx, cancel := lr.timeouts.write(ctx)
+	defer cancel()
+
+	_, err := lr.db.ExecContext(ctx,
+		lr.dialect.Rebind("DELETE FROM login_throttles WHERE throttle_key = ?"),
+		key,
+	)
+	return err
+}
+
+func (lr *SQLLoginThrottleRepository) scanThrottle(row *sql.Row) (domain.LoginThrottle, error) {
+	var throttle domain.LoginThrottle
+	var lastFailure, expiresAt int64
+	err := row.Scan(&throttle.Key, &throttle.Failures, &lastFailure, &expiresAt)
+	if err == sql.ErrNoRows {
+		return domain.LoginThrottle{}, domain.ErrLoginThrottleNotFound
+	}
+	if err != nil {
+		return domain.LoginThrottle{}, err
+	}
+
+	throttle.LastFailure = fromMillis(lastFailure)
+	throttle.ExpiresAt = fromMillis(expiresAt)
+	return throttle, nil
+}
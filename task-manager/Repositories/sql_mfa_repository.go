@@ -0,0 +1,218 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"task-manager/Domain"
+)
+
+// SQLMFARepository stores TOTP enrollments, their recovery codes and MFA
+// challenges in a SQL database. Expired challenges are deleted whenever a
+// challenge is saved.
+type SQLMFARepository struct {
+	db       *sql.DB
+	dialect  SQLDialect
+	timeouts Timeouts
+}
+
+func NewSQLMFARepository(db *sql.DB, dialect SQLDialect, timeouts Timeouts) domain.MFARepository {
+	return &SQLMFARepository{
+		db:       db,
+		dialect:  dialect,
+		timeouts: timeouts,
+	}
+}
+
+// SaveEnrollment replaces the enrollment and its recovery codes in one
+// transaction
+func (mr *SQLMFARepository) SaveEnrollment(ctx context.Context, enrollment domain.MFAEnrollment) error {
+	ctx, cancel := mr.timeouts.write(ctx)
+	defer cancel()
+
+	tx, err := mr.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, table := range []string{"mfa_enrollments", "mfa_recovery_codes"} {
+		_, err := tx.ExecContext(ctx, mr.dialect.Rebind("DELETE FROM "+table+" WHERE user_id = ?"), enrollment.UserID)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx,
+		mr.dialect.Rebind("INSERT INTO mfa_enrollments (user_id, secret, confirmed, last_step) VALUES (?, ?, ?, ?)"),
+		enrollment.UserID, enrollment.Secret, enrollment.Confirmed, enrollment.LastStep,
+	)
+	if err != nil {
+		return err
+	}
+
+	for _, hash := range enrollment.RecoveryCodes {
+		_, err := tx.ExecContext(ctx,
+			mr.dialect.Rebind("INSERT INTO mfa_recovery_codes (user_id, code_hash) VALUES (?, ?)"),
+			enrollment.UserID, hash,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (mr *SQLMFARepository) GetEnrollment(ctx context.Context, userID domain.ID) (domain.MFAEnrollment, error) {
+	ctx, cancel := mr.timeouts.read(ctx)
+	defer cancel()
+
+	var enrollment domain.MFAEnrollment
+	err := mr.db.QueryRowContext(ctx,
+		mr.dialect.Rebind("SELECT user_id, secret, confirmed, last_step FROM mfa_enrollments WHERE user_id = ?"),
+		userID,
+	).Scan(&enrollment.UserID, &enrollment.Secret, &enrollment.Confirmed, &enrollment.LastStep)
+	if err == sql.ErrNoRows {
+		return domain.MFAEnrollment{}, domain.ErrMFAEnrollmentNotFound
+	}
+	if err != nil {
+		return domain.MFAEnrollment{}, err
+	}
+
+	rows, err := mr.db.QueryContext(ctx,
+		mr.dialect.Rebind("SELECT code_hash FROM mfa_recovery_codes WHERE user_id = ?"),
+		userID,
+	)
+	if err != nil {
+		return domain.MFAEnrollment{}, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var hash string
+		if err := rows.Scan(&hash); err != nil {
+			return domain.MFAEnrollment{}, err
+		}
+		enrollment.RecoveryCodes = append(enrollment.RecoveryCodes, hash)
+	}
+	return enrollment, rows.Err()
+}
+
+func (mr *SQLMFARepository) DeleteEnrollment(ctx context.Context, userID domain.ID) error {
+	ctx, cancel := mr.timeouts.write(ctx)
+	defer cancel()
+
+	tx, err := mr.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, table := range []string{"mfa_enrollments", "mfa_recovery_codes"} {
+		_, err := tx.ExecContext(ctx, mr.dialect.Rebind("DELETE FROM "+table+" WHERE user_id = ?"), userID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (mr *SQLMFARepository) UseTOTPStep(ctx context.Context, userID domain.ID, step int64) error {
+	ctx, cancel := mr.timeouts.write(ctx)
+	defer cancel()
+
+	res, err := mr.db.ExecContext(ctx,
+		mr.dialect.Rebind("UPDATE mfa_enrollments SET last_step = ? WHERE user_id = ? AND last_step < ?"),
+		step, userID, step,
+	)
+	if err != nil {
+		return err
+	}
+	updated, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if updated == 0 {
+		return domain.ErrMFACodeReused
+	}
+	return nil
+}
+
+func (mr *SQLMFARepository) UseRecoveryCode(ctx context.Context, userID domain.ID, codeHash string) error {
+	ctx, cancel := mr.timeouts.write(ctx)
+	defer cancel()
+
+	res, err := mr.db.ExecContext(ctx,
+		mr.dialect.Rebind("DELETE FROM mfa_recovery_codes WHERE user_id = ? AND code_hash = ?"),
+		userID, codeHash,
+	)
+	if err != nil {
+		return err
+	}
+	deleted, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return domain.ErrRecoveryCodeNotFound
+	}
+	return nil
+}
+
+func (mr *SQLMFARepository) SaveMFAChallenge(ctx context.Context, challenge domain.MFAChallenge) error {
+	ctx, cancel := mr.timeouts.write(ctx)
+	defer cancel()
+
+	_, err := mr.db.ExecContext(ctx,
+		mr.dialect.Rebind("DELETE FROM mfa_challenges WHERE expires_at < ?"),
+		toMillis(now()),
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = mr.db.ExecContext(ctx,
+		mr.dialect.Rebind("INSERT INTO mfa_challenges (token_hash, user_id, username, client_ip, expires_at, attempts) VALUES (?, ?, ?, ?, ?, ?)"),
+		challenge.TokenHash, challenge.UserID, challenge.Username, challenge.ClientIP, toMillis(challenge.ExpiresAt), challenge.Attempts,
+	)
+	return err
+}
+
+// ConsumeMFAChallenge reads the challenge and then deletes it. Only the
+// request whose delete removes the row gets the challenge back.
+func (mr *SQLMFARepository) ConsumeMFAChallenge(ctx context.Context, tokenHash string) (domain.MFAChallenge, error) {
+	ctx, cancel := mr.timeouts.write(ctx)
+	defer cancel()
+
+	var challenge domain.MFAChallenge
+	var expiresAt int64
+	err := mr.db.QueryRowContext(ctx,
+		mr.dialect.Rebind("SELECT token_hash, user_id, username, client_ip, expires_at, attempts FROM mfa_challenges WHERE token_hash = ?"),
+		tokenHash,
+	).Scan(&challenge.TokenHash, &challenge.UserID, &challenge.Username, &challenge.ClientIP, &expiresAt, &challenge.Attempts)
+	if err == sql.ErrNoRows {
+		return domain.MFAChallenge{}, domain.ErrMFAChallengeNotFound
+	}
+	if err != nil {
+		return domain.MFAChallenge{}, err
+	}
+
+	res, err := mr.db.ExecContext(ctx,
+		mr.dialect.Rebind("DELETE FROM mfa_challenges WHERE token_hash = ?"),
+		tokenHash,
+	)
+	if err != nil {
+		return domain.MFAChallenge{}, err
+	}
+	deleted, err := res.RowsAffected()
+	if err != nil {
+		return domain.MFAChallenge{}, err
+	}
+	if deleted == 0 {
+		return domain.MFAChallenge{}, domain.ErrMFAChallengeNotFound
+	}
+
+	challenge.ExpiresAt = fromMillis(expiresAt)
+	return challenge, nil
+}
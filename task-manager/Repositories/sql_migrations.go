@@ -0,0 +1,222 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// SQLMigration is a versioned schema change for the SQL backends. The
+// statements are written to run unchanged on every supported dialect.
+type SQLMigration struct {
+	ID         string
+	Statements []string
+}
+
+var SQLMigrations = []SQLMigration{
+	{
+		ID: "0001_create_users_and_tasks",
+		Statements: []string{
+			`CREATE TABLE users (
+				id       VARCHAR(24) PRIMARY KEY,
+				username TEXT NOT NULL UNIQUE,
+				password TEXT NOT NULL,
+				role     TEXT NOT NULL
+			)`,
+			`CREATE TABLE tasks (
+				id          VARCHAR(24) PRIMARY KEY,
+				title       TEXT NOT NULL,
+				description TEXT NOT NULL,
+				due_date    BIGINT,
+				status      TEXT NOT NULL,
+				created_by  VARCHAR(24) NOT NULL,
+				assignee_id VARCHAR(24) NOT NULL,
+				created_at  BIGINT NOT NULL,
+				updated_at  BIGINT NOT NULL,
+				version     BIGINT NOT NULL
+			)`,
+			`CREATE INDEX tasks_created_by ON tasks (created_by)`,
+			`CREATE INDEX tasks_assignee_id ON tasks (assignee_id)`,
+			`CREATE INDEX tasks_status ON tasks (status)`,
+		},
+	},
+	{
+		ID: "0002_create_tokens",
+		Statements: []string{
+			`CREATE TABLE refresh_tokens (
+				token_hash VARCHAR(64) PRIMARY KEY,
+				user_id    VARCHAR(24) NOT NULL,
+				family_id  VARCHAR(24) NOT NULL,
+				expires_at BIGINT NOT NULL,
+				revoked    BOOLEAN NOT NULL
+			)`,
+			`CREATE INDEX refresh_tokens_family_id ON refresh_tokens (family_id)`,
+			`CREATE TABLE revoked_tokens (
+				token_id   VARCHAR(64) PRIMARY KEY,
+				expires_at BIGINT NOT NULL
+			)`,
+		},
+	},
+	{
+		ID:         "0003_member_role",
+		Statements: []string{`UPDATE users SET role = 'member' WHERE role = 'user'`},
+	},
+	{
+		ID:         "0004_user_disabled",
+		Statements: []string{`ALTER TABLE users ADD COLUMN disabled BOOLEAN NOT NULL DEFAULT FALSE`},
+	},
+	{
+		ID: "0005_create_password_reset_tokens",
+		Statements: []string{
+			`CREATE TABLE password_reset_tokens (
+				token_hash VARCHAR(64) PRIMARY KEY,
+				user_id    VARCHAR(24) NOT NULL,
+				expires_at BIGINT NOT NULL
+			)`,
+			`CREATE INDEX password_reset_tokens_user_id ON password_reset_tokens (user_id)`,
+		},
+	},
+	{
+		ID: "0006_user_email",
+		Statements: []string{
+			`ALTER TABLE users ADD COLUMN email TEXT`,
+			`ALTER TABLE users ADD COLUMN email_verified BOOLEAN NOT NULL DEFAULT FALSE`,
+			`CREATE UNIQUE INDEX users_email ON users (LOWER(email))`,
+			`CREATE TABLE email_verification_tokens (
+				token_hash VARCHAR(64) PRIMARY KEY,
+				user_id    VARCHAR(24) NOT NULL,
+				email      TEXT NOT NULL,
+				expires_at BIGINT NOT NULL
+			)`,
+			`CREATE INDEX email_verification_tokens_user_id ON email_verification_tokens (user_id)`,
+		},
+	},
+	{
+		ID: "0007_create_mfa",
+		Statements: []string{
+			`CREATE TABLE mfa_enrollments (
+				user_id   VARCHAR(24) PRIMARY KEY,
+				secret    TEXT NOT NULL,
+				confirmed BOOLEAN NOT NULL,
+				last_step BIGINT NOT NULL
+			)`,
+			`CREATE TABLE mfa_recovery_codes (
+				user_id   VARCHAR(24) NOT NULL,
+				code_hash VARCHAR(64) NOT NULL,
+				PRIMARY KEY (user_id, code_hash)
+			)`,
+			`CREATE TABLE mfa_challenges (
+				token_hash VARCHAR(64) PRIMARY KEY,
+				user_id    VARCHAR(24) NOT NULL,
+				expires_at BIGINT NOT NULL,
+				attempts   INTEGER NOT NULL
+			)`,
+		},
+	},
+	{
+		ID: "0008_create_login_throttles",
+		Statements: []string{
+			`CREATE TABLE login_throttles (
+				throttle_key TEXT PRIMARY KEY,
+				failures     INTEGER NOT NULL,
+				last_failure BIGINT NOT NULL,
+				expires_at   BIGINT NOT NULL
+			)`,
+		},
+	},
+	{
+		ID: "0009_mfa_challenge_login_keys",
+		Statements: []string{
+			`ALTER TABLE mfa_challenges ADD COLUMN username TEXT NOT NULL DEFAULT ''`,
+			`ALTER TABLE mfa_challenges ADD COLUMN client_ip TEXT NOT NULL DEFAULT ''`,
+		},
+	},
+	{
+		ID: "0010_setup_admin",
+		Statements: []string{
+			`ALTER TABLE users ADD COLUMN setup_admin BOOLEAN NOT NULL DEFAULT FALSE`,
+			`CREATE UNIQUE INDEX users_setup_admin ON users (setup_admin) WHERE setup_admin AND role = 'admin'`,
+		},
+	},
+	{
+		ID:         "0011_refresh_token_user_ids",
+		Statements: []string{`CREATE INDEX refresh_tokens_user_id ON refresh_tokens (user_id)`},
+	},
+}
+
+// RunSQLMigrations applies every migration that has not run yet, recording
+// each one in schema_migrations. Each migration runs in its own transaction.
+func RunSQLMigrations(ctx context.Context, db *sql.DB, dialect SQLDialect) error {
+	_, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		id         VARCHAR(255) PRIMARY KEY,
+		applied_at BIGINT NOT NULL
+	)`)
+	if err != nil {
+		return err
+	}
+
+	for _, migration := range SQLMigrations {
+		var count int
+		err := db.QueryRowContext(ctx,
+			dialect.Rebind(`SELECT COUNT(*) FROM schema_migrations WHERE id = ?`),
+			migration.ID,
+		).Scan(&count)
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			continue
+		}
+
+		if err := applySQLMigration(ctx, db, dialect, migration); err != nil {
+			return fmt.Errorf("migration %s failed: %w", migration.ID, err)
+		}
+	}
+
+	return nil
+}
+
+// PendingSQLMigrations lists the IDs of the migrations not yet applied to
+// db. It fails when schema_migrations doesn't exist yet.
+func PendingSQLMigrations(ctx context.Context, db *sql.DB, dialect SQLDialect) ([]string, error) {
+	var pending []string
+	for _, migration := range SQLMigrations {
+		var count int
+		err := db.QueryRowContext(ctx,
+			dialect.Rebind(`SELECT COUNT(*) FROM schema_migrations WHERE id = ?`),
+			migration.ID,
+		).Scan(&count)
+		if err != nil {
+			return nil, err
+		}
+		if count == 0 {
+			pending = append(pending, migration.ID)
+		}
+	}
+
+	return pending, nil
+}
+
+func applySQLMigration(ctx context.Context, db *sql.DB, dialect SQLDialect, migration SQLMigration) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, statement := range migration.Statements {
+		if _, err := tx.ExecContext(ctx, statement); err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx,
+		dialect.Rebind(`INSERT INTO schema_migrations (id, applied_at) VALUES (?, ?)`),
+		migration.ID, toMillis(now()),
+	)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,91 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"task-manager/Domain"
+)
+
+// SQLResetTokenRepository stores password reset tokens in a SQL database.
+// Expired rows are deleted whenever a token is saved.
+type SQLResetTokenRepository struct {
+	db       *sql.DB
+	dialect  SQLDialect
+	timeouts Timeouts
+}
+
+func NewSQLResetTokenRepository(db *sql.DB, dialect SQLDialect, timeouts Timeouts) domain.ResetTokenRepository {
+	return &SQLResetTokenRepository{
+		db:       db,
+		dialect:  dialect,
+		timeouts: timeouts,
+	}
+}
+
+func (rr *SQLResetTokenRepository) SaveResetToken(ctx context.Context, token domain.PasswordResetToken) error {
+	ctx, cancel := rr.timeouts.write(ctx)
+	defer cancel()
+
+	_, err := rr.db.ExecContext(ctx,
+		rr.dialect.Rebind("DELETE FROM password_reset_tokens WHERE expires_at < ?"),
+		toMillis(now()),
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = rr.db.ExecContext(ctx,
+		rr.dialect.Rebind("INSERT INTO password_reset_tokens (token_hash, user_id, expires_at) VALUES (?, ?, ?)"),
+		token.TokenHash, token.UserID, toMillis(token.ExpiresAt),
+	)
+	return err
+}
+
+// ConsumeResetToken reads the token and then deletes it. Only the request
+// whose delete removes the row gets the token back.
+func (rr *SQLResetTokenRepository) ConsumeResetToken(ctx context.Context, tokenHash string) (domain.PasswordResetToken, error) {
+	ctx, cancel := rr.timeouts.write(ctx)
+	defer cancel()
+
+	var token domain.PasswordResetToken
+	var expiresAt int64
+	err := rr.db.QueryRowContext(ctx,
+		rr.dialect.Rebind("SELECT token_hash, user_id, expires_at FROM password_reset_tokens WHERE token_hash = ?"),
+		tokenHash,
+	).Scan(&token.TokenHash, &token.UserID, &expiresAt)
+	if err == sql.ErrNoRows {
+		return domain.PasswordResetToken{}, domain.ErrResetTokenNotFound
+	}
+	if err != nil {
+		return domain.PasswordResetToken{}, err
+	}
+
+	res, err := rr.db.ExecContext(ctx,
+		rr.dialect.Rebind("DELETE FROM password_reset_tokens WHERE token_hash = ?"),
+		tokenHash,
+	)
+	if err != nil {
+		return domain.PasswordResetToken{}, err
+	}
+	deleted, err := res.RowsAffected()
+	if err != nil {
+		return domain.PasswordResetToken{}, err
+	}
+	if deleted == 0 {
+		return domain.PasswordResetToken{}, domain.ErrResetTokenNotFound
+	}
+
+	token.ExpiresAt = fromMillis(expiresAt)
+	return token, nil
+}
+
+func (rr *SQLResetTokenRepository) DeleteResetTokens(ctx context.Context, userID domain.ID) error {
+	ctx, cancel := rr.timeouts.write(ctx)
+	defer cancel()
+
+	_, err := rr.db.ExecContext(ctx,
+		rr.dialect.Rebind("DELETE FROM password_reset_tokens WHERE user_id = ?"),
+		userID,
+	)
+	return err
+}
@@ -0,0 +1,402 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"task-manager/Domain"
+	"time"
+)
+
+const taskColumns = "id, title, description, due_date, status, created_by, assignee_id, created_at, updated_at, version"
+
+// SQLTaskRepository stores tasks in a SQL database. Timestamps are kept as
+// Unix milliseconds so every dialect stores and compares them the same way.
+type SQLTaskRepository struct {
+	db       *sql.DB
+	dialect  SQLDialect
+	timeouts Timeouts
+}
+
+func NewSQLTaskRepository(db *sql.DB, dialect SQLDialect, timeouts Timeouts) domain.TaskRepository {
+	return &SQLTaskRepository{
+		db:       db,
+		dialect:  dialect,
+		timeouts: timeouts,
+	}
+}
+
+func (tr *SQLTaskRepository) QueryTasks(ctx context.Context, query domain.TaskQuery) ([]domain.Task, int64, error) {
+	ctx, cancel := tr.timeouts.read(ctx)
+	defer cancel()
+
+	where, args, err := sqlTaskFilter(query)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	orderBy, err := tr.taskOrder(query)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var total int64
+	err = tr.db.QueryRowContext(ctx, tr.dialect.Rebind("SELECT COUNT(*) FROM tasks"+where), args...).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	limit := tr.dialect.noLimit
+	if query.Limit > 0 {
+		limit = fmt.Sprint(query.Limit)
+	}
+	statement := "SELECT " + taskColumns + " FROM tasks" + where + " ORDER BY " + orderBy +
+		" LIMIT " + limit + " OFFSET ?"
+	args = append(args, query.Offset)
+
+	rows, err := tr.db.QueryContext(ctx, tr.dialect.Rebind(statement), args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	tasks := []domain.Task{}
+	for rows.Next() {
+		task, err := scanTask(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		tasks = append(tasks, task)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	return tasks, total, nil
+}
+
+func sqlTaskFilter(query domain.TaskQuery) (string, []interface{}, error) {
+	var conditions []string
+	var args []interface{}
+
+	if query.VisibleTo != "" {
+		if !domain.ID(query.VisibleTo).Valid() {
+			return "", nil, domain.InvalidID("invalid user ID")
+		}
+		conditions = append(conditions, "(created_by = ? OR assignee_id = ?)")
+		args = append(args, query.VisibleTo, query.VisibleTo)
+	}
+
+	if query.Status != "" {
+		conditions = append(conditions, "status = ?")
+		args = append(args, query.Status)
+	}
+
+	if query.Title != "" {
+		conditions = append(conditions, `LOWER(title) LIKE ? ESCAPE '\'`)
+		args = append(args, "%"+escapeLike(strings.ToLower(query.Title))+"%")
+	}
+
+	if !query.DueFrom.IsZero() {
+		conditions = append(conditions, "due_date >= ?")
+		args = append(args, toMillis(query.DueFrom))
+	}
+	if !query.DueTo.IsZero() {
+		conditions = append(conditions, "due_date <= ?")
+		args = append(args, toMillis(query.DueTo))
+	}
+
+	if len(conditions) == 0 {
+		return "", nil, nil
+	}
+	return " WHERE " + strings.Join(conditions, " AND "), args, nil
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// taskOrder builds the ORDER BY clause. Tasks without a due date sort first
+// and text compares byte-wise, as in MongoDB; id breaks ties so pages stay
+// stable between requests.
+func (tr *SQLTaskRepository) taskOrder(query domain.TaskQuery) (string, error) {
+	direction := " ASC"
+	if query.SortDesc {
+		direction = " DESC"
+	}
+
+	var keys []string
+	switch query.SortBy {
+	case "", "_id":
+	case "title", "status":
+		keys = append(keys, query.SortBy+tr.dialect.binaryCollation)
+	case "due_date":
+		keys = append(keys, "CASE WHEN due_date IS NULL THEN 0 ELSE 1 END", "due_date")
+	case "created_at", "updated_at":
+		keys = append(keys, query.SortBy)
+	default:
+		return "", fmt.Errorf("%w: cannot sort by %q", domain.ErrInvalidTaskQuery, query.SortBy)
+	}
+	keys = append(keys, "id")
+
+	for i := range keys {
+		keys[i] += direction
+	}
+	return strings.Join(keys, ", "), nil
+}
+
+func (tr *SQLTaskRepository) GetTaskByID(ctx context.Context, id string) (domain.Task, error) {
+	ctx, cancel := tr.timeouts.read(ctx)
+	defer cancel()
+
+	if !domain.ID(id).Valid() {
+		return domain.Task{}, domain.InvalidID("invalid id format")
+	}
+
+	row := tr.db.QueryRowContext(ctx, tr.dialect.Rebind("SELECT "+taskColumns+" FROM tasks WHERE id = ?"), id)
+	task, err := scanTask(row)
+	if err == sql.ErrNoRows {
+		return domain.Task{}, domain.ErrTaskNotFound
+	}
+
+	return task, err
+}
+
+func (tr *SQLTaskRepository) CreateTask(ctx context.Context, task domain.Task) (domain.Task, error) {
+	ctx, cancel := tr.timeouts.write(ctx)
+	defer cancel()
+
+	ts := now()
+	task.ID = domain.NewID()
+	task.DueDate = cloneTime(task.DueDate)
+	task.CreatedAt = ts
+	task.UpdatedAt = ts
+	task.Version = 1
+
+	_, err := tr.db.ExecContext(ctx,
+		tr.dialect.Rebind("INSERT INTO tasks ("+taskColumns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"),
+		task.ID, task.Title, task.Description, nullMillis(task.DueDate), task.Status,
+		task.CreatedBy, task.AssigneeID, toMillis(task.CreatedAt), toMillis(task.UpdatedAt), task.Version,
+	)
+	if err != nil {
+		return domain.Task{}, err
+	}
+
+	return task, nil
+}
+
+func (tr *SQLTaskRepository) UpdateTask(ctx context.Context, id string, updated domain.Task, version int64) (domain.Task, error) {
+	sets := []string{"title = ?", "description = ?", "due_date = ?", "status = ?"}
+	args := []interface{}{updated.Title, updated.Description, nullMillis(updated.DueDate), updated.Status}
+	if !updated.AssigneeID.IsZero() {
+		sets = append(sets, "assignee_id = ?")
+		args = append(args, updated.AssigneeID)
+	}
+
+	return tr.write(ctx, id, version, sets, args)
+}
+
+func (tr *SQLTaskRepository) PatchTask(ctx context.Context, id string, patch domain.TaskPatch, version int64) (domain.Task, error) {
+	var sets []string
+	var args []interface{}
+	if patch.Title != nil {
+		sets = append(sets, "title = ?")
+		args = append(args, *patch.Title)
+	}
+	if patch.Description != nil {
+		sets = append(sets, "description = ?")
+		args = append(args, *patch.Description)
+	}
+	if patch.ClearDueDate {
+		sets = append(sets, "due_date = NULL")
+	} else if patch.DueDate != nil {
+		sets = append(sets, "due_date = ?")
+		args = append(args, nullMillis(patch.DueDate))
+	}
+	if patch.Status != nil {
+		sets = append(sets, "status = ?")
+		args = append(args, *patch.Status)
+	}
+	if patch.AssigneeID != nil {
+		sets = append(sets, "assignee_id = ?")
+		args = append(args, *patch.AssigneeID)
+	}
+
+	return tr.write(ctx, id, version, sets, args)
+}
+
+// TransitionTask moves a task to a new status only if it is still in the
+// status the caller validated against, so concurrent transitions cannot
+// skip the state machine
+func (tr *SQLTaskRepository) TransitionTask(ctx context.Context, id string, from, to domain.TaskStatus) (domain.Task, error) {
+	ctx, cancel := tr.timeouts.write(ctx)
+	defer cancel()
+
+	if !domain.ID(id).Valid() {
+		return domain.Task{}, domain.InvalidID("invalid id format")
+	}
+
+	row := tr.db.QueryRowContext(ctx,
+		tr.dialect.Rebind("UPDATE tasks SET status = ?, updated_at = ?, version = version + 1 WHERE id = ? AND status = ? RETURNING "+taskColumns),
+		to, toMillis(now()), id, from,
+	)
+	task, err := scanTask(row)
+	if err == sql.ErrNoRows {
+		current, err := tr.GetTaskByID(ctx, id)
+		if err != nil {
+			return domain.Task{}, err
+		}
+		return domain.Task{}, &domain.TransitionError{From: current.Status, To: to}
+	}
+
+	return task, err
+}
+
+func (tr *SQLTaskRepository) DeleteTask(ctx context.Context, id string, version int64) error {
+	ctx, cancel := tr.timeouts.write(ctx)
+	defer cancel()
+
+	if !domain.ID(id).Valid() {
+		return domain.InvalidID("invalid id format")
+	}
+
+	where, args := sqlVersionFilter(id, version)
+	res, err := tr.db.ExecContext(ctx, tr.dialect.Rebind("DELETE FROM tasks"+where), args...)
+	if err != nil {
+		return err
+	}
+
+	deleted, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return tr.missingOrConflict(ctx, id)
+	}
+
+	return nil
+}
+
+func (tr *SQLTaskRepository) ReassignTasks(ctx context.Context, from, to domain.ID) error {
+	ctx, cancel := tr.timeouts.write(ctx)
+	defer cancel()
+
+	_, err := tr.db.ExecContext(ctx, tr.dialect.Rebind(
+		"UPDATE tasks SET "+
+			"created_by = CASE WHEN created_by = ? THEN ? ELSE created_by END, "+
+			"assignee_id = CASE WHEN assignee_id = ? THEN ? ELSE assignee_id END, "+
+			"updated_at = ?, version = version + 1 "+
+			"WHERE created_by = ? OR assignee_id = ?"),
+		from.String(), to.String(), from.String(), to.String(), toMillis(now()), from.String(), from.String(),
+	)
+	return err
+}
+
+func (tr *SQLTaskRepository) DeleteTasksCreatedBy(ctx context.Context, userID domain.ID) error {
+	ctx, cancel := tr.timeouts.write(ctx)
+	defer cancel()
+
+	_, err := tr.db.ExecContext(ctx, tr.dialect.Rebind("DELETE FROM tasks WHERE created_by = ?"), userID.String())
+	return err
+}
+
+func (tr *SQLTaskRepository) UnassignTasks(ctx context.Context, userID domain.ID) error {
+	ctx, cancel := tr.timeouts.write(ctx)
+	defer cancel()
+
+	_, err := tr.db.ExecContext(ctx, tr.dialect.Rebind(
+		"UPDATE tasks SET assignee_id = created_by, updated_at = ?, version = version + 1 WHERE assignee_id = ?"),
+		toMillis(now()), userID.String(),
+	)
+	return err
+}
+
+// write applies the SET clauses to a task, enforcing the same version check
+// as versionFilter, and returns the task as stored
+func (tr *SQLTaskRepository) write(ctx context.Context, id string, version int64, sets []string, args []interface{}) (domain.Task, error) {
+	ctx, cancel := tr.timeouts.write(ctx)
+	defer cancel()
+
+	if !domain.ID(id).Valid() {
+		return domain.Task{}, domain.InvalidID("invalid id format")
+	}
+
+	sets = append(sets, "updated_at = ?", "version = version + 1")
+	args = append(args, toMillis(now()))
+
+	where, whereArgs := sqlVersionFilter(id, version)
+	args = append(args, whereArgs...)
+
+	statement := "UPDATE tasks SET " + strings.Join(sets, ", ") + where + " RETURNING " + taskColumns
+	task, err := scanTask(tr.db.QueryRowContext(ctx, tr.dialect.Rebind(statement), args...))
+	if err == sql.ErrNoRows {
+		return domain.Task{}, tr.missingOrConflict(ctx, id)
+	}
+
+	return task, err
+}
+
+// sqlVersionFilter matches a task by ID and, unless version is zero, only
+// while it is still at the version the caller last read
+func sqlVersionFilter(id string, version int64) (string, []interface{}) {
+	if version == 0 {
+		return " WHERE id = ?", []interface{}{id}
+	}
+	return " WHERE id = ? AND version = ?", []interface{}{id, version}
+}
+
+// missingOrConflict explains why a versioned write matched nothing
+func (tr *SQLTaskRepository) missingOrConflict(ctx context.Context, id string) error {
+	var count int
+	err := tr.db.QueryRowContext(ctx, tr.dialect.Rebind("SELECT COUNT(*) FROM tasks WHERE id = ?"), id).Scan(&count)
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return domain.ErrTaskNotFound
+	}
+	return domain.ErrVersionConflict
+}
+
+// scanner is implemented by both *sql.Row and *sql.Rows
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanTask(row scanner) (domain.Task, error) {
+	var task domain.Task
+	var dueDate sql.NullInt64
+	var createdAt, updatedAt int64
+	err := row.Scan(
+		&task.ID, &task.Title, &task.Description, &dueDate, &task.Status,
+		&task.CreatedBy, &task.AssigneeID, &createdAt, &updatedAt, &task.Version,
+	)
+	if err != nil {
+		return domain.Task{}, err
+	}
+
+	if dueDate.Valid {
+		due := fromMillis(dueDate.Int64)
+		task.DueDate = &due
+	}
+	task.CreatedAt = fromMillis(createdAt)
+	task.UpdatedAt = fromMillis(updatedAt)
+	return task, nil
+}
+
+func toMillis(t time.Time) int64 {
+	return t.UnixMilli()
+}
+
+func fromMillis(ms int64) time.Time {
+	return time.UnixMilli(ms).UTC()
+}
+
+func nullMillis(t *time.Time) sql.NullInt64 {
+	if t == nil {
+		return sql.NullInt64{}
+	}
+	return sql.NullInt64{Int64: toMillis(*t), Valid: true}
+}
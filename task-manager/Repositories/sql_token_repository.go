@@ -0,0 +1,140 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"task-manager/Domain"
+	"time"
+)
+
+// SQLTokenRepository stores refresh tokens and revoked access tokens in a
+// SQL database. Expired rows stay until DeleteExpiredTokens deletes them.
+type SQLTokenRepository struct {
+	db       *sql.DB
+	dialect  SQLDialect
+	timeouts Timeouts
+}
+
+func NewSQLTokenRepository(db *sql.DB, dialect SQLDialect, timeouts Timeouts) domain.TokenRepository {
+	return &SQLTokenRepository{
+		db:       db,
+		dialect:  dialect,
+		timeouts: timeouts,
+	}
+}
+
+func (tr *SQLTokenRepository) SaveRefreshToken(ctx context.Context, token domain.RefreshToken) error {
+	ctx, cancel := tr.timeouts.write(ctx)
+	defer cancel()
+
+	_, err := tr.db.ExecContext(ctx,
+		tr.dialect.Rebind("INSERT INTO refresh_tokens (token_hash, user_id, family_id, expires_at, revoked) VALUES (?, ?, ?, ?, ?)"),
+		token.TokenHash, token.UserID, token.FamilyID, toMillis(token.ExpiresAt), token.Revoked,
+	)
+	return err
+}
+
+func (tr *SQLTokenRepository) GetRefreshToken(ctx context.Context, tokenHash string) (domain.RefreshToken, error) {
+	ctx, cancel := tr.timeouts.read(ctx)
+	defer cancel()
+
+	var token domain.RefreshToken
+	var expiresAt int64
+	err := tr.db.QueryRowContext(ctx,
+		tr.dialect.Rebind("SELECT token_hash, user_id, family_id, expires_at, revoked FROM refresh_tokens WHERE token_hash = ?"),
+		tokenHash,
+	).Scan(&token.TokenHash, &token.UserID, &token.FamilyID, &expiresAt, &token.Revoked)
+	if err == sql.ErrNoRows {
+		return domain.RefreshToken{}, domain.ErrRefreshTokenNotFound
+	}
+	if err != nil {
+		return domain.RefreshToken{}, err
+	}
+
+	token.ExpiresAt = fromMillis(expiresAt)
+	return token, nil
+}
+
+func (tr *SQLTokenRepository) RevokeRefreshToken(ctx context.Context, tokenHash string) error {
+	ctx, cancel := tr.timeouts.write(ctx)
+	defer cancel()
+
+	res, err := tr.db.ExecContext(ctx,
+		tr.dialect.Rebind("UPDATE refresh_tokens SET revoked = ? WHERE token_hash = ? AND revoked = ?"),
+		true, tokenHash, false,
+	)
+	if err != nil {
+		return err
+	}
+
+	revoked, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if revoked == 0 {
+		return domain.ErrRefreshTokenNotFound
+	}
+
+	return nil
+}
+
+func (tr *SQLTokenRepository) RevokeTokenFamily(ctx context.Context, familyID domain.ID) error {
+	ctx, cancel := tr.timeouts.write(ctx)
+	defer cancel()
+
+	_, err := tr.db.ExecContext(ctx,
+		tr.dialect.Rebind("UPDATE refresh_tokens SET revoked = ? WHERE family_id = ?"),
+		true, familyID,
+	)
+	return err
+}
+
+func (tr *SQLTokenRepository) RevokeUserTokens(ctx context.Context, userID domain.ID) error {
+	ctx, cancel := tr.timeouts.write(ctx)
+	defer cancel()
+
+	_, err := tr.db.ExecContext(ctx,
+		tr.dialect.Rebind("UPDATE refresh_tokens SET revoked = ? WHERE user_id = ?"),
+		true, userID,
+	)
+	return err
+}
+
+func (tr *SQLTokenRepository) RevokeAccessToken(ctx context.Context, tokenID string, expiresAt time.Time) error {
+	ctx, cancel := tr.timeouts.write(ctx)
+	defer cancel()
+
+	_, err := tr.db.ExecContext(ctx,
+		tr.dialect.Rebind("INSERT INTO revoked_tokens (token_id, expires_at) VALUES (?, ?) ON CONFLICT (token_id) DO NOTHING"),
+		tokenID, toMillis(expiresAt),
+	)
+	return err
+}
+
+func (tr *SQLTokenRepository) IsAccessTokenRevoked(ctx context.Context, tokenID string) (bool, error) {
+	ctx, cancel := tr.timeouts.read(ctx)
+	defer cancel()
+
+	var count int
+	err := tr.db.QueryRowContext(ctx,
+		tr.dialect.Rebind("SELECT COUNT(*) FROM revoked_tokens WHERE token_id = ?"),
+		tokenID,
+	).Scan(&count)
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (tr *SQLTokenRepository) DeleteExpiredTokens(ctx context.Context, before time.Time) error {
+	ctx, cancel := tr.timeouts.write(ctx)
+	defer cancel()
+
+	for _, table := range []string{"revoked_tokens", "refresh_tokens"} {
+		_, err := tr.db.ExecContext(ctx, tr.dialect.Rebind("DELETE FROM "+table+" WHERE expires_at < ?"), toMillis(before))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,403 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"task-manager/Domain"
+)
+
+const userColumns = "id, username, password, role, disabled, email, email_verified"
+
+// usersEmailIndex is the unique index on LOWER(email), named in the errors
+// of both dialects when an address is taken
+const usersEmailIndex = "users_email"
+
+// setupAdminColumn marks the admin created by admin setup. A unique partial
+// index on it is named in Postgres errors and the column in SQLite ones.
+const setupAdminColumn = "setup_admin"
+
+// SQLUserRepository stores users in a SQL database. Usernames, and email
+// addresses regardless of case, are unique at the schema level, so
+// concurrent registrations cannot create duplicates. Users without an
+// address have a NULL email.
+type SQLUserRepository struct {
+	db              *sql.DB
+	dialect         SQLDialect
+	passwordService domain.PasswordService
+	timeouts        Timeouts
+}
+
+func NewSQLUserRepository(
+	db *sql.DB,
+	dialect SQLDialect,
+	passwordService domain.PasswordService,
+	timeouts Timeouts,
+) *SQLUserRepository {
+	return &SQLUserRepository{
+		db:              db,
+		dialect:         dialect,
+		passwordService: passwordService,
+		timeouts:        timeouts,
+	}
+}
+
+func (ur *SQLUserRepository) RegisterUser(ctx context.Context, user domain.User) (domain.User, error) {
+	return ur.register(ctx, user, false)
+}
+
+// RegisterFirstAdmin marks the admin as created by setup. A unique partial
+// index covers such admins, so of setups racing past the count only one
+// insert goes through.
+func (ur *SQLUserRepository) RegisterFirstAdmin(ctx context.Context, user domain.User) (domain.User, error) {
+	countCtx, cancel := ur.timeouts.read(ctx)
+	var admins int
+	err := ur.db.QueryRowContext(countCtx,
+		ur.dialect.Rebind("SELECT COUNT(*) FROM users WHERE role = ?"),
+		domain.RoleAdmin,
+	).Scan(&admins)
+	cancel()
+	if err != nil {
+		return domain.User{}, err
+	}
+	if admins > 0 {
+		return domain.User{}, domain.ErrSetupComplete
+	}
+
+	user.Role = domain.RoleAdmin
+	return ur.register(ctx, user, true)
+}
+
+func (ur *SQLUserRepository) register(ctx context.Context, user domain.User, setupAdmin bool) (domain.User, error) {
+	ctx, cancel := ur.timeouts.write(ctx)
+	defer cancel()
+
+	if user.Username == "" || user.Password == "" {
+		return domain.User{}, domain.Validation("fields cannot be empty")
+	}
+
+	hashedPassword, err := ur.passwordService.HashPassword(user.Password)
+	if err != nil {
+		return domain.User{}, err
+	}
+	user.Password = hashedPassword
+	user.ID = domain.NewID()
+	user.Disabled = false
+	user.EmailVerified = false
+	if user.Role == "" {
+		user.Role = domain.RoleMember
+	}
+
+	_, err = ur.db.ExecContext(ctx,
+		ur.dialect.Rebind(`INSERT INTO users (id, username, password, role, email, setup_admin) VALUES (?, ?, ?, ?, ?, ?)`),
+		user.ID, user.Username, user.Password, user.Role, nullString(user.Email), setupAdmin,
+	)
+	if err != nil {
+		return domain.User{}, ur.conflict(err)
+	}
+
+	user.Password = ""
+	return user, nil
+}
+
+func (ur *SQLUserRepository) PromoteUser(ctx context.Context, id string) (domain.User, error) {
+	return ur.update(ctx, id, "role = ?", domain.RoleAdmin)
+}
+
+func (ur *SQLUserRepository) GetUserByUsername(ctx context.Context, username string) (domain.User, error) {
+	ctx, cancel := ur.timeouts.read(ctx)
+	defer cancel()
+
+	row := ur.db.QueryRowContext(ctx, ur.dialect.Rebind("SELECT "+userColumns+" FROM users WHERE username = ?"), username)
+	user, err := scanUser(row)
+	if err == sql.ErrNoRows {
+		return domain.User{}, domain.ErrUserNotFound
+	}
+
+	return user, err
+}
+
+func (ur *SQLUserRepository) GetUserByID(ctx context.Context, id string) (domain.User, error) {
+	ctx, cancel := ur.timeouts.read(ctx)
+	defer cancel()
+
+	if !domain.ID(id).Valid() {
+		return domain.User{}, domain.InvalidID("invalid user ID")
+	}
+
+	row := ur.db.QueryRowContext(ctx, ur.dialect.Rebind("SELECT "+userColumns+" FROM users WHERE id = ?"), id)
+	user, err := scanUser(row)
+	if err == sql.ErrNoRows {
+		return domain.User{}, domain.ErrUserNotFound
+	}
+
+	return user, err
+}
+
+func (ur *SQLUserRepository) ListUsers(ctx context.Context, query domain.UserQuery) ([]domain.User, int64, error) {
+	ctx, cancel := ur.timeouts.read(ctx)
+	defer cancel()
+
+	var conditions []string
+	var args []interface{}
+	if query.Role != "" {
+		conditions = append(conditions, "role = ?")
+		args = append(args, query.Role)
+	}
+	if query.Disabled != nil {
+		conditions = append(conditions, "disabled = ?")
+		args = append(args, *query.Disabled)
+	}
+	where := ""
+	if len(conditions) > 0 {
+		where = " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	var total int64
+	err := ur.db.QueryRowContext(ctx, ur.dialect.Rebind("SELECT COUNT(*) FROM users"+where), args...).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	limit := ur.dialect.noLimit
+	if query.Limit > 0 {
+		limit = fmt.Sprint(query.Limit)
+	}
+	statement := "SELECT " + userColumns + " FROM users" + where + " ORDER BY id LIMIT " + limit + " OFFSET ?"
+	args = append(args, query.Offset)
+
+	rows, err := ur.db.QueryContext(ctx, ur.dialect.Rebind(statement), args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	users := []domain.User{}
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		user.Password = ""
+		users = append(users, user)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	return users, total, nil
+}
+
+func (ur *SQLUserRepository) SetUserRole(ctx context.Context, id string, role string) (domain.User, error) {
+	if role == domain.RoleAdmin {
+		return ur.update(ctx, id, "role = ?", role)
+	}
+	return ur.updateKeepingAdmin(ctx, id, "role = ?", role)
+}
+
+func (ur *SQLUserRepository) SetUserDisabled(ctx context.Context, id string, disabled bool) (domain.User, error) {
+	if !disabled {
+		return ur.update(ctx, id, "disabled = ?", disabled)
+	}
+	return ur.updateKeepingAdmin(ctx, id, "disabled = ?", disabled)
+}
+
+func (ur *SQLUserRepository) DeleteUser(ctx context.Context, id string) error {
+	return ur.keepingAdmin(ctx, id, func(ctx context.Context, tx *sql.Tx) error {
+		res, err := tx.ExecContext(ctx, ur.dialect.Rebind("DELETE FROM users WHERE id = ?"), id)
+		if err != nil {
+			return err
+		}
+		deleted, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if deleted == 0 {
+			return domain.ErrUserNotFound
+		}
+		return nil
+	})
+}
+
+func (ur *SQLUserRepository) UpdateProfile(ctx context.Context, id string, patch domain.ProfilePatch) (domain.User, error) {
+	if patch.IsEmpty() {
+		user, err := ur.GetUserByID(ctx, id)
+		user.Password = ""
+		return user, err
+	}
+
+	var sets []string
+	var args []interface{}
+	if patch.Username != nil {
+		sets = append(sets, "username = ?")
+		args = append(args, *patch.Username)
+	}
+	if patch.Email != nil {
+		// Verification is kept when only the case of the address changes
+		sets = append(sets,
+			"email_verified = CASE WHEN LOWER(email) = LOWER(?) THEN email_verified ELSE ? END",
+			"email = ?",
+		)
+		args = append(args, *patch.Email, false, nullString(*patch.Email))
+	}
+
+	user, err := ur.update(ctx, id, strings.Join(sets, ", "), args...)
+	if err != nil {
+		return domain.User{}, ur.conflict(err)
+	}
+	return user, nil
+}
+
+func (ur *SQLUserRepository) SetPassword(ctx context.Context, id string, hash string) error {
+	_, err := ur.update(ctx, id, "password = ?", hash)
+	return err
+}
+
+func (ur *SQLUserRepository) MarkEmailVerified(ctx context.Context, id string, email string) (domain.User, error) {
+	ctx, cancel := ur.timeouts.write(ctx)
+	defer cancel()
+
+	if !domain.ID(id).Valid() {
+		return domain.User{}, domain.InvalidID("invalid user ID")
+	}
+
+	row := ur.db.QueryRowContext(ctx,
+		ur.dialect.Rebind("UPDATE users SET email_verified = ? WHERE id = ? AND email = ? RETURNING "+userColumns),
+		true, id, email,
+	)
+	user, err := scanUser(row)
+	if err == sql.ErrNoRows {
+		return domain.User{}, domain.ErrUserNotFound
+	}
+	if err != nil {
+		return domain.User{}, err
+	}
+
+	user.Password = ""
+	return user, nil
+}
+
+// conflict turns unique violations into Conflict errors naming the field
+func (ur *SQLUserRepository) conflict(err error) error {
+	if !ur.dialect.isUniqueViolation(err) {
+		return err
+	}
+	if strings.Contains(err.Error(), setupAdminColumn) {
+		return domain.ErrSetupComplete
+	}
+	if strings.Contains(err.Error(), usersEmailIndex) {
+		return domain.ErrEmailTaken
+	}
+	return domain.Conflict("username already taken")
+}
+
+// update applies a SET clause to a user and returns it without its
+// password. The args fill the clause's placeholders.
+func (ur *SQLUserRepository) update(ctx context.Context, id string, set string, args ...interface{}) (domain.User, error) {
+	ctx, cancel := ur.timeouts.write(ctx)
+	defer cancel()
+
+	if !domain.ID(id).Valid() {
+		return domain.User{}, domain.InvalidID("invalid user ID")
+	}
+	return ur.updateRow(ctx, ur.db, id, set, args...)
+}
+
+// updateKeepingAdmin is update for changes that take admin rights away,
+// refusing them with ErrLastAdmin for the only enabled admin
+func (ur *SQLUserRepository) updateKeepingAdmin(ctx context.Context, id string, set string, args ...interface{}) (domain.User, error) {
+	var user domain.User
+	err := ur.keepingAdmin(ctx, id, func(ctx context.Context, tx *sql.Tx) error {
+		var err error
+		user, err = ur.updateRow(ctx, tx, id, set, args...)
+		return err
+	})
+	return user, err
+}
+
+func (ur *SQLUserRepository) updateRow(ctx context.Context, q rowQuerier, id string, set string, args ...interface{}) (domain.User, error) {
+	row := q.QueryRowContext(ctx,
+		ur.dialect.Rebind("UPDATE users SET "+set+" WHERE id = ? RETURNING "+userColumns),
+		append(args, id)...,
+	)
+	user, err := scanUser(row)
+	if err == sql.ErrNoRows {
+		return domain.User{}, domain.ErrUserNotFound
+	}
+	if err != nil {
+		return domain.User{}, err
+	}
+
+	user.Password = ""
+	return user, nil
+}
+
+// keepingAdmin runs change in a transaction unless the user is the only
+// enabled admin, returning ErrLastAdmin then. The enabled admins stay locked
+// until it commits, so changes racing to remove the last two admins are
+// checked one after the other.
+func (ur *SQLUserRepository) keepingAdmin(ctx context.Context, id string, change func(context.Context, *sql.Tx) error) error {
+	ctx, cancel := ur.timeouts.write(ctx)
+	defer cancel()
+
+	if !domain.ID(id).Valid() {
+		return domain.InvalidID("invalid user ID")
+	}
+
+	tx, err := ur.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx,
+		ur.dialect.Rebind("SELECT id FROM users WHERE role = ? AND disabled = ?"+ur.dialect.forUpdate),
+		domain.RoleAdmin, false,
+	)
+	if err != nil {
+		return err
+	}
+	var admins []string
+	for rows.Next() {
+		var admin string
+		if err := rows.Scan(&admin); err != nil {
+			rows.Close()
+			return err
+		}
+		admins = append(admins, admin)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(admins) == 1 && admins[0] == id {
+		return domain.ErrLastAdmin
+	}
+
+	if err := change(ctx, tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// rowQuerier is satisfied by both *sql.DB and *sql.Tx
+type rowQuerier interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+func scanUser(row scanner) (domain.User, error) {
+	var user domain.User
+	var email sql.NullString
+	err := row.Scan(&user.ID, &user.Username, &user.Password, &user.Role, &user.Disabled, &email, &user.EmailVerified)
+	if err != nil {
+		return domain.User{}, err
+	}
+	user.Email = email.String
+	return user, nil
+}
+
+// nullString stores the empty string as NULL
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
@@ -0,0 +1,91 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"task-manager/Domain"
+)
+
+// SQLVerificationTokenRepository stores email verification tokens in a SQL
+// database. Expired rows are deleted whenever a token is saved.
+type SQLVerificationTokenRepository struct {
+	db       *sql.DB
+	dialect  SQLDialect
+	timeouts Timeouts
+}
+
+func NewSQLVerificationTokenRepository(db *sql.DB, dialect SQLDialect, timeouts Timeouts) domain.VerificationTokenRepository {
+	return &SQLVerificationTokenRepository{
+		db:       db,
+		dialect:  dialect,
+		timeouts: timeouts,
+	}
+}
+
+func (vr *SQLVerificationTokenRepository) SaveVerificationToken(ctx context.Context, token domain.EmailVerificationToken) error {
+	ctx, cancel := vr.timeouts.write(ctx)
+	defer cancel()
+
+	_, err := vr.db.ExecContext(ctx,
+		vr.dialect.Rebind("DELETE FROM email_verification_tokens WHERE expires_at < ?"),
+		toMillis(now()),
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = vr.db.ExecContext(ctx,
+		vr.dialect.Rebind("INSERT INTO email_verification_tokens (token_hash, user_id, email, expires_at) VALUES (?, ?, ?, ?)"),
+		token.TokenHash, token.UserID, token.Email, toMillis(token.ExpiresAt),
+	)
+	return err
+}
+
+// ConsumeVerificationToken reads the token and then deletes it. Only the
+// request whose delete removes the row gets the token back.
+func (vr *SQLVerificationTokenRepository) ConsumeVerificationToken(ctx context.Context, tokenHash string) (domain.EmailVerificationToken, error) {
+	ctx, cancel := vr.timeouts.write(ctx)
+	defer cancel()
+
+	var token domain.EmailVerificationToken
+	var expiresAt int64
+	err := vr.db.QueryRowContext(ctx,
+		vr.dialect.Rebind("SELECT token_hash, user_id, email, expires_at FROM email_verification_tokens WHERE token_hash = ?"),
+		tokenHash,
+	).Scan(&token.TokenHash, &token.UserID, &token.Email, &expiresAt)
+	if err == sql.ErrNoRows {
+		return domain.EmailVerificationToken{}, domain.ErrVerificationTokenNotFound
+	}
+	if err != nil {
+		return domain.EmailVerificationToken{}, err
+	}
+
+	res, err := vr.db.ExecContext(ctx,
+		vr.dialect.Rebind("DELETE FROM email_verification_tokens WHERE token_hash = ?"),
+		tokenHash,
+	)
+	if err != nil {
+		return domain.EmailVerificationToken{}, err
+	}
+	deleted, err := res.RowsAffected()
+	if err != nil {
+		return domain.EmailVerificationToken{}, err
+	}
+	if deleted == 0 {
+		return domain.EmailVerificationToken{}, domain.ErrVerificationTokenNotFound
+	}
+
+	token.ExpiresAt = fromMillis(expiresAt)
+	return token, nil
+}
+
+func (vr *SQLVerificationTokenRepository) DeleteVerificationTokens(ctx context.Context, userID domain.ID) error {
+	ctx, cancel := vr.timeouts.write(ctx)
+	defer cancel()
+
+	_, err := vr.db.ExecContext(ctx,
+		vr.dialect.Rebind("DELETE FROM email_verification_tokens WHERE user_id = ?"),
+		userID,
+	)
+	return err
+}
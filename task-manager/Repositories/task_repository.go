@@ -2,42 +2,115 @@ package repositories
 
 import (
 	"context"
-	"errors"
+	"regexp"
 	"task-manager/Domain"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type TaskRepository struct {
 	collection *mongo.Collection
+	timeouts   Timeouts
 }
 
-func NewTaskRepository(collection *mongo.Collection) domain.TaskRepository {
+func NewTaskRepository(collection *mongo.Collection, timeouts Timeouts) domain.TaskRepository {
 	return &TaskRepository{
 		collection: collection,
+		timeouts:   timeouts,
 	}
 }
 
-func (tr *TaskRepository) GetAllTasks() ([]domain.Task, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+func (tr *TaskRepository) QueryTasks(ctx context.Context, query domain.TaskQuery) ([]domain.Task, int64, error) {
+	ctx, cancel := tr.timeouts.read(ctx)
 	defer cancel()
 
-	cur, err := tr.collection.Find(ctx, bson.M{})
+	filter, err := taskFilter(query)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	total, err := tr.collection.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	direction := 1
+	if query.SortDesc {
+		direction = -1
+	}
+	sort := bson.D{{Key: "_id", Value: direction}}
+	if query.SortBy != "" && query.SortBy != "_id" {
+		// _id breaks ties so pages stay stable between requests
+		sort = bson.D{{Key: query.SortBy, Value: direction}, {Key: "_id", Value: direction}}
+	}
+
+	opts := options.Find().SetSort(sort).SetSkip(int64(query.Offset))
+	if query.Limit > 0 {
+		opts.SetLimit(int64(query.Limit))
+	}
+
+	tasks, err := tr.findTasks(ctx, filter, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return tasks, total, nil
+}
+
+func taskFilter(query domain.TaskQuery) (bson.M, error) {
+	filter := bson.M{}
+
+	if query.VisibleTo != "" {
+		objID, err := primitive.ObjectIDFromHex(query.VisibleTo)
+		if err != nil {
+			return nil, domain.InvalidID("invalid user ID")
+		}
+		filter["$or"] = []bson.M{
+			{"created_by": objID},
+			{"assignee_id": objID},
+		}
+	}
+
+	if query.Status != "" {
+		filter["status"] = query.Status
+	}
+
+	if query.Title != "" {
+		filter["title"] = primitive.Regex{Pattern: regexp.QuoteMeta(query.Title), Options: "i"}
+	}
+
+	due := bson.M{}
+	if !query.DueFrom.IsZero() {
+		due["$gte"] = query.DueFrom
+	}
+	if !query.DueTo.IsZero() {
+		due["$lte"] = query.DueTo
+	}
+	if len(due) > 0 {
+		filter["due_date"] = due
+	}
+
+	return filter, nil
+}
+
+func (tr *TaskRepository) findTasks(ctx context.Context, filter bson.M, opts *options.FindOptions) ([]domain.Task, error) {
+	cur, err := tr.collection.Find(ctx, filter, opts)
 	if err != nil {
 		return nil, err
 	}
 	defer cur.Close(ctx)
 
-	var tasks []domain.Task
+	tasks := []domain.Task{}
 	for cur.Next(ctx) {
-		var task domain.Task
-		if err := cur.Decode(&task); err != nil {
+		var doc taskDocument
+		if err := cur.Decode(&doc); err != nil {
 			return nil, err
 		}
-		tasks = append(tasks, task)
+		tasks = append(tasks, doc.toDomain())
 	}
 
 	if err := cur.Err(); err != nil {
@@ -47,81 +120,273 @@ func (tr *TaskRepository) GetAllTasks() ([]domain.Task, error) {
 	return tasks, nil
 }
 
-func (tr *TaskRepository) GetTaskByID(id string) (domain.Task, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+func (tr *TaskRepository) GetTaskByID(ctx context.Context, id string) (domain.Task, error) {
+	ctx, cancel := tr.timeouts.read(ctx)
 	defer cancel()
 
 	objID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
-		return domain.Task{}, errors.New("invalid id format")
+		return domain.Task{}, domain.InvalidID("invalid id format")
 	}
 
-	var task domain.Task
-	err = tr.collection.FindOne(ctx, bson.M{"_id": objID}).Decode(&task)
+	var doc taskDocument
+	err = tr.collection.FindOne(ctx, bson.M{"_id": objID}).Decode(&doc)
 	if err == mongo.ErrNoDocuments {
-		return domain.Task{}, errors.New("not found")
+		return domain.Task{}, domain.ErrTaskNotFound
+	}
+	if err != nil {
+		return domain.Task{}, err
 	}
 
-	return task, err
+	return doc.toDomain(), nil
 }
 
-func (tr *TaskRepository) CreateTask(task domain.Task) (domain.Task, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+func (tr *TaskRepository) CreateTask(ctx context.Context, task domain.Task) (domain.Task, error) {
+	ctx, cancel := tr.timeouts.write(ctx)
 	defer cancel()
 
-	task.ID = primitive.NewObjectID()
-	_, err := tr.collection.InsertOne(ctx, task)
-	return task, err
+	ts := now()
+	task.ID = domain.NewID()
+	task.CreatedAt = ts
+	task.UpdatedAt = ts
+	task.Version = 1
+
+	doc, err := newTaskDocument(task)
+	if err != nil {
+		return domain.Task{}, err
+	}
+	if _, err := tr.collection.InsertOne(ctx, doc); err != nil {
+		return domain.Task{}, err
+	}
+	return task, nil
 }
 
-func (tr *TaskRepository) UpdateTask(id string, updated domain.Task) (domain.Task, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+func (tr *TaskRepository) UpdateTask(ctx context.Context, id string, updated domain.Task, version int64) (domain.Task, error) {
+	ctx, cancel := tr.timeouts.write(ctx)
 	defer cancel()
 
 	objID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
-		return domain.Task{}, errors.New("invalid id format")
+		return domain.Task{}, domain.InvalidID("invalid id format")
 	}
 
-	filter := bson.M{"_id": objID}
-	update := bson.M{
-		"$set": bson.M{
-			"title":       updated.Title,
-			"description": updated.Description,
-			"due_date":    updated.DueDate,
-			"status":      updated.Status,
-		},
+	fields := bson.M{
+		"title":       updated.Title,
+		"description": updated.Description,
+		"due_date":    updated.DueDate,
+		"status":      updated.Status,
+		"updated_at":  now(),
+	}
+	if !updated.AssigneeID.IsZero() {
+		assigneeID, err := objectID(updated.AssigneeID)
+		if err != nil {
+			return domain.Task{}, err
+		}
+		fields["assignee_id"] = assigneeID
+	}
+
+	update := bson.M{"$set": fields, "$inc": bson.M{"version": 1}}
+
+	var doc taskDocument
+	err = tr.collection.FindOneAndUpdate(ctx, versionFilter(objID, version), update, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&doc)
+	if err == mongo.ErrNoDocuments {
+		return domain.Task{}, tr.missingOrConflict(ctx, objID)
+	}
+	if err != nil {
+		return domain.Task{}, err
+	}
+
+	return doc.toDomain(), nil
+}
+
+func (tr *TaskRepository) PatchTask(ctx context.Context, id string, patch domain.TaskPatch, version int64) (domain.Task, error) {
+	ctx, cancel := tr.timeouts.write(ctx)
+	defer cancel()
+
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return domain.Task{}, domain.InvalidID("invalid id format")
+	}
+
+	set := bson.M{"updated_at": now()}
+	if patch.Title != nil {
+		set["title"] = *patch.Title
+	}
+	if patch.Description != nil {
+		set["description"] = *patch.Description
+	}
+	if patch.DueDate != nil {
+		set["due_date"] = *patch.DueDate
+	}
+	if patch.Status != nil {
+		set["status"] = *patch.Status
+	}
+	if patch.AssigneeID != nil {
+		assigneeID, err := objectID(*patch.AssigneeID)
+		if err != nil {
+			return domain.Task{}, err
+		}
+		set["assignee_id"] = assigneeID
+	}
+
+	update := bson.M{"$set": set, "$inc": bson.M{"version": 1}}
+	if patch.ClearDueDate {
+		update["$unset"] = bson.M{"due_date": ""}
 	}
 
-	res, err := tr.collection.UpdateOne(ctx, filter, update)
+	var doc taskDocument
+	err = tr.collection.FindOneAndUpdate(ctx, versionFilter(objID, version), update, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&doc)
+	if err == mongo.ErrNoDocuments {
+		return domain.Task{}, tr.missingOrConflict(ctx, objID)
+	}
 	if err != nil {
 		return domain.Task{}, err
 	}
 
-	if res.MatchedCount == 0 {
-		return domain.Task{}, errors.New("not found")
+	return doc.toDomain(), nil
+}
+
+// TransitionTask moves a task to a new status only if it is still in the
+// status the caller validated against, so concurrent transitions cannot
+// skip the state machine
+func (tr *TaskRepository) TransitionTask(ctx context.Context, id string, from, to domain.TaskStatus) (domain.Task, error) {
+	ctx, cancel := tr.timeouts.write(ctx)
+	defer cancel()
+
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return domain.Task{}, domain.InvalidID("invalid id format")
+	}
+
+	filter := bson.M{"_id": objID, "status": from}
+	update := bson.M{"$set": bson.M{"status": to, "updated_at": now()}, "$inc": bson.M{"version": 1}}
+
+	var doc taskDocument
+	err = tr.collection.FindOneAndUpdate(ctx, filter, update, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&doc)
+	if err == mongo.ErrNoDocuments {
+		current, err := tr.GetTaskByID(ctx, id)
+		if err != nil {
+			return domain.Task{}, err
+		}
+		return domain.Task{}, &domain.TransitionError{From: current.Status, To: to}
+	}
+	if err != nil {
+		return domain.Task{}, err
 	}
 
-	return tr.GetTaskByID(id)
+	return doc.toDomain(), nil
 }
 
-func (tr *TaskRepository) DeleteTask(id string) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+func (tr *TaskRepository) DeleteTask(ctx context.Context, id string, version int64) error {
+	ctx, cancel := tr.timeouts.write(ctx)
 	defer cancel()
 
 	objID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
-		return errors.New("invalid id format")
+		return domain.InvalidID("invalid id format")
 	}
 
-	res, err := tr.collection.DeleteOne(ctx, bson.M{"_id": objID})
+	res, err := tr.collection.DeleteOne(ctx, versionFilter(objID, version))
 	if err != nil {
 		return err
 	}
 
 	if res.DeletedCount == 0 {
-		return errors.New("not found")
+		return tr.missingOrConflict(ctx, objID)
 	}
 
 	return nil
-}
\ No newline at end of file
+}
+
+func (tr *TaskRepository) ReassignTasks(ctx context.Context, from, to domain.ID) error {
+	ctx, cancel := tr.timeouts.write(ctx)
+	defer cancel()
+
+	fromID, err := objectID(from)
+	if err != nil {
+		return err
+	}
+	toID, err := objectID(to)
+	if err != nil {
+		return err
+	}
+
+	// A pipeline update so each field only moves when it names the user
+	reassign := func(field string) bson.M {
+		return bson.M{"$cond": bson.A{bson.M{"$eq": bson.A{"$" + field, fromID}}, toID, "$" + field}}
+	}
+	filter := bson.M{"$or": bson.A{bson.M{"created_by": fromID}, bson.M{"assignee_id": fromID}}}
+	update := mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{
+			"created_by":  reassign("created_by"),
+			"assignee_id": reassign("assignee_id"),
+			"updated_at":  now(),
+			"version":     bson.M{"$add": bson.A{"$version", 1}},
+		}}},
+	}
+
+	_, err = tr.collection.UpdateMany(ctx, filter, update)
+	return err
+}
+
+func (tr *TaskRepository) DeleteTasksCreatedBy(ctx context.Context, userID domain.ID) error {
+	ctx, cancel := tr.timeouts.write(ctx)
+	defer cancel()
+
+	objID, err := objectID(userID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tr.collection.DeleteMany(ctx, bson.M{"created_by": objID})
+	return err
+}
+
+func (tr *TaskRepository) UnassignTasks(ctx context.Context, userID domain.ID) error {
+	ctx, cancel := tr.timeouts.write(ctx)
+	defer cancel()
+
+	objID, err := objectID(userID)
+	if err != nil {
+		return err
+	}
+
+	update := mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{
+			"assignee_id": "$created_by",
+			"updated_at":  now(),
+			"version":     bson.M{"$add": bson.A{"$version", 1}},
+		}}},
+	}
+
+	_, err = tr.collection.UpdateMany(ctx, bson.M{"assignee_id": objID}, update)
+	return err
+}
+
+// versionFilter matches a task by ID and, unless version is zero, only while
+// it is still at the version the caller last read
+func versionFilter(objID primitive.ObjectID, version int64) bson.M {
+	filter := bson.M{"_id": objID}
+	if version != 0 {
+		filter["version"] = version
+	}
+	return filter
+}
+
+// missingOrConflict explains why a versioned write matched nothing
+func (tr *TaskRepository) missingOrConflict(ctx context.Context, objID primitive.ObjectID) error {
+	count, err := tr.collection.CountDocuments(ctx, bson.M{"_id": objID})
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return domain.ErrTaskNotFound
+	}
+	return domain.ErrVersionConflict
+}
+
+// now returns the current time at the millisecond precision Mongo stores,
+// so timestamps returned to callers match what is read back later
+func now() time.Time {
+	return time.Now().UTC().Truncate(time.Millisecond)
+}
@@ -0,0 +1,31 @@
+package repositories
+
+import (
+	"context"
+	"time"
+)
+
+// Timeouts bound each database operation of a repository. Operations run under the caller's
+// context, so they also stop when it is cancelled or its deadline passes
+// first, such as when an HTTP client disconnects.
+type Timeouts struct {
+	// Read bounds lookups, listings and counts
+	Read time.Duration
+	// Write bounds inserts, updates and deletes
+	Write time.Duration
+}
+
+var DefaultTimeouts = Timeouts{
+	Read:  5 * time.Second,
+	Write: 5 * time.Second,
+}
+
+// read bounds a read operation
+func (t Timeouts) read(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, t.Read)
+}
+
+// write bounds an operation that changes data
+func (t Timeouts) write(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, t.Write)
+}
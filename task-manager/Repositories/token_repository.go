@@ -0,0 +1,171 @@
+package repositories
+
+import (
+	"context"
+	"task-manager/Domain"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// TokenRepository stores refresh tokens and revoked access tokens in
+// MongoDB. Expired documents are removed by TTL indexes on expires_at, which
+// MongoDB runs about once a minute, as well as by DeleteExpiredTokens.
+type TokenRepository struct {
+	refreshTokens *mongo.Collection
+	revokedTokens *mongo.Collection
+	timeouts      Timeouts
+}
+
+func NewTokenRepository(refreshTokens, revokedTokens *mongo.Collection, timeouts Timeouts) domain.TokenRepository {
+	return &TokenRepository{
+		refreshTokens: refreshTokens,
+		revokedTokens: revokedTokens,
+		timeouts:      timeouts,
+	}
+}
+
+// refreshTokenDocument is the MongoDB representation of a refresh token,
+// keyed by the token hash
+type refreshTokenDocument struct {
+	TokenHash string             `bson:"_id"`
+	UserID    primitive.ObjectID `bson:"user_id"`
+	FamilyID  primitive.ObjectID `bson:"family_id"`
+	ExpiresAt time.Time          `bson:"expires_at"`
+	Revoked   bool               `bson:"revoked"`
+}
+
+func (doc refreshTokenDocument) toDomain() domain.RefreshToken {
+	return domain.RefreshToken{
+		TokenHash: doc.TokenHash,
+		UserID:    domainID(doc.UserID),
+		FamilyID:  domainID(doc.FamilyID),
+		ExpiresAt: doc.ExpiresAt,
+		Revoked:   doc.Revoked,
+	}
+}
+
+func (tr *TokenRepository) SaveRefreshToken(ctx context.Context, token domain.RefreshToken) error {
+	ctx, cancel := tr.timeouts.write(ctx)
+	defer cancel()
+
+	userID, err := objectID(token.UserID)
+	if err != nil {
+		return err
+	}
+	familyID, err := objectID(token.FamilyID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tr.refreshTokens.InsertOne(ctx, refreshTokenDocument{
+		TokenHash: token.TokenHash,
+		UserID:    userID,
+		FamilyID:  familyID,
+		ExpiresAt: token.ExpiresAt,
+		Revoked:   token.Revoked,
+	})
+	return err
+}
+
+func (tr *TokenRepository) GetRefreshToken(ctx context.Context, tokenHash string) (domain.RefreshToken, error) {
+	ctx, cancel := tr.timeouts.read(ctx)
+	defer cancel()
+
+	var doc refreshTokenDocument
+	err := tr.refreshTokens.FindOne(ctx, bson.M{"_id": tokenHash}).Decode(&doc)
+	if err == mongo.ErrNoDocuments {
+		return domain.RefreshToken{}, domain.ErrRefreshTokenNotFound
+	}
+	if err != nil {
+		return domain.RefreshToken{}, err
+	}
+
+	return doc.toDomain(), nil
+}
+
+func (tr *TokenRepository) RevokeRefreshToken(ctx context.Context, tokenHash string) error {
+	ctx, cancel := tr.timeouts.write(ctx)
+	defer cancel()
+
+	res, err := tr.refreshTokens.UpdateOne(ctx,
+		bson.M{"_id": tokenHash, "revoked": false},
+		bson.M{"$set": bson.M{"revoked": true}},
+	)
+	if err != nil {
+		return err
+	}
+	if res.ModifiedCount == 0 {
+		return domain.ErrRefreshTokenNotFound
+	}
+
+	return nil
+}
+
+func (tr *TokenRepository) RevokeTokenFamily(ctx context.Context, familyID domain.ID) error {
+	ctx, cancel := tr.timeouts.write(ctx)
+	defer cancel()
+
+	objID, err := objectID(familyID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tr.refreshTokens.UpdateMany(ctx,
+		bson.M{"family_id": objID},
+		bson.M{"$set": bson.M{"revoked": true}},
+	)
+	return err
+}
+
+func (tr *TokenRepository) RevokeUserTokens(ctx context.Context, userID domain.ID) error {
+	ctx, cancel := tr.timeouts.write(ctx)
+	defer cancel()
+
+	objID, err := objectID(userID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tr.refreshTokens.UpdateMany(ctx,
+		bson.M{"user_id": objID},
+		bson.M{"$set": bson.M{"revoked": true}},
+	)
+	return err
+}
+
+func (tr *TokenRepository) RevokeAccessToken(ctx context.Context, tokenID string, expiresAt time.Time) error {
+	ctx, cancel := tr.timeouts.write(ctx)
+	defer cancel()
+
+	_, err := tr.revokedTokens.InsertOne(ctx, bson.M{"_id": tokenID, "expires_at": expiresAt})
+	if mongo.IsDuplicateKeyError(err) {
+		return nil
+	}
+	return err
+}
+
+func (tr *TokenRepository) IsAccessTokenRevoked(ctx context.Context, tokenID string) (bool, error) {
+	ctx, cancel := tr.timeouts.read(ctx)
+	defer cancel()
+
+	count, err := tr.revokedTokens.CountDocuments(ctx, bson.M{"_id": tokenID})
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (tr *TokenRepository) DeleteExpiredTokens(ctx context.Context, before time.Time) error {
+	ctx, cancel := tr.timeouts.write(ctx)
+	defer cancel()
+
+	for _, collection := range []*mongo.Collection{tr.revokedTokens, tr.refreshTokens} {
+		if _, err := collection.DeleteMany(ctx, bson.M{"expires_at": bson.M{"$lt": before}}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -2,148 +2,458 @@ package repositories
 
 import (
 	"context"
-	"errors"
+	"strings"
 	"task-manager/Domain"
-	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type UserRepository struct {
 	collection  *mongo.Collection
-	jwtService  domain.JWTService
 	passwordService domain.PasswordService
+	timeouts    Timeouts
 }
 
 func NewUserRepository(
 	collection *mongo.Collection,
-	jwtService domain.JWTService,
 	passwordService domain.PasswordService,
+	timeouts Timeouts,
 ) *UserRepository {
 	return &UserRepository{
 		collection:      collection,
-		jwtService:      jwtService,
 		passwordService: passwordService,
+		timeouts:        timeouts,
 	}
 }
 
-func (ur *UserRepository) RegisterUser(user domain.User) (domain.User, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+func (ur *UserRepository) RegisterUser(ctx context.Context, user domain.User) (domain.User, error) {
+	return ur.register(ctx, user, false)
+}
+
+// RegisterFirstAdmin marks the admin as created by setup. A unique partial
+// index covers such admins, so of setups racing past the count only one
+// insert goes through.
+func (ur *UserRepository) RegisterFirstAdmin(ctx context.Context, user domain.User) (domain.User, error) {
+	countCtx, cancel := ur.timeouts.read(ctx)
+	admins, err := ur.collection.CountDocuments(countCtx, bson.M{"role": domain.RoleAdmin})
+	cancel()
+	if err != nil {
+		return domain.User{}, err
+	}
+	if admins > 0 {
+		return domain.User{}, domain.ErrSetupComplete
+	}
+
+	user.Role = domain.RoleAdmin
+	return ur.register(ctx, user, true)
+}
+
+func (ur *UserRepository) register(ctx context.Context, user domain.User, setupAdmin bool) (domain.User, error) {
+	ctx, cancel := ur.timeouts.write(ctx)
 	defer cancel()
 
 	if user.Username == "" || user.Password == "" {
-		return domain.User{}, errors.New("fields cannot be empty")
+		return domain.User{}, domain.Validation("fields cannot be empty")
+	}
+	if user.Role == "" {
+		user.Role = domain.RoleMember
 	}
 
-	var existing domain.User
-	err := ur.collection.FindOne(ctx, bson.M{"username": user.Username}).Decode(&existing)
-	if err == nil {
-		return domain.User{}, errors.New("username already taken")
+	// Hash the password before storing
+	hashedPassword, err := ur.passwordService.HashPassword(user.Password)
+	if err != nil {
+		return domain.User{}, err
 	}
-	if err != mongo.ErrNoDocuments {
+	user.Password = hashedPassword
+
+	user.ID = domain.NewID()
+	user.Disabled = false
+	user.EmailVerified = false
+
+	doc, err := newUserDocument(user)
+	if err != nil {
 		return domain.User{}, err
 	}
+	doc.SetupAdmin = setupAdmin
+	// The unique indexes on usernames and email addresses turn away
+	// concurrent registrations of the same ones
+	if _, err := ur.collection.InsertOne(ctx, doc); err != nil {
+		return domain.User{}, duplicateUser(err)
+	}
 
-	// Check if this is the first user (make admin if so)
-	userCount, err := ur.collection.CountDocuments(ctx, bson.M{})
+	user.Password = ""
+	return user, nil
+}
+
+func (ur *UserRepository) PromoteUser(ctx context.Context, id string) (domain.User, error) {
+	ctx, cancel := ur.timeouts.write(ctx)
+	defer cancel()
+
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return domain.User{}, domain.InvalidID("invalid user ID")
+	}
+
+	update := bson.M{"$set": bson.M{"role": domain.RoleAdmin}}
+	res, err := ur.collection.UpdateOne(ctx, bson.M{"_id": objID}, update)
 	if err != nil {
 		return domain.User{}, err
 	}
-	if userCount == 0 {
-		user.Role = "admin"
-	} else {
-		user.Role = "user"
+
+	if res.MatchedCount == 0 {
+		return domain.User{}, domain.ErrUserNotFound
 	}
 
-	// Hash the password before storing
-	hashedPassword, err := ur.passwordService.HashPassword(user.Password)
+	var doc userDocument
+	err = ur.collection.FindOne(ctx, bson.M{"_id": objID}).Decode(&doc)
 	if err != nil {
 		return domain.User{}, err
 	}
-	user.Password = hashedPassword
 
-	user.ID = primitive.NewObjectID()
+	updatedUser := doc.toDomain()
+	updatedUser.Password = ""
+	return updatedUser, nil
+}
+
+func (ur *UserRepository) GetUserByUsername(ctx context.Context, username string) (domain.User, error) {
+	ctx, cancel := ur.timeouts.read(ctx)
+	defer cancel()
 
-	_, err = ur.collection.InsertOne(ctx, user)
+	var doc userDocument
+	err := ur.collection.FindOne(ctx, bson.M{"username": username}).Decode(&doc)
+	if err == mongo.ErrNoDocuments {
+		return domain.User{}, domain.ErrUserNotFound
+	}
 	if err != nil {
 		return domain.User{}, err
 	}
 
-	user.Password = ""
-	return user, nil
+	return doc.toDomain(), nil
+}
+
+func (ur *UserRepository) GetUserByID(ctx context.Context, id string) (domain.User, error) {
+	ctx, cancel := ur.timeouts.read(ctx)
+	defer cancel()
+
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return domain.User{}, domain.InvalidID("invalid user ID")
+	}
+
+	var doc userDocument
+	err = ur.collection.FindOne(ctx, bson.M{"_id": objID}).Decode(&doc)
+	if err == mongo.ErrNoDocuments {
+		return domain.User{}, domain.ErrUserNotFound
+	}
+	if err != nil {
+		return domain.User{}, err
+	}
+
+	return doc.toDomain(), nil
 }
 
-func (ur *UserRepository) LoginUser(user domain.User) (domain.LoginResponse, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+func (ur *UserRepository) ListUsers(ctx context.Context, query domain.UserQuery) ([]domain.User, int64, error) {
+	ctx, cancel := ur.timeouts.read(ctx)
 	defer cancel()
 
-	if user.Username == "" {
-		return domain.LoginResponse{}, errors.New("username is a required field")
+	filter := bson.M{}
+	if query.Role != "" {
+		filter["role"] = query.Role
+	}
+	if query.Disabled != nil {
+		// Users stored before accounts could be disabled have no field
+		if *query.Disabled {
+			filter["disabled"] = true
+		} else {
+			filter["disabled"] = bson.M{"$ne": true}
+		}
 	}
 
-	var existingUser domain.User
-	err := ur.collection.FindOne(ctx, bson.M{"username": user.Username}).Decode(&existingUser)
+	total, err := ur.collection.CountDocuments(ctx, filter)
 	if err != nil {
-		return domain.LoginResponse{}, errors.New("invalid username or password")
+		return nil, 0, err
 	}
 
-	if err = ur.passwordService.ComparePassword(existingUser.Password, user.Password); err != nil {
-		return domain.LoginResponse{}, errors.New("invalid username or password")
+	opts := options.Find().
+		SetSort(bson.D{{Key: "_id", Value: 1}}).
+		SetSkip(int64(query.Offset)).
+		SetProjection(bson.M{"password": 0})
+	if query.Limit > 0 {
+		opts.SetLimit(int64(query.Limit))
 	}
 
-	// Generate JWT with role
-	jwtToken, err := ur.jwtService.GenerateToken(existingUser.ID.Hex(), existingUser.Username, existingUser.Role)
+	cursor, err := ur.collection.Find(ctx, filter, opts)
 	if err != nil {
-		return domain.LoginResponse{}, err
+		return nil, 0, err
 	}
+	defer cursor.Close(ctx)
 
-	return domain.LoginResponse{
-		ID:       existingUser.ID,
-		Username: existingUser.Username,
-		Token:    jwtToken,
-	}, nil
+	users := []domain.User{}
+	for cursor.Next(ctx) {
+		var doc userDocument
+		if err := cursor.Decode(&doc); err != nil {
+			return nil, 0, err
+		}
+		users = append(users, doc.toDomain())
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	return users, total, nil
+}
+
+func (ur *UserRepository) SetUserRole(ctx context.Context, id string, role string) (domain.User, error) {
+	if role == domain.RoleAdmin {
+		return ur.update(ctx, id, bson.M{"$set": bson.M{"role": role}})
+	}
+	return ur.updateKeepingAdmin(ctx, id, bson.M{"$set": bson.M{"role": role}})
 }
 
-func (ur *UserRepository) PromoteUser(id string) (domain.User, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+func (ur *UserRepository) SetUserDisabled(ctx context.Context, id string, disabled bool) (domain.User, error) {
+	if !disabled {
+		return ur.update(ctx, id, bson.M{"$unset": bson.M{"disabled": ""}})
+	}
+	return ur.updateKeepingAdmin(ctx, id, bson.M{"$set": bson.M{"disabled": true}})
+}
+
+func (ur *UserRepository) DeleteUser(ctx context.Context, id string) error {
+	ctx, cancel := ur.timeouts.write(ctx)
 	defer cancel()
 
 	objID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
-		return domain.User{}, errors.New("invalid user ID")
+		return domain.InvalidID("invalid user ID")
 	}
 
-	update := bson.M{"$set": bson.M{"role": "admin"}}
-	res, err := ur.collection.UpdateOne(ctx, bson.M{"_id": objID}, update)
+	var before userDocument
+	err = ur.collection.FindOneAndDelete(ctx, bson.M{"_id": objID}).Decode(&before)
+	if err == mongo.ErrNoDocuments {
+		return domain.ErrUserNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	return ur.keepAdmin(ctx, before, func() error {
+		_, err := ur.collection.InsertOne(ctx, before)
+		return err
+	})
+}
+
+func (ur *UserRepository) UpdateProfile(ctx context.Context, id string, patch domain.ProfilePatch) (domain.User, error) {
+	current, err := ur.GetUserByID(ctx, id)
 	if err != nil {
 		return domain.User{}, err
 	}
+	objID, _ := objectID(current.ID)
 
-	if res.MatchedCount == 0 {
-		return domain.User{}, errors.New("user not found")
+	ctx, cancel := ur.timeouts.write(ctx)
+	defer cancel()
+
+	set, unset := bson.M{}, bson.M{}
+	if patch.Username != nil {
+		taken, err := ur.collection.CountDocuments(ctx, bson.M{"username": *patch.Username, "_id": bson.M{"$ne": objID}})
+		if err != nil {
+			return domain.User{}, err
+		}
+		if taken > 0 {
+			return domain.User{}, domain.Conflict("username already taken")
+		}
+		set["username"] = *patch.Username
+	}
+	if patch.Email != nil {
+		taken, err := ur.emailTaken(ctx, *patch.Email, objID)
+		if err != nil {
+			return domain.User{}, err
+		}
+		if taken {
+			return domain.User{}, domain.ErrEmailTaken
+		}
+		if *patch.Email == "" {
+			unset["email"] = ""
+		} else {
+			set["email"] = *patch.Email
+		}
+		if !strings.EqualFold(*patch.Email, current.Email) {
+			unset["email_verified"] = ""
+		}
+	}
+
+	update := bson.M{}
+	if len(set) > 0 {
+		update["$set"] = set
+	}
+	if len(unset) > 0 {
+		update["$unset"] = unset
 	}
+	if len(update) == 0 {
+		current.Password = ""
+		return current, nil
+	}
+
+	user, err := ur.update(ctx, id, update)
+	if err != nil {
+		return domain.User{}, duplicateUser(err)
+	}
+	return user, nil
+}
+
+func (ur *UserRepository) SetPassword(ctx context.Context, id string, hash string) error {
+	_, err := ur.update(ctx, id, bson.M{"$set": bson.M{"password": hash}})
+	return err
+}
+
+func (ur *UserRepository) MarkEmailVerified(ctx context.Context, id string, email string) (domain.User, error) {
+	ctx, cancel := ur.timeouts.write(ctx)
+	defer cancel()
 
-	var updatedUser domain.User
-	err = ur.collection.FindOne(ctx, bson.M{"_id": objID}).Decode(&updatedUser)
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return domain.User{}, domain.InvalidID("invalid user ID")
+	}
+	if email == "" {
+		return domain.User{}, domain.ErrUserNotFound
+	}
+
+	var doc userDocument
+	err = ur.collection.FindOneAndUpdate(ctx,
+		bson.M{"_id": objID, "email": email},
+		bson.M{"$set": bson.M{"email_verified": true}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&doc)
+	if err == mongo.ErrNoDocuments {
+		return domain.User{}, domain.ErrUserNotFound
+	}
 	if err != nil {
 		return domain.User{}, err
 	}
 
-	updatedUser.Password = ""
-	return updatedUser, nil
+	user := doc.toDomain()
+	user.Password = ""
+	return user, nil
 }
 
-func (ur *UserRepository) GetUserByUsername(username string) (domain.User, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+// duplicateUser maps duplicate key errors to the conflict of the unique
+// index they hit, the username or the email address
+func duplicateUser(err error) error {
+	if !mongo.IsDuplicateKeyError(err) {
+		return err
+	}
+	if strings.Contains(err.Error(), "index: "+setupAdminIndex+" ") {
+		return domain.ErrSetupComplete
+	}
+	if strings.Contains(err.Error(), "index: "+usernameIndex+" ") {
+		return domain.Conflict("username already taken")
+	}
+	return domain.ErrEmailTaken
+}
+
+// emailTaken reports whether a user other than except has the address in
+// any case. No user has the empty address.
+func (ur *UserRepository) emailTaken(ctx context.Context, email string, except primitive.ObjectID) (bool, error) {
+	if email == "" {
+		return false, nil
+	}
+
+	count, err := ur.collection.CountDocuments(ctx,
+		bson.M{"email": email, "_id": bson.M{"$ne": except}},
+		options.Count().SetCollation(emailCollation),
+	)
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// update applies the update to a user and returns it without its password
+// updateKeepingAdmin is update for changes that take admin rights away,
+// refusing them with ErrLastAdmin for the only enabled admin
+func (ur *UserRepository) updateKeepingAdmin(ctx context.Context, id string, update bson.M) (domain.User, error) {
+	ctx, cancel := ur.timeouts.write(ctx)
 	defer cancel()
 
-	var user domain.User
-	err := ur.collection.FindOne(ctx, bson.M{"username": username}).Decode(&user)
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return domain.User{}, domain.InvalidID("invalid user ID")
+	}
+
+	var before userDocument
+	err = ur.collection.FindOneAndUpdate(ctx, bson.M{"_id": objID}, update).Decode(&before)
+	if err == mongo.ErrNoDocuments {
+		return domain.User{}, domain.ErrUserNotFound
+	}
+	if err != nil {
+		return domain.User{}, err
+	}
+
+	err = ur.keepAdmin(ctx, before, func() error {
+		_, err := ur.collection.UpdateOne(ctx, bson.M{"_id": objID}, bson.M{
+			"$set":   bson.M{"role": before.Role},
+			"$unset": bson.M{"disabled": ""},
+		})
+		return err
+	})
+	if err != nil {
+		return domain.User{}, err
+	}
+
+	var doc userDocument
+	if err := ur.collection.FindOne(ctx, bson.M{"_id": objID}).Decode(&doc); err != nil {
+		return domain.User{}, err
+	}
+	user := doc.toDomain()
+	user.Password = ""
+	return user, nil
+}
+
+// keepAdmin undoes a change that left no enabled admin, returning
+// ErrLastAdmin, when before was an enabled admin. Standalone servers have no
+// transactions, so the check follows the change instead of preceding it: of
+// changes racing to remove the last admins each sees the others' writes, and
+// at least the last to check is undone.
+func (ur *UserRepository) keepAdmin(ctx context.Context, before userDocument, undo func() error) error {
+	if before.Role != domain.RoleAdmin || before.Disabled {
+		return nil
+	}
+
+	admins, err := ur.collection.CountDocuments(ctx, bson.M{"role": domain.RoleAdmin, "disabled": bson.M{"$ne": true}})
+	if err != nil {
+		return err
+	}
+	if admins > 0 {
+		return nil
+	}
+	if err := undo(); err != nil {
+		return err
+	}
+	return domain.ErrLastAdmin
+}
+
+func (ur *UserRepository) update(ctx context.Context, id string, update bson.M) (domain.User, error) {
+	ctx, cancel := ur.timeouts.write(ctx)
+	defer cancel()
+
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return domain.User{}, domain.InvalidID("invalid user ID")
+	}
+
+	var doc userDocument
+	err = ur.collection.FindOneAndUpdate(ctx, bson.M{"_id": objID}, update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&doc)
 	if err == mongo.ErrNoDocuments {
-		return domain.User{}, errors.New("user not found")
+		return domain.User{}, domain.ErrUserNotFound
+	}
+	if err != nil {
+		return domain.User{}, err
 	}
 
-	return user, err
-}
\ No newline at end of file
+	user := doc.toDomain()
+	user.Password = ""
+	return user, nil
+}
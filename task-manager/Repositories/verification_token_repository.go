@@ -0,0 +1,83 @@
+package repositories
+
+import (
+	"context"
+	"task-manager/Domain"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// VerificationTokenRepository stores email verification tokens in MongoDB.
+// Expired documents are removed by a TTL index on expires_at.
+type VerificationTokenRepository struct {
+	collection *mongo.Collection
+	timeouts   Timeouts
+}
+
+func NewVerificationTokenRepository(collection *mongo.Collection, timeouts Timeouts) domain.VerificationTokenRepository {
+	return &VerificationTokenRepository{collection: collection, timeouts: timeouts}
+}
+
+// verificationTokenDocument is the MongoDB representation of an email
+// verification token, keyed by the token hash
+type verificationTokenDocument struct {
+	TokenHash string             `bson:"_id"`
+	UserID    primitive.ObjectID `bson:"user_id"`
+	Email     string             `bson:"email"`
+	ExpiresAt time.Time          `bson:"expires_at"`
+}
+
+func (vr *VerificationTokenRepository) SaveVerificationToken(ctx context.Context, token domain.EmailVerificationToken) error {
+	ctx, cancel := vr.timeouts.write(ctx)
+	defer cancel()
+
+	userID, err := objectID(token.UserID)
+	if err != nil {
+		return err
+	}
+
+	_, err = vr.collection.InsertOne(ctx, verificationTokenDocument{
+		TokenHash: token.TokenHash,
+		UserID:    userID,
+		Email:     token.Email,
+		ExpiresAt: token.ExpiresAt,
+	})
+	return err
+}
+
+func (vr *VerificationTokenRepository) ConsumeVerificationToken(ctx context.Context, tokenHash string) (domain.EmailVerificationToken, error) {
+	ctx, cancel := vr.timeouts.write(ctx)
+	defer cancel()
+
+	var doc verificationTokenDocument
+	err := vr.collection.FindOneAndDelete(ctx, bson.M{"_id": tokenHash}).Decode(&doc)
+	if err == mongo.ErrNoDocuments {
+		return domain.EmailVerificationToken{}, domain.ErrVerificationTokenNotFound
+	}
+	if err != nil {
+		return domain.EmailVerificationToken{}, err
+	}
+
+	return domain.EmailVerificationToken{
+		TokenHash: doc.TokenHash,
+		UserID:    domainID(doc.UserID),
+		Email:     doc.Email,
+		ExpiresAt: doc.ExpiresAt,
+	}, nil
+}
+
+func (vr *VerificationTokenRepository) DeleteVerificationTokens(ctx context.Context, userID domain.ID) error {
+	ctx, cancel := vr.timeouts.write(ctx)
+	defer cancel()
+
+	objID, err := objectID(userID)
+	if err != nil {
+		return err
+	}
+
+	_, err = vr.collection.DeleteMany(ctx, bson.M{"user_id": objID})
+	return err
+}
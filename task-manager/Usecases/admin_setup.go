@@ -0,0 +1,31 @@
+package usecases
+
+import (
+	"context"
+	"crypto/subtle"
+	"task-manager/Domain"
+)
+
+// SetAdminSetupToken turns on SetupAdmin with the token, which operators
+// hand to whoever creates the first admin. The empty token, the default,
+// turns it off.
+func (uu *UserUsecase) SetAdminSetupToken(token string) {
+	uu.setupTokenHash = ""
+	if token != "" {
+		uu.setupTokenHash = hashToken(token)
+	}
+}
+
+// SetupAdmin creates an admin, with the same checks as RegisterUser, as long
+// as none exists. The repository makes the check and the insert atomic, so
+// concurrent calls create one admin however many instances serve them.
+func (uu *UserUsecase) SetupAdmin(ctx context.Context, token string, user domain.User) (domain.User, error) {
+	if uu.setupTokenHash == "" || subtle.ConstantTimeCompare([]byte(hashToken(token)), []byte(uu.setupTokenHash)) != 1 {
+		return domain.User{}, domain.ErrInvalidSetupToken
+	}
+
+	if err := uu.checkNewUser(user); err != nil {
+		return domain.User{}, err
+	}
+	return uu.userRepo.RegisterFirstAdmin(ctx, user)
+}
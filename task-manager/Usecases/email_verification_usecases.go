@@ -0,0 +1,120 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"task-manager/Domain"
+	"time"
+)
+
+// EmailVerificationTTL is how long a mailed verification token can be used
+const EmailVerificationTTL = 24 * time.Hour
+
+type EmailVerificationUsecase struct {
+	userRepo         domain.UserRepository
+	verificationRepo domain.VerificationTokenRepository
+	mailer           domain.Mailer
+}
+
+func NewEmailVerificationUsecase(
+	userRepo domain.UserRepository,
+	verificationRepo domain.VerificationTokenRepository,
+	mailer domain.Mailer,
+) *EmailVerificationUsecase {
+	return &EmailVerificationUsecase{
+		userRepo:         userRepo,
+		verificationRepo: verificationRepo,
+		mailer:           mailer,
+	}
+}
+
+func (eu *EmailVerificationUsecase) SendVerification(ctx context.Context, userID string) error {
+	user, err := eu.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	return eu.send(ctx, user)
+}
+
+// RequestVerification resends the verification mail. Mail failures are
+// logged rather than returned, so the response doesn't reveal the user.
+func (eu *EmailVerificationUsecase) RequestVerification(ctx context.Context, username string) error {
+	if username == "" {
+		return domain.Validation("username is required")
+	}
+
+	user, err := eu.userRepo.GetUserByUsername(ctx, username)
+	if errors.Is(err, domain.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := eu.send(ctx, user); err != nil {
+		log.Printf("verification mail for user %s failed: %v", user.ID, err)
+	}
+	return nil
+}
+
+// VerifyEmail marks the address the token was mailed to as verified, as
+// long as the user still has it. Every other token the user was sent stops
+// working.
+func (eu *EmailVerificationUsecase) VerifyEmail(ctx context.Context, token string) error {
+	if token == "" {
+		return domain.ErrInvalidVerificationToken
+	}
+
+	stored, err := eu.verificationRepo.ConsumeVerificationToken(ctx, hashToken(token))
+	if errors.Is(err, domain.ErrNotFound) {
+		return domain.ErrInvalidVerificationToken
+	}
+	if err != nil {
+		return err
+	}
+	if time.Now().After(stored.ExpiresAt) {
+		return domain.ErrInvalidVerificationToken
+	}
+
+	if _, err := eu.userRepo.MarkEmailVerified(ctx, stored.UserID.String(), stored.Email); err != nil {
+		if errors.Is(err, domain.ErrNotFound) {
+			return domain.ErrInvalidVerificationToken
+		}
+		return err
+	}
+
+	return eu.verificationRepo.DeleteVerificationTokens(ctx, stored.UserID)
+}
+
+// send mails a token for the user's current address. Disabled users, users
+// without an address and users who already verified theirs get nothing.
+func (eu *EmailVerificationUsecase) send(ctx context.Context, user domain.User) error {
+	if user.Disabled || user.Email == "" || user.EmailVerified {
+		return nil
+	}
+
+	token, err := newToken()
+	if err != nil {
+		return err
+	}
+	err = eu.verificationRepo.SaveVerificationToken(ctx, domain.EmailVerificationToken{
+		TokenHash: hashToken(token),
+		UserID:    user.ID,
+		Email:     user.Email,
+		ExpiresAt: time.Now().Add(EmailVerificationTTL),
+	})
+	if err != nil {
+		return err
+	}
+
+	return eu.mailer.Send(domain.Message{
+		To:      user.Email,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf("Confirm that %s is the email address of %s.\n\n"+
+			"Use this token within %s to verify it:\n\n%s\n\n"+
+			"If you didn't sign up, ignore this email.\n",
+			user.Email, user.Username, EmailVerificationTTL, token),
+	})
+}
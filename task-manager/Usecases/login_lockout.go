@@ -0,0 +1,192 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"task-manager/Domain"
+	"time"
+)
+
+// LockoutPolicy sets when failed logins lock out a username or a client IP.
+// Zero MaxFailures or MaxIPFailures turns that lockout off.
+type LockoutPolicy struct {
+	// MaxFailures is how many failed logins lock out a username
+	MaxFailures int
+	// MaxIPFailures is how many failed logins lock out a client IP. It is
+	// higher, as many users can share an address.
+	MaxIPFailures int
+	// Lockout is how long the first lockout lasts. Every failure after it
+	// doubles the time, up to MaxLockout.
+	Lockout    time.Duration
+	MaxLockout time.Duration
+	// Window is how long failures are remembered after the last one
+	Window time.Duration
+}
+
+var DefaultLockoutPolicy = LockoutPolicy{
+	MaxFailures:   5,
+	MaxIPFailures: 50,
+	Lockout:       time.Minute,
+	MaxLockout:    time.Hour,
+	Window:        24 * time.Hour,
+}
+
+// lockedUntil returns when the lockout of a throttle with the given limit
+// ends, which is in the past when it isn't locked
+func (p LockoutPolicy) lockedUntil(throttle domain.LoginThrottle, limit int) time.Time {
+	if limit <= 0 || throttle.Failures < limit {
+		return time.Time{}
+	}
+
+	lockout := p.Lockout
+	for i := limit; i < throttle.Failures && lockout < p.MaxLockout; i++ {
+		lockout *= 2
+	}
+	if lockout > p.MaxLockout {
+		lockout = p.MaxLockout
+	}
+	return throttle.LastFailure.Add(lockout)
+}
+
+// loginKey is a throttle key of a login attempt and its failure limit
+type loginKey struct {
+	key   string
+	limit int
+	entry domain.AuditEntry
+}
+
+// SetLockoutPolicy replaces the policy LoginUser applies to failed logins,
+// DefaultLockoutPolicy unless set
+func (uu *UserUsecase) SetLockoutPolicy(policy LockoutPolicy) {
+	uu.lockoutPolicy = policy
+}
+
+// UnlockUser lets a locked out user try their password again. Lockouts of
+// client IPs are left to expire.
+func (uu *UserUsecase) UnlockUser(ctx context.Context, caller domain.Caller, id string) error {
+	if !caller.Can(domain.PermUserManage) {
+		return permissionRequired(domain.PermUserManage)
+	}
+
+	user, err := uu.userRepo.GetUserByID(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	key := usernameKey(user.Username)
+	_, err = uu.throttleRepo.GetLoginThrottle(ctx, key)
+	if errors.Is(err, domain.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := uu.throttleRepo.ClearLoginThrottle(ctx, key); err != nil {
+		return err
+	}
+
+	uu.audit(domain.AuditEntry{
+		Event:    domain.AuditLoginUnlocked,
+		ActorID:  caller.UserID,
+		Username: user.Username,
+	})
+	return nil
+}
+
+// loginKeys returns the keys failed logins are counted under: the username
+// and, when known, the client IP
+func (uu *UserUsecase) loginKeys(username, clientIP string) []loginKey {
+	keys := []loginKey{{
+		key:   usernameKey(username),
+		limit: uu.lockoutPolicy.MaxFailures,
+		entry: domain.AuditEntry{Username: username, ClientIP: clientIP},
+	}}
+	if clientIP != "" {
+		keys = append(keys, loginKey{
+			key:   "ip:" + clientIP,
+			limit: uu.lockoutPolicy.MaxIPFailures,
+			entry: domain.AuditEntry{ClientIP: clientIP},
+		})
+	}
+	return keys
+}
+
+func usernameKey(username string) string {
+	return "user:" + username
+}
+
+// checkLockout refuses logins while any of the keys is locked out, before
+// the password is looked at
+func (uu *UserUsecase) checkLockout(ctx context.Context, keys []loginKey) error {
+	now := time.Now()
+	var until time.Time
+
+	for _, key := range keys {
+		if key.limit <= 0 {
+			continue
+		}
+		throttle, err := uu.throttleRepo.GetLoginThrottle(ctx, key.key)
+		if errors.Is(err, domain.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if throttle.ExpiresAt.Before(now) {
+			continue
+		}
+		if locked := uu.lockoutPolicy.lockedUntil(throttle, key.limit); locked.After(until) {
+			until = locked
+		}
+	}
+
+	if until.After(now) {
+		return &domain.LoginLockedError{Until: until}
+	}
+	return nil
+}
+
+// recordLoginFailure counts a failed login against every key and audits the
+// lockouts it starts
+func (uu *UserUsecase) recordLoginFailure(ctx context.Context, keys []loginKey) error {
+	now := time.Now()
+
+	for _, key := range keys {
+		if key.limit <= 0 {
+			continue
+		}
+		throttle, err := uu.throttleRepo.RecordLoginFailure(ctx, key.key, now, uu.lockoutPolicy.Window)
+		if err != nil {
+			return err
+		}
+
+		until := uu.lockoutPolicy.lockedUntil(throttle, key.limit)
+		if until.After(now) {
+			entry := key.entry
+			entry.Event = domain.AuditLoginLocked
+			entry.Detail = fmt.Sprintf("%d failed logins, locked until %s", throttle.Failures, until.UTC().Format(time.RFC3339))
+			uu.audit(entry)
+		}
+	}
+	return nil
+}
+
+// clearLoginFailures forgets the failed logins of a username once a login
+// issued tokens. The client IP keeps its count, or one valid account would
+// let it guess forever.
+func (uu *UserUsecase) clearLoginFailures(ctx context.Context, username string) error {
+	return uu.throttleRepo.ClearLoginThrottle(ctx, usernameKey(username))
+}
+
+// audit records an entry. The action it describes already happened, so a
+// failure is only logged.
+func (uu *UserUsecase) audit(entry domain.AuditEntry) {
+	if entry.Time.IsZero() {
+		entry.Time = time.Now().UTC()
+	}
+	if err := uu.auditLog.Record(entry); err != nil {
+		log.Printf("audit entry %s failed: %v", entry.Event, err)
+	}
+}
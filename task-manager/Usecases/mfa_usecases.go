@@ -0,0 +1,235 @@
+package usecases
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"strings"
+	"task-manager/Domain"
+	"time"
+)
+
+const (
+	// MFAChallengeTTL is how long a user has to send the code after their
+	// password was accepted
+	MFAChallengeTTL = 5 * time.Minute
+	// MaxMFAAttempts is how many wrong codes a challenge takes before the
+	// user has to log in again
+	MaxMFAAttempts = 5
+	// RecoveryCodeCount is how many recovery codes users get on enrolling
+	RecoveryCodeCount = 10
+)
+
+// recoveryCodeAlphabet avoids 0, 1, 8 and 9, which are easily confused with
+// letters. Its 32 characters give each code 50 bits of entropy.
+const recoveryCodeAlphabet = "abcdefghijklmnopqrstuvwxyz234567"
+
+// errWrongCode is what checkCode returns for codes that don't verify.
+// Callers turn it into the error their endpoint reports.
+var errWrongCode = errors.New("wrong authentication code")
+
+type MFAUsecase struct {
+	userRepo        domain.UserRepository
+	mfaRepo         domain.MFARepository
+	totpService     domain.TOTPService
+	passwordService domain.PasswordService
+}
+
+func NewMFAUsecase(
+	userRepo domain.UserRepository,
+	mfaRepo domain.MFARepository,
+	totpService domain.TOTPService,
+	passwordService domain.PasswordService,
+) *MFAUsecase {
+	return &MFAUsecase{
+		userRepo:        userRepo,
+		mfaRepo:         mfaRepo,
+		totpService:     totpService,
+		passwordService: passwordService,
+	}
+}
+
+func (mu *MFAUsecase) GetMFAStatus(ctx context.Context, caller domain.Caller) (domain.MFAStatus, error) {
+	enrollment, err := mu.mfaRepo.GetEnrollment(ctx, domain.ID(caller.UserID))
+	if errors.Is(err, domain.ErrNotFound) || (err == nil && !enrollment.Confirmed) {
+		return domain.MFAStatus{}, nil
+	}
+	if err != nil {
+		return domain.MFAStatus{}, err
+	}
+
+	return domain.MFAStatus{
+		Enabled:                true,
+		RecoveryCodesRemaining: len(enrollment.RecoveryCodes),
+	}, nil
+}
+
+// EnrollMFA generates a new TOTP secret for the caller, replacing any setup
+// they didn't confirm. It needs the password, so a stolen access token
+// can't add a second factor the owner doesn't have.
+func (mu *MFAUsecase) EnrollMFA(ctx context.Context, caller domain.Caller, password string) (domain.MFASetup, error) {
+	user, err := mu.checkPassword(ctx, caller, password)
+	if err != nil {
+		return domain.MFASetup{}, err
+	}
+
+	enrollment, err := mu.mfaRepo.GetEnrollment(ctx, user.ID)
+	if err == nil && enrollment.Confirmed {
+		return domain.MFASetup{}, domain.ErrMFAAlreadyEnabled
+	}
+	if err != nil && !errors.Is(err, domain.ErrNotFound) {
+		return domain.MFASetup{}, err
+	}
+
+	secret, err := mu.totpService.GenerateSecret()
+	if err != nil {
+		return domain.MFASetup{}, err
+	}
+	if err := mu.mfaRepo.SaveEnrollment(ctx, domain.MFAEnrollment{UserID: user.ID, Secret: secret}); err != nil {
+		return domain.MFASetup{}, err
+	}
+
+	return domain.MFASetup{
+		Secret: secret,
+		URI:    mu.totpService.URI(secret, user.Username),
+	}, nil
+}
+
+// ConfirmMFA turns two-factor authentication on once the caller sends a
+// code from their authenticator, and returns new recovery codes. Only their
+// hashes are stored, so this is the one time they can be shown.
+func (mu *MFAUsecase) ConfirmMFA(ctx context.Context, caller domain.Caller, code string) ([]string, error) {
+	enrollment, err := mu.mfaRepo.GetEnrollment(ctx, domain.ID(caller.UserID))
+	if errors.Is(err, domain.ErrNotFound) {
+		return nil, domain.Conflict("two-factor authentication setup has not been started")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if enrollment.Confirmed {
+		return nil, domain.ErrMFAAlreadyEnabled
+	}
+
+	step, ok := mu.totpService.Validate(enrollment.Secret, normalizeCode(code), time.Now())
+	if !ok {
+		return nil, domain.ErrWrongMFACode
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	enrollment.Confirmed = true
+	enrollment.LastStep = step
+	enrollment.RecoveryCodes = hashes
+	if err := mu.mfaRepo.SaveEnrollment(ctx, enrollment); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// DisableMFA turns two-factor authentication off. It takes both the
+// password and a code, which may be a recovery code for users who lost
+// their authenticator.
+func (mu *MFAUsecase) DisableMFA(ctx context.Context, caller domain.Caller, password string, code string) error {
+	user, err := mu.checkPassword(ctx, caller, password)
+	if err != nil {
+		return err
+	}
+
+	enrollment, err := mu.mfaRepo.GetEnrollment(ctx, user.ID)
+	if errors.Is(err, domain.ErrNotFound) || (err == nil && !enrollment.Confirmed) {
+		return domain.ErrMFANotEnabled
+	}
+	if err != nil {
+		return err
+	}
+
+	err = checkCode(ctx, mu.mfaRepo, mu.totpService, enrollment, code)
+	if errors.Is(err, errWrongCode) {
+		return domain.ErrWrongMFACode
+	}
+	if err != nil {
+		return err
+	}
+
+	return mu.mfaRepo.DeleteEnrollment(ctx, user.ID)
+}
+
+func (mu *MFAUsecase) checkPassword(ctx context.Context, caller domain.Caller, password string) (domain.User, error) {
+	user, err := mu.userRepo.GetUserByID(ctx, caller.UserID)
+	if err != nil {
+		return domain.User{}, err
+	}
+	if err := mu.passwordService.ComparePassword(user.Password, password); err != nil {
+		return domain.User{}, domain.ErrWrongPassword
+	}
+	return user, nil
+}
+
+// checkCode accepts a TOTP code that is current and newer than the last one
+// used, or one of the enrollment's recovery codes, which it uses up
+func checkCode(ctx context.Context, repo domain.MFARepository, totp domain.TOTPService, enrollment domain.MFAEnrollment, code string) error {
+	code = normalizeCode(code)
+
+	if isTOTPCode(code) {
+		step, ok := totp.Validate(enrollment.Secret, code, time.Now())
+		if !ok {
+			return errWrongCode
+		}
+		err := repo.UseTOTPStep(ctx, enrollment.UserID, step)
+		if errors.Is(err, domain.ErrMFACodeReused) {
+			return errWrongCode
+		}
+		return err
+	}
+
+	if code == "" {
+		return errWrongCode
+	}
+	err := repo.UseRecoveryCode(ctx, enrollment.UserID, hashToken(code))
+	if errors.Is(err, domain.ErrRecoveryCodeNotFound) {
+		return errWrongCode
+	}
+	return err
+}
+
+// normalizeCode drops the spaces and dashes people type or copy along with
+// codes, and the case of recovery codes
+func normalizeCode(code string) string {
+	return strings.NewReplacer(" ", "", "-", "").Replace(strings.ToLower(code))
+}
+
+func isTOTPCode(code string) bool {
+	if len(code) != 6 {
+		return false
+	}
+	for _, r := range code {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// newRecoveryCodes returns RecoveryCodeCount codes, formatted as two groups
+// of five characters, and the hashes they are stored as
+func newRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, RecoveryCodeCount)
+	hashes := make([]string, RecoveryCodeCount)
+
+	for i := range codes {
+		raw := make([]byte, 10)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, nil, err
+		}
+		for j, b := range raw {
+			raw[j] = recoveryCodeAlphabet[int(b)%len(recoveryCodeAlphabet)]
+		}
+		codes[i] = string(raw[:5]) + "-" + string(raw[5:])
+		hashes[i] = hashToken(string(raw))
+	}
+
+	return codes, hashes, nil
+}
@@ -0,0 +1,124 @@
+package usecases
+
+import (
+	_ "embed"
+	"fmt"
+	"strings"
+	"task-manager/Domain"
+	"unicode"
+	"unicode/utf8"
+)
+
+// MaxPasswordBytes is the most bcrypt hashes. Longer passwords are refused
+// rather than cut short, so no two of them share a hash.
+const MaxPasswordBytes = 72
+
+// PasswordPolicy sets which new passwords RegisterUser, ChangePassword and
+// ResetPassword accept. Zero fields turn their rule off, except MaxBytes,
+// which can't be more than MaxPasswordBytes.
+type PasswordPolicy struct {
+	// MinLength is the fewest characters a password has
+	MinLength int
+	// MaxBytes is the most bytes a password has, up to MaxPasswordBytes
+	MaxBytes int
+	// MinClasses is how many of lowercase letters, uppercase letters,
+	// digits and other characters a password mixes
+	MinClasses int
+	// RejectUsername refuses passwords containing the username
+	RejectUsername bool
+	// RejectCommon refuses passwords from the bundled list of common ones
+	RejectCommon bool
+}
+
+var DefaultPasswordPolicy = PasswordPolicy{
+	MinLength:      8,
+	MaxBytes:       MaxPasswordBytes,
+	MinClasses:     2,
+	RejectUsername: true,
+	RejectCommon:   true,
+}
+
+// minUsernameLength is the shortest username RejectUsername looks for, as
+// shorter ones turn up in passwords by chance
+const minUsernameLength = 3
+
+//go:embed common_passwords.txt
+var commonPasswordList string
+
+// commonPasswords holds the bundled list in lowercase
+var commonPasswords = func() map[string]bool {
+	passwords := map[string]bool{}
+	for _, line := range strings.Split(commonPasswordList, "\n") {
+		if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+			passwords[strings.ToLower(line)] = true
+		}
+	}
+	return passwords
+}()
+
+// Check returns a *domain.PasswordPolicyError listing every rule the
+// password fails. An empty username skips RejectUsername.
+func (p PasswordPolicy) Check(username, password string) error {
+	var violations []domain.RuleViolation
+	fail := func(rule, format string, args ...interface{}) {
+		violations = append(violations, domain.RuleViolation{Rule: rule, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if utf8.RuneCountInString(password) < p.MinLength {
+		fail(domain.PasswordRuleMinLength, "must be at least %d characters", p.MinLength)
+	}
+	maxBytes := p.MaxBytes
+	if maxBytes <= 0 || maxBytes > MaxPasswordBytes {
+		maxBytes = MaxPasswordBytes
+	}
+	if len(password) > maxBytes {
+		fail(domain.PasswordRuleMaxLength, "must be at most %d bytes", maxBytes)
+	}
+	if characterClasses(password) < p.MinClasses {
+		fail(domain.PasswordRuleClasses, "must mix at least %d of lowercase letters, uppercase letters, digits and symbols", p.MinClasses)
+	}
+	if p.RejectUsername && utf8.RuneCountInString(username) >= minUsernameLength &&
+		strings.Contains(strings.ToLower(password), strings.ToLower(username)) {
+		fail(domain.PasswordRuleUsername, "must not contain the username")
+	}
+	if p.RejectCommon && commonPasswords[strings.ToLower(password)] {
+		fail(domain.PasswordRuleCommon, "is too common")
+	}
+
+	if len(violations) > 0 {
+		return &domain.PasswordPolicyError{Violations: violations}
+	}
+	return nil
+}
+
+// characterClasses counts which of lowercase letters, uppercase letters,
+// digits and other characters the password has
+func characterClasses(password string) int {
+	var lower, upper, digit, other bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			other = true
+		}
+	}
+
+	count := 0
+	for _, has := range []bool{lower, upper, digit, other} {
+		if has {
+			count++
+		}
+	}
+	return count
+}
+
+// SetPasswordPolicy replaces the policy new passwords are checked against,
+// DefaultPasswordPolicy unless set
+func (uu *UserUsecase) SetPasswordPolicy(policy PasswordPolicy) {
+	uu.passwordPolicy = policy
+}
@@ -0,0 +1,151 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"task-manager/Domain"
+	"time"
+)
+
+// PasswordResetTTL is how long a mailed reset token can be used
+const PasswordResetTTL = time.Hour
+
+type PasswordResetUsecase struct {
+	userRepo        domain.UserRepository
+	resetRepo       domain.ResetTokenRepository
+	tokenRepo       domain.TokenRepository
+	passwordService domain.PasswordService
+	mailer          domain.Mailer
+	passwordPolicy  PasswordPolicy
+}
+
+func NewPasswordResetUsecase(
+	userRepo domain.UserRepository,
+	resetRepo domain.ResetTokenRepository,
+	tokenRepo domain.TokenRepository,
+	passwordService domain.PasswordService,
+	mailer domain.Mailer,
+) *PasswordResetUsecase {
+	return &PasswordResetUsecase{
+		userRepo:        userRepo,
+		resetRepo:       resetRepo,
+		tokenRepo:       tokenRepo,
+		passwordService: passwordService,
+		mailer:          mailer,
+		passwordPolicy:  DefaultPasswordPolicy,
+	}
+}
+
+// SetPasswordPolicy replaces the policy new passwords are checked against,
+// DefaultPasswordPolicy unless set
+func (pu *PasswordResetUsecase) SetPasswordPolicy(policy PasswordPolicy) {
+	pu.passwordPolicy = policy
+}
+
+// RequestPasswordReset mails a reset token to the user's verified email
+// address. Unknown and disabled users, and users without a verified address,
+// get nothing, but the caller can't tell the difference. Mail failures are
+// logged rather than returned for the same reason.
+func (pu *PasswordResetUsecase) RequestPasswordReset(ctx context.Context, username string) error {
+	if username == "" {
+		return domain.Validation("username is required")
+	}
+
+	user, err := pu.userRepo.GetUserByUsername(ctx, username)
+	if errors.Is(err, domain.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if user.Disabled || user.Email == "" || !user.EmailVerified {
+		return nil
+	}
+
+	token, err := newToken()
+	if err != nil {
+		return err
+	}
+	err = pu.resetRepo.SaveResetToken(ctx, domain.PasswordResetToken{
+		TokenHash: hashToken(token),
+		UserID:    user.ID,
+		ExpiresAt: time.Now().Add(PasswordResetTTL),
+	})
+	if err != nil {
+		return err
+	}
+
+	err = pu.mailer.Send(domain.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Someone asked to reset the password for %s.\n\n"+
+			"Use this token within %s to choose a new password:\n\n%s\n\n"+
+			"If it wasn't you, ignore this email; your password stays the same.\n",
+			user.Username, PasswordResetTTL, token),
+	})
+	if err != nil {
+		log.Printf("password reset mail for user %s failed: %v", user.ID, err)
+	}
+	return nil
+}
+
+// ResetPassword sets a new password with a mailed token. The token is used
+// up even when it has expired, and every other token the user was sent
+// stops working once the password changes, as do the user's refresh tokens.
+// Passwords the policy rejects leave the token usable for another try.
+func (pu *PasswordResetUsecase) ResetPassword(ctx context.Context, token string, newPassword string) error {
+	if newPassword == "" {
+		return domain.Validation("new password cannot be empty")
+	}
+	// Only the token says whose password it is, so the username is
+	// checked once it has been consumed
+	if err := pu.passwordPolicy.Check("", newPassword); err != nil {
+		return err
+	}
+	if token == "" {
+		return domain.ErrInvalidResetToken
+	}
+
+	stored, err := pu.resetRepo.ConsumeResetToken(ctx, hashToken(token))
+	if errors.Is(err, domain.ErrNotFound) {
+		return domain.ErrInvalidResetToken
+	}
+	if err != nil {
+		return err
+	}
+	if time.Now().After(stored.ExpiresAt) {
+		return domain.ErrInvalidResetToken
+	}
+
+	user, err := pu.userRepo.GetUserByID(ctx, stored.UserID.String())
+	if errors.Is(err, domain.ErrNotFound) {
+		return domain.ErrInvalidResetToken
+	}
+	if err != nil {
+		return err
+	}
+	if err := pu.passwordPolicy.Check(user.Username, newPassword); err != nil {
+		if err := pu.resetRepo.SaveResetToken(ctx, stored); err != nil {
+			return err
+		}
+		return err
+	}
+
+	hash, err := pu.passwordService.HashPassword(newPassword)
+	if err != nil {
+		return err
+	}
+	if err := pu.userRepo.SetPassword(ctx, stored.UserID.String(), hash); err != nil {
+		if errors.Is(err, domain.ErrNotFound) {
+			return domain.ErrInvalidResetToken
+		}
+		return err
+	}
+
+	if err := pu.resetRepo.DeleteResetTokens(ctx, stored.UserID); err != nil {
+		return err
+	}
+	return pu.tokenRepo.RevokeUserTokens(ctx, stored.UserID)
+}
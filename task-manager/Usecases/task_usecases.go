@@ -1,35 +1,273 @@
 package usecases
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"task-manager/Domain"
 )
 
+const (
+	DefaultTaskPageSize = 20
+	MaxTaskPageSize     = 100
+)
+
 type TaskUsecase struct {
 	taskRepo domain.TaskRepository
+	userRepo domain.UserRepository
 }
 
-func NewTaskUsecase(taskRepo domain.TaskRepository) *TaskUsecase {
+func NewTaskUsecase(taskRepo domain.TaskRepository, userRepo domain.UserRepository) *TaskUsecase {
 	return &TaskUsecase{
 		taskRepo: taskRepo,
+		userRepo: userRepo,
+	}
+}
+
+func (tu *TaskUsecase) GetAllTasks(ctx context.Context, caller domain.Caller, query domain.TaskQuery) (domain.TaskPage, error) {
+	if err := normalizeTaskQuery(&query); err != nil {
+		return domain.TaskPage{}, err
+	}
+
+	query.VisibleTo = ""
+	if !caller.Can(domain.PermTaskReadAny) {
+		if !caller.Can(domain.PermTaskReadOwn) {
+			return domain.TaskPage{}, permissionRequired(domain.PermTaskReadOwn)
+		}
+		query.VisibleTo = caller.UserID
+	}
+
+	tasks, total, err := tu.taskRepo.QueryTasks(ctx, query)
+	if err != nil {
+		return domain.TaskPage{}, err
+	}
+
+	return domain.TaskPage{
+		Tasks:  tasks,
+		Total:  total,
+		Limit:  query.Limit,
+		Offset: query.Offset,
+	}, nil
+}
+
+func (tu *TaskUsecase) GetTaskByID(ctx context.Context, caller domain.Caller, id string) (domain.Task, error) {
+	task, err := tu.taskRepo.GetTaskByID(ctx, id)
+	if err != nil {
+		return domain.Task{}, err
+	}
+
+	// Tasks the caller cannot see are reported as missing so their existence isn't leaked
+	if !canView(caller, task) {
+		return domain.Task{}, domain.ErrTaskNotFound
+	}
+
+	return task, nil
+}
+
+func (tu *TaskUsecase) CreateTask(ctx context.Context, caller domain.Caller, task domain.Task) (domain.Task, error) {
+	if !caller.Can(domain.PermTaskCreate) {
+		return domain.Task{}, permissionRequired(domain.PermTaskCreate)
+	}
+
+	creatorID := domain.ID(caller.UserID)
+	if !creatorID.Valid() {
+		return domain.Task{}, domain.InvalidID("invalid user ID")
+	}
+
+	task.CreatedBy = creatorID
+	if task.AssigneeID.IsZero() {
+		task.AssigneeID = creatorID
+	} else if task.AssigneeID != creatorID {
+		if err := tu.checkAssignee(ctx, task.AssigneeID); err != nil {
+			return domain.Task{}, err
+		}
+	}
+
+	if task.Status == "" {
+		task.Status = domain.StatusTodo
+	}
+	if !task.Status.Valid() {
+		return domain.Task{}, fmt.Errorf("%w: %q", domain.ErrInvalidStatus, task.Status)
+	}
+
+	return tu.taskRepo.CreateTask(ctx, task)
+}
+
+func (tu *TaskUsecase) UpdateTask(ctx context.Context, caller domain.Caller, id string, task domain.Task, version int64) (domain.Task, error) {
+	current, err := tu.taskRepo.GetTaskByID(ctx, id)
+	if err != nil {
+		return domain.Task{}, err
+	}
+	if err := authorize(caller, current, domain.PermTaskUpdateOwn, domain.PermTaskUpdateAny); err != nil {
+		return domain.Task{}, err
+	}
+
+	if version != 0 && current.Version != version {
+		return domain.Task{}, domain.ErrVersionConflict
+	}
+	// Write against the version validated here so a concurrent change
+	// cannot slip a forbidden transition past the state machine
+	version = current.Version
+
+	if task.Status == "" {
+		task.Status = current.Status
+	}
+	if err := domain.ValidateTransition(current.Status, task.Status); err != nil {
+		return domain.Task{}, err
+	}
+	if !task.AssigneeID.IsZero() && task.AssigneeID != current.AssigneeID {
+		if err := tu.checkAssignee(ctx, task.AssigneeID); err != nil {
+			return domain.Task{}, err
+		}
+	}
+
+	return tu.taskRepo.UpdateTask(ctx, id, task, version)
+}
+
+func (tu *TaskUsecase) PatchTask(ctx context.Context, caller domain.Caller, id string, patch domain.TaskPatch, version int64) (domain.Task, error) {
+	if !caller.Can(domain.PermTaskUpdateAny) {
+		current, err := tu.taskRepo.GetTaskByID(ctx, id)
+		if err != nil {
+			return domain.Task{}, err
+		}
+		if err := authorize(caller, current, domain.PermTaskUpdateOwn, domain.PermTaskUpdateAny); err != nil {
+			return domain.Task{}, err
+		}
+	}
+
+	if patch.IsEmpty() {
+		return domain.Task{}, domain.ErrEmptyPatch
+	}
+	if patch.DueDate != nil && patch.ClearDueDate {
+		return domain.Task{}, domain.Validation("due_date cannot be both set and cleared")
+	}
+	if patch.AssigneeID != nil {
+		if patch.AssigneeID.IsZero() {
+			return domain.Task{}, domain.InvalidID("invalid assignee ID")
+		}
+		if err := tu.checkAssignee(ctx, *patch.AssigneeID); err != nil {
+			return domain.Task{}, err
+		}
+	}
+
+	if patch.Status != nil {
+		current, err := tu.taskRepo.GetTaskByID(ctx, id)
+		if err != nil {
+			return domain.Task{}, err
+		}
+		if version != 0 && current.Version != version {
+			return domain.Task{}, domain.ErrVersionConflict
+		}
+		if err := domain.ValidateTransition(current.Status, *patch.Status); err != nil {
+			return domain.Task{}, err
+		}
+		version = current.Version
+	}
+
+	return tu.taskRepo.PatchTask(ctx, id, patch, version)
+}
+
+func (tu *TaskUsecase) TransitionTask(ctx context.Context, caller domain.Caller, id string, status domain.TaskStatus) (domain.Task, error) {
+	current, err := tu.taskRepo.GetTaskByID(ctx, id)
+	if err != nil {
+		return domain.Task{}, err
+	}
+	if err := authorize(caller, current, domain.PermTaskTransitionOwn, domain.PermTaskTransitionAny); err != nil {
+		return domain.Task{}, err
+	}
+
+	if err := domain.ValidateTransition(current.Status, status); err != nil {
+		return domain.Task{}, err
+	}
+	if current.Status == status {
+		return current, nil
+	}
+
+	return tu.taskRepo.TransitionTask(ctx, id, current.Status, status)
+}
+
+func (tu *TaskUsecase) DeleteTask(ctx context.Context, caller domain.Caller, id string, version int64) error {
+	if !caller.Can(domain.PermTaskDeleteAny) {
+		current, err := tu.taskRepo.GetTaskByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		if err := authorize(caller, current, domain.PermTaskDeleteOwn, domain.PermTaskDeleteAny); err != nil {
+			return err
+		}
+	}
+
+	return tu.taskRepo.DeleteTask(ctx, id, version)
+}
+
+// checkAssignee rejects assignees that do not match a user, since such tasks
+// would be visible to admins only
+func (tu *TaskUsecase) checkAssignee(ctx context.Context, id domain.ID) error {
+	_, err := tu.userRepo.GetUserByID(ctx, id.String())
+	if errors.Is(err, domain.ErrNotFound) || errors.Is(err, domain.ErrInvalidID) {
+		return domain.Validation("assignee %s does not exist", id)
 	}
+	return err
 }
 
-func (tu *TaskUsecase) GetAllTasks() ([]domain.Task, error) {
-	return tu.taskRepo.GetAllTasks()
+func canView(caller domain.Caller, task domain.Task) bool {
+	if caller.Can(domain.PermTaskReadAny) {
+		return true
+	}
+	return caller.Can(domain.PermTaskReadOwn) && involved(caller, task)
 }
 
-func (tu *TaskUsecase) GetTaskByID(id string) (domain.Task, error) {
-	return tu.taskRepo.GetTaskByID(id)
+// involved reports whether the caller created or is assigned to the task,
+// which is what the ":own" permissions cover
+func involved(caller domain.Caller, task domain.Task) bool {
+	return caller.UserID != "" &&
+		(task.CreatedBy.String() == caller.UserID || task.AssigneeID.String() == caller.UserID)
 }
 
-func (tu *TaskUsecase) CreateTask(task domain.Task) (domain.Task, error) {
-	return tu.taskRepo.CreateTask(task)
+// authorize checks that the caller may act on the task, either through the
+// "any" permission or through the "own" one for tasks they are involved in.
+// Tasks the caller cannot see are reported as missing.
+func authorize(caller domain.Caller, task domain.Task, own, any domain.Permission) error {
+	if !canView(caller, task) {
+		return domain.ErrTaskNotFound
+	}
+	if caller.Can(any) || (caller.Can(own) && involved(caller, task)) {
+		return nil
+	}
+	if caller.Can(own) {
+		return permissionRequired(any)
+	}
+	return permissionRequired(own)
 }
 
-func (tu *TaskUsecase) UpdateTask(id string, task domain.Task) (domain.Task, error) {
-	return tu.taskRepo.UpdateTask(id, task)
+func permissionRequired(permission domain.Permission) error {
+	return domain.Forbidden("permission %s required", permission)
 }
 
-func (tu *TaskUsecase) DeleteTask(id string) error {
-	return tu.taskRepo.DeleteTask(id)
-}
\ No newline at end of file
+func normalizeTaskQuery(query *domain.TaskQuery) error {
+	if query.Offset < 0 {
+		return fmt.Errorf("%w: offset cannot be negative", domain.ErrInvalidTaskQuery)
+	}
+	if query.Limit < 0 {
+		return fmt.Errorf("%w: limit cannot be negative", domain.ErrInvalidTaskQuery)
+	}
+	if query.Status != "" && !query.Status.Valid() {
+		return fmt.Errorf("%w: unknown status %q", domain.ErrInvalidTaskQuery, query.Status)
+	}
+	if query.Limit == 0 {
+		query.Limit = DefaultTaskPageSize
+	}
+	if query.Limit > MaxTaskPageSize {
+		query.Limit = MaxTaskPageSize
+	}
+
+	if query.SortBy == "" {
+		query.SortBy = "_id"
+	}
+	for _, field := range domain.TaskSortFields {
+		if query.SortBy == field {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: cannot sort by %q", domain.ErrInvalidTaskQuery, query.SortBy)
+}
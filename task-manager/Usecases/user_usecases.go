@@ -1,31 +1,662 @@
 package usecases
 
 import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"log"
+	"strings"
 	"task-manager/Domain"
+	"time"
 )
 
+// RefreshTokenTTL is how long a refresh token can be exchanged for a new
+// access token. Every exchange rotates it, restarting the period.
+const RefreshTokenTTL = 7 * 24 * time.Hour
+
+const (
+	DefaultUserPageSize = 20
+	MaxUserPageSize     = 100
+)
+
+// LoginPolicy holds the checks LoginUser makes beyond the credentials
+type LoginPolicy struct {
+	// RequireVerifiedEmail refuses users whose email address is missing
+	// or not verified
+	RequireVerifiedEmail bool
+}
+
 type UserUsecase struct {
-	userRepo domain.UserRepository
+	userRepo        domain.UserRepository
+	taskRepo        domain.TaskRepository
+	tokenRepo       domain.TokenRepository
+	mfaRepo         domain.MFARepository
+	throttleRepo    domain.LoginThrottleRepository
+	jwtService      domain.JWTService
+	passwordService domain.PasswordService
+	totpService     domain.TOTPService
+	auditLog        domain.AuditLog
+	loginPolicy     LoginPolicy
+	lockoutPolicy   LockoutPolicy
+	passwordPolicy  PasswordPolicy
+	// setupTokenHash is the SHA-256 hash of the admin setup token, empty
+	// when setup is off
+	setupTokenHash string
 }
 
-func NewUserUsecase(userRepo domain.UserRepository) *UserUsecase {
+func NewUserUsecase(
+	userRepo domain.UserRepository,
+	taskRepo domain.TaskRepository,
+	tokenRepo domain.TokenRepository,
+	mfaRepo domain.MFARepository,
+	throttleRepo domain.LoginThrottleRepository,
+	jwtService domain.JWTService,
+	passwordService domain.PasswordService,
+	totpService domain.TOTPService,
+	auditLog domain.AuditLog,
+) *UserUsecase {
 	return &UserUsecase{
-		userRepo: userRepo,
+		userRepo:        userRepo,
+		taskRepo:        taskRepo,
+		tokenRepo:       tokenRepo,
+		mfaRepo:         mfaRepo,
+		throttleRepo:    throttleRepo,
+		jwtService:      jwtService,
+		passwordService: passwordService,
+		totpService:     totpService,
+		auditLog:        auditLog,
+		lockoutPolicy:   DefaultLockoutPolicy,
+		passwordPolicy:  DefaultPasswordPolicy,
+	}
+}
+
+// SetLoginPolicy replaces the policy LoginUser applies, which by default
+// only checks the credentials
+func (uu *UserUsecase) SetLoginPolicy(policy LoginPolicy) {
+	uu.loginPolicy = policy
+}
+
+// RegisterUser creates a member once the email address and the password
+// pass their checks. Registration never makes admins; the first one is
+// created with SetupAdmin.
+func (uu *UserUsecase) RegisterUser(ctx context.Context, user domain.User) (domain.User, error) {
+	user.Role = domain.RoleMember
+	if err := uu.checkNewUser(user); err != nil {
+		return domain.User{}, err
+	}
+	return uu.userRepo.RegisterUser(ctx, user)
+}
+
+// checkNewUser checks the fields, the email address and the password of a
+// user about to be created
+func (uu *UserUsecase) checkNewUser(user domain.User) error {
+	if user.Username == "" || user.Password == "" {
+		return domain.Validation("fields cannot be empty")
+	}
+	if user.Email != "" {
+		if err := domain.ValidateEmail(user.Email); err != nil {
+			return err
+		}
+	}
+	return uu.passwordPolicy.Check(user.Username, user.Password)
+}
+
+// LoginUser checks the credentials and the login policy. Users with
+// two-factor authentication get an MFA challenge to finish with
+// CompleteMFALogin; everyone else gets an access token and starts a new
+// refresh token family. Failed logins count towards the lockout of the
+// username and the client IP, and only a login that issues tokens resets
+// the count of the username.
+func (uu *UserUsecase) LoginUser(ctx context.Context, credentials domain.User, clientIP string) (domain.LoginResponse, error) {
+	if credentials.Username == "" {
+		return domain.LoginResponse{}, domain.Validation("username is a required field")
+	}
+
+	keys := uu.loginKeys(credentials.Username, clientIP)
+	if err := uu.checkLockout(ctx, keys); err != nil {
+		return domain.LoginResponse{}, err
+	}
+
+	user, err := uu.authenticate(ctx, credentials)
+	if errors.Is(err, domain.ErrUnauthorized) {
+		if err := uu.recordLoginFailure(ctx, keys); err != nil {
+			return domain.LoginResponse{}, err
+		}
+		return domain.LoginResponse{}, err
+	}
+	if err != nil {
+		return domain.LoginResponse{}, err
+	}
+
+	uu.rehashPassword(ctx, user, credentials.Password)
+	if uu.loginPolicy.RequireVerifiedEmail && !user.EmailVerified {
+		return domain.LoginResponse{}, domain.ErrEmailNotVerified
+	}
+
+	enrollment, err := uu.mfaRepo.GetEnrollment(ctx, user.ID)
+	if err == nil && enrollment.Confirmed {
+		return uu.startMFAChallenge(ctx, user, credentials.Username, clientIP)
+	}
+	if err != nil && !errors.Is(err, domain.ErrNotFound) {
+		return domain.LoginResponse{}, err
+	}
+
+	resp, err := uu.issueTokens(ctx, user, domain.NewID())
+	if err != nil {
+		return domain.LoginResponse{}, err
+	}
+	if err := uu.clearLoginFailures(ctx, credentials.Username); err != nil {
+		return domain.LoginResponse{}, err
+	}
+	return resp, nil
+}
+
+// CompleteMFALogin issues the tokens of a login waiting for its second
+// factor. Each MFA token is good for MaxMFAAttempts codes, after which the
+// user has to enter their password again. Wrong codes count towards the
+// lockout of the username and the client IP the login was made with, so
+// fresh challenges don't give an attacker unlimited guesses.
+func (uu *UserUsecase) CompleteMFALogin(ctx context.Context, mfaToken string, code string) (domain.LoginResponse, error) {
+	if mfaToken == "" {
+		return domain.LoginResponse{}, domain.ErrInvalidMFAChallenge
+	}
+
+	// Consuming the challenge up front means concurrent guesses with the
+	// same token don't each get their own attempt
+	challenge, err := uu.mfaRepo.ConsumeMFAChallenge(ctx, hashToken(mfaToken))
+	if errors.Is(err, domain.ErrNotFound) {
+		return domain.LoginResponse{}, domain.ErrInvalidMFAChallenge
+	}
+	if err != nil {
+		return domain.LoginResponse{}, err
+	}
+	// Challenges saved before the login keys were stored can't be
+	// throttled, so those logins start over
+	if !challenge.ExpiresAt.After(time.Now()) || challenge.Username == "" {
+		return domain.LoginResponse{}, domain.ErrInvalidMFAChallenge
+	}
+
+	keys := uu.loginKeys(challenge.Username, challenge.ClientIP)
+	if err := uu.checkLockout(ctx, keys); err != nil {
+		return domain.LoginResponse{}, err
+	}
+
+	// Two-factor authentication may have been turned off since
+	enrollment, err := uu.mfaRepo.GetEnrollment(ctx, challenge.UserID)
+	if errors.Is(err, domain.ErrNotFound) || (err == nil && !enrollment.Confirmed) {
+		return domain.LoginResponse{}, domain.ErrInvalidMFAChallenge
+	}
+	if err != nil {
+		return domain.LoginResponse{}, err
+	}
+
+	err = checkCode(ctx, uu.mfaRepo, uu.totpService, enrollment, code)
+	if errors.Is(err, errWrongCode) {
+		if err := uu.recordLoginFailure(ctx, keys); err != nil {
+			return domain.LoginResponse{}, err
+		}
+		challenge.Attempts++
+		if challenge.Attempts < MaxMFAAttempts {
+			if err := uu.mfaRepo.SaveMFAChallenge(ctx, challenge); err != nil {
+				return domain.LoginResponse{}, err
+			}
+		}
+		return domain.LoginResponse{}, domain.ErrInvalidMFACode
+	}
+	if err != nil {
+		return domain.LoginResponse{}, err
+	}
+
+	user, err := uu.userRepo.GetUserByID(ctx, challenge.UserID.String())
+	if errors.Is(err, domain.ErrNotFound) {
+		return domain.LoginResponse{}, domain.ErrInvalidMFAChallenge
+	}
+	if err != nil {
+		return domain.LoginResponse{}, err
+	}
+	if user.Disabled {
+		return domain.LoginResponse{}, domain.ErrAccountDisabled
+	}
+
+	resp, err := uu.issueTokens(ctx, user, domain.NewID())
+	if err != nil {
+		return domain.LoginResponse{}, err
+	}
+	if err := uu.clearLoginFailures(ctx, challenge.Username); err != nil {
+		return domain.LoginResponse{}, err
+	}
+	return resp, nil
+}
+
+// RefreshToken exchanges a refresh token for a new access token and a new
+// refresh token. Each refresh token works once; presenting one that was
+// already rotated means it leaked, so its whole family is revoked.
+func (uu *UserUsecase) RefreshToken(ctx context.Context, refreshToken string) (domain.LoginResponse, error) {
+	hash := hashToken(refreshToken)
+
+	stored, err := uu.tokenRepo.GetRefreshToken(ctx, hash)
+	if errors.Is(err, domain.ErrNotFound) {
+		return domain.LoginResponse{}, domain.ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return domain.LoginResponse{}, err
+	}
+
+	if stored.Revoked {
+		return domain.LoginResponse{}, uu.revokeReusedFamily(ctx, stored)
+	}
+	if !stored.ExpiresAt.After(time.Now()) {
+		return domain.LoginResponse{}, domain.ErrInvalidRefreshToken
+	}
+
+	// Losing this race means another request rotated the token first
+	err = uu.tokenRepo.RevokeRefreshToken(ctx, hash)
+	if errors.Is(err, domain.ErrNotFound) {
+		return domain.LoginResponse{}, uu.revokeReusedFamily(ctx, stored)
+	}
+	if err != nil {
+		return domain.LoginResponse{}, err
+	}
+
+	// Reload the user so role changes apply from the next access token
+	user, err := uu.userRepo.GetUserByID(ctx, stored.UserID.String())
+	if errors.Is(err, domain.ErrNotFound) {
+		return domain.LoginResponse{}, domain.ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return domain.LoginResponse{}, err
+	}
+	if user.Disabled {
+		return domain.LoginResponse{}, domain.ErrAccountDisabled
+	}
+
+	return uu.issueTokens(ctx, user, stored.FamilyID)
+}
+
+// Logout revokes the access token the caller used and, when given, the
+// refresh token family it belongs to. Refresh tokens of other users and
+// unknown tokens are ignored.
+func (uu *UserUsecase) Logout(ctx context.Context, caller domain.Caller, refreshToken string) error {
+	if caller.TokenID != "" {
+		if err := uu.tokenRepo.RevokeAccessToken(ctx, caller.TokenID, caller.TokenExpiresAt); err != nil {
+			return err
+		}
+	}
+
+	if refreshToken == "" {
+		return nil
+	}
+
+	stored, err := uu.tokenRepo.GetRefreshToken(ctx, hashToken(refreshToken))
+	if errors.Is(err, domain.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if stored.UserID.String() != caller.UserID {
+		return nil
+	}
+
+	return uu.tokenRepo.RevokeTokenFamily(ctx, stored.FamilyID)
+}
+
+func (uu *UserUsecase) PromoteUser(ctx context.Context, caller domain.Caller, id string) (domain.User, error) {
+	if !caller.Can(domain.PermUserPromote) {
+		return domain.User{}, permissionRequired(domain.PermUserPromote)
+	}
+	return uu.userRepo.PromoteUser(ctx, id)
+}
+
+func (uu *UserUsecase) GetUserByUsername(ctx context.Context, caller domain.Caller, username string) (domain.User, error) {
+	if !caller.Can(domain.PermUserRead) {
+		return domain.User{}, permissionRequired(domain.PermUserRead)
+	}
+
+	user, err := uu.userRepo.GetUserByUsername(ctx, username)
+	user.Password = ""
+	return user, err
+}
+
+func (uu *UserUsecase) ListUsers(ctx context.Context, caller domain.Caller, query domain.UserQuery) (domain.UserPage, error) {
+	if !caller.Can(domain.PermUserManage) {
+		return domain.UserPage{}, permissionRequired(domain.PermUserManage)
+	}
+	if query.Offset < 0 {
+		return domain.UserPage{}, domain.Validation("offset cannot be negative")
+	}
+	if query.Limit < 0 {
+		return domain.UserPage{}, domain.Validation("limit cannot be negative")
 	}
+	if query.Role != "" && !domain.ValidRole(query.Role) {
+		return domain.UserPage{}, domain.Validation("unknown role %q", query.Role)
+	}
+	if query.Limit == 0 {
+		query.Limit = DefaultUserPageSize
+	}
+	if query.Limit > MaxUserPageSize {
+		query.Limit = MaxUserPageSize
+	}
+
+	users, total, err := uu.userRepo.ListUsers(ctx, query)
+	if err != nil {
+		return domain.UserPage{}, err
+	}
+
+	public := make([]domain.PublicUser, len(users))
+	for i, user := range users {
+		public[i] = user.Public()
+	}
+
+	return domain.UserPage{
+		Users:  public,
+		Total:  total,
+		Limit:  query.Limit,
+		Offset: query.Offset,
+	}, nil
+}
+
+// SetUserRole changes a user's role. The change applies to the user's next
+// request, since AuthMiddleware reads the role from the stored user.
+func (uu *UserUsecase) SetUserRole(ctx context.Context, caller domain.Caller, id string, role string) (domain.User, error) {
+	if !caller.Can(domain.PermUserManage) {
+		return domain.User{}, permissionRequired(domain.PermUserManage)
+	}
+	if !domain.ValidRole(role) {
+		return domain.User{}, domain.Validation("role must be one of %s", strings.Join(domain.Roles, ", "))
+	}
+
+	// The repository refuses to demote the last enabled admin
+	return uu.userRepo.SetUserRole(ctx, id, role)
+}
+
+// SetUserDisabled disables or re-enables a user. Disabled users cannot log
+// in, refresh tokens or use access tokens they already hold.
+func (uu *UserUsecase) SetUserDisabled(ctx context.Context, caller domain.Caller, id string, disabled bool) (domain.User, error) {
+	if !caller.Can(domain.PermUserManage) {
+		return domain.User{}, permissionRequired(domain.PermUserManage)
+	}
+
+	// The repository refuses to disable the last enabled admin
+	return uu.userRepo.SetUserDisabled(ctx, id, disabled)
+}
+
+// ResetUserMFA turns off two-factor authentication for a user who lost both
+// their authenticator and their recovery codes. They can enroll again after
+// logging in with their password.
+func (uu *UserUsecase) ResetUserMFA(ctx context.Context, caller domain.Caller, id string) error {
+	if !caller.Can(domain.PermUserManage) {
+		return permissionRequired(domain.PermUserManage)
+	}
+
+	user, err := uu.userRepo.GetUserByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	return uu.mfaRepo.DeleteEnrollment(ctx, user.ID)
+}
+
+// DeleteUser deletes a user after handing their tasks over as the options
+// say. By default every task they created or are assigned to goes to the
+// caller.
+func (uu *UserUsecase) DeleteUser(ctx context.Context, caller domain.Caller, id string, options domain.DeleteUserOptions) error {
+	if !caller.Can(domain.PermUserManage) {
+		return permissionRequired(domain.PermUserManage)
+	}
+
+	user, err := uu.userRepo.GetUserByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	// Checked before the tasks are handed over; the repository checks again
+	// when deleting, in case another admin went meanwhile
+	if err := uu.ensureAdminRemains(ctx, user); err != nil {
+		return err
+	}
+
+	switch options.Tasks {
+	case "", domain.TaskPolicyReassign:
+		to := options.ReassignTo
+		if to.IsZero() {
+			to = domain.ID(caller.UserID)
+		}
+		if to == user.ID {
+			return domain.Validation("tasks cannot be reassigned to the user being deleted")
+		}
+		_, err := uu.userRepo.GetUserByID(ctx, to.String())
+		if errors.Is(err, domain.ErrNotFound) || errors.Is(err, domain.ErrInvalidID) {
+			return domain.Validation("user %s to reassign tasks to does not exist", to)
+		}
+		if err != nil {
+			return err
+		}
+		if err := uu.taskRepo.ReassignTasks(ctx, user.ID, to); err != nil {
+			return err
+		}
+	case domain.TaskPolicyDelete:
+		if err := uu.taskRepo.DeleteTasksCreatedBy(ctx, user.ID); err != nil {
+			return err
+		}
+		if err := uu.taskRepo.UnassignTasks(ctx, user.ID); err != nil {
+			return err
+		}
+	default:
+		return domain.Validation("tasks must be %s or %s", domain.TaskPolicyReassign, domain.TaskPolicyDelete)
+	}
+
+	if err := uu.userRepo.DeleteUser(ctx, id); err != nil {
+		return err
+	}
+	return uu.mfaRepo.DeleteEnrollment(ctx, user.ID)
+}
+
+// GetProfile returns the caller's own account. Every signed-in user may see
+// it, whatever their role.
+func (uu *UserUsecase) GetProfile(ctx context.Context, caller domain.Caller) (domain.User, error) {
+	user, err := uu.userRepo.GetUserByID(ctx, caller.UserID)
+	user.Password = ""
+	return user, err
+}
+
+func (uu *UserUsecase) UpdateProfile(ctx context.Context, caller domain.Caller, patch domain.ProfilePatch) (domain.User, error) {
+	if patch.IsEmpty() {
+		return domain.User{}, domain.ErrEmptyPatch
+	}
+	if patch.Username != nil && *patch.Username == "" {
+		return domain.User{}, domain.Validation("username cannot be empty")
+	}
+	if patch.Email != nil && *patch.Email != "" {
+		if err := domain.ValidateEmail(*patch.Email); err != nil {
+			return domain.User{}, err
+		}
+	}
+	return uu.userRepo.UpdateProfile(ctx, caller.UserID, patch)
+}
+
+// ChangePassword sets a new password after checking the current one, so a
+// stolen access token alone can't take over the account. Every refresh token
+// of the user and the access token the caller used are revoked, so sessions
+// opened with the old password end; other access tokens last until they
+// expire.
+func (uu *UserUsecase) ChangePassword(ctx context.Context, caller domain.Caller, current, next string) error {
+	if next == "" {
+		return domain.Validation("new password cannot be empty")
+	}
+
+	user, err := uu.userRepo.GetUserByID(ctx, caller.UserID)
+	if err != nil {
+		return err
+	}
+	if err := uu.passwordService.ComparePassword(user.Password, current); err != nil {
+		return domain.ErrWrongPassword
+	}
+	if err := uu.passwordPolicy.Check(user.Username, next); err != nil {
+		return err
+	}
+
+	hash, err := uu.passwordService.HashPassword(next)
+	if err != nil {
+		return err
+	}
+	if err := uu.userRepo.SetPassword(ctx, caller.UserID, hash); err != nil {
+		return err
+	}
+
+	if err := uu.tokenRepo.RevokeUserTokens(ctx, domain.ID(caller.UserID)); err != nil {
+		return err
+	}
+	if caller.TokenID != "" {
+		return uu.tokenRepo.RevokeAccessToken(ctx, caller.TokenID, caller.TokenExpiresAt)
+	}
+	return nil
 }
 
-func (uu *UserUsecase) RegisterUser(user domain.User) (domain.User, error) {
-	return uu.userRepo.RegisterUser(user)
+// ensureAdminRemains refuses to delete the user when they are the only
+// enabled admin left
+func (uu *UserUsecase) ensureAdminRemains(ctx context.Context, user domain.User) error {
+	if user.Role != domain.RoleAdmin || user.Disabled {
+		return nil
+	}
+
+	enabled := false
+	_, admins, err := uu.userRepo.ListUsers(ctx, domain.UserQuery{Role: domain.RoleAdmin, Disabled: &enabled, Limit: 1})
+	if err != nil {
+		return err
+	}
+	if admins <= 1 {
+		return domain.ErrLastAdmin
+	}
+	return nil
 }
 
-func (uu *UserUsecase) LoginUser(user domain.User) (domain.LoginResponse, error) {
-	return uu.userRepo.LoginUser(user)
+// authenticate returns the user the credentials belong to. Unknown users and
+// wrong passwords get the same error, and only someone who knows the
+// password learns that an account is disabled.
+func (uu *UserUsecase) authenticate(ctx context.Context, credentials domain.User) (domain.User, error) {
+	user, err := uu.userRepo.GetUserByUsername(ctx, credentials.Username)
+	if errors.Is(err, domain.ErrNotFound) {
+		return domain.User{}, domain.Unauthorized("invalid username or password")
+	}
+	if err != nil {
+		return domain.User{}, err
+	}
+
+	if err := uu.passwordService.ComparePassword(user.Password, credentials.Password); err != nil {
+		return domain.User{}, domain.Unauthorized("invalid username or password")
+	}
+	if user.Disabled {
+		return domain.User{}, domain.ErrAccountDisabled
+	}
+	return user, nil
 }
 
-func (uu *UserUsecase) PromoteUser(id string) (domain.User, error) {
-	return uu.userRepo.PromoteUser(id)
+// rehashPassword stores a new hash of a password that just matched when its
+// stored hash uses outdated parameters. The login goes ahead either way, so
+// a failure is only logged.
+func (uu *UserUsecase) rehashPassword(ctx context.Context, user domain.User, password string) {
+	if !uu.passwordService.NeedsRehash(user.Password) {
+		return
+	}
+
+	hash, err := uu.passwordService.HashPassword(password)
+	if err == nil {
+		err = uu.userRepo.SetPassword(ctx, user.ID.String(), hash)
+	}
+	if err != nil {
+		log.Printf("rehashing the password of user %s failed: %v", user.ID, err)
+	}
 }
 
-func (uu *UserUsecase) GetUserByUsername(username string) (domain.User, error) {
-	return uu.userRepo.GetUserByUsername(username)
-}
\ No newline at end of file
+// startMFAChallenge answers a correct password when the user has two-factor
+// authentication on. No access token is issued until the code arrives. The
+// username and client IP of the login are kept to throttle wrong codes.
+func (uu *UserUsecase) startMFAChallenge(ctx context.Context, user domain.User, username, clientIP string) (domain.LoginResponse, error) {
+	token, err := newToken()
+	if err != nil {
+		return domain.LoginResponse{}, err
+	}
+
+	err = uu.mfaRepo.SaveMFAChallenge(ctx, domain.MFAChallenge{
+		TokenHash: hashToken(token),
+		UserID:    user.ID,
+		Username:  username,
+		ClientIP:  clientIP,
+		ExpiresAt: time.Now().Add(MFAChallengeTTL),
+	})
+	if err != nil {
+		return domain.LoginResponse{}, err
+	}
+
+	return domain.LoginResponse{
+		ID:          user.ID,
+		Username:    user.Username,
+		MFARequired: true,
+		MFAToken:    token,
+	}, nil
+}
+
+// issueTokens returns an access token and a refresh token in the family
+func (uu *UserUsecase) issueTokens(ctx context.Context, user domain.User, familyID domain.ID) (domain.LoginResponse, error) {
+	token, err := uu.jwtService.GenerateToken(user.ID.String(), user.Username, user.Role)
+	if err != nil {
+		return domain.LoginResponse{}, err
+	}
+
+	refreshToken, err := uu.issueRefreshToken(ctx, user.ID, familyID)
+	if err != nil {
+		return domain.LoginResponse{}, err
+	}
+
+	return domain.LoginResponse{
+		ID:           user.ID,
+		Username:     user.Username,
+		Token:        token,
+		RefreshToken: refreshToken,
+	}, nil
+}
+
+func (uu *UserUsecase) issueRefreshToken(ctx context.Context, userID, familyID domain.ID) (string, error) {
+	token, err := newToken()
+	if err != nil {
+		return "", err
+	}
+
+	err = uu.tokenRepo.SaveRefreshToken(ctx, domain.RefreshToken{
+		TokenHash: hashToken(token),
+		UserID:    userID,
+		FamilyID:  familyID,
+		ExpiresAt: time.Now().Add(RefreshTokenTTL),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+func (uu *UserUsecase) revokeReusedFamily(ctx context.Context, stored domain.RefreshToken) error {
+	if err := uu.tokenRepo.RevokeTokenFamily(ctx, stored.FamilyID); err != nil {
+		return err
+	}
+	return domain.ErrInvalidRefreshToken
+}
+
+// newToken returns a random URL-safe token with 256 bits of entropy
+func newToken() (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+// hashToken is how refresh, reset and MFA tokens and recovery codes are
+// stored. They are random, so a fast hash is enough to keep a database leak
+// from exposing usable tokens.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,324 @@
+package config_test
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"task-manager/Delivery/config"
+	infrastructure "task-manager/Infrastructure"
+	repositories "task-manager/Repositories"
+	usecases "task-manager/Usecases"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// configEnv lists every variable Load reads
+var configEnv = []string{
+	"CONFIG_FILE", "SERVER_ADDR", "TRUSTED_PROXIES", "STORAGE_BACKEND", "MONGODB_URI", "MONGODB_DATABASE",
+	"DATABASE_URL", "JWT_PRIVATE_KEY_FILE", "JWT_PRIVATE_KEY", "JWT_SECRET", "JWT_VERIFICATION_KEY_FILES",
+	"PASSWORD_HASH", "BCRYPT_COST", "ARGON2_TIME", "ARGON2_MEMORY", "ARGON2_THREADS",
+	"SERVER_READ_TIMEOUT", "SERVER_WRITE_TIMEOUT", "SERVER_IDLE_TIMEOUT", "SHUTDOWN_TIMEOUT",
+	"DB_READ_TIMEOUT", "DB_WRITE_TIMEOUT", "DB_CONNECT_TIMEOUT", "MIGRATION_TIMEOUT", "HEALTH_CHECK_TIMEOUT",
+	"PASSWORD_MIN_LENGTH", "PASSWORD_MAX_BYTES", "PASSWORD_MIN_CLASSES", "PASSWORD_REJECT_USERNAME", "PASSWORD_REJECT_COMMON",
+	"REQUIRE_VERIFIED_EMAIL", "LOGIN_MAX_FAILURES", "LOGIN_MAX_IP_FAILURES", "LOGIN_LOCKOUT", "LOGIN_MAX_LOCKOUT",
+	"ADMIN_SETUP_TOKEN", "MFA_ISSUER", "SMTP_HOST", "SMTP_PORT", "SMTP_USERNAME", "SMTP_PASSWORD", "MAIL_FROM", "MAIL_DIR",
+	"AUDIT_LOG_FILE", "TOKEN_CLEANUP_INTERVAL",
+}
+
+// cleanEnv unsets the variables Load reads and restores them after the test
+func cleanEnv(t *testing.T) {
+	for _, name := range configEnv {
+		t.Setenv(name, "")
+		require.NoError(t, os.Unsetenv(name))
+	}
+}
+
+func writeFile(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+// load runs Load with an empty .env file, so a .env in the working
+// directory can't leak in
+func load(t *testing.T, args ...string) (config.Config, error) {
+	return config.Load(append([]string{"-env-file", writeFile(t, ".env", "")}, args...))
+}
+
+func TestDefaults(t *testing.T) {
+	cleanEnv(t)
+
+	cfg, err := load(t)
+	require.NoError(t, err)
+	assert.Equal(t, config.Default(), cfg)
+	assert.Equal(t, ":8080", cfg.Server.Addr)
+	assert.Equal(t, config.BackendMongo, cfg.Database.Backend)
+	assert.Equal(t, "taskdb", cfg.Database.Name)
+	assert.Equal(t, repositories.DefaultCollectionNames, cfg.Database.Collections)
+	assert.Equal(t, infrastructure.DefaultPasswordHashing, cfg.Password)
+	assert.Equal(t, usecases.DefaultPasswordPolicy, cfg.PasswordPolicy)
+	assert.Equal(t, usecases.DefaultLockoutPolicy, cfg.Lockout)
+	assert.Equal(t, infrastructure.DefaultMailSettings, cfg.Mail)
+	assert.Equal(t, "Task Manager", cfg.Auth.MFAIssuer)
+	assert.Empty(t, cfg.Auth.SetupToken)
+	assert.Equal(t, 5*time.Second, cfg.Timeouts.Read)
+	assert.Equal(t, 30*time.Second, cfg.Server.ShutdownTimeout)
+	assert.Equal(t, time.Hour, cfg.Database.TokenCleanupInterval)
+
+	t.Run("the default .env file is optional", func(t *testing.T) {
+		wd, err := os.Getwd()
+		require.NoError(t, err)
+		require.NoError(t, os.Chdir(t.TempDir()))
+		defer os.Chdir(wd)
+
+		_, err = config.Load(nil)
+		assert.NoError(t, err)
+	})
+
+	t.Run("a .env file asked for has to exist", func(t *testing.T) {
+		_, err := config.Load([]string{"-env-file", filepath.Join(t.TempDir(), "missing.env")})
+		assert.ErrorIs(t, err, os.ErrNotExist)
+	})
+
+	t.Run("SQLite stores its data in a file by default", func(t *testing.T) {
+		cfg, err := load(t, "-database.backend", config.BackendSQLite)
+		require.NoError(t, err)
+		assert.Equal(t, config.DefaultSQLiteFile, cfg.Database.URL)
+	})
+}
+
+func TestConfigFile(t *testing.T) {
+	want := config.Default()
+	want.Server.Addr = "127.0.0.1:9000"
+	want.Server.TrustedProxies = []string{"10.0.0.0/8", "192.168.1.1"}
+	want.Database.Name = "tasks_prod"
+	want.Database.Collections.Tasks = "prod_tasks"
+	want.Password.Algorithm = infrastructure.AlgorithmArgon2id
+	want.Password.Argon2.Threads = 4
+	want.Timeouts.Read = 2 * time.Second
+	want.PasswordPolicy.MinLength = 12
+	want.PasswordPolicy.RejectCommon = false
+	want.Login.RequireVerifiedEmail = true
+	want.Lockout.MaxFailures = 3
+	want.Lockout.Lockout = 5 * time.Minute
+	want.Mail.SMTPHost = "smtp.example.com"
+	want.Audit.LogFile = "/var/log/task-manager/audit.log"
+
+	files := map[string]string{
+		"config.yaml": `
+server:
+  addr: 127.0.0.1:9000
+  trusted_proxies: [10.0.0.0/8, 192.168.1.1]
+database:
+  name: tasks_prod
+  collections:
+    tasks: prod_tasks
+password:
+  hash: argon2id
+  argon2_threads: 4
+  min_length: 12
+  reject_common: false
+login:
+  require_verified_email: true
+  max_failures: 3
+  lockout: 5m
+mail:
+  smtp_host: smtp.example.com
+audit:
+  log_file: /var/log/task-manager/audit.log
+timeouts:
+  db_read: 2s
+`,
+		"config.toml": `
+[server]
+addr = "127.0.0.1:9000"
+trusted_proxies = ["10.0.0.0/8", "192.168.1.1"]
+
+[database]
+name = "tasks_prod"
+collections.tasks = "prod_tasks"
+
+[password]
+hash = "argon2id"
+argon2_threads = 4
+min_length = 12
+reject_common = false
+
+[login]
+require_verified_email = true
+max_failures = 3
+lockout = "5m"
+
+[mail]
+smtp_host = "smtp.example.com"
+
+[audit]
+log_file = "/var/log/task-manager/audit.log"
+
+[timeouts]
+db_read = "2s"
+`,
+	}
+
+	for name, content := range files {
+		t.Run(name, func(t *testing.T) {
+			cleanEnv(t)
+			path := writeFile(t, name, content)
+
+			cfg, err := load(t, "-config", path)
+			require.NoError(t, err)
+			assert.Equal(t, want, cfg)
+
+			t.Setenv("CONFIG_FILE", path)
+			cfg, err = load(t)
+			require.NoError(t, err)
+			assert.Equal(t, want, cfg)
+		})
+	}
+
+	t.Run("unknown settings are rejected", func(t *testing.T) {
+		cleanEnv(t)
+		_, err := load(t, "-config", writeFile(t, "config.yaml", "server:\n  adress: :9000\n"))
+		assert.ErrorContains(t, err, "unknown setting server.adress")
+	})
+
+	t.Run("invalid values are rejected", func(t *testing.T) {
+		cleanEnv(t)
+		_, err := load(t, "-config", writeFile(t, "config.toml", "[timeouts]\ndb_read = \"soon\"\n"))
+		assert.ErrorContains(t, err, "invalid timeouts.db_read")
+	})
+
+	t.Run("other formats are rejected", func(t *testing.T) {
+		cleanEnv(t)
+		_, err := load(t, "-config", writeFile(t, "config.json", "{}"))
+		assert.ErrorContains(t, err, "must end in .yaml, .yml or .toml")
+	})
+}
+
+func TestPrecedence(t *testing.T) {
+	cleanEnv(t)
+	configFile := writeFile(t, "config.yaml", "server:\n  addr: :1001\ndatabase:\n  name: from_file\n  backend: memory\ntimeouts:\n  db_read: 1s\n")
+	envFile := writeFile(t, ".env", "SERVER_ADDR=:1002\nMONGODB_DATABASE=from_env_file\nDB_READ_TIMEOUT=2s\n")
+	t.Setenv("SERVER_ADDR", ":1003")
+	t.Setenv("DB_READ_TIMEOUT", "3s")
+
+	cfg, err := config.Load([]string{"-config", configFile, "-env-file", envFile, "-timeouts.db_read", "4s"})
+	require.NoError(t, err)
+
+	assert.Equal(t, config.BackendMemory, cfg.Database.Backend, "the file overrides defaults")
+	assert.Equal(t, "from_env_file", cfg.Database.Name, ".env overrides the file")
+	assert.Equal(t, ":1003", cfg.Server.Addr, "the environment overrides .env")
+	assert.Equal(t, 4*time.Second, cfg.Timeouts.Read, "flags override everything")
+}
+
+func TestFlags(t *testing.T) {
+	cleanEnv(t)
+
+	cfg, err := load(t, "-server.addr", ":9090", "-server.trusted_proxies", "10.0.0.1, 10.0.0.2", "-password.bcrypt_cost", "12")
+	require.NoError(t, err)
+	assert.Equal(t, ":9090", cfg.Server.Addr)
+	assert.Equal(t, []string{"10.0.0.1", "10.0.0.2"}, cfg.Server.TrustedProxies)
+	assert.Equal(t, 12, cfg.Password.BcryptCost)
+
+	t.Run("secrets have no flag", func(t *testing.T) {
+		for _, secret := range []string{"-jwt.secret", "-jwt.private_key", "-database.mongodb_uri", "-database.url", "-auth.setup_token", "-mail.smtp_password"} {
+			_, err := load(t, secret, "value")
+			assert.Error(t, err, secret)
+		}
+	})
+
+	t.Run("help", func(t *testing.T) {
+		_, err := load(t, "-h")
+		assert.ErrorIs(t, err, flag.ErrHelp)
+	})
+
+	t.Run("invalid values are rejected", func(t *testing.T) {
+		_, err := load(t, "-password.bcrypt_cost", "high")
+		assert.ErrorContains(t, err, "invalid -password.bcrypt_cost")
+
+		t.Setenv("ARGON2_THREADS", "300")
+		_, err = load(t)
+		assert.ErrorContains(t, err, "invalid ARGON2_THREADS")
+	})
+
+	t.Run("switches", func(t *testing.T) {
+		cfg, err := load(t, "-login.require_verified_email", "true", "-password.reject_username", "false")
+		require.NoError(t, err)
+		assert.True(t, cfg.Login.RequireVerifiedEmail)
+		assert.False(t, cfg.PasswordPolicy.RejectUsername)
+
+		_, err = load(t, "-password.reject_common", "sometimes")
+		assert.ErrorContains(t, err, "invalid -password.reject_common")
+	})
+
+	t.Run("secrets come from the environment", func(t *testing.T) {
+		t.Setenv("ADMIN_SETUP_TOKEN", "setup-token-0123456789")
+		t.Setenv("SMTP_PASSWORD", "secret")
+		cfg, err := load(t)
+		require.NoError(t, err)
+		assert.Equal(t, "setup-token-0123456789", cfg.Auth.SetupToken)
+		assert.Equal(t, "secret", cfg.Mail.SMTPPassword)
+	})
+
+	t.Run("arguments are rejected", func(t *testing.T) {
+		_, err := load(t, "serve")
+		assert.ErrorContains(t, err, `unexpected argument "serve"`)
+	})
+}
+
+func TestValidate(t *testing.T) {
+	assert.NoError(t, config.Default().Validate())
+
+	cfg := config.Default()
+	cfg.Server.Addr = "8080"
+	cfg.Server.TrustedProxies = []string{"proxy.local"}
+	cfg.Database.Name = ""
+	cfg.Database.Collections.Users = cfg.Database.Collections.Tasks
+	cfg.Password.BcryptCost = 99
+	cfg.Server.ShutdownTimeout = 0
+	cfg.Timeouts.Write = 0
+	cfg.Database.TokenCleanupInterval = 0
+	cfg.PasswordPolicy.MaxBytes = 100
+	cfg.PasswordPolicy.MinClasses = 5
+	cfg.PasswordPolicy.MinLength = -1
+	cfg.Lockout.MaxLockout = time.Second
+	cfg.Auth.SetupToken = "short"
+	cfg.Auth.MFAIssuer = ""
+	cfg.Mail.SMTPHost = "smtp.example.com"
+	cfg.Mail.SMTPPort = "0"
+
+	err := cfg.Validate()
+	require.Error(t, err)
+	for _, problem := range []string{
+		`server.addr "8080"`,
+		`server.trusted_proxies entry "proxy.local"`,
+		"database.name is required",
+		"database.collections.tasks and users",
+		"password: bcrypt cost 99",
+		"server.shutdown_timeout must be positive",
+		"timeouts.db_write must be positive",
+		"database.token_cleanup_interval must be positive",
+		"password.max_bytes 100",
+		"password.min_classes 5",
+		"password.min_length cannot be negative",
+		"login.max_lockout 1s is shorter than login.lockout 1m0s",
+		"auth.setup_token must be at least 16 characters",
+		"auth.mfa_issuer cannot be empty",
+		`mail: SMTP port "0"`,
+	} {
+		assert.ErrorContains(t, err, problem)
+	}
+
+	cfg = config.Default()
+	cfg.Database.Backend = "redis"
+	assert.ErrorContains(t, cfg.Validate(), `database.backend "redis"`)
+
+	cfg.Database.Backend = config.BackendPostgres
+	assert.ErrorContains(t, cfg.Validate(), "database.url is required for postgres")
+	cfg.Database.URL = "postgres://localhost/tasks"
+	assert.NoError(t, cfg.Validate())
+}
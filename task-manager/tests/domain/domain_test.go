@@ -1,31 +1,48 @@
 package domain_test
 
 import (
+	"encoding/json"
+	"strings"
 	"testing"
+	"time"
 	"task-manager/Domain"
 	"github.com/stretchr/testify/assert"
-	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 func TestTask(t *testing.T) {
-	id := primitive.NewObjectID()
+	id := domain.NewID()
+	due := time.Date(2024, 12, 31, 0, 0, 0, 0, time.UTC)
 	task := domain.Task{
 		ID:          id,
 		Title:       "Test Task",
 		Description: "Test Description",
-		DueDate:     "2024-12-31",
-		Status:      "pending",
+		DueDate:     &due,
+		Status:      domain.StatusTodo,
 	}
 
 	assert.Equal(t, id, task.ID)
 	assert.Equal(t, "Test Task", task.Title)
 	assert.Equal(t, "Test Description", task.Description)
-	assert.Equal(t, "2024-12-31", task.DueDate)
-	assert.Equal(t, "pending", task.Status)
+	assert.Equal(t, due, *task.DueDate)
+	assert.Equal(t, domain.StatusTodo, task.Status)
+}
+
+func TestTaskStatusTransitions(t *testing.T) {
+	assert.True(t, domain.StatusTodo.Valid())
+	assert.False(t, domain.TaskStatus("pending").Valid())
+
+	assert.NoError(t, domain.ValidateTransition(domain.StatusTodo, domain.StatusInProgress))
+	assert.NoError(t, domain.ValidateTransition(domain.StatusDone, domain.StatusDone))
+	assert.NoError(t, domain.ValidateTransition(domain.StatusCancelled, domain.StatusTodo))
+
+	var transitionErr *domain.TransitionError
+	assert.ErrorAs(t, domain.ValidateTransition(domain.StatusDone, domain.StatusCancelled), &transitionErr)
+	assert.ErrorAs(t, domain.ValidateTransition(domain.StatusCancelled, domain.StatusDone), &transitionErr)
+	assert.ErrorIs(t, domain.ValidateTransition(domain.StatusTodo, "archived"), domain.ErrInvalidStatus)
 }
 
 func TestUser(t *testing.T) {
-	id := primitive.NewObjectID()
+	id := domain.NewID()
 	user := domain.User{
 		ID:       id,
 		Username: "testuser",
@@ -37,4 +54,83 @@ func TestUser(t *testing.T) {
 	assert.Equal(t, "testuser", user.Username)
 	assert.Equal(t, "hashedpassword", user.Password)
 	assert.Equal(t, "user", user.Role)
-}
\ No newline at end of file
+}
+
+func TestUserPublic(t *testing.T) {
+	user := domain.User{ID: domain.NewID(), Username: "testuser", Password: "hashedpassword", Role: domain.RoleMember}
+
+	data, err := json.Marshal(user.Public())
+	assert.NoError(t, err)
+	assert.NotContains(t, string(data), "password")
+	assert.Equal(t, domain.PublicUser{ID: user.ID, Username: "testuser", Role: domain.RoleMember}, user.Public())
+
+	user.Email = "test@example.com"
+	user.EmailVerified = true
+	assert.Equal(t, "test@example.com", user.Public().Email)
+	assert.True(t, user.Public().EmailVerified)
+}
+
+func TestValidateEmail(t *testing.T) {
+	for _, email := range []string{"jane@example.com", "Jane.Doe+tasks@mail.example.org"} {
+		assert.NoError(t, domain.ValidateEmail(email), email)
+	}
+
+	for _, email := range []string{
+		"", "jane", "jane@", "@example.com", "jane@localhost", "Jane <jane@example.com>",
+		" jane@example.com", "jane@example.com\r\nBcc: eve@example.com",
+		"jane@" + strings.Repeat("a", 250) + ".com",
+	} {
+		assert.ErrorIs(t, domain.ValidateEmail(email), domain.ErrValidation, email)
+	}
+}
+
+func TestID(t *testing.T) {
+	id := domain.NewID()
+	assert.True(t, id.Valid())
+	assert.Len(t, id.String(), 24)
+	assert.NotEqual(t, id, domain.NewID())
+
+	assert.True(t, domain.ID("507f1f77bcf86cd799439011").Valid(), "MongoDB ObjectIDs are valid IDs")
+	assert.False(t, domain.ID("not-an-id").Valid())
+	assert.False(t, domain.ID("507F1F77BCF86CD799439011").Valid())
+	assert.True(t, domain.ID("").IsZero())
+}
+
+func TestErrorKinds(t *testing.T) {
+	assert.ErrorIs(t, domain.ErrTaskNotFound, domain.ErrNotFound)
+	assert.ErrorIs(t, domain.ErrUserNotFound, domain.ErrNotFound)
+	assert.ErrorIs(t, domain.ErrVersionConflict, domain.ErrPreconditionFailed)
+	assert.ErrorIs(t, domain.ErrInvalidStatus, domain.ErrUnprocessable)
+	assert.ErrorIs(t, &domain.TransitionError{From: domain.StatusDone, To: domain.StatusTodo}, domain.ErrConflict)
+
+	err := domain.Validation("%s must be an integer", "limit")
+	assert.ErrorIs(t, err, domain.ErrValidation)
+	assert.EqualError(t, err, "limit must be an integer")
+	assert.NotErrorIs(t, err, domain.ErrNotFound)
+}
+
+func TestRolePermissions(t *testing.T) {
+	// Every role keeps the permissions of the roles below it
+	for _, role := range domain.Roles {
+		assert.True(t, domain.ValidRole(role))
+		assert.True(t, domain.HasPermission(role, domain.PermTaskReadOwn), role)
+		assert.True(t, domain.HasPermission(role, domain.PermUserRead), role)
+	}
+
+	assert.False(t, domain.HasPermission(domain.RoleViewer, domain.PermTaskTransitionOwn))
+	assert.True(t, domain.HasPermission(domain.RoleMember, domain.PermTaskTransitionOwn))
+	assert.False(t, domain.HasPermission(domain.RoleMember, domain.PermTaskCreate))
+	assert.True(t, domain.HasPermission(domain.RoleMaintainer, domain.PermTaskCreate))
+	assert.True(t, domain.HasPermission(domain.RoleMaintainer, domain.PermTaskUpdateOwn))
+	assert.False(t, domain.HasPermission(domain.RoleMaintainer, domain.PermTaskUpdateAny))
+	assert.True(t, domain.HasPermission(domain.RoleAdmin, domain.PermTaskDeleteAny))
+	assert.True(t, domain.HasPermission(domain.RoleAdmin, domain.PermUserPromote))
+
+	// Tokens issued before the rename still carry the old member role
+	assert.True(t, domain.HasPermission("user", domain.PermTaskTransitionOwn))
+	assert.False(t, domain.ValidRole("user"))
+	assert.False(t, domain.HasPermission("guest", domain.PermTaskReadOwn))
+
+	caller := domain.Caller{Role: domain.RoleMaintainer}
+	assert.True(t, caller.Can(domain.PermTaskReadAny))
+}
@@ -0,0 +1,61 @@
+package infrastructure_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	domain "task-manager/Domain"
+	infrastructure "task-manager/Infrastructure"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriterAuditLog(t *testing.T) {
+	var buf bytes.Buffer
+	audit := infrastructure.NewWriterAuditLog(&buf)
+
+	entries := []domain.AuditEntry{
+		{Time: time.Now().UTC().Truncate(time.Second), Event: domain.AuditLoginLocked, Username: "jane", Detail: "5 failed logins"},
+		{Time: time.Now().UTC().Truncate(time.Second), Event: domain.AuditLoginUnlocked, ActorID: "admin-id", Username: "jane"},
+	}
+	for _, entry := range entries {
+		require.NoError(t, audit.Record(entry))
+	}
+
+	// One JSON object per line
+	scanner := bufio.NewScanner(&buf)
+	var got []domain.AuditEntry
+	for scanner.Scan() {
+		var entry domain.AuditEntry
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &entry))
+		got = append(got, entry)
+	}
+	assert.Equal(t, entries, got)
+}
+
+func TestOpenAuditLog(t *testing.T) {
+	t.Run("appends to the file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "audit.log")
+		require.NoError(t, os.WriteFile(path, []byte("{}\n"), 0o600))
+
+		audit, err := infrastructure.OpenAuditLog(path)
+		require.NoError(t, err)
+		require.NoError(t, audit.Record(domain.AuditEntry{Event: domain.AuditLoginLocked}))
+
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, 2, bytes.Count(data, []byte("\n")))
+		assert.Contains(t, string(data), `"event":"login.locked"`)
+	})
+
+	t.Run("fails for unwritable paths", func(t *testing.T) {
+		_, err := infrastructure.OpenAuditLog(filepath.Join(t.TempDir(), "missing", "audit.log"))
+		assert.Error(t, err)
+	})
+}
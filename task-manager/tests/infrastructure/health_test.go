@@ -0,0 +1,81 @@
+package infrastructure_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	infrastructure "task-manager/Infrastructure"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func healthRouter(health *infrastructure.HealthRegistry) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/healthz", health.Healthz)
+	r.GET("/readyz", health.Readyz)
+	return r
+}
+
+func probe(t *testing.T, r *gin.Engine, path string) (int, infrastructure.HealthReport) {
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+
+	var report infrastructure.HealthReport
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &report), w.Body.String())
+	return w.Code, report
+}
+
+func TestHealthRegistry(t *testing.T) {
+	health := infrastructure.NewHealthRegistry(50 * time.Millisecond)
+	r := healthRouter(health)
+
+	code, report := probe(t, r, "/readyz")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, infrastructure.HealthReady, report.Status, "nothing registered is ready")
+
+	health.Register("database", func(context.Context) error { return nil })
+	health.Register("jwt_keys", func(context.Context) error { return nil })
+	code, report = probe(t, r, "/readyz")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, infrastructure.HealthReady, report.Status)
+	assert.Equal(t, infrastructure.HealthOK, report.Checks["database"].Status)
+	assert.Equal(t, infrastructure.HealthOK, report.Checks["jwt_keys"].Status)
+
+	t.Run("a failing check makes the server unavailable", func(t *testing.T) {
+		health.Register("database", func(context.Context) error { return errors.New("connection refused") })
+
+		code, report := probe(t, r, "/readyz")
+		assert.Equal(t, http.StatusServiceUnavailable, code)
+		assert.Equal(t, infrastructure.HealthUnavailable, report.Status)
+		assert.Equal(t, infrastructure.HealthFailed, report.Checks["database"].Status)
+		assert.Equal(t, "connection refused", report.Checks["database"].Error)
+		assert.Equal(t, infrastructure.HealthOK, report.Checks["jwt_keys"].Status)
+
+		code, report = probe(t, r, "/healthz")
+		assert.Equal(t, http.StatusOK, code, "liveness doesn't depend on the checks")
+		assert.Equal(t, infrastructure.HealthOK, report.Status)
+	})
+
+	t.Run("slow checks time out", func(t *testing.T) {
+		release := make(chan struct{})
+		defer close(release)
+		health.Register("database", func(context.Context) error {
+			<-release
+			return nil
+		})
+
+		started := time.Now()
+		code, report := probe(t, r, "/readyz")
+		assert.Less(t, time.Since(started), time.Second)
+		assert.Equal(t, http.StatusServiceUnavailable, code)
+		assert.Equal(t, context.DeadlineExceeded.Error(), report.Checks["database"].Error)
+	})
+}
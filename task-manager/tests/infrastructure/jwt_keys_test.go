@@ -0,0 +1,188 @@
+package infrastructure_test
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	infrastructure "task-manager/Infrastructure"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writePEM writes a PEM block to a file in a temporary directory
+func writePEM(t *testing.T, name, blockType string, der []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	data := pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+	require.NoError(t, os.WriteFile(path, data, 0o600))
+	return path
+}
+
+func TestParseKeyPEM(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	edPublic, edPrivate, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	pkcs8RSA, err := x509.MarshalPKCS8PrivateKey(rsaKey)
+	require.NoError(t, err)
+	pkcs8Ed, err := x509.MarshalPKCS8PrivateKey(edPrivate)
+	require.NoError(t, err)
+	pkixRSA, err := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	require.NoError(t, err)
+	pkixEd, err := x509.MarshalPKIXPublicKey(edPublic)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name      string
+		blockType string
+		der       []byte
+		alg       string
+		canSign   bool
+	}{
+		{"PKCS1 RSA private key", "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(rsaKey), "RS256", true},
+		{"PKCS8 RSA private key", "PRIVATE KEY", pkcs8RSA, "RS256", true},
+		{"PKCS8 Ed25519 private key", "PRIVATE KEY", pkcs8Ed, "EdDSA", true},
+		{"PKCS1 RSA public key", "RSA PUBLIC KEY", x509.MarshalPKCS1PublicKey(&rsaKey.PublicKey), "RS256", false},
+		{"PKIX RSA public key", "PUBLIC KEY", pkixRSA, "RS256", false},
+		{"PKIX Ed25519 public key", "PUBLIC KEY", pkixEd, "EdDSA", false},
+	}
+
+	ids := map[string]string{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := infrastructure.ParseKeyPEM(pem.EncodeToMemory(&pem.Block{Type: tt.blockType, Bytes: tt.der}))
+			require.NoError(t, err)
+			assert.Equal(t, tt.alg, key.Method.Alg())
+			assert.Equal(t, tt.canSign, key.CanSign())
+
+			// Private and public forms of a key share its ID
+			if id, ok := ids[tt.alg]; ok {
+				assert.Equal(t, id, key.ID)
+			}
+			ids[tt.alg] = key.ID
+		})
+	}
+
+	t.Run("Rejects short RSA keys", func(t *testing.T) {
+		short, err := rsa.GenerateKey(rand.Reader, 1024)
+		require.NoError(t, err)
+		_, err = infrastructure.ParseKeyPEM(pem.EncodeToMemory(&pem.Block{
+			Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(short),
+		}))
+		assert.Error(t, err)
+	})
+
+	t.Run("Rejects non-PEM data", func(t *testing.T) {
+		_, err := infrastructure.ParseKeyPEM([]byte("not a key"))
+		assert.Error(t, err)
+	})
+}
+
+func TestNewHMACKeyRejectsShortSecrets(t *testing.T) {
+	_, err := infrastructure.NewHMACKey([]byte("your_dev_secret_key"))
+	assert.Error(t, err)
+}
+
+func TestNewKeySetRequiresPrivateKey(t *testing.T) {
+	edPublic, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	pkix, err := x509.MarshalPKIXPublicKey(edPublic)
+	require.NoError(t, err)
+	public, err := infrastructure.ParseKeyPEM(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pkix}))
+	require.NoError(t, err)
+
+	_, err = infrastructure.NewKeySet(public)
+	assert.Error(t, err)
+}
+
+func TestLoadKeySet(t *testing.T) {
+	_, err := infrastructure.LoadKeySet(infrastructure.KeySources{})
+	assert.ErrorIs(t, err, infrastructure.ErrNoSigningKey)
+
+	t.Run("HS256 secret", func(t *testing.T) {
+		keys, err := infrastructure.LoadKeySet(infrastructure.KeySources{Secret: strings.Repeat("s", 32)})
+		require.NoError(t, err)
+		assert.Empty(t, keys.JWKS().Keys, "HMAC secrets are never published")
+	})
+
+	t.Run("Private key file with verification keys", func(t *testing.T) {
+		_, signing, err := ed25519.GenerateKey(rand.Reader)
+		require.NoError(t, err)
+		pkcs8, err := x509.MarshalPKCS8PrivateKey(signing)
+		require.NoError(t, err)
+
+		previous, err := rsa.GenerateKey(rand.Reader, 2048)
+		require.NoError(t, err)
+		pkix, err := x509.MarshalPKIXPublicKey(&previous.PublicKey)
+		require.NoError(t, err)
+
+		keys, err := infrastructure.LoadKeySet(infrastructure.KeySources{
+			Secret:               strings.Repeat("s", 32),
+			PrivateKeyFile:       writePEM(t, "signing.pem", "PRIVATE KEY", pkcs8),
+			VerificationKeyFiles: []string{writePEM(t, "previous.pem", "PUBLIC KEY", pkix), " "},
+		})
+		require.NoError(t, err)
+
+		jwks := keys.JWKS()
+		require.Len(t, jwks.Keys, 2)
+		assert.Equal(t, "EdDSA", jwks.Keys[0].Algorithm, "the signing key is listed first")
+		assert.Equal(t, "RS256", jwks.Keys[1].Algorithm)
+	})
+
+	t.Run("Inline private key", func(t *testing.T) {
+		rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+		require.NoError(t, err)
+
+		keys, err := infrastructure.LoadKeySet(infrastructure.KeySources{PrivateKey: string(pem.EncodeToMemory(&pem.Block{
+			Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey),
+		}))})
+		require.NoError(t, err)
+		require.Len(t, keys.JWKS().Keys, 1)
+		assert.Equal(t, "RS256", keys.JWKS().Keys[0].Algorithm)
+	})
+
+	t.Run("Missing key file", func(t *testing.T) {
+		_, err := infrastructure.LoadKeySet(infrastructure.KeySources{PrivateKeyFile: filepath.Join(t.TempDir(), "missing.pem")})
+		assert.Error(t, err)
+	})
+}
+
+func TestJWKSEndpoint(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	keys, key := newKeySet(t)
+	service := infrastructure.NewJWTService(keys)
+
+	r := gin.New()
+	r.GET("/.well-known/jwks.json", service.JWKS)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/.well-known/jwks.json", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Header().Get("Cache-Control"), "max-age")
+
+	var jwks infrastructure.JWKS
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &jwks))
+	require.Len(t, jwks.Keys, 1)
+	assert.Equal(t, infrastructure.JWK{
+		KeyType:   "OKP",
+		KeyID:     key.ID,
+		Use:       "sig",
+		Algorithm: "EdDSA",
+		Curve:     "Ed25519",
+		X:         jwks.Keys[0].X,
+	}, jwks.Keys[0])
+	assert.NotContains(t, w.Body.String(), `"d"`, "private key material is never served")
+}
@@ -0,0 +1,278 @@
+package infrastructure_test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	domain "task-manager/Domain"
+	infrastructure "task-manager/Infrastructure"
+	repositories "task-manager/Repositories"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newKeySet returns a key set signing with a fresh Ed25519 key
+func newKeySet(t *testing.T, verification ...infrastructure.SigningKey) (*infrastructure.KeySet, infrastructure.SigningKey) {
+	t.Helper()
+	key, err := infrastructure.GenerateEd25519Key()
+	require.NoError(t, err)
+	keys, err := infrastructure.NewKeySet(key, verification...)
+	require.NoError(t, err)
+	return keys, key
+}
+
+// newUser registers a user with the given role in the repository
+func newUser(t *testing.T, users *repositories.MemoryUserRepository, username, role string) domain.User {
+	t.Helper()
+	user, err := users.RegisterUser(context.Background(), domain.User{Username: username, Password: "password123"})
+	require.NoError(t, err)
+	user, err = users.SetUserRole(context.Background(), user.ID.String(), role)
+	require.NoError(t, err)
+	return user
+}
+
+func TestJWTServiceClaims(t *testing.T) {
+	keys, _ := newKeySet(t)
+	service := infrastructure.NewJWTService(keys)
+
+	token, err := service.GenerateToken("user-1", "jane", "user")
+	require.NoError(t, err)
+
+	claims, err := service.ValidateToken(token)
+	require.NoError(t, err)
+	assert.Equal(t, "user-1", claims["_id"])
+	assert.NotEmpty(t, claims["jti"])
+	assert.NotEmpty(t, claims["iat"])
+
+	exp := time.Unix(int64(claims["exp"].(float64)), 0)
+	assert.WithinDuration(t, time.Now().Add(infrastructure.AccessTokenTTL), exp, 5*time.Second)
+
+	other, err := service.GenerateToken("user-1", "jane", "user")
+	require.NoError(t, err)
+	otherClaims, err := service.ValidateToken(other)
+	require.NoError(t, err)
+	assert.NotEqual(t, claims["jti"], otherClaims["jti"], "every token gets its own ID")
+}
+
+func TestJWTServiceRejectsExpiredAndLegacyTokens(t *testing.T) {
+	secret := []byte(strings.Repeat("s", 32))
+	key, err := infrastructure.NewHMACKey(secret)
+	require.NoError(t, err)
+	keys, err := infrastructure.NewKeySet(key)
+	require.NoError(t, err)
+
+	expired, err := infrastructure.NewJWTServiceWithTTL(keys, -time.Minute).GenerateToken("user-1", "jane", "user")
+	require.NoError(t, err)
+	_, err = infrastructure.NewJWTService(keys).ValidateToken(expired)
+	assert.ErrorIs(t, err, domain.ErrUnauthorized)
+
+	// Tokens issued before expiry was added carry no exp or jti
+	legacy := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"_id": "user-1", "username": "jane", "role": "user",
+	})
+	legacy.Header["kid"] = key.ID
+	signed, err := legacy.SignedString(secret)
+	require.NoError(t, err)
+	_, err = infrastructure.NewJWTService(keys).ValidateToken(signed)
+	assert.ErrorIs(t, err, domain.ErrUnauthorized)
+}
+
+func TestJWTServiceAlgorithms(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	rs256, err := infrastructure.ParseKeyPEM(pem.EncodeToMemory(&pem.Block{
+		Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey),
+	}))
+	require.NoError(t, err)
+	eddsa, err := infrastructure.GenerateEd25519Key()
+	require.NoError(t, err)
+	hs256, err := infrastructure.NewHMACKey([]byte(strings.Repeat("s", 32)))
+	require.NoError(t, err)
+
+	for _, key := range []infrastructure.SigningKey{rs256, eddsa, hs256} {
+		t.Run(key.Method.Alg(), func(t *testing.T) {
+			keys, err := infrastructure.NewKeySet(key)
+			require.NoError(t, err)
+			service := infrastructure.NewJWTService(keys)
+
+			token, err := service.GenerateToken("user-1", "jane", "user")
+			require.NoError(t, err)
+
+			parsed, _, err := new(jwt.Parser).ParseUnverified(token, jwt.MapClaims{})
+			require.NoError(t, err)
+			assert.Equal(t, key.Method.Alg(), parsed.Method.Alg())
+			assert.Equal(t, key.ID, parsed.Header["kid"])
+
+			claims, err := service.ValidateToken(token)
+			require.NoError(t, err)
+			assert.Equal(t, "jane", claims["username"])
+		})
+	}
+}
+
+func TestJWTServiceKeyRotation(t *testing.T) {
+	oldKeys, oldKey := newKeySet(t)
+	oldToken, err := infrastructure.NewJWTService(oldKeys).GenerateToken("user-1", "jane", "user")
+	require.NoError(t, err)
+
+	// The old key stays accepted while it is listed for verification
+	rotated, _ := newKeySet(t, oldKey)
+	_, err = infrastructure.NewJWTService(rotated).ValidateToken(oldToken)
+	assert.NoError(t, err)
+
+	retired, _ := newKeySet(t)
+	_, err = infrastructure.NewJWTService(retired).ValidateToken(oldToken)
+	assert.ErrorIs(t, err, domain.ErrUnauthorized)
+}
+
+func TestJWTServiceRejectsAlgorithmMismatch(t *testing.T) {
+	keys, key := newKeySet(t)
+	jwks := keys.JWKS()
+	require.Len(t, jwks.Keys, 1)
+
+	// An HS256 token keyed with the published public key must not verify
+	publicKey, err := base64.RawURLEncoding.DecodeString(jwks.Keys[0].X)
+	require.NoError(t, err)
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"_id": "user-1", "username": "jane", "role": "admin",
+		"jti": "forged", "exp": time.Now().Add(time.Minute).Unix(),
+	})
+	forged.Header["kid"] = key.ID
+	signed, err := forged.SignedString(publicKey)
+	require.NoError(t, err)
+
+	_, err = infrastructure.NewJWTService(keys).ValidateToken(signed)
+	assert.ErrorIs(t, err, domain.ErrUnauthorized)
+}
+
+func TestAuthMiddlewareRejectsRevokedTokens(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	keys, _ := newKeySet(t)
+	service := infrastructure.NewJWTService(keys)
+	tokens := repositories.NewMemoryTokenRepository()
+	users := repositories.NewMemoryUserRepository(infrastructure.NewPasswordService())
+	auth := infrastructure.NewAuthMiddleware(service, tokens, users)
+
+	r := gin.New()
+	r.GET("/", auth.AuthMiddleware(), func(c *gin.Context) {
+		c.String(http.StatusOK, c.GetString("token_id"))
+	})
+
+	user := newUser(t, users, "jane", domain.RoleMember)
+	token, err := service.GenerateToken(user.ID.String(), user.Username, user.Role)
+	require.NoError(t, err)
+
+	request := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := request()
+	require.Equal(t, http.StatusOK, w.Code)
+	tokenID := w.Body.String()
+	assert.NotEmpty(t, tokenID)
+
+	require.NoError(t, tokens.RevokeAccessToken(context.Background(), tokenID, time.Now().Add(time.Minute)))
+	assert.Equal(t, http.StatusUnauthorized, request().Code)
+}
+
+func TestRequirePermission(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	keys, _ := newKeySet(t)
+	service := infrastructure.NewJWTService(keys)
+	users := repositories.NewMemoryUserRepository(infrastructure.NewPasswordService())
+	auth := infrastructure.NewAuthMiddleware(service, repositories.NewMemoryTokenRepository(), users)
+
+	r := gin.New()
+	r.GET("/", auth.AuthMiddleware(),
+		auth.RequirePermission(domain.PermTaskUpdateOwn, domain.PermTaskUpdateAny),
+		func(c *gin.Context) { c.Status(http.StatusOK) },
+	)
+
+	tests := []struct {
+		role   string
+		status int
+	}{
+		{domain.RoleViewer, http.StatusForbidden},
+		{domain.RoleMember, http.StatusForbidden},
+		{domain.RoleMaintainer, http.StatusOK},
+		{domain.RoleAdmin, http.StatusOK},
+		{"guest", http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.role, func(t *testing.T) {
+			user := newUser(t, users, "jane-"+tt.role, tt.role)
+			token, err := service.GenerateToken(user.ID.String(), user.Username, user.Role)
+			require.NoError(t, err)
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("Authorization", "Bearer "+token)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.status, w.Code)
+			if tt.status == http.StatusForbidden {
+				assert.Contains(t, w.Body.String(), "permission task:update:own required")
+			}
+		})
+	}
+}
+
+func TestAuthMiddlewareUsesStoredUser(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	keys, _ := newKeySet(t)
+	service := infrastructure.NewJWTService(keys)
+	users := repositories.NewMemoryUserRepository(infrastructure.NewPasswordService())
+	auth := infrastructure.NewAuthMiddleware(service, repositories.NewMemoryTokenRepository(), users)
+
+	r := gin.New()
+	r.GET("/", auth.AuthMiddleware(), func(c *gin.Context) {
+		c.String(http.StatusOK, c.GetString("role"))
+	})
+
+	user := newUser(t, users, "jane", domain.RoleMember)
+	token, err := service.GenerateToken(user.ID.String(), user.Username, user.Role)
+	require.NoError(t, err)
+
+	request := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	// The role in the token is ignored in favour of the stored one
+	_, err = users.SetUserRole(context.Background(), user.ID.String(), domain.RoleViewer)
+	require.NoError(t, err)
+	w := request()
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, domain.RoleViewer, w.Body.String())
+
+	_, err = users.SetUserDisabled(context.Background(), user.ID.String(), true)
+	require.NoError(t, err)
+	w = request()
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), "account is disabled")
+
+	require.NoError(t, users.DeleteUser(context.Background(), user.ID.String()))
+	w = request()
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, w.Body.String(), "user no longer exists")
+}
@@ -0,0 +1,214 @@
+package infrastructure_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	infrastructure "task-manager/Infrastructure"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// runLifecycle starts lifecycle.Run with handler on a free port and returns
+// the server URL and a channel receiving Run's result
+func runLifecycle(t *testing.T, lifecycle *infrastructure.Lifecycle, ctx context.Context, handler http.Handler, timeout time.Duration) (string, <-chan error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- lifecycle.Run(ctx, &http.Server{Handler: handler}, listener, timeout)
+	}()
+	return "http://" + listener.Addr().String(), done
+}
+
+func waitForRun(t *testing.T, done <-chan error) error {
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return")
+		return nil
+	}
+}
+
+func TestLifecycleDrainsRequests(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		io.WriteString(w, "done")
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	url, done := runLifecycle(t, infrastructure.NewLifecycle(), ctx, handler, 5*time.Second)
+
+	type result struct {
+		body string
+		err  error
+	}
+	response := make(chan result, 1)
+	go func() {
+		resp, err := http.Get(url)
+		if err != nil {
+			response <- result{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		response <- result{string(body), err}
+	}()
+
+	<-started
+	cancel()
+	select {
+	case <-done:
+		t.Fatal("Run returned with a request in flight")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	close(release)
+	got := <-response
+	require.NoError(t, got.err)
+	assert.Equal(t, "done", got.body)
+	assert.NoError(t, waitForRun(t, done))
+
+	_, err := http.Get(url)
+	assert.Error(t, err, "the server no longer accepts connections")
+}
+
+func TestLifecycleShutdownOrder(t *testing.T) {
+	var mu sync.Mutex
+	var events []string
+	record := func(event string) {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, event)
+	}
+
+	lifecycle := infrastructure.NewLifecycle()
+	lifecycle.OnStop("database", func(context.Context) error {
+		record("database closed")
+		return nil
+	})
+	lifecycle.OnStop("cache", func(context.Context) error {
+		record("cache closed")
+		return nil
+	})
+	lifecycle.Go("worker", func(ctx context.Context) error {
+		<-ctx.Done()
+		record("worker stopped")
+		return ctx.Err()
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	_, done := runLifecycle(t, lifecycle, ctx, http.NotFoundHandler(), 5*time.Second)
+	cancel()
+
+	assert.NoError(t, waitForRun(t, done), "workers returning on cancellation isn't a failure")
+	assert.Equal(t, []string{"worker stopped", "cache closed", "database closed"}, events)
+}
+
+func TestLifecycleEvery(t *testing.T) {
+	var mu sync.Mutex
+	runs := 0
+	lifecycle := infrastructure.NewLifecycle()
+	lifecycle.Every("token cleanup", 10*time.Millisecond, func(ctx context.Context) error {
+		mu.Lock()
+		defer mu.Unlock()
+		runs++
+		if runs == 1 {
+			return errors.New("database unavailable")
+		}
+		return nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	_, done := runLifecycle(t, lifecycle, ctx, http.NotFoundHandler(), 5*time.Second)
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return runs >= 3
+	}, 5*time.Second, 5*time.Millisecond, "a failed run doesn't stop the next ones")
+	cancel()
+
+	assert.NoError(t, waitForRun(t, done), "failed runs don't fail the shutdown")
+}
+
+func TestLifecycleFailures(t *testing.T) {
+	t.Run("a failing worker shuts everything down", func(t *testing.T) {
+		closed := false
+		lifecycle := infrastructure.NewLifecycle()
+		lifecycle.OnStop("database", func(context.Context) error {
+			closed = true
+			return nil
+		})
+		lifecycle.Go("mail queue", func(ctx context.Context) error {
+			return errors.New("queue unavailable")
+		})
+
+		_, done := runLifecycle(t, lifecycle, context.Background(), http.NotFoundHandler(), 5*time.Second)
+
+		err := waitForRun(t, done)
+		assert.ErrorContains(t, err, "mail queue: queue unavailable")
+		assert.True(t, closed)
+	})
+
+	t.Run("requests outlasting the timeout are cut off", func(t *testing.T) {
+		release := make(chan struct{})
+		defer close(release)
+		started := make(chan struct{})
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			close(started)
+			<-release
+		})
+
+		closed := false
+		lifecycle := infrastructure.NewLifecycle()
+		lifecycle.OnStop("database", func(context.Context) error {
+			closed = true
+			return nil
+		})
+
+		ctx, cancel := context.WithCancel(context.Background())
+		url, done := runLifecycle(t, lifecycle, ctx, handler, 50*time.Millisecond)
+		go http.Get(url)
+		<-started
+		cancel()
+
+		err := waitForRun(t, done)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.ErrorContains(t, err, "draining connections")
+		assert.True(t, closed, "resources are closed even after the deadline")
+	})
+
+	t.Run("closing errors are reported", func(t *testing.T) {
+		lifecycle := infrastructure.NewLifecycle()
+		lifecycle.OnStop("database", func(context.Context) error {
+			return errors.New("connection reset")
+		})
+
+		ctx, cancel := context.WithCancel(context.Background())
+		_, done := runLifecycle(t, lifecycle, ctx, http.NotFoundHandler(), 5*time.Second)
+		cancel()
+
+		assert.ErrorContains(t, waitForRun(t, done), "closing database: connection reset")
+	})
+
+	t.Run("serve errors are reported", func(t *testing.T) {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		listener.Close()
+
+		err = infrastructure.NewLifecycle().Run(context.Background(), &http.Server{}, listener, time.Second)
+		assert.ErrorContains(t, err, "http server")
+	})
+}
@@ -0,0 +1,166 @@
+package infrastructure_test
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	domain "task-manager/Domain"
+	infrastructure "task-manager/Infrastructure"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSMTPServer accepts one message without auth or TLS and returns the
+// envelope recipients and the data
+func fakeSMTPServer(t *testing.T) (string, <-chan string) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	received := make(chan string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		r := bufio.NewReader(conn)
+		reply := func(line string) { io.WriteString(conn, line+"\r\n") }
+		var transcript strings.Builder
+
+		reply("220 localhost ESMTP")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			command := strings.ToUpper(strings.TrimSpace(line))
+			switch {
+			case strings.HasPrefix(command, "EHLO"), strings.HasPrefix(command, "HELO"):
+				reply("250 localhost")
+			case strings.HasPrefix(command, "RCPT TO:"):
+				transcript.WriteString(strings.TrimSpace(line) + "\n")
+				reply("250 OK")
+			case command == "DATA":
+				reply("354 go ahead")
+				for {
+					line, err := r.ReadString('\n')
+					if err != nil || line == ".\r\n" {
+						break
+					}
+					transcript.WriteString(line)
+				}
+				reply("250 queued")
+			case command == "QUIT":
+				reply("221 bye")
+				received <- transcript.String()
+				return
+			default:
+				reply("250 OK")
+			}
+		}
+	}()
+
+	return listener.Addr().String(), received
+}
+
+func TestSMTPMailer(t *testing.T) {
+	addr, received := fakeSMTPServer(t)
+	mailer, err := infrastructure.NewSMTPMailer(addr, "", "", "no-reply@example.com")
+	require.NoError(t, err)
+
+	require.NoError(t, mailer.Send(domain.Message{
+		To: "jane@example.com", Subject: "Hello", Body: "line one\nline two\n",
+	}))
+
+	transcript := <-received
+	assert.Contains(t, transcript, "RCPT TO:<jane@example.com>")
+	assert.Contains(t, transcript, "From: no-reply@example.com\r\n")
+	assert.Contains(t, transcript, "Subject: Hello\r\n")
+	assert.Contains(t, transcript, "line one\r\nline two\r\n")
+}
+
+func TestNewSMTPMailerValidatesConfig(t *testing.T) {
+	_, err := infrastructure.NewSMTPMailer("smtp.example.com", "", "", "no-reply@example.com")
+	assert.Error(t, err, "the port is required")
+
+	_, err = infrastructure.NewSMTPMailer("smtp.example.com:587", "", "", "")
+	assert.Error(t, err, "the sender is required")
+}
+
+func TestFileMailer(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "mail")
+	mailer := infrastructure.NewFileMailer(dir, "no-reply@example.com")
+
+	require.NoError(t, mailer.Send(domain.Message{To: "jane@example.com", Subject: "Hello", Body: "Hi Jane\n"}))
+	require.NoError(t, mailer.Send(domain.Message{To: "john@example.com", Subject: "Hello", Body: "Hi John\n"}))
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+	require.NoError(t, err)
+	require.Len(t, files, 2, "each message gets its own file")
+
+	data, err := os.ReadFile(files[0])
+	require.NoError(t, err)
+	message, err := mail.ReadMessage(bytes.NewReader(data))
+	require.NoError(t, err)
+	assert.Equal(t, "no-reply@example.com", message.Header.Get("From"))
+	assert.Equal(t, "Hello", message.Header.Get("Subject"))
+	assert.Contains(t, message.Header.Get("Content-Type"), "text/plain")
+}
+
+func TestMailerRejectsHeaderInjection(t *testing.T) {
+	mailer := infrastructure.NewFileMailer(t.TempDir(), "no-reply@example.com")
+
+	for _, message := range []domain.Message{
+		{To: "jane@example.com\r\nBcc: everyone@example.com", Subject: "Hello"},
+		{To: "jane@example.com", Subject: "Hello\nBcc: everyone@example.com"},
+		{To: "", Subject: "Hello"},
+	} {
+		assert.Error(t, mailer.Send(message), "%q", message.To+message.Subject)
+	}
+}
+
+func TestNewMailer(t *testing.T) {
+	t.Run("Files without an SMTP host", func(t *testing.T) {
+		settings := infrastructure.DefaultMailSettings
+		settings.Dir = t.TempDir()
+		mailer, err := infrastructure.NewMailer(settings)
+		require.NoError(t, err)
+		assert.IsType(t, &infrastructure.FileMailer{}, mailer)
+	})
+
+	t.Run("SMTP", func(t *testing.T) {
+		settings := infrastructure.DefaultMailSettings
+		settings.SMTPHost = "smtp.example.com"
+		settings.SMTPUsername = "mailer"
+		settings.SMTPPassword = "secret"
+		mailer, err := infrastructure.NewMailer(settings)
+		require.NoError(t, err)
+		assert.IsType(t, &infrastructure.SMTPMailer{}, mailer)
+	})
+}
+
+func TestMailSettingsValidate(t *testing.T) {
+	assert.NoError(t, infrastructure.DefaultMailSettings.Validate())
+
+	settings := infrastructure.DefaultMailSettings
+	settings.From = ""
+	assert.ErrorContains(t, settings.Validate(), "sender address is required")
+
+	settings = infrastructure.DefaultMailSettings
+	settings.SMTPHost = "smtp.example.com"
+	settings.SMTPPort = "smtp"
+	assert.ErrorContains(t, settings.Validate(), `SMTP port "smtp"`)
+	settings.SMTPPort = "465"
+	assert.NoError(t, settings.Validate())
+}
@@ -0,0 +1,122 @@
+package infrastructure_test
+
+import (
+	"strings"
+	"testing"
+
+	infrastructure "task-manager/Infrastructure"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// testArgon2Params keep argon2id hashing fast in tests
+var testArgon2Params = infrastructure.Argon2Params{Time: 1, Memory: 64, Threads: 1, SaltLen: 16, KeyLen: 32}
+
+func newPasswordService(t *testing.T, hashing infrastructure.PasswordHashing) *infrastructure.PasswordService {
+	service, err := infrastructure.NewPasswordServiceWithHashing(hashing)
+	require.NoError(t, err)
+	return service
+}
+
+func TestPasswordServiceHashing(t *testing.T) {
+	services := map[string]*infrastructure.PasswordService{
+		"bcrypt":   newPasswordService(t, infrastructure.PasswordHashing{Algorithm: infrastructure.AlgorithmBcrypt, BcryptCost: bcrypt.MinCost}),
+		"argon2id": newPasswordService(t, infrastructure.PasswordHashing{Algorithm: infrastructure.AlgorithmArgon2id, Argon2: testArgon2Params}),
+	}
+
+	for name, service := range services {
+		t.Run(name, func(t *testing.T) {
+			hash, err := service.HashPassword("correct-horse-1")
+			require.NoError(t, err)
+			assert.NotContains(t, hash, "correct-horse-1")
+
+			assert.NoError(t, service.ComparePassword(hash, "correct-horse-1"))
+			assert.ErrorIs(t, service.ComparePassword(hash, "correct-horse-2"), infrastructure.ErrPasswordMismatch)
+			assert.False(t, service.NeedsRehash(hash))
+
+			again, err := service.HashPassword("correct-horse-1")
+			require.NoError(t, err)
+			assert.NotEqual(t, hash, again, "hashes are salted")
+		})
+	}
+
+	t.Run("argon2id hashes record their parameters", func(t *testing.T) {
+		hash, err := services["argon2id"].HashPassword("correct-horse-1")
+		require.NoError(t, err)
+		assert.True(t, strings.HasPrefix(hash, "$argon2id$v=19$m=64,t=1,p=1$"), hash)
+	})
+
+	t.Run("either algorithm checks hashes of both", func(t *testing.T) {
+		bcryptHash, err := services["bcrypt"].HashPassword("correct-horse-1")
+		require.NoError(t, err)
+		argonHash, err := services["argon2id"].HashPassword("correct-horse-1")
+		require.NoError(t, err)
+
+		assert.NoError(t, services["argon2id"].ComparePassword(bcryptHash, "correct-horse-1"))
+		assert.NoError(t, services["bcrypt"].ComparePassword(argonHash, "correct-horse-1"))
+		assert.Error(t, services["bcrypt"].ComparePassword("$argon2id$v=19$broken", "correct-horse-1"))
+	})
+}
+
+func TestPasswordServiceNeedsRehash(t *testing.T) {
+	bcryptLow := newPasswordService(t, infrastructure.PasswordHashing{Algorithm: infrastructure.AlgorithmBcrypt, BcryptCost: bcrypt.MinCost})
+	bcryptHigh := newPasswordService(t, infrastructure.PasswordHashing{Algorithm: infrastructure.AlgorithmBcrypt, BcryptCost: bcrypt.MinCost + 1})
+	argon := newPasswordService(t, infrastructure.PasswordHashing{Algorithm: infrastructure.AlgorithmArgon2id, Argon2: testArgon2Params})
+	stronger := testArgon2Params
+	stronger.Time = 2
+	argonStronger := newPasswordService(t, infrastructure.PasswordHashing{Algorithm: infrastructure.AlgorithmArgon2id, Argon2: stronger})
+
+	bcryptHash, err := bcryptLow.HashPassword("correct-horse-1")
+	require.NoError(t, err)
+	argonHash, err := argon.HashPassword("correct-horse-1")
+	require.NoError(t, err)
+
+	assert.True(t, bcryptHigh.NeedsRehash(bcryptHash), "other bcrypt cost")
+	assert.True(t, argon.NeedsRehash(bcryptHash), "other algorithm")
+	assert.True(t, bcryptLow.NeedsRehash(argonHash), "other algorithm")
+	assert.True(t, argonStronger.NeedsRehash(argonHash), "other argon2id parameters")
+	assert.True(t, bcryptLow.NeedsRehash("not a hash"))
+	assert.False(t, bcryptLow.NeedsRehash(bcryptHash))
+	assert.False(t, argon.NeedsRehash(argonHash))
+}
+
+func TestNewPasswordServiceWithHashing(t *testing.T) {
+	for name, hashing := range map[string]infrastructure.PasswordHashing{
+		"unknown algorithm": {Algorithm: "md5"},
+		"bcrypt cost":       {Algorithm: infrastructure.AlgorithmBcrypt, BcryptCost: bcrypt.MaxCost + 1},
+		"argon2id passes":   {Algorithm: infrastructure.AlgorithmArgon2id, Argon2: infrastructure.Argon2Params{Memory: 64, Threads: 1, SaltLen: 16, KeyLen: 32}},
+		"argon2id memory":   {Algorithm: infrastructure.AlgorithmArgon2id, Argon2: infrastructure.Argon2Params{Time: 1, Memory: 8, Threads: 4, SaltLen: 16, KeyLen: 32}},
+		"argon2id salt":     {Algorithm: infrastructure.AlgorithmArgon2id, Argon2: infrastructure.Argon2Params{Time: 1, Memory: 64, Threads: 1, SaltLen: 4, KeyLen: 32}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := infrastructure.NewPasswordServiceWithHashing(hashing)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestPasswordHashingValidate(t *testing.T) {
+	assert.NoError(t, infrastructure.DefaultPasswordHashing.Validate())
+
+	argon2id := infrastructure.DefaultPasswordHashing
+	argon2id.Algorithm = infrastructure.AlgorithmArgon2id
+	assert.NoError(t, argon2id.Validate())
+
+	md5 := infrastructure.DefaultPasswordHashing
+	md5.Algorithm = "md5"
+	cheap := infrastructure.DefaultPasswordHashing
+	cheap.BcryptCost = 3
+	threadless := argon2id
+	threadless.Argon2.Threads = 0
+	for name, hashing := range map[string]infrastructure.PasswordHashing{
+		"unknown algorithm": md5,
+		"low bcrypt cost":   cheap,
+		"no threads":        threadless,
+	} {
+		assert.Error(t, hashing.Validate(), name)
+		_, err := infrastructure.NewPasswordServiceWithHashing(hashing)
+		assert.Error(t, err, name)
+	}
+}
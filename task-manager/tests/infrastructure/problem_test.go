@@ -0,0 +1,88 @@
+package infrastructure_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	domain "task-manager/Domain"
+	infrastructure "task-manager/Infrastructure"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatusForError(t *testing.T) {
+	cases := []struct {
+		err    error
+		status int
+	}{
+		{domain.ErrTaskNotFound, http.StatusNotFound},
+		{domain.InvalidID("invalid id format"), http.StatusBadRequest},
+		{domain.Validation("fields cannot be empty"), http.StatusBadRequest},
+		{domain.Conflict("username already taken"), http.StatusConflict},
+		{&domain.TransitionError{From: domain.StatusDone, To: domain.StatusTodo}, http.StatusConflict},
+		{domain.Unauthorized("invalid username or password"), http.StatusUnauthorized},
+		{domain.ErrVersionConflict, http.StatusPreconditionFailed},
+		{fmt.Errorf("%w: %q", domain.ErrInvalidStatus, "someday"), http.StatusUnprocessableEntity},
+		{&domain.LoginLockedError{Until: time.Now().Add(time.Minute)}, http.StatusTooManyRequests},
+		{&domain.PasswordPolicyError{}, http.StatusBadRequest},
+		{errors.New("connection reset"), http.StatusInternalServerError},
+	}
+
+	for _, tc := range cases {
+		assert.Equal(t, tc.status, infrastructure.StatusForError(tc.err), tc.err.Error())
+	}
+}
+
+func TestAbortWithError(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	respond := func(err error) (*httptest.ResponseRecorder, infrastructure.Problem) {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodGet, "/tasks/123", nil)
+
+		infrastructure.AbortWithError(c, err)
+
+		var problem infrastructure.Problem
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &problem))
+		assert.True(t, c.IsAborted())
+		return w, problem
+	}
+
+	w, problem := respond(domain.ErrTaskNotFound)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, "application/problem+json", w.Header().Get("Content-Type"))
+	assert.Equal(t, "Not Found", problem.Title)
+	assert.Equal(t, http.StatusNotFound, problem.Status)
+	assert.Equal(t, "task not found", problem.Detail)
+	assert.Equal(t, "/tasks/123", problem.Instance)
+
+	w, problem = respond(errors.New("mongo: connection string leaked"))
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.NotContains(t, problem.Detail, "mongo")
+	assert.Empty(t, w.Header().Get("Retry-After"))
+
+	w, problem = respond(&domain.LoginLockedError{Until: time.Now().Add(90 * time.Second)})
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Contains(t, []string{"89", "90"}, w.Header().Get("Retry-After"))
+	assert.Equal(t, "too many failed login attempts, try again later", problem.Detail)
+
+	w, _ = respond(&domain.LoginLockedError{Until: time.Now().Add(-time.Second)})
+	assert.Equal(t, "1", w.Header().Get("Retry-After"), "clients are never told to retry in the past")
+
+	violations := []domain.RuleViolation{
+		{Rule: domain.PasswordRuleMinLength, Message: "must be at least 8 characters"},
+		{Rule: domain.PasswordRuleCommon, Message: "is too common"},
+	}
+	w, problem = respond(&domain.PasswordPolicyError{Violations: violations})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, "password does not meet the policy: must be at least 8 characters; is too common", problem.Detail)
+	assert.Equal(t, violations, problem.Violations)
+}
@@ -0,0 +1,97 @@
+package infrastructure_test
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	infrastructure "task-manager/Infrastructure"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// rfc6238Secret is the SHA1 key of the RFC 6238 test vectors,
+// "12345678901234567890", in base32
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestTOTPCodeMatchesRFC6238(t *testing.T) {
+	totp := infrastructure.NewTOTPService("Task Manager")
+
+	// The RFC lists eight digits, of which we use the last six
+	for unix, want := range map[int64]string{
+		59:          "287082",
+		1111111109:  "081804",
+		1111111111:  "050471",
+		1234567890:  "005924",
+		2000000000:  "279037",
+		20000000000: "353130",
+	} {
+		code, err := totp.Code(rfc6238Secret, time.Unix(unix, 0))
+		require.NoError(t, err)
+		assert.Equal(t, want, code, unix)
+	}
+}
+
+func TestTOTPValidate(t *testing.T) {
+	totp := infrastructure.NewTOTPService("Task Manager")
+	at := time.Unix(1111111111, 0)
+	step := at.Unix() / 30
+
+	t.Run("accepts one step of drift", func(t *testing.T) {
+		for _, drift := range []int64{-1, 0, 1} {
+			code, err := totp.Code(rfc6238Secret, at.Add(time.Duration(drift)*30*time.Second))
+			require.NoError(t, err)
+			got, ok := totp.Validate(rfc6238Secret, code, at)
+			assert.True(t, ok, drift)
+			assert.Equal(t, step+drift, got, "the matching step is reported for replay checks")
+		}
+	})
+
+	t.Run("rejects older and newer codes", func(t *testing.T) {
+		for _, drift := range []int64{-2, 2} {
+			code, err := totp.Code(rfc6238Secret, at.Add(time.Duration(drift)*30*time.Second))
+			require.NoError(t, err)
+			_, ok := totp.Validate(rfc6238Secret, code, at)
+			assert.False(t, ok, drift)
+		}
+	})
+
+	t.Run("rejects malformed input", func(t *testing.T) {
+		for _, code := range []string{"", "05047", "0504711", "abcdef"} {
+			_, ok := totp.Validate(rfc6238Secret, code, at)
+			assert.False(t, ok, code)
+		}
+		_, ok := totp.Validate("not base32!", "050471", at)
+		assert.False(t, ok)
+	})
+
+	t.Run("accepts lowercase and padded secrets", func(t *testing.T) {
+		_, ok := totp.Validate("gezdgnbvgy3tqojqgezdgnbvgy3tqojq====", "050471", at)
+		assert.True(t, ok)
+	})
+}
+
+func TestTOTPGenerateSecretAndURI(t *testing.T) {
+	totp := infrastructure.NewTOTPService("Task Manager")
+
+	secret, err := totp.GenerateSecret()
+	require.NoError(t, err)
+	assert.Len(t, secret, 32, "20 bytes in unpadded base32")
+	other, err := totp.GenerateSecret()
+	require.NoError(t, err)
+	assert.NotEqual(t, secret, other)
+
+	uri, err := url.Parse(totp.URI(secret, "jane doe"))
+	require.NoError(t, err)
+	assert.Equal(t, "otpauth", uri.Scheme)
+	assert.Equal(t, "totp", uri.Host)
+	assert.Equal(t, "/Task Manager:jane doe", uri.Path)
+	assert.Equal(t, url.Values{
+		"secret":    {secret},
+		"issuer":    {"Task Manager"},
+		"algorithm": {"SHA1"},
+		"digits":    {"6"},
+		"period":    {"30"},
+	}, uri.Query())
+}
@@ -0,0 +1,1367 @@
+package test_repositories
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	domain "task-manager/Domain"
+	infrastructure "task-manager/Infrastructure"
+	repositories "task-manager/Repositories"
+
+	"github.com/stretchr/testify/suite"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// -------------------------------------------------------------------
+// Contract suites every repository implementation must pass
+// -------------------------------------------------------------------
+
+type TaskRepoContractSuite struct {
+	suite.Suite
+	newRepo func() domain.TaskRepository
+	repo    domain.TaskRepository
+}
+
+func (suite *TaskRepoContractSuite) SetupTest() {
+	suite.repo = suite.newRepo()
+}
+
+func TestMemoryTaskRepoContract(t *testing.T) {
+	suite.Run(t, &TaskRepoContractSuite{newRepo: repositories.NewMemoryTaskRepository})
+}
+
+func TestMongoTaskRepoContract(t *testing.T) {
+	if testMongoClient == nil {
+		t.Skip("MongoDB not initialized, skipping tests.")
+	}
+	coll := testMongoClient.Database("test_contract").Collection("tasks")
+	suite.Run(t, &TaskRepoContractSuite{newRepo: func() domain.TaskRepository {
+		_, err := coll.DeleteMany(context.Background(), bson.D{})
+		if err != nil {
+			t.Fatalf("Database cleanup failed: %v", err)
+		}
+		return repositories.NewTaskRepository(coll, repositories.DefaultTimeouts)
+	}})
+}
+
+func TestSQLiteTaskRepoContract(t *testing.T) {
+	suite.Run(t, &TaskRepoContractSuite{newRepo: func() domain.TaskRepository {
+		return repositories.NewSQLTaskRepository(newSQLiteDB(t), repositories.SQLite, repositories.DefaultTimeouts)
+	}})
+}
+
+func TestPostgresTaskRepoContract(t *testing.T) {
+	db := postgresDB(t)
+	suite.Run(t, &TaskRepoContractSuite{newRepo: func() domain.TaskRepository {
+		if _, err := db.Exec("DELETE FROM tasks"); err != nil {
+			t.Fatalf("Database cleanup failed: %v", err)
+		}
+		return repositories.NewSQLTaskRepository(db, repositories.Postgres, repositories.DefaultTimeouts)
+	}})
+}
+
+// newSQLiteDB returns a migrated database in a file that is removed with the test
+func newSQLiteDB(t *testing.T) *sql.DB {
+	db, err := repositories.OpenSQL(repositories.SQLite, filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open SQLite: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	if err := repositories.RunSQLMigrations(context.Background(), db, repositories.SQLite); err != nil {
+		t.Fatalf("Failed to migrate SQLite: %v", err)
+	}
+	return db
+}
+
+// postgresDB connects to POSTGRES_DSN, skipping the test when it is unset
+func postgresDB(t *testing.T) *sql.DB {
+	dsn := os.Getenv("POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("POSTGRES_DSN not set, skipping tests.")
+	}
+
+	db, err := repositories.OpenSQL(repositories.Postgres, dsn)
+	if err != nil {
+		t.Fatalf("Failed to connect to Postgres: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	if err := repositories.RunSQLMigrations(context.Background(), db, repositories.Postgres); err != nil {
+		t.Fatalf("Failed to migrate Postgres: %v", err)
+	}
+	return db
+}
+
+func (suite *TaskRepoContractSuite) create(task domain.Task) domain.Task {
+	created, err := suite.repo.CreateTask(context.Background(), task)
+	suite.Require().NoError(err)
+	return created
+}
+
+func (suite *TaskRepoContractSuite) TestCreateAndGet() {
+	due := time.Date(2024, time.July, 17, 9, 30, 0, 123456789, time.UTC)
+	created := suite.create(domain.Task{Title: "Write docs", DueDate: &due, Status: domain.StatusTodo})
+
+	suite.False(created.ID.IsZero())
+	suite.Equal(int64(1), created.Version)
+	suite.False(created.CreatedAt.IsZero())
+	suite.Equal(created.CreatedAt, created.UpdatedAt)
+
+	fetched, err := suite.repo.GetTaskByID(context.Background(), created.ID.String())
+	suite.Require().NoError(err)
+	suite.Equal(created.Title, fetched.Title)
+	suite.Require().NotNil(fetched.DueDate)
+	suite.True(due.Truncate(time.Millisecond).Equal(*fetched.DueDate), "due dates are stored at millisecond precision")
+	suite.Equal(created.Version, fetched.Version)
+}
+
+func (suite *TaskRepoContractSuite) TestMissingAndInvalidIDs() {
+	_, err := suite.repo.GetTaskByID(context.Background(), domain.NewID().String())
+	suite.ErrorIs(err, domain.ErrNotFound)
+
+	_, err = suite.repo.GetTaskByID(context.Background(), "not-an-id")
+	suite.ErrorIs(err, domain.ErrInvalidID)
+
+	err = suite.repo.DeleteTask(context.Background(), domain.NewID().String(), 0)
+	suite.ErrorIs(err, domain.ErrNotFound)
+
+	_, err = suite.repo.UpdateTask(context.Background(), "not-an-id", domain.Task{}, 0)
+	suite.ErrorIs(err, domain.ErrInvalidID)
+}
+
+func (suite *TaskRepoContractSuite) TestQueryTasks() {
+	owner := domain.NewID()
+	jan := time.Date(2024, time.January, 10, 0, 0, 0, 0, time.UTC)
+	mar := time.Date(2024, time.March, 10, 0, 0, 0, 0, time.UTC)
+
+	suite.create(domain.Task{Title: "Alpha report", Status: domain.StatusTodo, CreatedBy: owner, DueDate: &mar})
+	suite.create(domain.Task{Title: "Beta", Status: domain.StatusDone, AssigneeID: owner, DueDate: &jan})
+	suite.create(domain.Task{Title: "Gamma REPORT", Status: domain.StatusTodo})
+
+	tasks, total, err := suite.repo.QueryTasks(context.Background(), domain.TaskQuery{VisibleTo: owner.String(), SortBy: "title"})
+	suite.Require().NoError(err)
+	suite.Equal(int64(2), total)
+	suite.Require().Len(tasks, 2)
+	suite.Equal("Alpha report", tasks[0].Title)
+
+	tasks, total, err = suite.repo.QueryTasks(context.Background(), domain.TaskQuery{Title: "report", Status: domain.StatusTodo, SortBy: "title", SortDesc: true})
+	suite.Require().NoError(err)
+	suite.Equal(int64(2), total)
+	suite.Require().Len(tasks, 2)
+	suite.Equal("Gamma REPORT", tasks[0].Title)
+
+	tasks, total, err = suite.repo.QueryTasks(context.Background(), domain.TaskQuery{DueFrom: jan, DueTo: jan})
+	suite.Require().NoError(err)
+	suite.Equal(int64(1), total)
+	suite.Require().Len(tasks, 1)
+	suite.Equal("Beta", tasks[0].Title)
+
+	// Tasks without a due date sort first
+	tasks, _, err = suite.repo.QueryTasks(context.Background(), domain.TaskQuery{SortBy: "due_date"})
+	suite.Require().NoError(err)
+	suite.Require().Len(tasks, 3)
+	suite.Equal([]string{"Gamma REPORT", "Beta", "Alpha report"}, []string{tasks[0].Title, tasks[1].Title, tasks[2].Title})
+
+	tasks, total, err = suite.repo.QueryTasks(context.Background(), domain.TaskQuery{SortBy: "title", Limit: 1, Offset: 1})
+	suite.Require().NoError(err)
+	suite.Equal(int64(3), total)
+	suite.Require().Len(tasks, 1)
+	suite.Equal("Beta", tasks[0].Title)
+
+	tasks, _, err = suite.repo.QueryTasks(context.Background(), domain.TaskQuery{Offset: 10})
+	suite.Require().NoError(err)
+	suite.Empty(tasks)
+
+	_, _, err = suite.repo.QueryTasks(context.Background(), domain.TaskQuery{VisibleTo: "nobody"})
+	suite.ErrorIs(err, domain.ErrInvalidID)
+}
+
+func (suite *TaskRepoContractSuite) TestVersionedWrites() {
+	created := suite.create(domain.Task{Title: "Original", Status: domain.StatusTodo})
+	id := created.ID.String()
+
+	updated, err := suite.repo.UpdateTask(context.Background(), id, domain.Task{Title: "Renamed", Status: domain.StatusInProgress}, 1)
+	suite.Require().NoError(err)
+	suite.Equal("Renamed", updated.Title)
+	suite.Equal(int64(2), updated.Version)
+	suite.Equal(created.CreatedAt, updated.CreatedAt)
+
+	_, err = suite.repo.UpdateTask(context.Background(), id, domain.Task{Title: "Stale"}, 1)
+	suite.ErrorIs(err, domain.ErrVersionConflict)
+
+	desc := "patched"
+	patched, err := suite.repo.PatchTask(context.Background(), id, domain.TaskPatch{Description: &desc}, 0)
+	suite.Require().NoError(err)
+	suite.Equal("Renamed", patched.Title)
+	suite.Equal("patched", patched.Description)
+	suite.Equal(int64(3), patched.Version)
+
+	err = suite.repo.DeleteTask(context.Background(), id, 2)
+	suite.ErrorIs(err, domain.ErrVersionConflict)
+
+	suite.NoError(suite.repo.DeleteTask(context.Background(), id, 3))
+	_, err = suite.repo.GetTaskByID(context.Background(), id)
+	suite.ErrorIs(err, domain.ErrNotFound)
+}
+
+func (suite *TaskRepoContractSuite) TestPatchClearsDueDate() {
+	due := time.Date(2024, time.May, 1, 0, 0, 0, 0, time.UTC)
+	created := suite.create(domain.Task{Title: "Dated", DueDate: &due, Status: domain.StatusTodo})
+
+	patched, err := suite.repo.PatchTask(context.Background(), created.ID.String(), domain.TaskPatch{ClearDueDate: true}, 0)
+	suite.Require().NoError(err)
+	suite.Nil(patched.DueDate)
+}
+
+func (suite *TaskRepoContractSuite) TestTransitionTask() {
+	created := suite.create(domain.Task{Title: "Flow", Status: domain.StatusTodo})
+
+	moved, err := suite.repo.TransitionTask(context.Background(), created.ID.String(), domain.StatusTodo, domain.StatusInProgress)
+	suite.Require().NoError(err)
+	suite.Equal(domain.StatusInProgress, moved.Status)
+	suite.Equal(int64(2), moved.Version)
+
+	_, err = suite.repo.TransitionTask(context.Background(), created.ID.String(), domain.StatusTodo, domain.StatusDone)
+	var transitionErr *domain.TransitionError
+	suite.Require().ErrorAs(err, &transitionErr)
+	suite.Equal(domain.StatusInProgress, transitionErr.From)
+
+	_, err = suite.repo.TransitionTask(context.Background(), domain.NewID().String(), domain.StatusTodo, domain.StatusDone)
+	suite.ErrorIs(err, domain.ErrNotFound)
+}
+
+func (suite *TaskRepoContractSuite) TestReassignTasks() {
+	from, to, other := domain.NewID(), domain.NewID(), domain.NewID()
+	created := suite.create(domain.Task{Title: "Created", Status: domain.StatusTodo, CreatedBy: from, AssigneeID: other})
+	assigned := suite.create(domain.Task{Title: "Assigned", Status: domain.StatusTodo, CreatedBy: other, AssigneeID: from})
+	own := suite.create(domain.Task{Title: "Own", Status: domain.StatusTodo, CreatedBy: from, AssigneeID: from})
+	untouched := suite.create(domain.Task{Title: "Untouched", Status: domain.StatusTodo, CreatedBy: other, AssigneeID: other})
+
+	suite.Require().NoError(suite.repo.ReassignTasks(context.Background(), from, to))
+
+	tests := []struct {
+		task                domain.Task
+		createdBy, assignee domain.ID
+		version             int64
+	}{
+		{created, to, other, 2},
+		{assigned, other, to, 2},
+		{own, to, to, 2},
+		{untouched, other, other, 1},
+	}
+	for _, tt := range tests {
+		fetched, err := suite.repo.GetTaskByID(context.Background(), tt.task.ID.String())
+		suite.Require().NoError(err)
+		suite.Equal(tt.createdBy, fetched.CreatedBy, tt.task.Title)
+		suite.Equal(tt.assignee, fetched.AssigneeID, tt.task.Title)
+		suite.Equal(tt.version, fetched.Version, tt.task.Title)
+	}
+}
+
+func (suite *TaskRepoContractSuite) TestDeleteAndUnassignTasks() {
+	user, other := domain.NewID(), domain.NewID()
+	created := suite.create(domain.Task{Title: "Created", Status: domain.StatusTodo, CreatedBy: user, AssigneeID: other})
+	assigned := suite.create(domain.Task{Title: "Assigned", Status: domain.StatusTodo, CreatedBy: other, AssigneeID: user})
+
+	suite.Require().NoError(suite.repo.DeleteTasksCreatedBy(context.Background(), user))
+	suite.Require().NoError(suite.repo.UnassignTasks(context.Background(), user))
+
+	_, err := suite.repo.GetTaskByID(context.Background(), created.ID.String())
+	suite.ErrorIs(err, domain.ErrNotFound)
+
+	fetched, err := suite.repo.GetTaskByID(context.Background(), assigned.ID.String())
+	suite.Require().NoError(err)
+	suite.Equal(other, fetched.AssigneeID, "tasks go back to their creator")
+	suite.Equal(int64(2), fetched.Version)
+}
+
+type UserRepoContractSuite struct {
+	suite.Suite
+	newRepo func() domain.UserRepository
+	repo    domain.UserRepository
+}
+
+func (suite *UserRepoContractSuite) SetupTest() {
+	suite.repo = suite.newRepo()
+}
+
+func TestMemoryUserRepoContract(t *testing.T) {
+	suite.Run(t, &UserRepoContractSuite{newRepo: func() domain.UserRepository {
+		return repositories.NewMemoryUserRepository(infrastructure.NewPasswordService())
+	}})
+}
+
+func TestMongoUserRepoContract(t *testing.T) {
+	if testMongoClient == nil {
+		t.Skip("MongoDB not initialized, skipping tests.")
+	}
+	db := testMongoClient.Database("test_contract")
+	if err := repositories.RunMigrations(context.Background(), db, repositories.DefaultCollectionNames); err != nil {
+		t.Fatalf("Migrations failed: %v", err)
+	}
+	coll := db.Collection("users")
+	suite.Run(t, &UserRepoContractSuite{newRepo: func() domain.UserRepository {
+		_, err := coll.DeleteMany(context.Background(), bson.D{})
+		if err != nil {
+			t.Fatalf("Database cleanup failed: %v", err)
+		}
+		return repositories.NewUserRepository(coll, infrastructure.NewPasswordService(), repositories.DefaultTimeouts)
+	}})
+}
+
+func TestSQLiteUserRepoContract(t *testing.T) {
+	suite.Run(t, &UserRepoContractSuite{newRepo: func() domain.UserRepository {
+		return repositories.NewSQLUserRepository(newSQLiteDB(t), repositories.SQLite, infrastructure.NewPasswordService(), repositories.DefaultTimeouts)
+	}})
+}
+
+func TestPostgresUserRepoContract(t *testing.T) {
+	db := postgresDB(t)
+	suite.Run(t, &UserRepoContractSuite{newRepo: func() domain.UserRepository {
+		if _, err := db.Exec("DELETE FROM users"); err != nil {
+			t.Fatalf("Database cleanup failed: %v", err)
+		}
+		return repositories.NewSQLUserRepository(db, repositories.Postgres, infrastructure.NewPasswordService(), repositories.DefaultTimeouts)
+	}})
+}
+
+func (suite *UserRepoContractSuite) TestRegisterKeepsRole() {
+	admin, err := suite.repo.RegisterUser(context.Background(), domain.User{Username: "root", Password: "secret", Role: domain.RoleAdmin})
+	suite.Require().NoError(err)
+	suite.Equal(domain.RoleAdmin, admin.Role)
+	suite.Empty(admin.Password)
+	suite.False(admin.ID.IsZero())
+
+	// Being first doesn't make a user admin
+	suite.SetupTest()
+	first, err := suite.repo.RegisterUser(context.Background(), domain.User{Username: "member", Password: "secret"})
+	suite.Require().NoError(err)
+	suite.Equal(domain.RoleMember, first.Role)
+	stored, err := suite.repo.GetUserByID(context.Background(), first.ID.String())
+	suite.Require().NoError(err)
+	suite.Equal(domain.RoleMember, stored.Role)
+}
+
+func (suite *UserRepoContractSuite) TestRegisterFirstAdmin() {
+	admin, err := suite.repo.RegisterFirstAdmin(context.Background(), domain.User{Username: "root", Password: "secret"})
+	suite.Require().NoError(err)
+	suite.Equal(domain.RoleAdmin, admin.Role)
+	suite.Empty(admin.Password)
+
+	_, err = suite.repo.RegisterFirstAdmin(context.Background(), domain.User{Username: "root2", Password: "secret"})
+	suite.ErrorIs(err, domain.ErrSetupComplete)
+
+	// Admins made any other way end setup too
+	suite.SetupTest()
+	_, err = suite.repo.RegisterUser(context.Background(), domain.User{Username: "boss", Password: "secret", Role: domain.RoleAdmin})
+	suite.Require().NoError(err)
+	_, err = suite.repo.RegisterFirstAdmin(context.Background(), domain.User{Username: "root", Password: "secret"})
+	suite.ErrorIs(err, domain.ErrSetupComplete)
+
+	// A taken username fails as a conflict and leaves setup open
+	suite.SetupTest()
+	_, err = suite.repo.RegisterUser(context.Background(), domain.User{Username: "root", Password: "secret"})
+	suite.Require().NoError(err)
+	_, err = suite.repo.RegisterFirstAdmin(context.Background(), domain.User{Username: "root", Password: "secret"})
+	suite.ErrorIs(err, domain.ErrConflict)
+	suite.NotErrorIs(err, domain.ErrSetupComplete)
+	_, err = suite.repo.RegisterFirstAdmin(context.Background(), domain.User{Username: "root2", Password: "secret"})
+	suite.NoError(err)
+}
+
+func (suite *UserRepoContractSuite) TestConcurrentFirstAdmin() {
+	const attempts = 8
+	errs := make([]error, attempts)
+	var wg sync.WaitGroup
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = suite.repo.RegisterFirstAdmin(context.Background(), domain.User{Username: fmt.Sprintf("root%d", i), Password: "secret"})
+		}(i)
+	}
+	wg.Wait()
+
+	created := 0
+	for _, err := range errs {
+		if err == nil {
+			created++
+			continue
+		}
+		suite.ErrorIs(err, domain.ErrSetupComplete)
+	}
+	suite.Equal(1, created)
+
+	_, admins, err := suite.repo.ListUsers(context.Background(), domain.UserQuery{Role: domain.RoleAdmin})
+	suite.Require().NoError(err)
+	suite.Equal(int64(1), admins)
+}
+
+func (suite *UserRepoContractSuite) TestConcurrentRegistration() {
+	const attempts = 8
+	register := func(user func(i int) domain.User) []error {
+		errs := make([]error, attempts)
+		var wg sync.WaitGroup
+		for i := 0; i < attempts; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				_, errs[i] = suite.repo.RegisterUser(context.Background(), user(i))
+			}(i)
+		}
+		wg.Wait()
+		return errs
+	}
+
+	// outcome counts the registrations that succeeded and checks the rest
+	// failed with the conflict
+	outcome := func(errs []error, conflict error) int {
+		created := 0
+		for _, err := range errs {
+			if err == nil {
+				created++
+				continue
+			}
+			suite.ErrorIs(err, conflict)
+		}
+		return created
+	}
+
+	errs := register(func(i int) domain.User {
+		return domain.User{Username: "racer", Password: "secret"}
+	})
+	suite.Equal(1, outcome(errs, domain.ErrConflict), "one user gets the username")
+	for _, err := range errs {
+		if err != nil {
+			suite.Contains(err.Error(), "username already taken")
+		}
+	}
+
+	errs = register(func(i int) domain.User {
+		return domain.User{Username: fmt.Sprintf("mailer-%d", i), Password: "secret", Email: "race@example.com"}
+	})
+	for _, err := range errs {
+		if err != nil {
+			suite.ErrorIs(err, domain.ErrEmailTaken)
+		}
+	}
+	suite.Equal(1, outcome(errs, domain.ErrConflict), "one user gets the email address")
+
+	_, total, err := suite.repo.ListUsers(context.Background(), domain.UserQuery{})
+	suite.Require().NoError(err)
+	suite.Equal(int64(2), total)
+}
+
+func (suite *UserRepoContractSuite) TestRegisterValidation() {
+	_, err := suite.repo.RegisterUser(context.Background(), domain.User{Username: "empty"})
+	suite.ErrorIs(err, domain.ErrValidation)
+
+	_, err = suite.repo.RegisterUser(context.Background(), domain.User{Username: "dupe", Password: "one"})
+	suite.Require().NoError(err)
+	_, err = suite.repo.RegisterUser(context.Background(), domain.User{Username: "dupe", Password: "two"})
+	suite.ErrorIs(err, domain.ErrConflict)
+}
+
+// checkPassword compares a password with the stored hash of a user
+func (suite *UserRepoContractSuite) checkPassword(username, password string) error {
+	user, err := suite.repo.GetUserByUsername(context.Background(), username)
+	suite.Require().NoError(err)
+	return infrastructure.NewPasswordService().ComparePassword(user.Password, password)
+}
+
+func (suite *UserRepoContractSuite) TestRegisterHashesPassword() {
+	registered, err := suite.repo.RegisterUser(context.Background(), domain.User{Username: "jane", Password: "secret"})
+	suite.Require().NoError(err)
+	suite.Empty(registered.Password)
+
+	stored, err := suite.repo.GetUserByUsername(context.Background(), "jane")
+	suite.Require().NoError(err)
+	suite.Equal(registered.ID, stored.ID)
+	suite.NotEqual("secret", stored.Password)
+	suite.NoError(suite.checkPassword("jane", "secret"))
+	suite.Error(suite.checkPassword("jane", "wrong"))
+}
+
+func (suite *UserRepoContractSuite) TestPromoteAndLookup() {
+	_, err := suite.repo.RegisterUser(context.Background(), domain.User{Username: "root", Password: "secret"})
+	suite.Require().NoError(err)
+	member, err := suite.repo.RegisterUser(context.Background(), domain.User{Username: "member", Password: "secret"})
+	suite.Require().NoError(err)
+
+	promoted, err := suite.repo.PromoteUser(context.Background(), member.ID.String())
+	suite.Require().NoError(err)
+	suite.Equal("admin", promoted.Role)
+	suite.Empty(promoted.Password)
+
+	byName, err := suite.repo.GetUserByUsername(context.Background(), "member")
+	suite.Require().NoError(err)
+	suite.Equal(member.ID, byName.ID)
+
+	byID, err := suite.repo.GetUserByID(context.Background(), member.ID.String())
+	suite.Require().NoError(err)
+	suite.Equal("admin", byID.Role)
+
+	_, err = suite.repo.PromoteUser(context.Background(), domain.NewID().String())
+	suite.ErrorIs(err, domain.ErrNotFound)
+
+	_, err = suite.repo.PromoteUser(context.Background(), "bad")
+	suite.ErrorIs(err, domain.ErrInvalidID)
+
+	_, err = suite.repo.GetUserByUsername(context.Background(), "ghost")
+	suite.ErrorIs(err, domain.ErrNotFound)
+}
+
+func (suite *UserRepoContractSuite) TestListUsers() {
+	var registered []domain.User
+	for _, name := range []string{"root", "ann", "bob", "cid"} {
+		user, err := suite.repo.RegisterUser(context.Background(), domain.User{Username: name, Password: "secret"})
+		suite.Require().NoError(err)
+		registered = append(registered, user)
+	}
+	_, err := suite.repo.SetUserRole(context.Background(), registered[1].ID.String(), domain.RoleViewer)
+	suite.Require().NoError(err)
+	_, err = suite.repo.SetUserDisabled(context.Background(), registered[2].ID.String(), true)
+	suite.Require().NoError(err)
+
+	all, total, err := suite.repo.ListUsers(context.Background(), domain.UserQuery{})
+	suite.Require().NoError(err)
+	suite.Equal(int64(4), total)
+	suite.Len(all, 4)
+	for i, user := range all {
+		suite.Empty(user.Password, "listings never include password hashes")
+		if i > 0 {
+			suite.Less(all[i-1].ID.String(), user.ID.String(), "users are ordered by ID")
+		}
+	}
+
+	page, total, err := suite.repo.ListUsers(context.Background(), domain.UserQuery{Limit: 2, Offset: 3})
+	suite.Require().NoError(err)
+	suite.Equal(int64(4), total)
+	suite.Equal(all[3:], page)
+
+	viewers, total, err := suite.repo.ListUsers(context.Background(), domain.UserQuery{Role: domain.RoleViewer})
+	suite.Require().NoError(err)
+	suite.Equal(int64(1), total)
+	suite.Equal("ann", viewers[0].Username)
+
+	disabled, enabled := true, false
+	users, total, err := suite.repo.ListUsers(context.Background(), domain.UserQuery{Disabled: &disabled})
+	suite.Require().NoError(err)
+	suite.Equal(int64(1), total)
+	suite.Equal("bob", users[0].Username)
+	suite.True(users[0].Disabled)
+
+	_, total, err = suite.repo.ListUsers(context.Background(), domain.UserQuery{Disabled: &enabled})
+	suite.Require().NoError(err)
+	suite.Equal(int64(3), total)
+}
+
+func (suite *UserRepoContractSuite) TestSetUserRoleAndDisabled() {
+	user, err := suite.repo.RegisterUser(context.Background(), domain.User{Username: "jane", Password: "secret"})
+	suite.Require().NoError(err)
+
+	updated, err := suite.repo.SetUserRole(context.Background(), user.ID.String(), domain.RoleMaintainer)
+	suite.Require().NoError(err)
+	suite.Equal(domain.RoleMaintainer, updated.Role)
+	suite.Empty(updated.Password)
+
+	updated, err = suite.repo.SetUserDisabled(context.Background(), user.ID.String(), true)
+	suite.Require().NoError(err)
+	suite.True(updated.Disabled)
+	suite.Equal(domain.RoleMaintainer, updated.Role)
+
+	stored, err := suite.repo.GetUserByUsername(context.Background(), "jane")
+	suite.Require().NoError(err)
+	suite.True(stored.Disabled)
+
+	updated, err = suite.repo.SetUserDisabled(context.Background(), user.ID.String(), false)
+	suite.Require().NoError(err)
+	suite.False(updated.Disabled)
+	stored, err = suite.repo.GetUserByUsername(context.Background(), "jane")
+	suite.Require().NoError(err)
+	suite.False(stored.Disabled)
+
+	_, err = suite.repo.SetUserRole(context.Background(), domain.NewID().String(), domain.RoleViewer)
+	suite.ErrorIs(err, domain.ErrNotFound)
+	_, err = suite.repo.SetUserDisabled(context.Background(), "bad", true)
+	suite.ErrorIs(err, domain.ErrInvalidID)
+}
+
+func (suite *UserRepoContractSuite) TestDeleteUser() {
+	user, err := suite.repo.RegisterUser(context.Background(), domain.User{Username: "jane", Password: "secret"})
+	suite.Require().NoError(err)
+
+	suite.Require().NoError(suite.repo.DeleteUser(context.Background(), user.ID.String()))
+	_, err = suite.repo.GetUserByID(context.Background(), user.ID.String())
+	suite.ErrorIs(err, domain.ErrNotFound)
+
+	suite.ErrorIs(suite.repo.DeleteUser(context.Background(), user.ID.String()), domain.ErrNotFound)
+	suite.ErrorIs(suite.repo.DeleteUser(context.Background(), "bad"), domain.ErrInvalidID)
+}
+
+func (suite *UserRepoContractSuite) TestLastAdmin() {
+	ctx := context.Background()
+	boss, err := suite.repo.RegisterUser(ctx, domain.User{Username: "boss", Password: "secret", Role: domain.RoleAdmin})
+	suite.Require().NoError(err)
+	retired, err := suite.repo.RegisterUser(ctx, domain.User{Username: "retired", Password: "secret", Role: domain.RoleAdmin})
+	suite.Require().NoError(err)
+	_, err = suite.repo.SetUserDisabled(ctx, retired.ID.String(), true)
+	suite.Require().NoError(err)
+
+	// Disabled admins don't count
+	_, err = suite.repo.SetUserRole(ctx, boss.ID.String(), domain.RoleMember)
+	suite.ErrorIs(err, domain.ErrLastAdmin)
+	_, err = suite.repo.SetUserDisabled(ctx, boss.ID.String(), true)
+	suite.ErrorIs(err, domain.ErrLastAdmin)
+	suite.ErrorIs(suite.repo.DeleteUser(ctx, boss.ID.String()), domain.ErrLastAdmin)
+
+	stored, err := suite.repo.GetUserByID(ctx, boss.ID.String())
+	suite.Require().NoError(err)
+	suite.Equal(domain.RoleAdmin, stored.Role)
+	suite.False(stored.Disabled)
+
+	// Keeping the role or changing other admins is fine
+	_, err = suite.repo.SetUserRole(ctx, boss.ID.String(), domain.RoleAdmin)
+	suite.NoError(err)
+	_, err = suite.repo.SetUserRole(ctx, retired.ID.String(), domain.RoleMember)
+	suite.NoError(err)
+	suite.NoError(suite.repo.DeleteUser(ctx, retired.ID.String()))
+
+	// With a second enabled admin either can go
+	deputy, err := suite.repo.RegisterUser(ctx, domain.User{Username: "deputy", Password: "secret", Role: domain.RoleAdmin})
+	suite.Require().NoError(err)
+	_, err = suite.repo.SetUserDisabled(ctx, boss.ID.String(), true)
+	suite.NoError(err)
+	_, err = suite.repo.SetUserDisabled(ctx, deputy.ID.String(), true)
+	suite.ErrorIs(err, domain.ErrLastAdmin)
+}
+
+func (suite *UserRepoContractSuite) TestConcurrentLastAdmin() {
+	ctx := context.Background()
+	const admins = 6
+	ids := make([]string, admins)
+	for i := range ids {
+		admin, err := suite.repo.RegisterUser(ctx, domain.User{Username: fmt.Sprintf("admin%d", i), Password: "secret", Role: domain.RoleAdmin})
+		suite.Require().NoError(err)
+		ids[i] = admin.ID.String()
+	}
+
+	// Every admin is demoted, disabled or deleted at once
+	errs := make([]error, admins)
+	var wg sync.WaitGroup
+	for i, id := range ids {
+		wg.Add(1)
+		go func(i int, id string) {
+			defer wg.Done()
+			switch i % 3 {
+			case 0:
+				_, errs[i] = suite.repo.SetUserRole(ctx, id, domain.RoleMember)
+			case 1:
+				_, errs[i] = suite.repo.SetUserDisabled(ctx, id, true)
+			default:
+				errs[i] = suite.repo.DeleteUser(ctx, id)
+			}
+		}(i, id)
+	}
+	wg.Wait()
+
+	removed := 0
+	for _, err := range errs {
+		if err == nil {
+			removed++
+			continue
+		}
+		suite.ErrorIs(err, domain.ErrLastAdmin)
+	}
+
+	enabled := false
+	_, remaining, err := suite.repo.ListUsers(ctx, domain.UserQuery{Role: domain.RoleAdmin, Disabled: &enabled})
+	suite.Require().NoError(err)
+	suite.GreaterOrEqual(remaining, int64(1))
+	suite.Equal(int64(admins), int64(removed)+remaining)
+}
+
+func (suite *UserRepoContractSuite) TestUpdateProfile() {
+	jane, err := suite.repo.RegisterUser(context.Background(), domain.User{Username: "jane", Password: "secret"})
+	suite.Require().NoError(err)
+	_, err = suite.repo.RegisterUser(context.Background(), domain.User{Username: "john", Password: "secret"})
+	suite.Require().NoError(err)
+
+	name := "janet"
+	updated, err := suite.repo.UpdateProfile(context.Background(), jane.ID.String(), domain.ProfilePatch{Username: &name})
+	suite.Require().NoError(err)
+	suite.Equal("janet", updated.Username)
+	suite.Empty(updated.Password)
+
+	suite.NoError(suite.checkPassword("janet", "secret"), "the password survives a rename")
+
+	// Renaming to the current name is not a conflict
+	_, err = suite.repo.UpdateProfile(context.Background(), jane.ID.String(), domain.ProfilePatch{Username: &name})
+	suite.NoError(err)
+
+	taken := "john"
+	_, err = suite.repo.UpdateProfile(context.Background(), jane.ID.String(), domain.ProfilePatch{Username: &taken})
+	suite.ErrorIs(err, domain.ErrConflict)
+
+	unchanged, err := suite.repo.UpdateProfile(context.Background(), jane.ID.String(), domain.ProfilePatch{})
+	suite.Require().NoError(err)
+	suite.Equal("janet", unchanged.Username)
+	suite.Empty(unchanged.Password)
+
+	_, err = suite.repo.UpdateProfile(context.Background(), domain.NewID().String(), domain.ProfilePatch{Username: &name})
+	suite.ErrorIs(err, domain.ErrNotFound)
+	_, err = suite.repo.UpdateProfile(context.Background(), "bad", domain.ProfilePatch{Username: &name})
+	suite.ErrorIs(err, domain.ErrInvalidID)
+}
+
+func (suite *UserRepoContractSuite) TestSetPassword() {
+	jane, err := suite.repo.RegisterUser(context.Background(), domain.User{Username: "jane", Password: "secret"})
+	suite.Require().NoError(err)
+
+	hash, err := infrastructure.NewPasswordService().HashPassword("changed")
+	suite.Require().NoError(err)
+	suite.Require().NoError(suite.repo.SetPassword(context.Background(), jane.ID.String(), hash))
+
+	suite.Error(suite.checkPassword("jane", "secret"))
+	suite.NoError(suite.checkPassword("jane", "changed"))
+
+	suite.ErrorIs(suite.repo.SetPassword(context.Background(), domain.NewID().String(), hash), domain.ErrNotFound)
+}
+
+func (suite *UserRepoContractSuite) TestEmail() {
+	jane, err := suite.repo.RegisterUser(context.Background(), domain.User{Username: "jane", Password: "secret", Email: "Jane@Example.com", EmailVerified: true})
+	suite.Require().NoError(err)
+	suite.Equal("Jane@Example.com", jane.Email)
+	suite.False(jane.EmailVerified, "new addresses start unverified")
+
+	_, err = suite.repo.RegisterUser(context.Background(), domain.User{Username: "janet", Password: "secret", Email: "jane@example.COM"})
+	suite.ErrorIs(err, domain.ErrEmailTaken, "addresses are unique regardless of case")
+
+	// Any number of users may have no address
+	for _, name := range []string{"john", "jim"} {
+		_, err = suite.repo.RegisterUser(context.Background(), domain.User{Username: name, Password: "secret"})
+		suite.Require().NoError(err)
+	}
+
+	_, err = suite.repo.MarkEmailVerified(context.Background(), jane.ID.String(), "other@example.com")
+	suite.ErrorIs(err, domain.ErrNotFound, "a changed address isn't verified")
+	verified, err := suite.repo.MarkEmailVerified(context.Background(), jane.ID.String(), "Jane@Example.com")
+	suite.Require().NoError(err)
+	suite.True(verified.EmailVerified)
+	suite.Empty(verified.Password)
+
+	// Changing only the case keeps the address verified
+	recased := "jane@example.com"
+	updated, err := suite.repo.UpdateProfile(context.Background(), jane.ID.String(), domain.ProfilePatch{Email: &recased})
+	suite.Require().NoError(err)
+	suite.Equal(recased, updated.Email)
+	suite.True(updated.EmailVerified)
+
+	changed := "jane@example.org"
+	updated, err = suite.repo.UpdateProfile(context.Background(), jane.ID.String(), domain.ProfilePatch{Email: &changed})
+	suite.Require().NoError(err)
+	suite.Equal(changed, updated.Email)
+	suite.False(updated.EmailVerified)
+
+	john, err := suite.repo.GetUserByUsername(context.Background(), "john")
+	suite.Require().NoError(err)
+	_, err = suite.repo.UpdateProfile(context.Background(), john.ID.String(), domain.ProfilePatch{Email: &changed})
+	suite.ErrorIs(err, domain.ErrEmailTaken)
+
+	removed := ""
+	updated, err = suite.repo.UpdateProfile(context.Background(), jane.ID.String(), domain.ProfilePatch{Email: &removed})
+	suite.Require().NoError(err)
+	suite.Empty(updated.Email)
+	suite.False(updated.EmailVerified)
+
+	// The removed address is free again
+	_, err = suite.repo.UpdateProfile(context.Background(), john.ID.String(), domain.ProfilePatch{Email: &changed})
+	suite.NoError(err)
+}
+
+type TokenRepoContractSuite struct {
+	suite.Suite
+	newRepo func() domain.TokenRepository
+	repo    domain.TokenRepository
+}
+
+func (suite *TokenRepoContractSuite) SetupTest() {
+	suite.repo = suite.newRepo()
+}
+
+func TestMemoryTokenRepoContract(t *testing.T) {
+	suite.Run(t, &TokenRepoContractSuite{newRepo: repositories.NewMemoryTokenRepository})
+}
+
+func TestSQLiteTokenRepoContract(t *testing.T) {
+	suite.Run(t, &TokenRepoContractSuite{newRepo: func() domain.TokenRepository {
+		return repositories.NewSQLTokenRepository(newSQLiteDB(t), repositories.SQLite, repositories.DefaultTimeouts)
+	}})
+}
+
+func TestMongoTokenRepoContract(t *testing.T) {
+	if testMongoClient == nil {
+		t.Skip("MongoDB not initialized, skipping tests.")
+	}
+	db := testMongoClient.Database("test_contract")
+	suite.Run(t, &TokenRepoContractSuite{newRepo: func() domain.TokenRepository {
+		for _, name := range []string{"refresh_tokens", "revoked_tokens"} {
+			if _, err := db.Collection(name).DeleteMany(context.Background(), bson.D{}); err != nil {
+				t.Fatalf("Database cleanup failed: %v", err)
+			}
+		}
+		return repositories.NewTokenRepository(db.Collection("refresh_tokens"), db.Collection("revoked_tokens"), repositories.DefaultTimeouts)
+	}})
+}
+
+func TestPostgresTokenRepoContract(t *testing.T) {
+	db := postgresDB(t)
+	suite.Run(t, &TokenRepoContractSuite{newRepo: func() domain.TokenRepository {
+		for _, table := range []string{"refresh_tokens", "revoked_tokens"} {
+			if _, err := db.Exec("DELETE FROM " + table); err != nil {
+				t.Fatalf("Database cleanup failed: %v", err)
+			}
+		}
+		return repositories.NewSQLTokenRepository(db, repositories.Postgres, repositories.DefaultTimeouts)
+	}})
+}
+
+func (suite *TokenRepoContractSuite) TestRefreshTokenLifecycle() {
+	family := domain.NewID()
+	token := domain.RefreshToken{
+		TokenHash: "hash-1",
+		UserID:    domain.NewID(),
+		FamilyID:  family,
+		ExpiresAt: time.Now().Add(time.Hour).UTC().Truncate(time.Millisecond),
+	}
+	suite.Require().NoError(suite.repo.SaveRefreshToken(context.Background(), token))
+
+	stored, err := suite.repo.GetRefreshToken(context.Background(), "hash-1")
+	suite.Require().NoError(err)
+	suite.Equal(token.UserID, stored.UserID)
+	suite.Equal(family, stored.FamilyID)
+	suite.True(token.ExpiresAt.Equal(stored.ExpiresAt))
+	suite.False(stored.Revoked)
+
+	_, err = suite.repo.GetRefreshToken(context.Background(), "missing")
+	suite.ErrorIs(err, domain.ErrNotFound)
+
+	// Only the first revocation wins
+	suite.Require().NoError(suite.repo.RevokeRefreshToken(context.Background(), "hash-1"))
+	suite.ErrorIs(suite.repo.RevokeRefreshToken(context.Background(), "hash-1"), domain.ErrNotFound)
+	suite.ErrorIs(suite.repo.RevokeRefreshToken(context.Background(), "missing"), domain.ErrNotFound)
+
+	stored, err = suite.repo.GetRefreshToken(context.Background(), "hash-1")
+	suite.Require().NoError(err)
+	suite.True(stored.Revoked)
+}
+
+func (suite *TokenRepoContractSuite) TestRevokeTokenFamily() {
+	family := domain.NewID()
+	other := domain.NewID()
+	expires := time.Now().Add(time.Hour)
+	for hash, familyID := range map[string]domain.ID{"a": family, "b": family, "c": other} {
+		suite.Require().NoError(suite.repo.SaveRefreshToken(context.Background(), domain.RefreshToken{
+			TokenHash: hash, UserID: domain.NewID(), FamilyID: familyID, ExpiresAt: expires,
+		}))
+	}
+
+	suite.Require().NoError(suite.repo.RevokeTokenFamily(context.Background(), family))
+
+	for hash, revoked := range map[string]bool{"a": true, "b": true, "c": false} {
+		stored, err := suite.repo.GetRefreshToken(context.Background(), hash)
+		suite.Require().NoError(err)
+		suite.Equal(revoked, stored.Revoked, hash)
+	}
+}
+
+func (suite *TokenRepoContractSuite) TestRevokeUserTokens() {
+	user := domain.NewID()
+	expires := time.Now().Add(time.Hour)
+	for hash, userID := range map[string]domain.ID{"a": user, "b": user, "c": domain.NewID()} {
+		suite.Require().NoError(suite.repo.SaveRefreshToken(context.Background(), domain.RefreshToken{
+			TokenHash: hash, UserID: userID, FamilyID: domain.NewID(), ExpiresAt: expires,
+		}))
+	}
+
+	suite.Require().NoError(suite.repo.RevokeUserTokens(context.Background(), user))
+
+	for hash, revoked := range map[string]bool{"a": true, "b": true, "c": false} {
+		stored, err := suite.repo.GetRefreshToken(context.Background(), hash)
+		suite.Require().NoError(err)
+		suite.Equal(revoked, stored.Revoked, hash)
+	}
+}
+
+func (suite *TokenRepoContractSuite) TestAccessTokenRevocation() {
+	revoked, err := suite.repo.IsAccessTokenRevoked(context.Background(), "jti-1")
+	suite.Require().NoError(err)
+	suite.False(revoked)
+
+	expires := time.Now().Add(time.Hour)
+	suite.Require().NoError(suite.repo.RevokeAccessToken(context.Background(), "jti-1", expires))
+	suite.Require().NoError(suite.repo.RevokeAccessToken(context.Background(), "jti-1", expires), "revoking twice is not an error")
+
+	revoked, err = suite.repo.IsAccessTokenRevoked(context.Background(), "jti-1")
+	suite.Require().NoError(err)
+	suite.True(revoked)
+
+	revoked, err = suite.repo.IsAccessTokenRevoked(context.Background(), "jti-2")
+	suite.Require().NoError(err)
+	suite.False(revoked)
+}
+
+func (suite *TokenRepoContractSuite) TestDeleteExpiredTokens() {
+	ctx := context.Background()
+	cutoff := time.Now()
+	for hash, expires := range map[string]time.Time{"expired": cutoff.Add(-time.Minute), "active": cutoff.Add(time.Hour)} {
+		suite.Require().NoError(suite.repo.SaveRefreshToken(ctx, domain.RefreshToken{
+			TokenHash: hash, UserID: domain.NewID(), FamilyID: domain.NewID(), ExpiresAt: expires,
+		}))
+		suite.Require().NoError(suite.repo.RevokeAccessToken(ctx, "jti-"+hash, expires))
+	}
+
+	suite.Require().NoError(suite.repo.DeleteExpiredTokens(ctx, cutoff))
+
+	_, err := suite.repo.GetRefreshToken(ctx, "expired")
+	suite.ErrorIs(err, domain.ErrNotFound)
+	_, err = suite.repo.GetRefreshToken(ctx, "active")
+	suite.NoError(err)
+
+	revoked, err := suite.repo.IsAccessTokenRevoked(ctx, "jti-expired")
+	suite.Require().NoError(err)
+	suite.False(revoked)
+	revoked, err = suite.repo.IsAccessTokenRevoked(ctx, "jti-active")
+	suite.Require().NoError(err)
+	suite.True(revoked)
+}
+
+type ResetTokenRepoContractSuite struct {
+	suite.Suite
+	newRepo func() domain.ResetTokenRepository
+	repo    domain.ResetTokenRepository
+}
+
+func (suite *ResetTokenRepoContractSuite) SetupTest() {
+	suite.repo = suite.newRepo()
+}
+
+func TestMemoryResetTokenRepoContract(t *testing.T) {
+	suite.Run(t, &ResetTokenRepoContractSuite{newRepo: repositories.NewMemoryResetTokenRepository})
+}
+
+func TestSQLiteResetTokenRepoContract(t *testing.T) {
+	suite.Run(t, &ResetTokenRepoContractSuite{newRepo: func() domain.ResetTokenRepository {
+		return repositories.NewSQLResetTokenRepository(newSQLiteDB(t), repositories.SQLite, repositories.DefaultTimeouts)
+	}})
+}
+
+func TestMongoResetTokenRepoContract(t *testing.T) {
+	if testMongoClient == nil {
+		t.Skip("MongoDB not initialized, skipping tests.")
+	}
+	coll := testMongoClient.Database("test_contract").Collection("password_reset_tokens")
+	suite.Run(t, &ResetTokenRepoContractSuite{newRepo: func() domain.ResetTokenRepository {
+		if _, err := coll.DeleteMany(context.Background(), bson.D{}); err != nil {
+			t.Fatalf("Database cleanup failed: %v", err)
+		}
+		return repositories.NewResetTokenRepository(coll, repositories.DefaultTimeouts)
+	}})
+}
+
+func TestPostgresResetTokenRepoContract(t *testing.T) {
+	db := postgresDB(t)
+	suite.Run(t, &ResetTokenRepoContractSuite{newRepo: func() domain.ResetTokenRepository {
+		if _, err := db.Exec("DELETE FROM password_reset_tokens"); err != nil {
+			t.Fatalf("Database cleanup failed: %v", err)
+		}
+		return repositories.NewSQLResetTokenRepository(db, repositories.Postgres, repositories.DefaultTimeouts)
+	}})
+}
+
+func (suite *ResetTokenRepoContractSuite) TestConsumeResetToken() {
+	token := domain.PasswordResetToken{
+		TokenHash: "hash-1",
+		UserID:    domain.NewID(),
+		ExpiresAt: time.Now().Add(time.Hour).UTC().Truncate(time.Millisecond),
+	}
+	suite.Require().NoError(suite.repo.SaveResetToken(context.Background(), token))
+
+	stored, err := suite.repo.ConsumeResetToken(context.Background(), "hash-1")
+	suite.Require().NoError(err)
+	suite.Equal(token.UserID, stored.UserID)
+	suite.True(token.ExpiresAt.Equal(stored.ExpiresAt))
+
+	// Tokens are single use
+	_, err = suite.repo.ConsumeResetToken(context.Background(), "hash-1")
+	suite.ErrorIs(err, domain.ErrNotFound)
+	_, err = suite.repo.ConsumeResetToken(context.Background(), "missing")
+	suite.ErrorIs(err, domain.ErrNotFound)
+}
+
+func (suite *ResetTokenRepoContractSuite) TestDeleteResetTokens() {
+	user := domain.NewID()
+	other := domain.NewID()
+	expires := time.Now().Add(time.Hour)
+	for hash, userID := range map[string]domain.ID{"a": user, "b": user, "c": other} {
+		suite.Require().NoError(suite.repo.SaveResetToken(context.Background(), domain.PasswordResetToken{
+			TokenHash: hash, UserID: userID, ExpiresAt: expires,
+		}))
+	}
+
+	suite.Require().NoError(suite.repo.DeleteResetTokens(context.Background(), user))
+
+	for hash, deleted := range map[string]bool{"a": true, "b": true, "c": false} {
+		_, err := suite.repo.ConsumeResetToken(context.Background(), hash)
+		if deleted {
+			suite.ErrorIs(err, domain.ErrNotFound, hash)
+		} else {
+			suite.NoError(err, hash)
+		}
+	}
+}
+
+type VerificationTokenRepoContractSuite struct {
+	suite.Suite
+	newRepo func() domain.VerificationTokenRepository
+	repo    domain.VerificationTokenRepository
+}
+
+func (suite *VerificationTokenRepoContractSuite) SetupTest() {
+	suite.repo = suite.newRepo()
+}
+
+func TestMemoryVerificationTokenRepoContract(t *testing.T) {
+	suite.Run(t, &VerificationTokenRepoContractSuite{newRepo: repositories.NewMemoryVerificationTokenRepository})
+}
+
+func TestSQLiteVerificationTokenRepoContract(t *testing.T) {
+	suite.Run(t, &VerificationTokenRepoContractSuite{newRepo: func() domain.VerificationTokenRepository {
+		return repositories.NewSQLVerificationTokenRepository(newSQLiteDB(t), repositories.SQLite, repositories.DefaultTimeouts)
+	}})
+}
+
+func TestMongoVerificationTokenRepoContract(t *testing.T) {
+	if testMongoClient == nil {
+		t.Skip("MongoDB not initialized, skipping tests.")
+	}
+	coll := testMongoClient.Database("test_contract").Collection("email_verification_tokens")
+	suite.Run(t, &VerificationTokenRepoContractSuite{newRepo: func() domain.VerificationTokenRepository {
+		if _, err := coll.DeleteMany(context.Background(), bson.D{}); err != nil {
+			t.Fatalf("Database cleanup failed: %v", err)
+		}
+		return repositories.NewVerificationTokenRepository(coll, repositories.DefaultTimeouts)
+	}})
+}
+
+func TestPostgresVerificationTokenRepoContract(t *testing.T) {
+	db := postgresDB(t)
+	suite.Run(t, &VerificationTokenRepoContractSuite{newRepo: func() domain.VerificationTokenRepository {
+		if _, err := db.Exec("DELETE FROM email_verification_tokens"); err != nil {
+			t.Fatalf("Database cleanup failed: %v", err)
+		}
+		return repositories.NewSQLVerificationTokenRepository(db, repositories.Postgres, repositories.DefaultTimeouts)
+	}})
+}
+
+func (suite *VerificationTokenRepoContractSuite) TestConsumeVerificationToken() {
+	token := domain.EmailVerificationToken{
+		TokenHash: "hash-1",
+		UserID:    domain.NewID(),
+		Email:     "jane@example.com",
+		ExpiresAt: time.Now().Add(time.Hour).UTC().Truncate(time.Millisecond),
+	}
+	suite.Require().NoError(suite.repo.SaveVerificationToken(context.Background(), token))
+
+	stored, err := suite.repo.ConsumeVerificationToken(context.Background(), "hash-1")
+	suite.Require().NoError(err)
+	suite.Equal(token.UserID, stored.UserID)
+	suite.Equal(token.Email, stored.Email)
+	suite.True(token.ExpiresAt.Equal(stored.ExpiresAt))
+
+	// Tokens are single use
+	_, err = suite.repo.ConsumeVerificationToken(context.Background(), "hash-1")
+	suite.ErrorIs(err, domain.ErrNotFound)
+	_, err = suite.repo.ConsumeVerificationToken(context.Background(), "missing")
+	suite.ErrorIs(err, domain.ErrNotFound)
+}
+
+func (suite *VerificationTokenRepoContractSuite) TestDeleteVerificationTokens() {
+	user := domain.NewID()
+	other := domain.NewID()
+	expires := time.Now().Add(time.Hour)
+	for hash, userID := range map[string]domain.ID{"a": user, "b": user, "c": other} {
+		suite.Require().NoError(suite.repo.SaveVerificationToken(context.Background(), domain.EmailVerificationToken{
+			TokenHash: hash, UserID: userID, Email: "jane@example.com", ExpiresAt: expires,
+		}))
+	}
+
+	suite.Require().NoError(suite.repo.DeleteVerificationTokens(context.Background(), user))
+
+	for hash, deleted := range map[string]bool{"a": true, "b": true, "c": false} {
+		_, err := suite.repo.ConsumeVerificationToken(context.Background(), hash)
+		if deleted {
+			suite.ErrorIs(err, domain.ErrNotFound, hash)
+		} else {
+			suite.NoError(err, hash)
+		}
+	}
+}
+
+type MFARepoContractSuite struct {
+	suite.Suite
+	newRepo func() domain.MFARepository
+	repo    domain.MFARepository
+}
+
+func (suite *MFARepoContractSuite) SetupTest() {
+	suite.repo = suite.newRepo()
+}
+
+func TestMemoryMFARepoContract(t *testing.T) {
+	suite.Run(t, &MFARepoContractSuite{newRepo: repositories.NewMemoryMFARepository})
+}
+
+func TestSQLiteMFARepoContract(t *testing.T) {
+	suite.Run(t, &MFARepoContractSuite{newRepo: func() domain.MFARepository {
+		return repositories.NewSQLMFARepository(newSQLiteDB(t), repositories.SQLite, repositories.DefaultTimeouts)
+	}})
+}
+
+func TestMongoMFARepoContract(t *testing.T) {
+	if testMongoClient == nil {
+		t.Skip("MongoDB not initialized, skipping tests.")
+	}
+	enrollments := testMongoClient.Database("test_contract").Collection("mfa_enrollments")
+	challenges := testMongoClient.Database("test_contract").Collection("mfa_challenges")
+	suite.Run(t, &MFARepoContractSuite{newRepo: func() domain.MFARepository {
+		for _, coll := range []*mongo.Collection{enrollments, challenges} {
+			if _, err := coll.DeleteMany(context.Background(), bson.D{}); err != nil {
+				t.Fatalf("Database cleanup failed: %v", err)
+			}
+		}
+		return repositories.NewMFARepository(enrollments, challenges, repositories.DefaultTimeouts)
+	}})
+}
+
+func TestPostgresMFARepoContract(t *testing.T) {
+	db := postgresDB(t)
+	suite.Run(t, &MFARepoContractSuite{newRepo: func() domain.MFARepository {
+		for _, table := range []string{"mfa_enrollments", "mfa_recovery_codes", "mfa_challenges"} {
+			if _, err := db.Exec("DELETE FROM " + table); err != nil {
+				t.Fatalf("Database cleanup failed: %v", err)
+			}
+		}
+		return repositories.NewSQLMFARepository(db, repositories.Postgres, repositories.DefaultTimeouts)
+	}})
+}
+
+func (suite *MFARepoContractSuite) TestEnrollmentLifecycle() {
+	user := domain.NewID()
+	_, err := suite.repo.GetEnrollment(context.Background(), user)
+	suite.ErrorIs(err, domain.ErrNotFound)
+
+	suite.Require().NoError(suite.repo.SaveEnrollment(context.Background(), domain.MFAEnrollment{UserID: user, Secret: "first"}))
+	stored, err := suite.repo.GetEnrollment(context.Background(), user)
+	suite.Require().NoError(err)
+	suite.Equal("first", stored.Secret)
+	suite.False(stored.Confirmed)
+	suite.Empty(stored.RecoveryCodes)
+
+	// Saving replaces the enrollment along with its recovery codes
+	confirmed := domain.MFAEnrollment{UserID: user, Secret: "second", Confirmed: true, RecoveryCodes: []string{"a", "b"}, LastStep: 7}
+	suite.Require().NoError(suite.repo.SaveEnrollment(context.Background(), confirmed))
+	stored, err = suite.repo.GetEnrollment(context.Background(), user)
+	suite.Require().NoError(err)
+	suite.Equal(confirmed.Secret, stored.Secret)
+	suite.True(stored.Confirmed)
+	suite.ElementsMatch(confirmed.RecoveryCodes, stored.RecoveryCodes)
+	suite.Equal(int64(7), stored.LastStep)
+
+	suite.Require().NoError(suite.repo.DeleteEnrollment(context.Background(), user))
+	_, err = suite.repo.GetEnrollment(context.Background(), user)
+	suite.ErrorIs(err, domain.ErrNotFound)
+	suite.NoError(suite.repo.DeleteEnrollment(context.Background(), user), "deleting is idempotent")
+}
+
+func (suite *MFARepoContractSuite) TestUseTOTPStep() {
+	user := domain.NewID()
+	suite.Require().NoError(suite.repo.SaveEnrollment(context.Background(), domain.MFAEnrollment{UserID: user, Secret: "s", Confirmed: true, LastStep: 10}))
+
+	suite.ErrorIs(suite.repo.UseTOTPStep(context.Background(), user, 10), domain.ErrMFACodeReused)
+	suite.ErrorIs(suite.repo.UseTOTPStep(context.Background(), user, 9), domain.ErrMFACodeReused)
+	suite.NoError(suite.repo.UseTOTPStep(context.Background(), user, 11))
+	suite.ErrorIs(suite.repo.UseTOTPStep(context.Background(), user, 11), domain.ErrMFACodeReused)
+	suite.ErrorIs(suite.repo.UseTOTPStep(context.Background(), domain.NewID(), 12), domain.ErrMFACodeReused)
+
+	stored, err := suite.repo.GetEnrollment(context.Background(), user)
+	suite.Require().NoError(err)
+	suite.Equal(int64(11), stored.LastStep)
+}
+
+func (suite *MFARepoContractSuite) TestUseRecoveryCode() {
+	user := domain.NewID()
+	other := domain.NewID()
+	suite.Require().NoError(suite.repo.SaveEnrollment(context.Background(), domain.MFAEnrollment{UserID: user, Secret: "s", Confirmed: true, RecoveryCodes: []string{"a", "b"}}))
+	suite.Require().NoError(suite.repo.SaveEnrollment(context.Background(), domain.MFAEnrollment{UserID: other, Secret: "s", Confirmed: true, RecoveryCodes: []string{"c"}}))
+
+	suite.NoError(suite.repo.UseRecoveryCode(context.Background(), user, "a"))
+	suite.ErrorIs(suite.repo.UseRecoveryCode(context.Background(), user, "a"), domain.ErrNotFound)
+	suite.ErrorIs(suite.repo.UseRecoveryCode(context.Background(), user, "c"), domain.ErrNotFound, "codes belong to one user")
+	suite.ErrorIs(suite.repo.UseRecoveryCode(context.Background(), domain.NewID(), "b"), domain.ErrNotFound)
+
+	stored, err := suite.repo.GetEnrollment(context.Background(), user)
+	suite.Require().NoError(err)
+	suite.Equal([]string{"b"}, stored.RecoveryCodes)
+}
+
+func (suite *MFARepoContractSuite) TestConsumeMFAChallenge() {
+	challenge := domain.MFAChallenge{
+		TokenHash: "hash-1",
+		UserID:    domain.NewID(),
+		Username:  "jane",
+		ClientIP:  "192.0.2.1",
+		ExpiresAt: time.Now().Add(time.Minute).UTC().Truncate(time.Millisecond),
+		Attempts:  2,
+	}
+	suite.Require().NoError(suite.repo.SaveMFAChallenge(context.Background(), challenge))
+
+	stored, err := suite.repo.ConsumeMFAChallenge(context.Background(), "hash-1")
+	suite.Require().NoError(err)
+	suite.Equal(challenge.UserID, stored.UserID)
+	suite.Equal("jane", stored.Username)
+	suite.Equal("192.0.2.1", stored.ClientIP)
+	suite.Equal(2, stored.Attempts)
+	suite.True(challenge.ExpiresAt.Equal(stored.ExpiresAt))
+
+	// Challenges are single use
+	_, err = suite.repo.ConsumeMFAChallenge(context.Background(), "hash-1")
+	suite.ErrorIs(err, domain.ErrNotFound)
+	_, err = suite.repo.ConsumeMFAChallenge(context.Background(), "missing")
+	suite.ErrorIs(err, domain.ErrNotFound)
+
+	// and can be saved again for another attempt
+	challenge.Attempts++
+	suite.Require().NoError(suite.repo.SaveMFAChallenge(context.Background(), challenge))
+	stored, err = suite.repo.ConsumeMFAChallenge(context.Background(), "hash-1")
+	suite.Require().NoError(err)
+	suite.Equal(3, stored.Attempts)
+}
+
+type LoginThrottleRepoContractSuite struct {
+	suite.Suite
+	newRepo func() domain.LoginThrottleRepository
+	repo    domain.LoginThrottleRepository
+}
+
+func (suite *LoginThrottleRepoContractSuite) SetupTest() {
+	suite.repo = suite.newRepo()
+}
+
+func TestMemoryLoginThrottleRepoContract(t *testing.T) {
+	suite.Run(t, &LoginThrottleRepoContractSuite{newRepo: repositories.NewMemoryLoginThrottleRepository})
+}
+
+func TestSQLiteLoginThrottleRepoContract(t *testing.T) {
+	suite.Run(t, &LoginThrottleRepoContractSuite{newRepo: func() domain.LoginThrottleRepository {
+		return repositories.NewSQLLoginThrottleRepository(newSQLiteDB(t), repositories.SQLite, repositories.DefaultTimeouts)
+	}})
+}
+
+func TestMongoLoginThrottleRepoContract(t *testing.T) {
+	if testMongoClient == nil {
+		t.Skip("MongoDB not initialized, skipping tests.")
+	}
+	coll := testMongoClient.Database("test_contract").Collection("login_throttles")
+	suite.Run(t, &LoginThrottleRepoContractSuite{newRepo: func() domain.LoginThrottleRepository {
+		if _, err := coll.DeleteMany(context.Background(), bson.D{}); err != nil {
+			t.Fatalf("Database cleanup failed: %v", err)
+		}
+		return repositories.NewLoginThrottleRepository(coll, repositories.DefaultTimeouts)
+	}})
+}
+
+func TestPostgresLoginThrottleRepoContract(t *testing.T) {
+	db := postgresDB(t)
+	suite.Run(t, &LoginThrottleRepoContractSuite{newRepo: func() domain.LoginThrottleRepository {
+		if _, err := db.Exec("DELETE FROM login_throttles"); err != nil {
+			t.Fatalf("Database cleanup failed: %v", err)
+		}
+		return repositories.NewSQLLoginThrottleRepository(db, repositories.Postgres, repositories.DefaultTimeouts)
+	}})
+}
+
+func (suite *LoginThrottleRepoContractSuite) TestRecordLoginFailure() {
+	_, err := suite.repo.GetLoginThrottle(context.Background(), "user:jane")
+	suite.ErrorIs(err, domain.ErrNotFound)
+
+	start := time.Now().UTC().Truncate(time.Millisecond)
+	for i := 1; i <= 3; i++ {
+		at := start.Add(time.Duration(i) * time.Second)
+		throttle, err := suite.repo.RecordLoginFailure(context.Background(), "user:jane", at, time.Hour)
+		suite.Require().NoError(err)
+		suite.Equal(domain.LoginThrottle{Key: "user:jane", Failures: i, LastFailure: at, ExpiresAt: at.Add(time.Hour)}, throttle)
+	}
+
+	stored, err := suite.repo.GetLoginThrottle(context.Background(), "user:jane")
+	suite.Require().NoError(err)
+	suite.Equal(3, stored.Failures)
+	suite.True(start.Add(3 * time.Second).Equal(stored.LastFailure))
+
+	// Keys are counted separately
+	other, err := suite.repo.RecordLoginFailure(context.Background(), "ip:192.0.2.1", start, time.Hour)
+	suite.Require().NoError(err)
+	suite.Equal(1, other.Failures)
+}
+
+func (suite *LoginThrottleRepoContractSuite) TestExpiredCountsStartOver() {
+	start := time.Now().UTC().Truncate(time.Millisecond)
+	for i := 0; i < 2; i++ {
+		_, err := suite.repo.RecordLoginFailure(context.Background(), "user:jane", start, time.Minute)
+		suite.Require().NoError(err)
+	}
+
+	throttle, err := suite.repo.RecordLoginFailure(context.Background(), "user:jane", start.Add(2*time.Minute), time.Minute)
+	suite.Require().NoError(err)
+	suite.Equal(1, throttle.Failures)
+}
+
+func (suite *LoginThrottleRepoContractSuite) TestConcurrentFailuresAreAllCounted() {
+	const n = 10
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		go func() {
+			_, err := suite.repo.RecordLoginFailure(context.Background(), "user:jane", time.Now(), time.Hour)
+			errs <- err
+		}()
+	}
+	for i := 0; i < n; i++ {
+		suite.Require().NoError(<-errs)
+	}
+
+	throttle, err := suite.repo.GetLoginThrottle(context.Background(), "user:jane")
+	suite.Require().NoError(err)
+	suite.Equal(n, throttle.Failures)
+}
+
+func (suite *LoginThrottleRepoContractSuite) TestClearLoginThrottle() {
+	for _, key := range []string{"user:jane", "user:john"} {
+		_, err := suite.repo.RecordLoginFailure(context.Background(), key, time.Now(), time.Hour)
+		suite.Require().NoError(err)
+	}
+
+	suite.Require().NoError(suite.repo.ClearLoginThrottle(context.Background(), "user:jane"))
+	_, err := suite.repo.GetLoginThrottle(context.Background(), "user:jane")
+	suite.ErrorIs(err, domain.ErrNotFound)
+	_, err = suite.repo.GetLoginThrottle(context.Background(), "user:john")
+	suite.NoError(err)
+	suite.NoError(suite.repo.ClearLoginThrottle(context.Background(), "user:jane"), "clearing is idempotent")
+}
@@ -0,0 +1,230 @@
+package test_repositories
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	domain "task-manager/Domain"
+	repositories "task-manager/Repositories"
+
+	"github.com/stretchr/testify/suite"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type MigrationTestSuite struct {
+	suite.Suite
+	db *mongo.Database
+}
+
+func TestMigrationTestSuite(t *testing.T) {
+	if testMongoClient == nil {
+		t.Skip("MongoDB not initialized, skipping tests.")
+	}
+	suite.Run(t, new(MigrationTestSuite))
+}
+
+func (suite *MigrationTestSuite) SetupTest() {
+	suite.db = testMongoClient.Database("test_migrations")
+	suite.Require().NoError(suite.db.Drop(context.Background()))
+}
+
+func (suite *MigrationTestSuite) TearDownSuite() {
+	_ = suite.db.Drop(context.Background())
+}
+
+func (suite *MigrationTestSuite) TestLegacyDueDatesAreConverted() {
+	ctx := context.Background()
+	tasks := suite.db.Collection("tasks")
+
+	parsable := primitive.NewObjectID()
+	garbage := primitive.NewObjectID()
+	_, err := tasks.InsertMany(ctx, []interface{}{
+		bson.M{"_id": parsable, "title": "Parsable", "due_date": "July 17, 2024"},
+		bson.M{"_id": garbage, "title": "Garbage", "due_date": "tomorrow-ish"},
+	})
+	suite.Require().NoError(err)
+
+	suite.Require().NoError(repositories.RunMigrations(ctx, suite.db, repositories.DefaultCollectionNames))
+
+	var converted bson.M
+	suite.Require().NoError(tasks.FindOne(ctx, bson.M{"_id": parsable}).Decode(&converted))
+	suite.Equal(primitive.NewDateTimeFromTime(time.Date(2024, time.July, 17, 0, 0, 0, 0, time.UTC)), converted["due_date"])
+	suite.NotNil(converted["created_at"])
+
+	var kept bson.M
+	suite.Require().NoError(tasks.FindOne(ctx, bson.M{"_id": garbage}).Decode(&kept))
+	suite.NotContains(kept, "due_date")
+	suite.Equal("tomorrow-ish", kept["legacy_due_date"])
+
+	// A second run is a no-op because the migration is recorded
+	suite.Require().NoError(repositories.RunMigrations(ctx, suite.db, repositories.DefaultCollectionNames))
+	count, err := suite.db.Collection("migrations").CountDocuments(ctx, bson.M{})
+	suite.Require().NoError(err)
+	suite.Equal(int64(len(repositories.Migrations)), count)
+}
+
+func (suite *MigrationTestSuite) TestLegacyStatusesAreMapped() {
+	ctx := context.Background()
+	tasks := suite.db.Collection("tasks")
+
+	_, err := tasks.InsertMany(ctx, []interface{}{
+		bson.M{"title": "Pending", "status": "pending"},
+		bson.M{"title": "Working", "status": "in-progress"},
+		bson.M{"title": "Finished", "status": "completed"},
+		bson.M{"title": "Odd", "status": "someday"},
+	})
+	suite.Require().NoError(err)
+
+	suite.Require().NoError(repositories.RunMigrations(ctx, suite.db, repositories.DefaultCollectionNames))
+
+	expected := map[string]domain.TaskStatus{
+		"Pending":  domain.StatusTodo,
+		"Working":  domain.StatusInProgress,
+		"Finished": domain.StatusDone,
+		"Odd":      domain.StatusTodo,
+	}
+	for title, status := range expected {
+		var task bson.M
+		suite.Require().NoError(tasks.FindOne(ctx, bson.M{"title": title}).Decode(&task))
+		suite.Equal(string(status), task["status"], title)
+	}
+
+	var odd bson.M
+	suite.Require().NoError(tasks.FindOne(ctx, bson.M{"title": "Odd"}).Decode(&odd))
+	suite.Equal("someday", odd["legacy_status"])
+}
+
+func (suite *MigrationTestSuite) TestLegacyTasksGetAnOwner() {
+	ctx := context.Background()
+	tasks := suite.db.Collection("tasks")
+
+	first := primitive.NewObjectID()
+	_, err := suite.db.Collection("users").InsertMany(ctx, []interface{}{
+		bson.M{"_id": first, "username": "root", "role": "admin"},
+		bson.M{"_id": primitive.NewObjectID(), "username": "later", "role": "admin"},
+	})
+	suite.Require().NoError(err)
+
+	owner := primitive.NewObjectID()
+	_, err = tasks.InsertMany(ctx, []interface{}{
+		bson.M{"title": "Orphan"},
+		bson.M{"title": "Owned", "created_by": owner},
+	})
+	suite.Require().NoError(err)
+
+	suite.Require().NoError(repositories.RunMigrations(ctx, suite.db, repositories.DefaultCollectionNames))
+
+	var orphan, owned bson.M
+	suite.Require().NoError(tasks.FindOne(ctx, bson.M{"title": "Orphan"}).Decode(&orphan))
+	suite.Require().NoError(tasks.FindOne(ctx, bson.M{"title": "Owned"}).Decode(&owned))
+	suite.Equal(first, orphan["created_by"])
+	suite.Equal(owner, owned["created_by"])
+}
+
+func (suite *MigrationTestSuite) TestTaskOwnersWaitForAnAdmin() {
+	ctx := context.Background()
+	tasks := suite.db.Collection("tasks")
+
+	_, err := tasks.InsertOne(ctx, bson.M{"title": "Orphan"})
+	suite.Require().NoError(err)
+
+	suite.Require().NoError(repositories.RunMigrations(ctx, suite.db, repositories.DefaultCollectionNames))
+	var orphan bson.M
+	suite.Require().NoError(tasks.FindOne(ctx, bson.M{"title": "Orphan"}).Decode(&orphan))
+	suite.NotContains(orphan, "created_by")
+
+	pending, err := repositories.PendingMigrations(ctx, suite.db, repositories.DefaultCollectionNames)
+	suite.Require().NoError(err)
+	suite.Empty(pending, "deferred migrations don't hold up readiness")
+
+	// The next start after an admin exists completes it
+	admin := primitive.NewObjectID()
+	_, err = suite.db.Collection("users").InsertOne(ctx, bson.M{"_id": admin, "username": "root", "role": "admin"})
+	suite.Require().NoError(err)
+	suite.Require().NoError(repositories.RunMigrations(ctx, suite.db, repositories.DefaultCollectionNames))
+
+	suite.Require().NoError(tasks.FindOne(ctx, bson.M{"title": "Orphan"}).Decode(&orphan))
+	suite.Equal(admin, orphan["created_by"])
+	var record bson.M
+	suite.Require().NoError(suite.db.Collection("migrations").FindOne(ctx, bson.M{"_id": "0004_task_owners"}).Decode(&record))
+	suite.NotContains(record, "deferred")
+}
+
+func (suite *MigrationTestSuite) TestUsernamesBecomeUnique() {
+	ctx := context.Background()
+	users := suite.db.Collection("users")
+
+	_, err := users.InsertMany(ctx, []interface{}{
+		bson.M{"username": "twin", "role": "member"},
+		bson.M{"username": "twin", "role": "member"},
+	})
+	suite.Require().NoError(err)
+
+	err = repositories.RunMigrations(ctx, suite.db, repositories.DefaultCollectionNames)
+	suite.Require().Error(err, "duplicates are reported rather than dropped")
+	suite.Contains(err.Error(), `"twin"`)
+
+	_, err = users.DeleteOne(ctx, bson.M{"username": "twin"})
+	suite.Require().NoError(err)
+	suite.Require().NoError(repositories.RunMigrations(ctx, suite.db, repositories.DefaultCollectionNames))
+
+	_, err = users.InsertOne(ctx, bson.M{"username": "twin", "role": "member"})
+	suite.True(mongo.IsDuplicateKeyError(err))
+}
+
+func (suite *MigrationTestSuite) TestPendingMigrations() {
+	ctx := context.Background()
+
+	pending, err := repositories.PendingMigrations(ctx, suite.db, repositories.DefaultCollectionNames)
+	suite.Require().NoError(err)
+	suite.Len(pending, len(repositories.Migrations))
+
+	suite.Require().NoError(repositories.RunMigrations(ctx, suite.db, repositories.DefaultCollectionNames))
+	pending, err = repositories.PendingMigrations(ctx, suite.db, repositories.DefaultCollectionNames)
+	suite.Require().NoError(err)
+	suite.Empty(pending)
+}
+
+func TestSQLMigrationsRunOnce(t *testing.T) {
+	db := newSQLiteDB(t)
+
+	// newSQLiteDB already migrated; a second run must be a no-op
+	if err := repositories.RunSQLMigrations(context.Background(), db, repositories.SQLite); err != nil {
+		t.Fatalf("Re-running migrations failed: %v", err)
+	}
+
+	var applied int
+	if err := db.QueryRow("SELECT COUNT(*) FROM schema_migrations").Scan(&applied); err != nil {
+		t.Fatalf("Reading schema_migrations failed: %v", err)
+	}
+	if applied != len(repositories.SQLMigrations) {
+		t.Errorf("expected %d applied migrations, got %d", len(repositories.SQLMigrations), applied)
+	}
+}
+
+func TestPendingSQLMigrations(t *testing.T) {
+	db := newSQLiteDB(t)
+
+	pending, err := repositories.PendingSQLMigrations(context.Background(), db, repositories.SQLite)
+	if err != nil {
+		t.Fatalf("Listing pending migrations failed: %v", err)
+	}
+	if len(pending) != 0 {
+		t.Errorf("expected no pending migrations, got %v", pending)
+	}
+
+	last := repositories.SQLMigrations[len(repositories.SQLMigrations)-1].ID
+	if _, err := db.Exec("DELETE FROM schema_migrations WHERE id = ?", last); err != nil {
+		t.Fatalf("Forgetting a migration failed: %v", err)
+	}
+	pending, err = repositories.PendingSQLMigrations(context.Background(), db, repositories.SQLite)
+	if err != nil {
+		t.Fatalf("Listing pending migrations failed: %v", err)
+	}
+	if len(pending) != 1 || pending[0] != last {
+		t.Errorf("expected %s to be pending, got %v", last, pending)
+	}
+}
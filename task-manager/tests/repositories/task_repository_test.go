@@ -5,6 +5,7 @@ import (
 	"log"
 	"os"
 	"testing"
+	"time"
 
 	domain "task-manager/Domain"
 	repositories "task-manager/Repositories"
@@ -19,14 +20,17 @@ import (
 
 var testMongoClient *mongo.Client
 
+// TestMain connects to MongoDB when MONGODB_URI is set. Without it the Mongo
+// suites skip and only the in-memory contract tests run.
 func TestMain(m *testing.M) {
 	if err := godotenv.Load("../../.env"); err != nil {
-		log.Fatal("Unable to load environment config")
+		log.Printf("Could not load .env file: %v", err)
 	}
 
 	connStr := os.Getenv("MONGODB_URI")
 	if connStr == "" {
-		log.Fatal("MONGODB_URI not found in environment")
+		log.Println("MONGODB_URI not set, skipping MongoDB tests")
+		os.Exit(m.Run())
 	}
 
 	client, err := mongo.Connect(context.Background(), options.Client().ApplyURI(connStr))
@@ -77,35 +81,78 @@ func (suite *TaskRepoTestSuite) SetupSuite() {
 func (suite *TaskRepoTestSuite) SetupTest() {
 	_, err := suite.coll.DeleteMany(context.Background(), bson.D{})
 	suite.Require().NoError(err, "Database cleanup failed")
-	suite.repo = repositories.NewTaskRepository(suite.coll)
+	suite.repo = repositories.NewTaskRepository(suite.coll, repositories.DefaultTimeouts)
+}
+
+// insertTasks writes documents directly, bypassing the repository, in the
+// layout the repository stores them with
+func (suite *TaskRepoTestSuite) insertTasks(tasks ...domain.Task) {
+	docs := make([]interface{}, 0, len(tasks))
+	for _, task := range tasks {
+		doc := bson.M{
+			"_id":         objectID(suite.T(), task.ID),
+			"title":       task.Title,
+			"description": task.Description,
+			"status":      task.Status,
+		}
+		if task.DueDate != nil {
+			doc["due_date"] = *task.DueDate
+		}
+		if !task.CreatedBy.IsZero() {
+			doc["created_by"] = objectID(suite.T(), task.CreatedBy)
+		}
+		if !task.AssigneeID.IsZero() {
+			doc["assignee_id"] = objectID(suite.T(), task.AssigneeID)
+		}
+		docs = append(docs, doc)
+	}
+
+	_, err := suite.coll.InsertMany(context.Background(), docs)
+	suite.Require().NoError(err)
+}
+
+func objectID(t *testing.T, id domain.ID) primitive.ObjectID {
+	objID, err := primitive.ObjectIDFromHex(id.String())
+	if err != nil {
+		t.Fatalf("invalid ObjectID %q: %v", id, err)
+	}
+	return objID
 }
 
 func (suite *TaskRepoTestSuite) TestTaskCreation() {
-	input := &domain.Task{
+	due := time.Date(2024, time.July, 17, 0, 0, 0, 0, time.UTC)
+	input := domain.Task{
 		Title:       "Integration Task",
 		Description: "Testing task creation flow",
-		DueDate:     "July 17, 2024",
-		Status:      "pending",
+		DueDate:     &due,
+		Status:      domain.StatusTodo,
 	}
 
-	result, err := suite.repo.CreateTask(*input)
+	result, err := suite.repo.CreateTask(context.Background(), input)
 
 	suite.Require().NoError(err)
 	suite.NotNil(result)
 	suite.False(result.ID.IsZero(), "Task ID should be generated")
 	suite.Equal(input.Title, result.Title)
+	suite.False(result.CreatedAt.IsZero(), "CreatedAt should be set")
+	suite.Equal(result.CreatedAt, result.UpdatedAt)
+
+	stored, err := suite.repo.GetTaskByID(context.Background(), result.ID.String())
+	suite.Require().NoError(err)
+	suite.Require().NotNil(stored.DueDate)
+	suite.True(due.Equal(*stored.DueDate))
+	suite.True(result.CreatedAt.Equal(stored.CreatedAt))
 }
 
 func (suite *TaskRepoTestSuite) TestGetTaskByID() {
 	suite.Run("Should return task if present", func() {
-		task := &domain.Task{
-			ID:    primitive.NewObjectID(),
+		task := domain.Task{
+			ID:    domain.NewID(),
 			Title: "Lookup Task",
 		}
-		_, err := suite.coll.InsertOne(context.Background(), task)
-		suite.Require().NoError(err)
+		suite.insertTasks(task)
 
-		retrieved, err := suite.repo.GetTaskByID(task.ID.Hex())
+		retrieved, err := suite.repo.GetTaskByID(context.Background(), task.ID.String())
 
 		suite.Require().NoError(err)
 		suite.Require().NotNil(retrieved)
@@ -113,58 +160,183 @@ func (suite *TaskRepoTestSuite) TestGetTaskByID() {
 	})
 
 	suite.Run("Should return error if task is missing", func() {
-		unknownID := primitive.NewObjectID().Hex()
-		_, err := suite.repo.GetTaskByID(unknownID)
+		unknownID := domain.NewID().String()
+		_, err := suite.repo.GetTaskByID(context.Background(), unknownID)
 
 		suite.Require().Error(err)
 	})
 }
 
 func (suite *TaskRepoTestSuite) TestRetrieveAllTasks() {
-	docs := []interface{}{
-		&domain.Task{ID: primitive.NewObjectID(), Title: "Alpha"},
-		&domain.Task{ID: primitive.NewObjectID(), Title: "Beta"},
-	}
-	_, err := suite.coll.InsertMany(context.Background(), docs)
+	suite.insertTasks(
+		domain.Task{ID: domain.NewID(), Title: "Alpha"},
+		domain.Task{ID: domain.NewID(), Title: "Beta"},
+	)
+
+	tasks, total, err := suite.repo.QueryTasks(context.Background(), domain.TaskQuery{})
 	suite.Require().NoError(err)
+	suite.Len(tasks, 2)
+	suite.Equal(int64(2), total)
+}
 
-	tasks, err := suite.repo.GetAllTasks()
+func (suite *TaskRepoTestSuite) TestRetrieveTasksByUser() {
+	owner := domain.NewID()
+	assignee := domain.NewID()
+	suite.insertTasks(
+		domain.Task{ID: domain.NewID(), Title: "Created", CreatedBy: owner},
+		domain.Task{ID: domain.NewID(), Title: "Assigned", CreatedBy: assignee, AssigneeID: owner},
+		domain.Task{ID: domain.NewID(), Title: "Unrelated", CreatedBy: assignee},
+	)
+
+	tasks, total, err := suite.repo.QueryTasks(context.Background(), domain.TaskQuery{VisibleTo: owner.String()})
 	suite.Require().NoError(err)
 	suite.Len(tasks, 2)
+	suite.Equal(int64(2), total)
+
+	_, _, err = suite.repo.QueryTasks(context.Background(), domain.TaskQuery{VisibleTo: "not-an-id"})
+	suite.Error(err)
+}
+
+func (suite *TaskRepoTestSuite) TestQueryTasks() {
+	date := func(month time.Month) *time.Time {
+		d := time.Date(2024, month, 10, 0, 0, 0, 0, time.UTC)
+		return &d
+	}
+	suite.insertTasks(
+		domain.Task{ID: domain.NewID(), Title: "Write report", DueDate: date(time.January), Status: domain.StatusTodo},
+		domain.Task{ID: domain.NewID(), Title: "Review REPORT", DueDate: date(time.February), Status: domain.StatusTodo},
+		domain.Task{ID: domain.NewID(), Title: "Ship release", DueDate: date(time.March), Status: domain.StatusDone},
+	)
+
+	suite.Run("Filters by status and title", func() {
+		tasks, total, err := suite.repo.QueryTasks(context.Background(), domain.TaskQuery{Status: domain.StatusTodo, Title: "report"})
+		suite.Require().NoError(err)
+		suite.Equal(int64(2), total)
+		suite.Len(tasks, 2)
+	})
+
+	suite.Run("Filters by due date range", func() {
+		tasks, _, err := suite.repo.QueryTasks(context.Background(), domain.TaskQuery{
+			DueFrom: time.Date(2024, time.February, 1, 0, 0, 0, 0, time.UTC),
+			DueTo:   time.Date(2024, time.March, 31, 0, 0, 0, 0, time.UTC),
+		})
+		suite.Require().NoError(err)
+		suite.Len(tasks, 2)
+	})
+
+	suite.Run("Sorts and paginates", func() {
+		tasks, total, err := suite.repo.QueryTasks(context.Background(), domain.TaskQuery{SortBy: "due_date", SortDesc: true, Limit: 2, Offset: 1})
+		suite.Require().NoError(err)
+		suite.Equal(int64(3), total)
+		suite.Require().Len(tasks, 2)
+		suite.Equal("Review REPORT", tasks[0].Title)
+		suite.Equal("Write report", tasks[1].Title)
+	})
 }
 
 func (suite *TaskRepoTestSuite) TestUpdateTask() {
-	existing := &domain.Task{
-		ID:     primitive.NewObjectID(),
+	existing := domain.Task{
+		ID:     domain.NewID(),
 		Title:  "Initial Title",
-		Status: "pending",
+		Status: domain.StatusTodo,
 	}
-	_, err := suite.coll.InsertOne(context.Background(), existing)
-	suite.Require().NoError(err)
+	suite.insertTasks(existing)
 
-	patch := &domain.Task{
+	patch := domain.Task{
 		Title:  "Final Title",
-		Status: "completed",
+		Status: domain.StatusDone,
 	}
-	updated, err := suite.repo.UpdateTask(existing.ID.Hex(), *patch)
+	updated, err := suite.repo.UpdateTask(context.Background(), existing.ID.String(), patch, 0)
 
 	suite.Require().NoError(err)
 	suite.Require().NotNil(updated)
 	suite.Equal("Final Title", updated.Title)
 	suite.Equal(existing.ID, updated.ID)
+	suite.False(updated.UpdatedAt.IsZero(), "UpdatedAt should be set")
+}
+
+func (suite *TaskRepoTestSuite) TestPatchTask() {
+	due := time.Date(2024, time.May, 1, 0, 0, 0, 0, time.UTC)
+	existing := domain.Task{
+		ID:          domain.NewID(),
+		Title:       "Original",
+		Description: "Keep me",
+		DueDate:     &due,
+		Status:      domain.StatusTodo,
+	}
+	suite.insertTasks(existing)
+
+	status := domain.StatusInProgress
+	patched, err := suite.repo.PatchTask(context.Background(), existing.ID.String(), domain.TaskPatch{Status: &status}, 0)
+	suite.Require().NoError(err)
+	suite.Equal(domain.StatusInProgress, patched.Status)
+	suite.Equal("Original", patched.Title)
+	suite.Equal("Keep me", patched.Description)
+	suite.Require().NotNil(patched.DueDate)
+
+	patched, err = suite.repo.PatchTask(context.Background(), existing.ID.String(), domain.TaskPatch{ClearDueDate: true}, 0)
+	suite.Require().NoError(err)
+	suite.Nil(patched.DueDate)
+
+	_, err = suite.repo.PatchTask(context.Background(), domain.NewID().String(), domain.TaskPatch{Status: &status}, 0)
+	suite.ErrorIs(err, domain.ErrNotFound)
+}
+
+func (suite *TaskRepoTestSuite) TestTransitionTask() {
+	task := domain.Task{ID: domain.NewID(), Title: "Moving", Status: domain.StatusTodo}
+	suite.insertTasks(task)
+
+	moved, err := suite.repo.TransitionTask(context.Background(), task.ID.String(), domain.StatusTodo, domain.StatusInProgress)
+	suite.Require().NoError(err)
+	suite.Equal(domain.StatusInProgress, moved.Status)
+
+	// A stale "from" status means someone else moved the task first
+	_, err = suite.repo.TransitionTask(context.Background(), task.ID.String(), domain.StatusTodo, domain.StatusDone)
+	var transitionErr *domain.TransitionError
+	suite.Require().ErrorAs(err, &transitionErr)
+	suite.Equal(domain.StatusInProgress, transitionErr.From)
+
+	_, err = suite.repo.TransitionTask(context.Background(), domain.NewID().String(), domain.StatusTodo, domain.StatusDone)
+	suite.ErrorIs(err, domain.ErrNotFound)
+}
+
+func (suite *TaskRepoTestSuite) TestVersionChecks() {
+	created, err := suite.repo.CreateTask(context.Background(), domain.Task{Title: "Versioned", Status: domain.StatusTodo})
+	suite.Require().NoError(err)
+	suite.Equal(int64(1), created.Version)
+
+	updated, err := suite.repo.UpdateTask(context.Background(), created.ID.String(), domain.Task{Title: "First writer", Status: domain.StatusTodo}, 1)
+	suite.Require().NoError(err)
+	suite.Equal(int64(2), updated.Version)
+
+	// The second writer still holds version 1
+	_, err = suite.repo.UpdateTask(context.Background(), created.ID.String(), domain.Task{Title: "Second writer", Status: domain.StatusTodo}, 1)
+	suite.ErrorIs(err, domain.ErrVersionConflict)
+
+	title := "Patched"
+	_, err = suite.repo.PatchTask(context.Background(), created.ID.String(), domain.TaskPatch{Title: &title}, 1)
+	suite.ErrorIs(err, domain.ErrVersionConflict)
+
+	err = suite.repo.DeleteTask(context.Background(), created.ID.String(), 1)
+	suite.ErrorIs(err, domain.ErrVersionConflict)
+
+	err = suite.repo.DeleteTask(context.Background(), created.ID.String(), 2)
+	suite.NoError(err)
+
+	err = suite.repo.DeleteTask(context.Background(), created.ID.String(), 2)
+	suite.ErrorIs(err, domain.ErrNotFound)
 }
 
 func (suite *TaskRepoTestSuite) TestRemoveTask() {
-	task := &domain.Task{
-		ID:    primitive.NewObjectID(),
+	task := domain.Task{
+		ID:    domain.NewID(),
 		Title: "Obsolete Task",
 	}
-	_, err := suite.coll.InsertOne(context.Background(), task)
-	suite.Require().NoError(err)
+	suite.insertTasks(task)
 
-	err = suite.repo.DeleteTask(task.ID.Hex())
+	err := suite.repo.DeleteTask(context.Background(), task.ID.String(), 0)
 	suite.Require().NoError(err)
 
-	_, err = suite.repo.GetTaskByID(task.ID.Hex())
+	_, err = suite.repo.GetTaskByID(context.Background(), task.ID.String())
 	suite.Error(err, "Expected error after deletion")
 }
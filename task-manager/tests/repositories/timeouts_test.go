@@ -0,0 +1,51 @@
+package test_repositories
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	domain "task-manager/Domain"
+	repositories "task-manager/Repositories"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCancelledContext(t *testing.T) {
+	repo := repositories.NewSQLTaskRepository(newSQLiteDB(t), repositories.SQLite, repositories.DefaultTimeouts)
+	task, err := repo.CreateTask(context.Background(), domain.Task{Title: "Report", Status: domain.StatusTodo})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err = repo.GetTaskByID(ctx, task.ID.String())
+	assert.ErrorIs(t, err, context.Canceled)
+	_, err = repo.CreateTask(ctx, domain.Task{Title: "Never stored", Status: domain.StatusTodo})
+	assert.ErrorIs(t, err, context.Canceled)
+
+	_, total, err := repo.QueryTasks(context.Background(), domain.TaskQuery{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), total)
+}
+
+func TestTimeouts(t *testing.T) {
+	db := newSQLiteDB(t)
+	repo := repositories.NewSQLTaskRepository(db, repositories.SQLite, repositories.Timeouts{Read: time.Nanosecond, Write: time.Minute})
+	task, err := repo.CreateTask(context.Background(), domain.Task{Title: "Report", Status: domain.StatusTodo})
+	require.NoError(t, err, "writes keep their own timeout")
+	_, err = repo.GetTaskByID(context.Background(), task.ID.String())
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	repo = repositories.NewSQLTaskRepository(db, repositories.SQLite, repositories.Timeouts{Read: time.Minute, Write: time.Nanosecond})
+	_, err = repo.UpdateTask(context.Background(), task.ID.String(), domain.Task{Title: "Renamed", Status: domain.StatusTodo}, 0)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// The caller's deadline applies when it is the earlier one
+	ctx, cancel := context.WithTimeout(context.Background(), time.Nanosecond)
+	defer cancel()
+	repo = repositories.NewSQLTaskRepository(db, repositories.SQLite, repositories.DefaultTimeouts)
+	_, err = repo.GetTaskByID(ctx, task.ID.String())
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
@@ -18,20 +18,6 @@ import (
 // Mock Implementations for Dependencies
 // -------------------------------------------------------------------
 
-type MockJWTService struct{}
-
-func (f *MockJWTService) GenerateToken(id, role, username string) (string, error) {
-	return "dummy-token", nil
-}
-
-func (f *MockJWTService) ValidateToken(token string) (map[string]interface{}, error) {
-	return map[string]interface{}{
-		"id":       "dummy-id",
-		"username": "dummy-user",
-		"role":     "standard",
-	}, nil
-}
-
 type MockPasswordService struct{}
 
 func (f *MockPasswordService) HashPassword(pw string) (string, error) {
@@ -42,6 +28,10 @@ func (f *MockPasswordService) ComparePassword(hashed, plain string) error {
 	return nil
 }
 
+func (f *MockPasswordService) NeedsRehash(hashed string) bool {
+	return false
+}
+
 // -------------------------------------------------------------------
 // User Repository Test Suite
 // -------------------------------------------------------------------
@@ -77,10 +67,9 @@ func (ts *AuthRepoTestSuite) SetupTest() {
 	_, err = ts.users.Indexes().CreateOne(context.Background(), index)
 	ts.Require().NoError(err)
 
-	jwtService := &MockJWTService{}
 	passService := &MockPasswordService{}
 
-	ts.repo = repositories.NewUserRepository(ts.users, jwtService, passService)
+	ts.repo = repositories.NewUserRepository(ts.users, passService, repositories.DefaultTimeouts)
 }
 
 // -------------------------------------------------------------------
@@ -94,7 +83,7 @@ func (ts *AuthRepoTestSuite) TestUserRegistration() {
 			Password: "plaintext",
 		}
 
-		storedUser, err := ts.repo.RegisterUser(*newUser)
+		storedUser, err := ts.repo.RegisterUser(context.Background(), *newUser)
 
 		ts.Require().NoError(err)
 		ts.NotEmpty(storedUser.ID)
@@ -103,11 +92,11 @@ func (ts *AuthRepoTestSuite) TestUserRegistration() {
 
 	ts.Run("Should reject duplicate usernames", func() {
 		first := &domain.User{Username: "dupe", Password: "pw1"}
-		_, err := ts.repo.RegisterUser(*first)
+		_, err := ts.repo.RegisterUser(context.Background(), *first)
 		ts.Require().NoError(err)
 
 		second := &domain.User{Username: "dupe", Password: "pw2"}
-		_, err = ts.repo.RegisterUser(*second)
+		_, err = ts.repo.RegisterUser(context.Background(), *second)
 
 		ts.Require().Error(err)
 	})
@@ -115,23 +104,46 @@ func (ts *AuthRepoTestSuite) TestUserRegistration() {
 
 func (ts *AuthRepoTestSuite) TestFindUserByUsername() {
 	ts.Run("Should locate user by username", func() {
-		targetUser := &domain.User{
-			ID:       primitive.NewObjectID(),
-			Username: "target",
-			Password: "hash",
-		}
-		_, err := ts.users.InsertOne(context.Background(), targetUser)
+		targetID := primitive.NewObjectID()
+		_, err := ts.users.InsertOne(context.Background(), bson.M{
+			"_id":      targetID,
+			"username": "target",
+			"password": "hash",
+		})
 		ts.Require().NoError(err)
 
-		retrievedUser, err := ts.repo.GetUserByUsername("target")
+		retrievedUser, err := ts.repo.GetUserByUsername(context.Background(), "target")
 
 		ts.Require().NoError(err)
-		ts.Equal(targetUser.ID, retrievedUser.ID)
+		ts.Equal(targetID.Hex(), retrievedUser.ID.String())
 	})
 
 	ts.Run("Should return error for missing user", func() {
-		_, err := ts.repo.GetUserByUsername("ghostuser")
+		_, err := ts.repo.GetUserByUsername(context.Background(), "ghostuser")
 
 		ts.Require().Error(err)
 	})
 }
+
+func (ts *AuthRepoTestSuite) TestFindUserByID() {
+	ts.Run("Should locate user by ID", func() {
+		targetID := primitive.NewObjectID()
+		_, err := ts.users.InsertOne(context.Background(), bson.M{
+			"_id":      targetID,
+			"username": "byid",
+			"password": "hash",
+		})
+		ts.Require().NoError(err)
+
+		retrievedUser, err := ts.repo.GetUserByID(context.Background(), targetID.Hex())
+
+		ts.Require().NoError(err)
+		ts.Equal("byid", retrievedUser.Username)
+	})
+
+	ts.Run("Should return not found for missing user", func() {
+		_, err := ts.repo.GetUserByID(context.Background(), domain.NewID().String())
+
+		ts.ErrorIs(err, domain.ErrNotFound)
+	})
+}
@@ -0,0 +1,129 @@
+package usecases_test
+
+import (
+	"context"
+	"testing"
+
+	domain "task-manager/Domain"
+	repositories "task-manager/Repositories"
+	usecases "task-manager/Usecases"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type EmailVerificationUseCaseSuite struct {
+	suite.Suite
+	repo     *StubRepo
+	tokens   domain.VerificationTokenRepository
+	mailer   *StubMailer
+	service  *usecases.EmailVerificationUsecase
+	user     domain.User
+	verified map[string]string
+}
+
+func TestEmailVerificationUseCaseSuite(t *testing.T) {
+	suite.Run(t, &EmailVerificationUseCaseSuite{})
+}
+
+func (s *EmailVerificationUseCaseSuite) SetupTest() {
+	s.user = domain.User{ID: domain.NewID(), Username: "jane", Role: domain.RoleMember, Email: "jane@example.com"}
+	s.verified = map[string]string{}
+	s.repo = &StubRepo{
+		OnFindByID: func(id string) (domain.User, error) {
+			if id != s.user.ID.String() {
+				return domain.User{}, domain.ErrUserNotFound
+			}
+			return s.user, nil
+		},
+		OnFindByUsername: func(username string) (domain.User, error) {
+			if username != s.user.Username {
+				return domain.User{}, domain.ErrUserNotFound
+			}
+			return s.user, nil
+		},
+		OnMarkVerified: func(id string, email string) (domain.User, error) {
+			if id != s.user.ID.String() || email != s.user.Email {
+				return domain.User{}, domain.ErrUserNotFound
+			}
+			s.verified[id] = email
+			return s.user, nil
+		},
+	}
+	s.tokens = repositories.NewMemoryVerificationTokenRepository()
+	s.mailer = &StubMailer{}
+	s.service = usecases.NewEmailVerificationUsecase(s.repo, s.tokens, s.mailer)
+}
+
+// sendToken mails a verification token and returns it
+func (s *EmailVerificationUseCaseSuite) sendToken() string {
+	s.Require().NoError(s.service.SendVerification(context.Background(), s.user.ID.String()))
+	s.Require().NotEmpty(s.mailer.Sent)
+	message := s.mailer.Sent[len(s.mailer.Sent)-1]
+	s.Equal(s.user.Email, message.To)
+
+	token := tokenInBody.FindString(message.Body)
+	s.Require().NotEmpty(token, "mail contains the token")
+	return token
+}
+
+func (s *EmailVerificationUseCaseSuite) TestSendVerification() {
+	s.Run("mails a token to the address", func() {
+		s.SetupTest()
+		s.sendToken()
+		s.Len(s.mailer.Sent, 1)
+	})
+
+	s.Run("skips users with nothing to verify", func() {
+		s.SetupTest()
+		for _, change := range []func(*domain.User){
+			func(u *domain.User) { u.Email = "" },
+			func(u *domain.User) { u.EmailVerified = true },
+			func(u *domain.User) { u.Disabled = true },
+		} {
+			s.user = domain.User{ID: s.user.ID, Username: "jane", Email: "jane@example.com"}
+			change(&s.user)
+			s.NoError(s.service.SendVerification(context.Background(), s.user.ID.String()))
+		}
+		s.Empty(s.mailer.Sent)
+	})
+
+	s.Run("requests by username don't reveal users", func() {
+		s.SetupTest()
+		s.NoError(s.service.RequestVerification(context.Background(), "nobody"))
+		s.Empty(s.mailer.Sent)
+
+		s.NoError(s.service.RequestVerification(context.Background(), "jane"))
+		s.Len(s.mailer.Sent, 1)
+
+		s.ErrorIs(s.service.RequestVerification(context.Background(), ""), domain.ErrValidation)
+	})
+}
+
+func (s *EmailVerificationUseCaseSuite) TestVerifyEmail() {
+	s.Run("verifies the address once", func() {
+		s.SetupTest()
+		token := s.sendToken()
+		other := s.sendToken()
+
+		s.Require().NoError(s.service.VerifyEmail(context.Background(), token))
+		s.Equal("jane@example.com", s.verified[s.user.ID.String()])
+
+		s.ErrorIs(s.service.VerifyEmail(context.Background(), token), domain.ErrInvalidVerificationToken)
+		s.ErrorIs(s.service.VerifyEmail(context.Background(), other), domain.ErrInvalidVerificationToken, "other tokens are cancelled")
+	})
+
+	s.Run("rejects tokens for an old address", func() {
+		s.SetupTest()
+		token := s.sendToken()
+		s.user.Email = "jane@example.org"
+
+		s.ErrorIs(s.service.VerifyEmail(context.Background(), token), domain.ErrInvalidVerificationToken)
+		s.Empty(s.verified)
+	})
+
+	s.Run("rejects unknown tokens", func() {
+		s.SetupTest()
+		s.ErrorIs(s.service.VerifyEmail(context.Background(), "unknown"), domain.ErrInvalidVerificationToken)
+		s.ErrorIs(s.service.VerifyEmail(context.Background(), ""), domain.ErrInvalidVerificationToken)
+	})
+}
@@ -0,0 +1,239 @@
+package usecases_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	domain "task-manager/Domain"
+	infrastructure "task-manager/Infrastructure"
+	repositories "task-manager/Repositories"
+	usecases "task-manager/Usecases"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// testLockoutPolicy locks out after three failures per username and five
+// per client IP
+var testLockoutPolicy = usecases.LockoutPolicy{
+	MaxFailures:   3,
+	MaxIPFailures: 5,
+	Lockout:       time.Minute,
+	MaxLockout:    4 * time.Minute,
+	Window:        time.Hour,
+}
+
+type LoginLockoutSuite struct {
+	suite.Suite
+	repo      *StubRepo
+	throttles domain.LoginThrottleRepository
+	audit     *StubAuditLog
+	service   *usecases.UserUsecase
+	user      domain.User
+	admin     domain.Caller
+}
+
+func TestLoginLockoutSuite(t *testing.T) {
+	suite.Run(t, &LoginLockoutSuite{})
+}
+
+func (s *LoginLockoutSuite) SetupSuite() {
+	hash, err := infrastructure.NewPasswordService().HashPassword(testPassword)
+	s.Require().NoError(err)
+	s.user = domain.User{ID: domain.NewID(), Username: "jane", Role: domain.RoleMember, Password: hash}
+	s.admin = domain.Caller{UserID: domain.NewID().String(), Username: "boss", Role: domain.RoleAdmin}
+}
+
+func (s *LoginLockoutSuite) SetupTest() {
+	s.repo = &StubRepo{
+		OnFindByUsername: func(username string) (domain.User, error) {
+			if username != s.user.Username {
+				return domain.User{}, domain.ErrUserNotFound
+			}
+			return s.user, nil
+		},
+		OnFindByID: func(id string) (domain.User, error) {
+			if id != s.user.ID.String() {
+				return domain.User{}, domain.ErrUserNotFound
+			}
+			return s.user, nil
+		},
+	}
+	s.throttles = repositories.NewMemoryLoginThrottleRepository()
+	s.audit = &StubAuditLog{}
+	s.service = usecases.NewUserUsecase(s.repo, &StubTaskRepo{}, repositories.NewMemoryTokenRepository(),
+		repositories.NewMemoryMFARepository(), s.throttles, &StubJWT{}, infrastructure.NewPasswordService(),
+		infrastructure.NewTOTPService("Task Manager"), s.audit)
+	s.service.SetLockoutPolicy(testLockoutPolicy)
+}
+
+func (s *LoginLockoutSuite) login(username, password, clientIP string) error {
+	_, err := s.service.LoginUser(context.Background(), domain.User{Username: username, Password: password}, clientIP)
+	return err
+}
+
+// fail logs in with a wrong password n times, expecting each to be refused
+// as a wrong password
+func (s *LoginLockoutSuite) fail(username, clientIP string, n int) {
+	for i := 0; i < n; i++ {
+		s.Require().ErrorIs(s.login(username, "wrong", clientIP), domain.ErrUnauthorized)
+	}
+}
+
+// age moves the last failure of a key into the past, as if time had passed
+func (s *LoginLockoutSuite) age(key string, by time.Duration) {
+	throttle, err := s.throttles.GetLoginThrottle(context.Background(), key)
+	s.Require().NoError(err)
+	s.Require().NoError(s.throttles.ClearLoginThrottle(context.Background(), key))
+	for i := 0; i < throttle.Failures; i++ {
+		_, err := s.throttles.RecordLoginFailure(context.Background(), key, throttle.LastFailure.Add(-by), testLockoutPolicy.Window)
+		s.Require().NoError(err)
+	}
+}
+
+func (s *LoginLockoutSuite) TestUsernameLockout() {
+	s.Run("locks the username after too many failures", func() {
+		s.SetupTest()
+		s.fail("jane", "192.0.2.1", testLockoutPolicy.MaxFailures)
+
+		// Even the right password is refused, from any address
+		err := s.login("jane", testPassword, "192.0.2.2")
+		var locked *domain.LoginLockedError
+		s.Require().True(errors.As(err, &locked), "got %v", err)
+		s.ErrorIs(err, domain.ErrTooManyRequests)
+		s.WithinDuration(time.Now().Add(time.Minute), locked.Until, 5*time.Second)
+
+		s.Require().Len(s.audit.Entries, 1)
+		entry := s.audit.Entries[0]
+		s.Equal(domain.AuditLoginLocked, entry.Event)
+		s.Equal("jane", entry.Username)
+		s.Equal("192.0.2.1", entry.ClientIP)
+		s.Contains(entry.Detail, "3 failed logins")
+
+		// Other users are not affected
+		s.ErrorIs(s.login("ghost", "wrong", "192.0.2.2"), domain.ErrUnauthorized)
+	})
+
+	s.Run("unknown usernames lock the same way", func() {
+		s.SetupTest()
+		s.fail("ghost", "", testLockoutPolicy.MaxFailures)
+		s.ErrorIs(s.login("ghost", "wrong", ""), domain.ErrTooManyRequests)
+	})
+
+	s.Run("backs off exponentially once the lockout ends", func() {
+		s.SetupTest()
+		s.fail("jane", "", testLockoutPolicy.MaxFailures)
+
+		s.age("user:jane", time.Minute+time.Second)
+		s.fail("jane", "", 1)
+		err := s.login("jane", testPassword, "")
+		var locked *domain.LoginLockedError
+		s.Require().True(errors.As(err, &locked), "got %v", err)
+		s.WithinDuration(time.Now().Add(2*time.Minute), locked.Until, 5*time.Second)
+
+		// up to MaxLockout
+		for i := 0; i < 3; i++ {
+			s.age("user:jane", testLockoutPolicy.MaxLockout)
+			s.fail("jane", "", 1)
+		}
+		err = s.login("jane", testPassword, "")
+		s.Require().True(errors.As(err, &locked), "got %v", err)
+		s.WithinDuration(time.Now().Add(testLockoutPolicy.MaxLockout), locked.Until, 5*time.Second)
+		s.Len(s.audit.Entries, 5, "every lockout is audited")
+	})
+
+	s.Run("a successful login resets the count", func() {
+		s.SetupTest()
+		s.fail("jane", "", testLockoutPolicy.MaxFailures-1)
+		s.Require().NoError(s.login("jane", testPassword, ""))
+		s.fail("jane", "", testLockoutPolicy.MaxFailures-1)
+		s.NoError(s.login("jane", testPassword, ""))
+		s.Empty(s.audit.Entries)
+	})
+
+	s.Run("failures are forgotten after the window", func() {
+		s.SetupTest()
+		s.fail("jane", "", testLockoutPolicy.MaxFailures-1)
+		s.age("user:jane", testLockoutPolicy.Window+time.Second)
+		s.fail("jane", "", testLockoutPolicy.MaxFailures-1)
+		s.NoError(s.login("jane", testPassword, ""))
+	})
+
+	s.Run("disabled accounts don't count as failures", func() {
+		s.SetupTest()
+		s.user.Disabled = true
+		defer func() { s.user.Disabled = false }()
+
+		for i := 0; i < testLockoutPolicy.MaxFailures+1; i++ {
+			s.ErrorIs(s.login("jane", testPassword, ""), domain.ErrAccountDisabled)
+		}
+	})
+}
+
+func (s *LoginLockoutSuite) TestClientIPLockout() {
+	s.Run("locks the address after failures across usernames", func() {
+		s.SetupTest()
+		for i := 0; i < testLockoutPolicy.MaxIPFailures; i++ {
+			s.Require().ErrorIs(s.login("user"+string(rune('a'+i)), "wrong", "192.0.2.1"), domain.ErrUnauthorized)
+		}
+
+		s.ErrorIs(s.login("jane", testPassword, "192.0.2.1"), domain.ErrTooManyRequests)
+		s.NoError(s.login("jane", testPassword, "192.0.2.2"))
+
+		s.Require().Len(s.audit.Entries, 1)
+		s.Equal(domain.AuditEntry{
+			Time:     s.audit.Entries[0].Time,
+			Event:    domain.AuditLoginLocked,
+			ClientIP: "192.0.2.1",
+			Detail:   s.audit.Entries[0].Detail,
+		}, s.audit.Entries[0])
+	})
+
+	s.Run("successful logins don't reset the address", func() {
+		s.SetupTest()
+		for i := 0; i < testLockoutPolicy.MaxIPFailures-1; i++ {
+			s.Require().ErrorIs(s.login("user"+string(rune('a'+i)), "wrong", "192.0.2.1"), domain.ErrUnauthorized)
+			s.Require().NoError(s.login("jane", testPassword, "192.0.2.1"))
+		}
+		s.fail("ghost", "192.0.2.1", 1)
+		s.ErrorIs(s.login("jane", testPassword, "192.0.2.1"), domain.ErrTooManyRequests)
+	})
+
+	s.Run("zero limits turn lockouts off", func() {
+		s.SetupTest()
+		s.service.SetLockoutPolicy(usecases.LockoutPolicy{})
+		s.fail("jane", "192.0.2.1", 10)
+		s.NoError(s.login("jane", testPassword, "192.0.2.1"))
+	})
+}
+
+func (s *LoginLockoutSuite) TestUnlockUser() {
+	s.Run("requires user:manage", func() {
+		s.SetupTest()
+		member := domain.Caller{UserID: domain.NewID().String(), Role: domain.RoleMember}
+		s.ErrorIs(s.service.UnlockUser(context.Background(), member, s.user.ID.String()), domain.ErrForbidden)
+		s.ErrorIs(s.service.UnlockUser(context.Background(), s.admin, domain.NewID().String()), domain.ErrNotFound)
+	})
+
+	s.Run("lets the user log in again", func() {
+		s.SetupTest()
+		s.fail("jane", "", testLockoutPolicy.MaxFailures)
+		s.Require().ErrorIs(s.login("jane", testPassword, ""), domain.ErrTooManyRequests)
+
+		s.Require().NoError(s.service.UnlockUser(context.Background(), s.admin, s.user.ID.String()))
+		s.NoError(s.login("jane", testPassword, ""))
+
+		s.Require().Len(s.audit.Entries, 2)
+		unlock := s.audit.Entries[1]
+		s.Equal(domain.AuditLoginUnlocked, unlock.Event)
+		s.Equal(s.admin.UserID, unlock.ActorID)
+		s.Equal("jane", unlock.Username)
+	})
+
+	s.Run("is a no-op for users who aren't locked", func() {
+		s.SetupTest()
+		s.NoError(s.service.UnlockUser(context.Background(), s.admin, s.user.ID.String()))
+		s.Empty(s.audit.Entries)
+	})
+}
@@ -0,0 +1,328 @@
+package usecases_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	domain "task-manager/Domain"
+	infrastructure "task-manager/Infrastructure"
+	repositories "task-manager/Repositories"
+	usecases "task-manager/Usecases"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// recoveryCodeFormat is how recovery codes are shown to users
+var recoveryCodeFormat = regexp.MustCompile(`^[a-z2-7]{5}-[a-z2-7]{5}$`)
+
+type MFAUseCaseSuite struct {
+	suite.Suite
+	repo   *StubRepo
+	mfa    domain.MFARepository
+	totp   *infrastructure.TOTPService
+	jwt    *StubJWT
+	users  *usecases.UserUsecase
+	mfaUse *usecases.MFAUsecase
+	user   domain.User
+	caller domain.Caller
+	// confirmCode is the TOTP code enable confirmed the enrollment with
+	confirmCode string
+}
+
+func TestMFAUseCaseSuite(t *testing.T) {
+	suite.Run(t, &MFAUseCaseSuite{})
+}
+
+func (s *MFAUseCaseSuite) SetupSuite() {
+	hash, err := infrastructure.NewPasswordService().HashPassword(testPassword)
+	s.Require().NoError(err)
+	s.user = domain.User{ID: domain.NewID(), Username: "jane", Role: domain.RoleAdmin, Password: hash}
+	s.caller = domain.Caller{UserID: s.user.ID.String(), Username: "jane", Role: domain.RoleAdmin}
+}
+
+func (s *MFAUseCaseSuite) SetupTest() {
+	s.repo = &StubRepo{
+		OnFindByUsername: func(username string) (domain.User, error) {
+			if username != s.user.Username {
+				return domain.User{}, domain.ErrUserNotFound
+			}
+			return s.user, nil
+		},
+		OnFindByID: func(id string) (domain.User, error) {
+			if id != s.user.ID.String() {
+				return domain.User{}, domain.ErrUserNotFound
+			}
+			return s.user, nil
+		},
+	}
+	s.mfa = repositories.NewMemoryMFARepository()
+	s.totp = infrastructure.NewTOTPService("Task Manager")
+	s.jwt = &StubJWT{}
+	passwords := infrastructure.NewPasswordService()
+	s.users = usecases.NewUserUsecase(s.repo, &StubTaskRepo{}, repositories.NewMemoryTokenRepository(), s.mfa, repositories.NewMemoryLoginThrottleRepository(), s.jwt, passwords, s.totp, &StubAuditLog{})
+	s.mfaUse = usecases.NewMFAUsecase(s.repo, s.mfa, s.totp, passwords)
+}
+
+// code returns the TOTP code steps time steps from now
+func (s *MFAUseCaseSuite) code(secret string, steps int) string {
+	code, err := s.totp.Code(secret, time.Now().Add(time.Duration(steps)*30*time.Second))
+	s.Require().NoError(err)
+	return code
+}
+
+// enable turns on two-factor authentication and returns the secret and the
+// recovery codes
+func (s *MFAUseCaseSuite) enable() (string, []string) {
+	setup, err := s.mfaUse.EnrollMFA(context.Background(), s.caller, testPassword)
+	s.Require().NoError(err)
+	s.confirmCode = s.code(setup.Secret, 0)
+	codes, err := s.mfaUse.ConfirmMFA(context.Background(), s.caller, s.confirmCode)
+	s.Require().NoError(err)
+	return setup.Secret, codes
+}
+
+// challenge logs in with the password and returns the MFA token
+func (s *MFAUseCaseSuite) challenge() string {
+	resp, err := s.users.LoginUser(context.Background(), domain.User{Username: "jane", Password: testPassword}, "")
+	s.Require().NoError(err)
+	s.Require().True(resp.MFARequired)
+	return resp.MFAToken
+}
+
+func (s *MFAUseCaseSuite) TestEnrollMFA() {
+	s.Run("needs the password", func() {
+		s.SetupTest()
+		_, err := s.mfaUse.EnrollMFA(context.Background(), s.caller, "wrong")
+		s.ErrorIs(err, domain.ErrWrongPassword)
+	})
+
+	s.Run("applies once confirmed", func() {
+		s.SetupTest()
+		setup, err := s.mfaUse.EnrollMFA(context.Background(), s.caller, testPassword)
+		s.Require().NoError(err)
+		s.NotEmpty(setup.Secret)
+
+		uri, err := url.Parse(setup.URI)
+		s.Require().NoError(err)
+		s.Equal("otpauth", uri.Scheme)
+		s.Equal("totp", uri.Host)
+		s.Equal("/Task Manager:jane", uri.Path)
+		s.Equal(setup.Secret, uri.Query().Get("secret"))
+		s.Equal("Task Manager", uri.Query().Get("issuer"))
+
+		// Unconfirmed setups don't change how the user logs in
+		status, err := s.mfaUse.GetMFAStatus(context.Background(), s.caller)
+		s.Require().NoError(err)
+		s.False(status.Enabled)
+		resp, err := s.users.LoginUser(context.Background(), domain.User{Username: "jane", Password: testPassword}, "")
+		s.Require().NoError(err)
+		s.NotEmpty(resp.Token)
+
+		_, err = s.mfaUse.ConfirmMFA(context.Background(), s.caller, "000000")
+		s.ErrorIs(err, domain.ErrWrongMFACode)
+
+		codes, err := s.mfaUse.ConfirmMFA(context.Background(), s.caller, s.code(setup.Secret, 0))
+		s.Require().NoError(err)
+		s.Len(codes, usecases.RecoveryCodeCount)
+		seen := map[string]bool{}
+		for _, code := range codes {
+			s.Regexp(recoveryCodeFormat, code)
+			s.False(seen[code], "recovery codes are unique")
+			seen[code] = true
+		}
+
+		status, err = s.mfaUse.GetMFAStatus(context.Background(), s.caller)
+		s.Require().NoError(err)
+		s.Equal(domain.MFAStatus{Enabled: true, RecoveryCodesRemaining: usecases.RecoveryCodeCount}, status)
+
+		// Recovery codes are stored only as hashes
+		enrollment, err := s.mfa.GetEnrollment(context.Background(), s.user.ID)
+		s.Require().NoError(err)
+		s.NotContains(enrollment.RecoveryCodes, codes[0])
+	})
+
+	s.Run("can't enroll twice", func() {
+		s.SetupTest()
+		secret, _ := s.enable()
+
+		_, err := s.mfaUse.EnrollMFA(context.Background(), s.caller, testPassword)
+		s.ErrorIs(err, domain.ErrMFAAlreadyEnabled)
+		_, err = s.mfaUse.ConfirmMFA(context.Background(), s.caller, s.code(secret, 1))
+		s.ErrorIs(err, domain.ErrMFAAlreadyEnabled)
+	})
+
+	s.Run("can't confirm without enrolling", func() {
+		s.SetupTest()
+		_, err := s.mfaUse.ConfirmMFA(context.Background(), s.caller, "123456")
+		s.ErrorIs(err, domain.ErrConflict)
+	})
+}
+
+func (s *MFAUseCaseSuite) TestLoginWithMFA() {
+	s.Run("issues no token until the code arrives", func() {
+		s.SetupTest()
+		secret, _ := s.enable()
+
+		resp, err := s.users.LoginUser(context.Background(), domain.User{Username: "jane", Password: testPassword}, "")
+		s.Require().NoError(err)
+		s.True(resp.MFARequired)
+		s.NotEmpty(resp.MFAToken)
+		s.Empty(resp.Token)
+		s.Empty(resp.RefreshToken)
+		s.Zero(s.jwt.issued, "no access token is generated for the password alone")
+
+		_, err = s.users.CompleteMFALogin(context.Background(), resp.MFAToken, "000000")
+		s.ErrorIs(err, domain.ErrInvalidMFACode)
+
+		// The code from confirming is used up, the next one isn't
+		_, err = s.users.CompleteMFALogin(context.Background(), resp.MFAToken, s.confirmCode)
+		s.ErrorIs(err, domain.ErrInvalidMFACode)
+
+		next := s.code(secret, 1)
+		full, err := s.users.CompleteMFALogin(context.Background(), resp.MFAToken, next)
+		s.Require().NoError(err)
+		s.Equal("jane-admin-1", full.Token)
+		s.NotEmpty(full.RefreshToken)
+		s.False(full.MFARequired)
+
+		_, err = s.users.CompleteMFALogin(context.Background(), resp.MFAToken, next)
+		s.ErrorIs(err, domain.ErrInvalidMFAChallenge, "MFA tokens work once")
+		_, err = s.users.CompleteMFALogin(context.Background(), s.challenge(), next)
+		s.ErrorIs(err, domain.ErrInvalidMFACode, "TOTP codes work once")
+	})
+
+	s.Run("accepts each recovery code once", func() {
+		s.SetupTest()
+		_, codes := s.enable()
+
+		_, err := s.users.CompleteMFALogin(context.Background(), s.challenge(), " "+codes[0]+" ")
+		s.Require().NoError(err)
+		_, err = s.users.CompleteMFALogin(context.Background(), s.challenge(), codes[0])
+		s.ErrorIs(err, domain.ErrInvalidMFACode)
+
+		// Case and dashes don't matter
+		_, err = s.users.CompleteMFALogin(context.Background(), s.challenge(), strings.ToUpper(codes[1][:5]+codes[1][6:]))
+		s.NoError(err)
+
+		status, err := s.mfaUse.GetMFAStatus(context.Background(), s.caller)
+		s.Require().NoError(err)
+		s.Equal(usecases.RecoveryCodeCount-2, status.RecoveryCodesRemaining)
+	})
+
+	s.Run("limits wrong codes per login", func() {
+		s.SetupTest()
+		secret, _ := s.enable()
+		token := s.challenge()
+
+		for i := 0; i < usecases.MaxMFAAttempts; i++ {
+			_, err := s.users.CompleteMFALogin(context.Background(), token, "000000")
+			s.ErrorIs(err, domain.ErrInvalidMFACode)
+		}
+		_, err := s.users.CompleteMFALogin(context.Background(), token, s.code(secret, 1))
+		s.ErrorIs(err, domain.ErrInvalidMFAChallenge)
+	})
+
+	s.Run("locks out after wrong codes across fresh challenges", func() {
+		s.SetupTest()
+		s.users.SetLockoutPolicy(testLockoutPolicy)
+		secret, _ := s.enable()
+		spare := s.challenge()
+
+		for i := 0; i < testLockoutPolicy.MaxFailures; i++ {
+			_, err := s.users.CompleteMFALogin(context.Background(), s.challenge(), "000000")
+			s.Require().ErrorIs(err, domain.ErrInvalidMFACode)
+		}
+
+		// Neither the right code on an earlier challenge nor the password
+		// gets past the lockout
+		_, err := s.users.CompleteMFALogin(context.Background(), spare, s.code(secret, 1))
+		var locked *domain.LoginLockedError
+		s.True(errors.As(err, &locked), "got %v", err)
+		_, err = s.users.LoginUser(context.Background(), domain.User{Username: "jane", Password: testPassword}, "")
+		s.True(errors.As(err, &locked), "got %v", err)
+	})
+
+	s.Run("only a completed login resets the count", func() {
+		s.SetupTest()
+		s.users.SetLockoutPolicy(testLockoutPolicy)
+		secret, _ := s.enable()
+
+		for i := 0; i < testLockoutPolicy.MaxFailures-1; i++ {
+			_, err := s.users.CompleteMFALogin(context.Background(), s.challenge(), "000000")
+			s.Require().ErrorIs(err, domain.ErrInvalidMFACode)
+		}
+		_, err := s.users.CompleteMFALogin(context.Background(), s.challenge(), s.code(secret, 1))
+		s.Require().NoError(err)
+
+		_, err = s.users.CompleteMFALogin(context.Background(), s.challenge(), "000000")
+		s.ErrorIs(err, domain.ErrInvalidMFACode)
+		s.challenge()
+	})
+
+	s.Run("rejects expired and unknown MFA tokens", func() {
+		s.SetupTest()
+		secret, _ := s.enable()
+
+		sum := sha256.Sum256([]byte("expired"))
+		s.Require().NoError(s.mfa.SaveMFAChallenge(context.Background(), domain.MFAChallenge{
+			TokenHash: hex.EncodeToString(sum[:]),
+			UserID:    s.user.ID,
+			ExpiresAt: time.Now().Add(-time.Second),
+		}))
+		_, err := s.users.CompleteMFALogin(context.Background(), "expired", s.code(secret, 1))
+		s.ErrorIs(err, domain.ErrInvalidMFAChallenge)
+
+		_, err = s.users.CompleteMFALogin(context.Background(), "unknown", s.code(secret, 1))
+		s.ErrorIs(err, domain.ErrInvalidMFAChallenge)
+		_, err = s.users.CompleteMFALogin(context.Background(), "", s.code(secret, 1))
+		s.ErrorIs(err, domain.ErrInvalidMFAChallenge)
+	})
+}
+
+func (s *MFAUseCaseSuite) TestDisableMFA() {
+	s.Run("needs the password and a code", func() {
+		s.SetupTest()
+		secret, codes := s.enable()
+		token := s.challenge()
+
+		s.ErrorIs(s.mfaUse.DisableMFA(context.Background(), s.caller, "wrong", s.code(secret, 1)), domain.ErrWrongPassword)
+		s.ErrorIs(s.mfaUse.DisableMFA(context.Background(), s.caller, testPassword, "000000"), domain.ErrWrongMFACode)
+
+		// A recovery code will do for users who lost their authenticator
+		s.Require().NoError(s.mfaUse.DisableMFA(context.Background(), s.caller, testPassword, codes[0]))
+
+		status, err := s.mfaUse.GetMFAStatus(context.Background(), s.caller)
+		s.Require().NoError(err)
+		s.False(status.Enabled)
+
+		_, err = s.users.CompleteMFALogin(context.Background(), token, s.code(secret, 1))
+		s.ErrorIs(err, domain.ErrInvalidMFAChallenge, "pending logins end with MFA")
+
+		resp, err := s.users.LoginUser(context.Background(), domain.User{Username: "jane", Password: testPassword}, "")
+		s.Require().NoError(err)
+		s.NotEmpty(resp.Token)
+
+		s.ErrorIs(s.mfaUse.DisableMFA(context.Background(), s.caller, testPassword, codes[1]), domain.ErrMFANotEnabled)
+	})
+
+	s.Run("admins can reset it", func() {
+		s.SetupTest()
+		s.enable()
+
+		member := domain.Caller{UserID: domain.NewID().String(), Role: domain.RoleMaintainer}
+		s.ErrorIs(s.users.ResetUserMFA(context.Background(), member, s.user.ID.String()), domain.ErrForbidden)
+		s.ErrorIs(s.users.ResetUserMFA(context.Background(), s.caller, domain.NewID().String()), domain.ErrNotFound)
+
+		s.Require().NoError(s.users.ResetUserMFA(context.Background(), s.caller, s.user.ID.String()))
+		status, err := s.mfaUse.GetMFAStatus(context.Background(), s.caller)
+		s.Require().NoError(err)
+		s.False(status.Enabled)
+	})
+}
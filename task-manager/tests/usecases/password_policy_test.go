@@ -0,0 +1,80 @@
+package usecases_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	domain "task-manager/Domain"
+	usecases "task-manager/Usecases"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// failedRules returns the rules the password fails under the policy
+func failedRules(t *testing.T, policy usecases.PasswordPolicy, username, password string) []string {
+	err := policy.Check(username, password)
+	if err == nil {
+		return nil
+	}
+	var policyErr *domain.PasswordPolicyError
+	require.True(t, errors.As(err, &policyErr), "got %v", err)
+	require.ErrorIs(t, err, domain.ErrValidation)
+
+	var rules []string
+	for _, violation := range policyErr.Violations {
+		rules = append(rules, violation.Rule)
+	}
+	return rules
+}
+
+func TestDefaultPasswordPolicy(t *testing.T) {
+	policy := usecases.DefaultPasswordPolicy
+
+	cases := []struct {
+		name     string
+		username string
+		password string
+		rules    []string
+	}{
+		{"accepted", "jane", "correct-horse-1", nil},
+		{"unicode letters count as characters", "jane", "пароль-дня", nil},
+		{"too short", "jane", "ab-1", []string{domain.PasswordRuleMinLength}},
+		{"one character class", "jane", "abcdefghij", []string{domain.PasswordRuleClasses}},
+		{"longer than bcrypt hashes", "jane", strings.Repeat("a1", 37), []string{domain.PasswordRuleMaxLength}},
+		{"exactly 72 bytes", "jane", strings.Repeat("a1", 36), nil},
+		{"contains the username in any case", "jane", "I-am-JANE-1", []string{domain.PasswordRuleUsername}},
+		{"short usernames are ignored", "jo", "enjoy-the-day-1", nil},
+		{"common in any case", "jane", "Password123", []string{domain.PasswordRuleCommon}},
+		{"everything wrong", "secret", "secret", []string{
+			domain.PasswordRuleMinLength, domain.PasswordRuleClasses, domain.PasswordRuleUsername, domain.PasswordRuleCommon,
+		}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.rules, failedRules(t, policy, tc.username, tc.password))
+		})
+	}
+}
+
+func TestCustomPasswordPolicy(t *testing.T) {
+	t.Run("zero fields turn rules off", func(t *testing.T) {
+		assert.Empty(t, failedRules(t, usecases.PasswordPolicy{}, "secret", "secret"))
+	})
+
+	t.Run("MaxBytes can't exceed what bcrypt hashes", func(t *testing.T) {
+		policy := usecases.PasswordPolicy{MaxBytes: 1000}
+		assert.Equal(t, []string{domain.PasswordRuleMaxLength}, failedRules(t, policy, "", strings.Repeat("a", 73)))
+
+		policy.MaxBytes = 16
+		assert.Equal(t, []string{domain.PasswordRuleMaxLength}, failedRules(t, policy, "", strings.Repeat("a", 17)))
+	})
+
+	t.Run("stricter character classes", func(t *testing.T) {
+		policy := usecases.PasswordPolicy{MinClasses: 4}
+		assert.Equal(t, []string{domain.PasswordRuleClasses}, failedRules(t, policy, "", "correct-horse-1"))
+		assert.Empty(t, failedRules(t, policy, "", "Correct-horse-1"))
+	})
+}
@@ -0,0 +1,212 @@
+package usecases_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	domain "task-manager/Domain"
+	infrastructure "task-manager/Infrastructure"
+	repositories "task-manager/Repositories"
+	usecases "task-manager/Usecases"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// StubMailer records the messages it is asked to send
+type StubMailer struct {
+	Sent []domain.Message
+	Err  error
+}
+
+func (m *StubMailer) Send(message domain.Message) error {
+	if m.Err != nil {
+		return m.Err
+	}
+	m.Sent = append(m.Sent, message)
+	return nil
+}
+
+// tokenInBody finds the reset token on its own line of a reset mail
+var tokenInBody = regexp.MustCompile(`(?m)^[A-Za-z0-9_-]{43}$`)
+
+type PasswordResetUseCaseSuite struct {
+	suite.Suite
+	repo      *StubRepo
+	resets    domain.ResetTokenRepository
+	tokens    domain.TokenRepository
+	mailer    *StubMailer
+	passwords domain.PasswordService
+	service   *usecases.PasswordResetUsecase
+	user      domain.User
+	hashes    map[string]string
+}
+
+func TestPasswordResetUseCaseSuite(t *testing.T) {
+	suite.Run(t, &PasswordResetUseCaseSuite{})
+}
+
+func (s *PasswordResetUseCaseSuite) SetupTest() {
+	s.user = domain.User{
+		ID: domain.NewID(), Username: "jane", Role: domain.RoleMember,
+		Email: "jane@example.com", EmailVerified: true,
+	}
+	s.hashes = map[string]string{}
+	s.repo = &StubRepo{
+		OnFindByUsername: func(username string) (domain.User, error) {
+			if username != s.user.Username {
+				return domain.User{}, domain.ErrUserNotFound
+			}
+			return s.user, nil
+		},
+		OnFindByID: func(id string) (domain.User, error) {
+			if id != s.user.ID.String() {
+				return domain.User{}, domain.ErrUserNotFound
+			}
+			return s.user, nil
+		},
+		OnSetPassword: func(id string, hash string) error {
+			s.hashes[id] = hash
+			return nil
+		},
+	}
+	s.resets = repositories.NewMemoryResetTokenRepository()
+	s.mailer = &StubMailer{}
+	s.passwords = infrastructure.NewPasswordService()
+	s.tokens = repositories.NewMemoryTokenRepository()
+	s.service = usecases.NewPasswordResetUsecase(s.repo, s.resets, s.tokens, s.passwords, s.mailer)
+}
+
+// requestToken asks for a reset and returns the mailed token
+func (s *PasswordResetUseCaseSuite) requestToken() string {
+	s.Require().NoError(s.service.RequestPasswordReset(context.Background(), s.user.Username))
+	s.Require().NotEmpty(s.mailer.Sent)
+	message := s.mailer.Sent[len(s.mailer.Sent)-1]
+	s.Equal(s.user.Email, message.To)
+
+	token := tokenInBody.FindString(message.Body)
+	s.Require().NotEmpty(token, "mail contains the token")
+	return token
+}
+
+func (s *PasswordResetUseCaseSuite) TestRequestPasswordReset() {
+	s.Run("mails a token to the user's email", func() {
+		s.SetupTest()
+		s.requestToken()
+		s.Len(s.mailer.Sent, 1)
+	})
+
+	s.Run("unknown and disabled users get no mail", func() {
+		s.SetupTest()
+		s.NoError(s.service.RequestPasswordReset(context.Background(), "nobody"))
+
+		s.user.Disabled = true
+		s.NoError(s.service.RequestPasswordReset(context.Background(), s.user.Username))
+		s.Empty(s.mailer.Sent)
+	})
+
+	s.Run("users without a verified email get no mail", func() {
+		s.SetupTest()
+		s.user.EmailVerified = false
+		s.NoError(s.service.RequestPasswordReset(context.Background(), s.user.Username))
+
+		s.user.Email = ""
+		s.NoError(s.service.RequestPasswordReset(context.Background(), s.user.Username))
+		s.Empty(s.mailer.Sent)
+	})
+
+	s.Run("mail failures are not reported", func() {
+		s.SetupTest()
+		s.mailer.Err = errors.New("connection refused")
+		s.NoError(s.service.RequestPasswordReset(context.Background(), s.user.Username))
+	})
+
+	s.Run("username is required", func() {
+		s.SetupTest()
+		s.ErrorIs(s.service.RequestPasswordReset(context.Background(), ""), domain.ErrValidation)
+	})
+}
+
+func (s *PasswordResetUseCaseSuite) TestResetPassword() {
+	s.Run("sets the new password once", func() {
+		s.SetupTest()
+		token := s.requestToken()
+
+		s.Require().NoError(s.service.ResetPassword(context.Background(), token, "brand-new"))
+		hash := s.hashes[s.user.ID.String()]
+		s.NoError(s.passwords.ComparePassword(hash, "brand-new"))
+
+		s.ErrorIs(s.service.ResetPassword(context.Background(), token, "brand-new-2"), domain.ErrInvalidResetToken)
+	})
+
+	s.Run("a reset cancels the user's other tokens", func() {
+		s.SetupTest()
+		first := s.requestToken()
+		second := s.requestToken()
+
+		s.Require().NoError(s.service.ResetPassword(context.Background(), second, "brand-new"))
+		s.ErrorIs(s.service.ResetPassword(context.Background(), first, "other-pass-1"), domain.ErrInvalidResetToken)
+	})
+
+	s.Run("a reset revokes the user's refresh tokens", func() {
+		s.SetupTest()
+		for hash, userID := range map[string]domain.ID{"mine": s.user.ID, "theirs": domain.NewID()} {
+			s.Require().NoError(s.tokens.SaveRefreshToken(context.Background(), domain.RefreshToken{
+				TokenHash: hash, UserID: userID, FamilyID: domain.NewID(), ExpiresAt: time.Now().Add(time.Hour),
+			}))
+		}
+
+		s.Require().NoError(s.service.ResetPassword(context.Background(), s.requestToken(), "brand-new"))
+		for hash, revoked := range map[string]bool{"mine": true, "theirs": false} {
+			stored, err := s.tokens.GetRefreshToken(context.Background(), hash)
+			s.Require().NoError(err)
+			s.Equal(revoked, stored.Revoked, hash)
+		}
+	})
+
+	s.Run("rejects expired and unknown tokens", func() {
+		s.SetupTest()
+		sum := sha256.Sum256([]byte("expired"))
+		hash := hex.EncodeToString(sum[:])
+		s.Require().NoError(s.resets.SaveResetToken(context.Background(), domain.PasswordResetToken{
+			TokenHash: hash,
+			UserID:    s.user.ID,
+			ExpiresAt: time.Now().Add(-time.Minute),
+		}))
+
+		s.ErrorIs(s.service.ResetPassword(context.Background(), "expired", "brand-new"), domain.ErrInvalidResetToken)
+		s.ErrorIs(s.service.ResetPassword(context.Background(), hash, "brand-new"), domain.ErrInvalidResetToken, "the stored hash is not a token")
+		s.ErrorIs(s.service.ResetPassword(context.Background(), "unknown", "brand-new"), domain.ErrInvalidResetToken)
+		s.ErrorIs(s.service.ResetPassword(context.Background(), "", "brand-new"), domain.ErrInvalidResetToken)
+		s.Empty(s.hashes)
+	})
+
+	s.Run("requires a new password", func() {
+		s.SetupTest()
+		token := s.requestToken()
+		s.ErrorIs(s.service.ResetPassword(context.Background(), token, ""), domain.ErrValidation)
+
+		// The token survives a rejected request
+		s.NoError(s.service.ResetPassword(context.Background(), token, "brand-new"))
+	})
+
+	s.Run("applies the password policy", func() {
+		s.SetupTest()
+		token := s.requestToken()
+
+		var policyErr *domain.PasswordPolicyError
+		err := s.service.ResetPassword(context.Background(), token, "short")
+		s.Require().True(errors.As(err, &policyErr), "got %v", err)
+		err = s.service.ResetPassword(context.Background(), token, "jane-doe-99")
+		s.Require().True(errors.As(err, &policyErr), "got %v", err)
+		s.Equal(domain.PasswordRuleUsername, policyErr.Violations[0].Rule)
+		s.Empty(s.hashes)
+
+		// The token survives both, even once consumed for the username check
+		s.NoError(s.service.ResetPassword(context.Background(), token, "brand-new"))
+	})
+}
@@ -1,6 +1,7 @@
 package usecases_test
 
 import (
+	"context"
 	"errors"
 	"testing"
 
@@ -8,7 +9,6 @@ import (
 	usecases "task-manager/Usecases"
 
 	"github.com/stretchr/testify/suite"
-	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 // -----------------------------------------------------------
@@ -18,46 +18,86 @@ import (
 type StubTaskRepo struct {
 	OnCreate  func(domain.Task) (domain.Task, error)
 	OnFind    func(string) (domain.Task, error)
-	OnFetch   func() ([]domain.Task, error)
-	OnUpdate  func(string, domain.Task) (domain.Task, error)
-	OnRemove  func(string) error
+	OnQuery   func(domain.TaskQuery) ([]domain.Task, int64, error)
+	OnUpdate  func(string, domain.Task, int64) (domain.Task, error)
+	OnPatch   func(string, domain.TaskPatch, int64) (domain.Task, error)
+	OnMove    func(string, domain.TaskStatus, domain.TaskStatus) (domain.Task, error)
+	OnRemove  func(string, int64) error
+	OnReassign        func(from, to domain.ID) error
+	OnDeleteCreatedBy func(domain.ID) error
+	OnUnassign        func(domain.ID) error
 }
 
-func (s *StubTaskRepo) CreateTask(t domain.Task) (domain.Task, error) {
+func (s *StubTaskRepo) CreateTask(_ context.Context, t domain.Task) (domain.Task, error) {
 	if s.OnCreate != nil {
 		return s.OnCreate(t)
 	}
 	return domain.Task{}, errors.New("CreateTask not implemented")
 }
 
-func (s *StubTaskRepo) GetTaskByID(id string) (domain.Task, error) {
+func (s *StubTaskRepo) GetTaskByID(_ context.Context, id string) (domain.Task, error) {
 	if s.OnFind != nil {
 		return s.OnFind(id)
 	}
 	return domain.Task{}, errors.New("GetTaskByID not implemented")
 }
 
-func (s *StubTaskRepo) GetAllTasks() ([]domain.Task, error) {
-	if s.OnFetch != nil {
-		return s.OnFetch()
+func (s *StubTaskRepo) QueryTasks(_ context.Context, q domain.TaskQuery) ([]domain.Task, int64, error) {
+	if s.OnQuery != nil {
+		return s.OnQuery(q)
 	}
-	return nil, errors.New("GetAllTasks not implemented")
+	return nil, 0, errors.New("QueryTasks not implemented")
 }
 
-func (s *StubTaskRepo) UpdateTask(id string, t domain.Task) (domain.Task, error) {
+func (s *StubTaskRepo) UpdateTask(_ context.Context, id string, t domain.Task, version int64) (domain.Task, error) {
 	if s.OnUpdate != nil {
-		return s.OnUpdate(id, t)
+		return s.OnUpdate(id, t, version)
 	}
 	return domain.Task{}, errors.New("UpdateTask not implemented")
 }
 
-func (s *StubTaskRepo) DeleteTask(id string) error {
+func (s *StubTaskRepo) PatchTask(_ context.Context, id string, p domain.TaskPatch, version int64) (domain.Task, error) {
+	if s.OnPatch != nil {
+		return s.OnPatch(id, p, version)
+	}
+	return domain.Task{}, errors.New("PatchTask not implemented")
+}
+
+func (s *StubTaskRepo) TransitionTask(_ context.Context, id string, from, to domain.TaskStatus) (domain.Task, error) {
+	if s.OnMove != nil {
+		return s.OnMove(id, from, to)
+	}
+	return domain.Task{}, errors.New("TransitionTask not implemented")
+}
+
+func (s *StubTaskRepo) DeleteTask(_ context.Context, id string, version int64) error {
 	if s.OnRemove != nil {
-		return s.OnRemove(id)
+		return s.OnRemove(id, version)
 	}
 	return errors.New("DeleteTask not implemented")
 }
 
+func (s *StubTaskRepo) ReassignTasks(_ context.Context, from, to domain.ID) error {
+	if s.OnReassign != nil {
+		return s.OnReassign(from, to)
+	}
+	return errors.New("ReassignTasks not implemented")
+}
+
+func (s *StubTaskRepo) DeleteTasksCreatedBy(_ context.Context, userID domain.ID) error {
+	if s.OnDeleteCreatedBy != nil {
+		return s.OnDeleteCreatedBy(userID)
+	}
+	return errors.New("DeleteTasksCreatedBy not implemented")
+}
+
+func (s *StubTaskRepo) UnassignTasks(_ context.Context, userID domain.ID) error {
+	if s.OnUnassign != nil {
+		return s.OnUnassign(userID)
+	}
+	return errors.New("UnassignTasks not implemented")
+}
+
 // -----------------------------------------------------------
 // Task Use Case Test Suite
 // -----------------------------------------------------------
@@ -65,7 +105,12 @@ func (s *StubTaskRepo) DeleteTask(id string) error {
 type TaskUseCaseSuite struct {
 	suite.Suite
 	mockStore *StubTaskRepo
+	users     *StubRepo
 	handler   *usecases.TaskUsecase
+	admin      domain.Caller
+	maintainer domain.Caller
+	member     domain.Caller
+	viewer     domain.Caller
 }
 
 func TestTaskUseCaseSuite(t *testing.T) {
@@ -74,7 +119,16 @@ func TestTaskUseCaseSuite(t *testing.T) {
 
 func (ts *TaskUseCaseSuite) SetupTest() {
 	ts.mockStore = &StubTaskRepo{}
-	ts.handler = usecases.NewTaskUsecase(ts.mockStore)
+	ts.users = &StubRepo{
+		OnFindByID: func(id string) (domain.User, error) {
+			return domain.User{ID: domain.ID(id)}, nil
+		},
+	}
+	ts.handler = usecases.NewTaskUsecase(ts.mockStore, ts.users)
+	ts.admin = domain.Caller{UserID: domain.NewID().String(), Username: "boss", Role: domain.RoleAdmin}
+	ts.maintainer = domain.Caller{UserID: domain.NewID().String(), Username: "lead", Role: domain.RoleMaintainer}
+	ts.member = domain.Caller{UserID: domain.NewID().String(), Username: "worker", Role: domain.RoleMember}
+	ts.viewer = domain.Caller{UserID: domain.NewID().String(), Username: "guest", Role: domain.RoleViewer}
 }
 
 func (ts *TaskUseCaseSuite) TestCreateTask() {
@@ -84,108 +138,514 @@ func (ts *TaskUseCaseSuite) TestCreateTask() {
 		incoming := domain.Task{
 			Title:       "Prepare report",
 			Description: "End of quarter summary",
-			Status:      "pending",
+			Status:      domain.StatusTodo,
 		}
 		result := incoming
-		result.ID = primitive.NewObjectID()
+		result.ID = domain.NewID()
 
 		ts.mockStore.OnCreate = func(t domain.Task) (domain.Task, error) {
 			t.ID = result.ID
 			return t, nil
 		}
 
-		out, err := ts.handler.CreateTask(incoming)
+		out, err := ts.handler.CreateTask(context.Background(), ts.admin, incoming)
 
 		ts.Require().NoError(err)
 		ts.Require().NotNil(out)
 		ts.Equal(result.Title, out.Title)
 		ts.Equal(result.ID, out.ID)
+		ts.Equal(ts.admin.UserID, out.CreatedBy.String())
+		ts.Equal(ts.admin.UserID, out.AssigneeID.String(), "Assignee should default to the creator")
+	})
+
+	ts.Run("Defaults status to todo", func() {
+		ts.SetupTest()
+		ts.mockStore.OnCreate = func(t domain.Task) (domain.Task, error) {
+			return t, nil
+		}
+
+		out, err := ts.handler.CreateTask(context.Background(), ts.admin, domain.Task{Title: "No status"})
+
+		ts.Require().NoError(err)
+		ts.Equal(domain.StatusTodo, out.Status)
+	})
+
+	ts.Run("Rejects unknown status", func() {
+		ts.SetupTest()
+
+		_, err := ts.handler.CreateTask(context.Background(), ts.admin, domain.Task{Title: "Odd", Status: "someday"})
+
+		ts.ErrorIs(err, domain.ErrInvalidStatus)
+	})
+
+	ts.Run("Keeps explicit assignee", func() {
+		ts.SetupTest()
+		assignee := domain.ID(ts.member.UserID)
+
+		ts.mockStore.OnCreate = func(t domain.Task) (domain.Task, error) {
+			return t, nil
+		}
+
+		out, err := ts.handler.CreateTask(context.Background(), ts.admin, domain.Task{Title: "Delegated", AssigneeID: assignee})
+
+		ts.Require().NoError(err)
+		ts.Equal(ts.admin.UserID, out.CreatedBy.String())
+		ts.Equal(assignee, out.AssigneeID)
+	})
+
+	ts.Run("Rejects unknown assignee", func() {
+		ts.SetupTest()
+		ts.users.OnFindByID = func(id string) (domain.User, error) {
+			return domain.User{}, domain.ErrUserNotFound
+		}
+
+		_, err := ts.handler.CreateTask(context.Background(), ts.admin, domain.Task{Title: "Typo", AssigneeID: domain.NewID()})
+
+		ts.ErrorIs(err, domain.ErrValidation)
 	})
 }
 
 func (ts *TaskUseCaseSuite) TestGetTaskByID() {
 	ts.Run("Success", func() {
 		ts.SetupTest()
-		objID := primitive.NewObjectID()
+		objID := domain.NewID()
 		expected := domain.Task{ID: objID, Title: "Mock Task"}
 
 		ts.mockStore.OnFind = func(id string) (domain.Task, error) {
-			ts.Equal(objID.Hex(), id)
+			ts.Equal(objID.String(), id)
 			return expected, nil
 		}
 
-		found, err := ts.handler.GetTaskByID(objID.Hex())
+		found, err := ts.handler.GetTaskByID(context.Background(), ts.admin, objID.String())
 
 		ts.Require().NoError(err)
 		ts.Require().NotNil(found)
 		ts.Equal(expected.ID, found.ID)
 	})
+
+	ts.Run("Assignee can view", func() {
+		ts.SetupTest()
+		assignee := domain.ID(ts.member.UserID)
+		expected := domain.Task{ID: domain.NewID(), AssigneeID: assignee}
+
+		ts.mockStore.OnFind = func(id string) (domain.Task, error) {
+			return expected, nil
+		}
+
+		found, err := ts.handler.GetTaskByID(context.Background(), ts.member, expected.ID.String())
+
+		ts.Require().NoError(err)
+		ts.Equal(expected.ID, found.ID)
+	})
+
+	ts.Run("Hidden from unrelated user", func() {
+		ts.SetupTest()
+		ts.mockStore.OnFind = func(id string) (domain.Task, error) {
+			return domain.Task{ID: domain.NewID(), CreatedBy: domain.NewID()}, nil
+		}
+
+		_, err := ts.handler.GetTaskByID(context.Background(), ts.member, domain.NewID().String())
+
+		ts.Require().Error(err)
+		ts.ErrorIs(err, domain.ErrNotFound)
+	})
 }
 
 func (ts *TaskUseCaseSuite) TestGetAllTasks() {
 	ts.Run("Success", func() {
 		ts.SetupTest()
 		mocked := []domain.Task{
-			{ID: primitive.NewObjectID(), Title: "One"},
-			{ID: primitive.NewObjectID(), Title: "Two"},
+			{ID: domain.NewID(), Title: "One"},
+			{ID: domain.NewID(), Title: "Two"},
 		}
 
-		ts.mockStore.OnFetch = func() ([]domain.Task, error) {
-			return mocked, nil
+		ts.mockStore.OnQuery = func(q domain.TaskQuery) ([]domain.Task, int64, error) {
+			ts.Empty(q.VisibleTo, "Admins should not be scoped")
+			return mocked, 2, nil
 		}
 
-		results, err := ts.handler.GetAllTasks()
+		page, err := ts.handler.GetAllTasks(context.Background(), ts.admin, domain.TaskQuery{})
+
+		ts.Require().NoError(err)
+		ts.Len(page.Tasks, 2)
+		ts.Equal(mocked, page.Tasks)
+		ts.Equal(int64(2), page.Total)
+		ts.Equal(usecases.DefaultTaskPageSize, page.Limit)
+	})
+
+	ts.Run("Non-admin only sees own tasks", func() {
+		ts.SetupTest()
+		owned := []domain.Task{{ID: domain.NewID(), Title: "Mine"}}
+
+		ts.mockStore.OnQuery = func(q domain.TaskQuery) ([]domain.Task, int64, error) {
+			ts.Equal(ts.member.UserID, q.VisibleTo)
+			return owned, 1, nil
+		}
+
+		page, err := ts.handler.GetAllTasks(context.Background(), ts.member, domain.TaskQuery{VisibleTo: ts.admin.UserID})
+
+		ts.Require().NoError(err)
+		ts.Equal(owned, page.Tasks)
+	})
+
+	ts.Run("Caps page size", func() {
+		ts.SetupTest()
+		ts.mockStore.OnQuery = func(q domain.TaskQuery) ([]domain.Task, int64, error) {
+			ts.Equal(usecases.MaxTaskPageSize, q.Limit)
+			return nil, 0, nil
+		}
 
+		_, err := ts.handler.GetAllTasks(context.Background(), ts.admin, domain.TaskQuery{Limit: 10000})
 		ts.Require().NoError(err)
-		ts.Len(results, 2)
-		ts.Equal(mocked, results)
+	})
+
+	ts.Run("Rejects invalid query", func() {
+		ts.SetupTest()
+
+		_, err := ts.handler.GetAllTasks(context.Background(), ts.admin, domain.TaskQuery{SortBy: "password"})
+		ts.ErrorIs(err, domain.ErrInvalidTaskQuery)
+
+		_, err = ts.handler.GetAllTasks(context.Background(), ts.admin, domain.TaskQuery{Offset: -1})
+		ts.ErrorIs(err, domain.ErrInvalidTaskQuery)
+
+		_, err = ts.handler.GetAllTasks(context.Background(), ts.admin, domain.TaskQuery{Status: "pending"})
+		ts.ErrorIs(err, domain.ErrInvalidTaskQuery)
 	})
 }
 
 func (ts *TaskUseCaseSuite) TestUpdateTask() {
 	ts.Run("Success", func() {
 		ts.SetupTest()
-		oid := primitive.NewObjectID()
-		oidStr := oid.Hex()
+		oid := domain.NewID()
+		oidStr := oid.String()
 
 		updates := domain.Task{
 			Title:       "Edited",
 			Description: "Changes made",
-			Status:      "done",
+			Status:      domain.StatusDone,
 		}
 		final := updates
 		final.ID = oid
 
-		ts.mockStore.OnUpdate = func(id string, in domain.Task) (domain.Task, error) {
-			parsed, err := primitive.ObjectIDFromHex(id)
-			if err != nil {
-				return domain.Task{}, err
-			}
-			in.ID = parsed
+		ts.mockStore.OnFind = func(id string) (domain.Task, error) {
+			return domain.Task{ID: oid, Status: domain.StatusInProgress}, nil
+		}
+
+		ts.mockStore.OnUpdate = func(id string, in domain.Task, version int64) (domain.Task, error) {
+			in.ID = domain.ID(id)
 			return in, nil
 		}
 
-		out, err := ts.handler.UpdateTask(oidStr, updates)
+		out, err := ts.handler.UpdateTask(context.Background(), ts.admin, oidStr, updates, 0)
 
 		ts.Require().NoError(err)
 		ts.Require().NotNil(out)
 		ts.Equal(final.Title, out.Title)
 		ts.Equal(final.ID, out.ID)
 	})
+
+	ts.Run("Rejects illegal status change", func() {
+		ts.SetupTest()
+		ts.mockStore.OnFind = func(id string) (domain.Task, error) {
+			return domain.Task{Status: domain.StatusCancelled}, nil
+		}
+
+		_, err := ts.handler.UpdateTask(context.Background(), ts.admin, domain.NewID().String(), domain.Task{Status: domain.StatusDone}, 0)
+
+		var transitionErr *domain.TransitionError
+		ts.Require().ErrorAs(err, &transitionErr)
+		ts.Equal(domain.StatusCancelled, transitionErr.From)
+		ts.Equal(domain.StatusDone, transitionErr.To)
+	})
+
+	ts.Run("Rejects stale version", func() {
+		ts.SetupTest()
+		ts.mockStore.OnFind = func(id string) (domain.Task, error) {
+			return domain.Task{Status: domain.StatusTodo, Version: 3}, nil
+		}
+
+		_, err := ts.handler.UpdateTask(context.Background(), ts.admin, domain.NewID().String(), domain.Task{Title: "Late"}, 2)
+
+		ts.ErrorIs(err, domain.ErrVersionConflict)
+	})
+
+	ts.Run("Passes expected version to repository", func() {
+		ts.SetupTest()
+		ts.mockStore.OnFind = func(id string) (domain.Task, error) {
+			return domain.Task{Status: domain.StatusTodo, Version: 3}, nil
+		}
+		ts.mockStore.OnUpdate = func(id string, in domain.Task, version int64) (domain.Task, error) {
+			ts.Equal(int64(3), version)
+			in.Version = version + 1
+			return in, nil
+		}
+
+		out, err := ts.handler.UpdateTask(context.Background(), ts.admin, domain.NewID().String(), domain.Task{Title: "On time"}, 3)
+
+		ts.Require().NoError(err)
+		ts.Equal(int64(4), out.Version)
+	})
+
+	ts.Run("Pins the validated version without If-Match", func() {
+		ts.SetupTest()
+		ts.mockStore.OnFind = func(id string) (domain.Task, error) {
+			return domain.Task{Status: domain.StatusInProgress, Version: 5}, nil
+		}
+		ts.mockStore.OnUpdate = func(id string, in domain.Task, version int64) (domain.Task, error) {
+			ts.Equal(int64(5), version)
+			return domain.Task{}, domain.ErrVersionConflict
+		}
+
+		_, err := ts.handler.UpdateTask(context.Background(), ts.admin, domain.NewID().String(), domain.Task{Status: domain.StatusDone}, 0)
+
+		ts.ErrorIs(err, domain.ErrVersionConflict)
+	})
+}
+
+func (ts *TaskUseCaseSuite) TestPatchTask() {
+	ts.Run("Passes only supplied fields", func() {
+		ts.SetupTest()
+		title := "Renamed"
+
+		ts.mockStore.OnPatch = func(id string, p domain.TaskPatch, version int64) (domain.Task, error) {
+			ts.Require().NotNil(p.Title)
+			ts.Equal(title, *p.Title)
+			ts.Nil(p.Description)
+			ts.Nil(p.Status)
+			return domain.Task{Title: *p.Title, Description: "untouched"}, nil
+		}
+
+		out, err := ts.handler.PatchTask(context.Background(), ts.admin, domain.NewID().String(), domain.TaskPatch{Title: &title}, 0)
+
+		ts.Require().NoError(err)
+		ts.Equal("Renamed", out.Title)
+		ts.Equal("untouched", out.Description)
+	})
+
+	ts.Run("Rejects empty patch before touching the repository", func() {
+		ts.SetupTest()
+
+		_, err := ts.handler.PatchTask(context.Background(), ts.admin, domain.NewID().String(), domain.TaskPatch{}, 0)
+
+		ts.ErrorIs(err, domain.ErrEmptyPatch)
+	})
+
+	ts.Run("Validates status transition", func() {
+		ts.SetupTest()
+		status := domain.StatusTodo
+		ts.mockStore.OnFind = func(id string) (domain.Task, error) {
+			return domain.Task{Status: domain.StatusDone}, nil
+		}
+
+		_, err := ts.handler.PatchTask(context.Background(), ts.admin, domain.NewID().String(), domain.TaskPatch{Status: &status}, 0)
+
+		var transitionErr *domain.TransitionError
+		ts.ErrorAs(err, &transitionErr)
+	})
+
+	ts.Run("Pins the validated version for status changes", func() {
+		ts.SetupTest()
+		status := domain.StatusDone
+		ts.mockStore.OnFind = func(id string) (domain.Task, error) {
+			return domain.Task{Status: domain.StatusInProgress, Version: 7}, nil
+		}
+		ts.mockStore.OnPatch = func(id string, p domain.TaskPatch, version int64) (domain.Task, error) {
+			ts.Equal(int64(7), version)
+			return domain.Task{Status: *p.Status, Version: version + 1}, nil
+		}
+
+		out, err := ts.handler.PatchTask(context.Background(), ts.admin, domain.NewID().String(), domain.TaskPatch{Status: &status}, 0)
+
+		ts.Require().NoError(err)
+		ts.Equal(int64(8), out.Version)
+	})
+
+	ts.Run("Rejects unknown assignee", func() {
+		ts.SetupTest()
+		assignee := domain.NewID()
+		ts.users.OnFindByID = func(id string) (domain.User, error) {
+			return domain.User{}, domain.ErrUserNotFound
+		}
+
+		_, err := ts.handler.PatchTask(context.Background(), ts.admin, domain.NewID().String(), domain.TaskPatch{AssigneeID: &assignee}, 0)
+
+		ts.ErrorIs(err, domain.ErrValidation)
+	})
+}
+
+func (ts *TaskUseCaseSuite) TestTransitionTask() {
+	ts.Run("Success", func() {
+		ts.SetupTest()
+		assignee := domain.ID(ts.member.UserID)
+		task := domain.Task{ID: domain.NewID(), AssigneeID: assignee, Status: domain.StatusTodo}
+
+		ts.mockStore.OnFind = func(id string) (domain.Task, error) {
+			return task, nil
+		}
+		ts.mockStore.OnMove = func(id string, from, to domain.TaskStatus) (domain.Task, error) {
+			ts.Equal(domain.StatusTodo, from)
+			moved := task
+			moved.Status = to
+			return moved, nil
+		}
+
+		out, err := ts.handler.TransitionTask(context.Background(), ts.member, task.ID.String(), domain.StatusInProgress)
+
+		ts.Require().NoError(err)
+		ts.Equal(domain.StatusInProgress, out.Status)
+	})
+
+	ts.Run("Rejects illegal transition", func() {
+		ts.SetupTest()
+		ts.mockStore.OnFind = func(id string) (domain.Task, error) {
+			return domain.Task{Status: domain.StatusDone}, nil
+		}
+
+		_, err := ts.handler.TransitionTask(context.Background(), ts.admin, domain.NewID().String(), domain.StatusBlocked)
+
+		var transitionErr *domain.TransitionError
+		ts.ErrorAs(err, &transitionErr)
+	})
+
+	ts.Run("Rejects unknown status", func() {
+		ts.SetupTest()
+		ts.mockStore.OnFind = func(id string) (domain.Task, error) {
+			return domain.Task{Status: domain.StatusTodo}, nil
+		}
+
+		_, err := ts.handler.TransitionTask(context.Background(), ts.admin, domain.NewID().String(), "archived")
+
+		ts.ErrorIs(err, domain.ErrInvalidStatus)
+	})
+
+	ts.Run("Hidden from unrelated user", func() {
+		ts.SetupTest()
+		ts.mockStore.OnFind = func(id string) (domain.Task, error) {
+			return domain.Task{CreatedBy: domain.NewID(), Status: domain.StatusTodo}, nil
+		}
+
+		_, err := ts.handler.TransitionTask(context.Background(), ts.member, domain.NewID().String(), domain.StatusDone)
+
+		ts.ErrorIs(err, domain.ErrNotFound)
+	})
 }
 
 func (ts *TaskUseCaseSuite) TestDeleteTask() {
 	ts.Run("Success", func() {
 		ts.SetupTest()
-		toRemove := primitive.NewObjectID()
+		toRemove := domain.NewID()
 
-		ts.mockStore.OnRemove = func(id string) error {
-			ts.Equal(toRemove.Hex(), id)
+		ts.mockStore.OnRemove = func(id string, version int64) error {
+			ts.Equal(toRemove.String(), id)
+			ts.Equal(int64(0), version)
 			return nil
 		}
 
-		err := ts.handler.DeleteTask(toRemove.Hex())
+		err := ts.handler.DeleteTask(context.Background(), ts.admin, toRemove.String(), 0)
 
 		ts.Require().NoError(err)
 	})
-}
\ No newline at end of file
+
+	ts.Run("Surfaces version conflicts", func() {
+		ts.SetupTest()
+		ts.mockStore.OnRemove = func(id string, version int64) error {
+			return domain.ErrVersionConflict
+		}
+
+		err := ts.handler.DeleteTask(context.Background(), ts.admin, domain.NewID().String(), 7)
+
+		ts.ErrorIs(err, domain.ErrVersionConflict)
+	})
+}
+
+func (ts *TaskUseCaseSuite) TestPermissions() {
+	ts.Run("Only roles with task:create can create", func() {
+		ts.SetupTest()
+		ts.mockStore.OnCreate = func(t domain.Task) (domain.Task, error) {
+			return t, nil
+		}
+
+		_, err := ts.handler.CreateTask(context.Background(), ts.maintainer, domain.Task{Title: "Plan"})
+		ts.NoError(err)
+
+		_, err = ts.handler.CreateTask(context.Background(), ts.member, domain.Task{Title: "Plan"})
+		ts.ErrorIs(err, domain.ErrForbidden)
+	})
+
+	ts.Run("Maintainer updates own tasks only", func() {
+		ts.SetupTest()
+		own := domain.Task{ID: domain.NewID(), CreatedBy: domain.ID(ts.maintainer.UserID), Status: domain.StatusTodo, Version: 1}
+		other := domain.Task{ID: domain.NewID(), CreatedBy: domain.NewID(), Status: domain.StatusTodo, Version: 1}
+		ts.mockStore.OnFind = func(id string) (domain.Task, error) {
+			if id == own.ID.String() {
+				return own, nil
+			}
+			return other, nil
+		}
+		ts.mockStore.OnUpdate = func(id string, t domain.Task, version int64) (domain.Task, error) {
+			return t, nil
+		}
+		ts.mockStore.OnPatch = func(id string, p domain.TaskPatch, version int64) (domain.Task, error) {
+			return own, nil
+		}
+		title := "Renamed"
+
+		_, err := ts.handler.UpdateTask(context.Background(), ts.maintainer, own.ID.String(), domain.Task{Title: title}, 0)
+		ts.NoError(err)
+		_, err = ts.handler.PatchTask(context.Background(), ts.maintainer, own.ID.String(), domain.TaskPatch{Title: &title}, 0)
+		ts.NoError(err)
+
+		_, err = ts.handler.UpdateTask(context.Background(), ts.maintainer, other.ID.String(), domain.Task{Title: title}, 0)
+		ts.ErrorIs(err, domain.ErrForbidden)
+		_, err = ts.handler.PatchTask(context.Background(), ts.maintainer, other.ID.String(), domain.TaskPatch{Title: &title}, 0)
+		ts.ErrorIs(err, domain.ErrForbidden)
+	})
+
+	ts.Run("Maintainer deletes own tasks only", func() {
+		ts.SetupTest()
+		own := domain.Task{ID: domain.NewID(), AssigneeID: domain.ID(ts.maintainer.UserID)}
+		ts.mockStore.OnFind = func(id string) (domain.Task, error) {
+			if id == own.ID.String() {
+				return own, nil
+			}
+			return domain.Task{CreatedBy: domain.NewID()}, nil
+		}
+		ts.mockStore.OnRemove = func(id string, version int64) error {
+			return nil
+		}
+
+		ts.NoError(ts.handler.DeleteTask(context.Background(), ts.maintainer, own.ID.String(), 0))
+		ts.ErrorIs(ts.handler.DeleteTask(context.Background(), ts.maintainer, domain.NewID().String(), 0), domain.ErrForbidden)
+	})
+
+	ts.Run("Member cannot update assigned tasks", func() {
+		ts.SetupTest()
+		ts.mockStore.OnFind = func(id string) (domain.Task, error) {
+			return domain.Task{AssigneeID: domain.ID(ts.member.UserID)}, nil
+		}
+		title := "Renamed"
+
+		_, err := ts.handler.PatchTask(context.Background(), ts.member, domain.NewID().String(), domain.TaskPatch{Title: &title}, 0)
+		ts.ErrorIs(err, domain.ErrForbidden)
+	})
+
+	ts.Run("Viewer reads but cannot transition", func() {
+		ts.SetupTest()
+		task := domain.Task{ID: domain.NewID(), AssigneeID: domain.ID(ts.viewer.UserID), Status: domain.StatusTodo}
+		ts.mockStore.OnFind = func(id string) (domain.Task, error) {
+			return task, nil
+		}
+
+		_, err := ts.handler.GetTaskByID(context.Background(), ts.viewer, task.ID.String())
+		ts.NoError(err)
+
+		_, err = ts.handler.TransitionTask(context.Background(), ts.viewer, task.ID.String(), domain.StatusInProgress)
+		ts.ErrorIs(err, domain.ErrForbidden)
+	})
+
+	ts.Run("Unknown roles see nothing", func() {
+		ts.SetupTest()
+		_, err := ts.handler.GetAllTasks(context.Background(), domain.Caller{UserID: domain.NewID().String(), Role: "guest"}, domain.TaskQuery{})
+		ts.ErrorIs(err, domain.ErrForbidden)
+	})
+}
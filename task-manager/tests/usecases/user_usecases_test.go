@@ -3,52 +3,133 @@ package usecases_test
 import (
 	"context"
 	"errors"
+	"fmt"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	domain "task-manager/Domain"
+	infrastructure "task-manager/Infrastructure"
+	repositories "task-manager/Repositories"
 	usecases "task-manager/Usecases"
 
 	"github.com/stretchr/testify/suite"
-	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 // StubRepo simulates UserRepository behaviors for testing
 type StubRepo struct {
 	OnRegister      func(domain.User) (domain.User, error)
-	OnLogin         func(domain.User) (domain.LoginResponse, error)
+	OnRegisterAdmin func(domain.User) (domain.User, error)
 	OnPromote       func(string) (domain.User, error)
 	OnFindByUsername func(string) (domain.User, error)
+	OnFindByID       func(string) (domain.User, error)
+	OnList           func(domain.UserQuery) ([]domain.User, int64, error)
+	OnSetRole        func(string, string) (domain.User, error)
+	OnSetDisabled    func(string, bool) (domain.User, error)
+	OnDelete         func(string) error
+	OnUpdateProfile  func(string, domain.ProfilePatch) (domain.User, error)
+	OnSetPassword    func(string, string) error
+	OnMarkVerified   func(string, string) (domain.User, error)
 }
 
-func (r *StubRepo) RegisterUser(u domain.User) (domain.User, error) {
+func (r *StubRepo) RegisterUser(_ context.Context, u domain.User) (domain.User, error) {
 	return r.OnRegister(u)
 }
-func (r *StubRepo) LoginUser(u domain.User) (domain.LoginResponse, error) {
-	return r.OnLogin(u)
+func (r *StubRepo) RegisterFirstAdmin(_ context.Context, u domain.User) (domain.User, error) {
+	return r.OnRegisterAdmin(u)
 }
-func (r *StubRepo) PromoteUser(id string) (domain.User, error) {
+func (r *StubRepo) PromoteUser(_ context.Context, id string) (domain.User, error) {
 	return r.OnPromote(id)
 }
-func (r *StubRepo) GetUserByUsername(username string) (domain.User, error) {
+func (r *StubRepo) GetUserByUsername(_ context.Context, username string) (domain.User, error) {
 	return r.OnFindByUsername(username)
 }
+func (r *StubRepo) GetUserByID(_ context.Context, id string) (domain.User, error) {
+	return r.OnFindByID(id)
+}
+func (r *StubRepo) ListUsers(_ context.Context, q domain.UserQuery) ([]domain.User, int64, error) {
+	return r.OnList(q)
+}
+func (r *StubRepo) SetUserRole(_ context.Context, id string, role string) (domain.User, error) {
+	return r.OnSetRole(id, role)
+}
+func (r *StubRepo) SetUserDisabled(_ context.Context, id string, disabled bool) (domain.User, error) {
+	return r.OnSetDisabled(id, disabled)
+}
+func (r *StubRepo) DeleteUser(_ context.Context, id string) error {
+	return r.OnDelete(id)
+}
+func (r *StubRepo) UpdateProfile(_ context.Context, id string, patch domain.ProfilePatch) (domain.User, error) {
+	return r.OnUpdateProfile(id, patch)
+}
+func (r *StubRepo) SetPassword(_ context.Context, id string, hash string) error {
+	return r.OnSetPassword(id, hash)
+}
+func (r *StubRepo) MarkEmailVerified(_ context.Context, id string, email string) (domain.User, error) {
+	return r.OnMarkVerified(id, email)
+}
+
+// StubJWT issues numbered tokens so tests can tell them apart
+type StubJWT struct {
+	issued int
+}
+
+func (j *StubJWT) GenerateToken(userID, username, role string) (string, error) {
+	j.issued++
+	return fmt.Sprintf("%s-%s-%d", username, role, j.issued), nil
+}
+func (j *StubJWT) ValidateToken(token string) (map[string]interface{}, error) {
+	return nil, domain.Unauthorized("not supported")
+}
+
+// StubAuditLog keeps the entries it is asked to record
+type StubAuditLog struct {
+	Entries []domain.AuditEntry
+}
+
+func (l *StubAuditLog) Record(entry domain.AuditEntry) error {
+	l.Entries = append(l.Entries, entry)
+	return nil
+}
+
+// testPassword is the password of users set up with withCredentials
+const testPassword = "password123"
 
 // UserUseCaseSuite is the testing suite for user-related use cases
 type UserUseCaseSuite struct {
 	suite.Suite
 	repo    *StubRepo
+	tasks   *StubTaskRepo
+	tokens  domain.TokenRepository
+	mfa     domain.MFARepository
+	passwords domain.PasswordService
 	service *usecases.UserUsecase
 	ctx     context.Context
+	admin   domain.Caller
+	// passwordHash is a hash of testPassword, computed once since bcrypt is slow
+	passwordHash string
 }
 
 func TestUserUseCaseSuite(t *testing.T) {
 	suite.Run(t, &UserUseCaseSuite{})
 }
 
+func (s *UserUseCaseSuite) SetupSuite() {
+	hash, err := infrastructure.NewPasswordService().HashPassword(testPassword)
+	s.Require().NoError(err)
+	s.passwordHash = hash
+}
+
 func (s *UserUseCaseSuite) SetupTest() {
 	s.repo = &StubRepo{}
-	s.service = usecases.NewUserUsecase(s.repo)
+	s.tasks = &StubTaskRepo{}
+	s.tokens = repositories.NewMemoryTokenRepository()
+	s.mfa = repositories.NewMemoryMFARepository()
+	s.passwords = infrastructure.NewPasswordService()
+	s.service = usecases.NewUserUsecase(s.repo, s.tasks, s.tokens, s.mfa, repositories.NewMemoryLoginThrottleRepository(), &StubJWT{}, s.passwords, infrastructure.NewTOTPService("Task Manager"), &StubAuditLog{})
 	s.ctx = context.TODO()
+	s.admin = domain.Caller{UserID: domain.NewID().String(), Username: "boss", Role: domain.RoleAdmin}
 }
 
 func (s *UserUseCaseSuite) TestRegisterUser() {
@@ -56,67 +137,375 @@ func (s *UserUseCaseSuite) TestRegisterUser() {
 		s.SetupTest()
 		input := domain.User{Username: "john", Password: "secure123"}
 		mocked := input
-		mocked.ID = primitive.NewObjectID()
-		mocked.Role = "user"
+		mocked.ID = domain.NewID()
+		mocked.Role = domain.RoleMember
 
 		s.repo.OnRegister = func(u domain.User) (domain.User, error) {
 			u.ID = mocked.ID
-			u.Role = "user"
+			u.Role = domain.RoleMember
 			return u, nil
 		}
 
-		res, err := s.service.RegisterUser(input)
+		res, err := s.service.RegisterUser(context.Background(), input)
 		s.Require().NoError(err)
 		s.Equal(mocked.Username, res.Username)
 		s.Equal(mocked.ID, res.ID)
-		s.Equal("user", res.Role)
+		s.Equal(domain.RoleMember, res.Role)
 	})
 
 	s.Run("should fail when username is taken", func() {
 		s.SetupTest()
 		s.repo.OnRegister = func(u domain.User) (domain.User, error) {
-			return domain.User{}, errors.New("username already taken")
+			return domain.User{}, domain.Conflict("username already taken")
+		}
+		_, err := s.service.RegisterUser(context.Background(), domain.User{Username: "john", Password: "secure123"})
+		s.ErrorIs(err, domain.ErrConflict)
+	})
+
+	s.Run("should never register admins", func() {
+		s.SetupTest()
+		s.repo.OnRegister = func(u domain.User) (domain.User, error) {
+			return u, nil
+		}
+		res, err := s.service.RegisterUser(context.Background(), domain.User{Username: "john", Password: "secure123", Role: domain.RoleAdmin})
+		s.Require().NoError(err)
+		s.Equal(domain.RoleMember, res.Role)
+	})
+
+	s.Run("should require a username and a password", func() {
+		s.SetupTest()
+		_, err := s.service.RegisterUser(context.Background(), domain.User{Username: "john"})
+		s.ErrorIs(err, domain.ErrValidation)
+		_, err = s.service.RegisterUser(context.Background(), domain.User{Password: "secure123"})
+		s.ErrorIs(err, domain.ErrValidation)
+	})
+
+	s.Run("should apply the password policy", func() {
+		s.SetupTest()
+		_, err := s.service.RegisterUser(context.Background(), domain.User{Username: "john", Password: "john"})
+		var policyErr *domain.PasswordPolicyError
+		s.Require().True(errors.As(err, &policyErr), "got %v", err)
+		s.ErrorIs(err, domain.ErrValidation)
+
+		var rules []string
+		for _, violation := range policyErr.Violations {
+			rules = append(rules, violation.Rule)
 		}
-		_, err := s.service.RegisterUser(domain.User{Username: "john"})
-		s.Error(err)
+		s.Equal([]string{domain.PasswordRuleMinLength, domain.PasswordRuleClasses, domain.PasswordRuleUsername}, rules)
+	})
+
+	s.Run("should reject invalid email addresses", func() {
+		s.SetupTest()
+		_, err := s.service.RegisterUser(context.Background(), domain.User{Username: "john", Password: "secure123", Email: "john"})
+		s.ErrorIs(err, domain.ErrValidation)
+	})
+}
+
+func (s *UserUseCaseSuite) TestSetupAdmin() {
+	const token = "setup-token-0123456789"
+	var created []domain.User
+
+	setup := func() {
+		s.SetupTest()
+		created = nil
+		s.repo.OnRegisterAdmin = func(u domain.User) (domain.User, error) {
+			if len(created) > 0 {
+				return domain.User{}, domain.ErrSetupComplete
+			}
+			u.Role = domain.RoleAdmin
+			created = append(created, u)
+			return u, nil
+		}
+	}
+
+	s.Run("should be off without a token", func() {
+		setup()
+		_, err := s.service.SetupAdmin(context.Background(), "", domain.User{Username: "root", Password: "secure123"})
+		s.ErrorIs(err, domain.ErrInvalidSetupToken)
+		s.Empty(created)
+	})
+
+	s.Run("should create one admin with the token", func() {
+		setup()
+		s.service.SetAdminSetupToken(token)
+
+		_, err := s.service.SetupAdmin(context.Background(), "wrong", domain.User{Username: "root", Password: "secure123"})
+		s.ErrorIs(err, domain.ErrInvalidSetupToken)
+
+		_, err = s.service.SetupAdmin(context.Background(), token, domain.User{Username: "root", Password: "root"})
+		s.ErrorIs(err, domain.ErrValidation, "the password policy applies")
+
+		admin, err := s.service.SetupAdmin(context.Background(), token, domain.User{Username: "root", Password: "secure123"})
+		s.Require().NoError(err)
+		s.Equal(domain.RoleAdmin, admin.Role)
+
+		_, err = s.service.SetupAdmin(context.Background(), token, domain.User{Username: "root2", Password: "secure123"})
+		s.ErrorIs(err, domain.ErrSetupComplete)
+		s.Len(created, 1)
+	})
+
+	s.Run("should let only one of concurrent calls through", func() {
+		users := repositories.NewMemoryUserRepository(s.passwords)
+		// Separate usecases stand in for instances sharing the database
+		var wg sync.WaitGroup
+		for i := 0; i < 5; i++ {
+			service := usecases.NewUserUsecase(users, s.tasks, s.tokens, s.mfa, repositories.NewMemoryLoginThrottleRepository(), &StubJWT{}, s.passwords, infrastructure.NewTOTPService("Task Manager"), &StubAuditLog{})
+			service.SetAdminSetupToken(token)
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				service.SetupAdmin(context.Background(), token, domain.User{Username: fmt.Sprintf("root%d", i), Password: "secure123"})
+			}(i)
+		}
+		wg.Wait()
+
+		_, admins, err := users.ListUsers(context.Background(), domain.UserQuery{Role: domain.RoleAdmin})
+		s.Require().NoError(err)
+		s.Equal(int64(1), admins)
 	})
 }
 
 func (s *UserUseCaseSuite) TestLoginUser() {
 	s.Run("should authenticate with correct credentials", func() {
 		s.SetupTest()
-		input := domain.User{Username: "jane", Password: "pass123"}
-		mockResp := domain.LoginResponse{
-			ID:       primitive.NewObjectID(),
-			Username: "jane",
-			Token:    "valid-token",
+		user := domain.User{ID: domain.NewID(), Username: "jane", Role: domain.RoleMember}
+
+		resp := s.login(user)
+		s.Equal(user.ID, resp.ID)
+		s.Equal("jane-member-1", resp.Token)
+		s.NotEmpty(resp.RefreshToken, "login should issue a refresh token")
+		s.False(resp.MFARequired)
+	})
+
+	s.Run("should reject invalid login", func() {
+		s.SetupTest()
+		s.withCredentials(domain.User{ID: domain.NewID(), Username: "jane", Role: domain.RoleMember})
+
+		_, err := s.service.LoginUser(context.Background(), domain.User{Username: "jane", Password: "wrong"}, "")
+		s.ErrorIs(err, domain.ErrUnauthorized)
+		_, err = s.service.LoginUser(context.Background(), domain.User{Username: "ghost", Password: testPassword}, "")
+		s.ErrorIs(err, domain.ErrUnauthorized)
+		_, err = s.service.LoginUser(context.Background(), domain.User{Password: testPassword}, "")
+		s.ErrorIs(err, domain.ErrValidation)
+	})
+
+	s.Run("should only reveal disabled accounts to the password holder", func() {
+		s.SetupTest()
+		s.withCredentials(domain.User{ID: domain.NewID(), Username: "jane", Role: domain.RoleMember, Disabled: true})
+
+		_, err := s.service.LoginUser(context.Background(), domain.User{Username: "jane", Password: "wrong"}, "")
+		s.ErrorIs(err, domain.ErrUnauthorized)
+		_, err = s.service.LoginUser(context.Background(), domain.User{Username: "jane", Password: testPassword}, "")
+		s.ErrorIs(err, domain.ErrAccountDisabled)
+	})
+
+	s.Run("should apply the verified email policy", func() {
+		s.SetupTest()
+		s.service.SetLoginPolicy(usecases.LoginPolicy{RequireVerifiedEmail: true})
+		user := domain.User{ID: domain.NewID(), Username: "jane", Email: "jane@example.com"}
+		s.withCredentials(user)
+
+		_, err := s.service.LoginUser(context.Background(), domain.User{Username: "jane", Password: testPassword}, "")
+		s.ErrorIs(err, domain.ErrEmailNotVerified)
+
+		user.EmailVerified = true
+		resp := s.login(user)
+		s.NotEmpty(resp.RefreshToken)
+	})
+
+	s.Run("should upgrade outdated password hashes", func() {
+		s.SetupTest()
+		var saved string
+		s.repo.OnSetPassword = func(id string, hash string) error {
+			saved = hash
+			return nil
 		}
 
-		s.repo.OnLogin = func(u domain.User) (domain.LoginResponse, error) {
-			return mockResp, nil
+		// The current hash is kept
+		s.login(domain.User{ID: domain.NewID(), Username: "jane", Role: domain.RoleMember})
+		s.Empty(saved)
+
+		argon, err := infrastructure.NewPasswordServiceWithHashing(infrastructure.PasswordHashing{
+			Algorithm: infrastructure.AlgorithmArgon2id,
+			Argon2:    infrastructure.Argon2Params{Time: 1, Memory: 64, Threads: 1, SaltLen: 16, KeyLen: 32},
+		})
+		s.Require().NoError(err)
+		s.service = usecases.NewUserUsecase(s.repo, s.tasks, s.tokens, s.mfa, repositories.NewMemoryLoginThrottleRepository(), &StubJWT{}, argon, infrastructure.NewTOTPService("Task Manager"), &StubAuditLog{})
+
+		// A wrong password changes nothing
+		_, err = s.service.LoginUser(context.Background(), domain.User{Username: "jane", Password: "wrong"}, "")
+		s.ErrorIs(err, domain.ErrUnauthorized)
+		s.Empty(saved)
+
+		s.login(domain.User{ID: domain.NewID(), Username: "jane", Role: domain.RoleMember})
+		s.True(strings.HasPrefix(saved, "$argon2id$"), saved)
+		s.NoError(argon.ComparePassword(saved, testPassword))
+		s.False(argon.NeedsRehash(saved))
+	})
+}
+
+// withCredentials makes the stub repository find the user, whose password
+// is testPassword
+func (s *UserUseCaseSuite) withCredentials(user domain.User) {
+	user.Password = s.passwordHash
+	s.repo.OnFindByUsername = func(username string) (domain.User, error) {
+		if username != user.Username {
+			return domain.User{}, domain.ErrUserNotFound
+		}
+		return user, nil
+	}
+	s.repo.OnFindByID = func(id string) (domain.User, error) {
+		if id != user.ID.String() {
+			return domain.User{}, domain.ErrUserNotFound
 		}
+		return user, nil
+	}
+}
+
+// login signs in a user through the stub repository and returns the response
+func (s *UserUseCaseSuite) login(user domain.User) domain.LoginResponse {
+	s.withCredentials(user)
+	resp, err := s.service.LoginUser(context.Background(), domain.User{Username: user.Username, Password: testPassword}, "")
+	s.Require().NoError(err)
+	return resp
+}
 
-		token, err := s.service.LoginUser(input)
+func (s *UserUseCaseSuite) TestRefreshToken() {
+	s.Run("should rotate the refresh token", func() {
+		s.SetupTest()
+		user := domain.User{ID: domain.NewID(), Username: "jane", Role: domain.RoleMember}
+		first := s.login(user)
+
+		second, err := s.service.RefreshToken(context.Background(), first.RefreshToken)
+		s.Require().NoError(err)
+		s.Equal(user.ID, second.ID)
+		s.Equal("jane-member-2", second.Token)
+		s.NotEmpty(second.RefreshToken)
+		s.NotEqual(first.RefreshToken, second.RefreshToken)
+
+		third, err := s.service.RefreshToken(context.Background(), second.RefreshToken)
 		s.Require().NoError(err)
-		s.Equal(mockResp, token)
+		s.NotEqual(second.Token, third.Token)
 	})
 
-	s.Run("should reject invalid login", func() {
+	s.Run("should pick up role changes", func() {
+		s.SetupTest()
+		user := domain.User{ID: domain.NewID(), Username: "jane", Role: domain.RoleMember}
+		first := s.login(user)
+
+		s.repo.OnFindByID = func(string) (domain.User, error) {
+			promoted := user
+			promoted.Role = "admin"
+			return promoted, nil
+		}
+		resp, err := s.service.RefreshToken(context.Background(), first.RefreshToken)
+		s.Require().NoError(err)
+		s.Equal("jane-admin-2", resp.Token)
+	})
+
+	s.Run("should revoke the family when a rotated token is reused", func() {
+		s.SetupTest()
+		user := domain.User{ID: domain.NewID(), Username: "jane", Role: domain.RoleMember}
+		first := s.login(user)
+
+		second, err := s.service.RefreshToken(context.Background(), first.RefreshToken)
+		s.Require().NoError(err)
+
+		_, err = s.service.RefreshToken(context.Background(), first.RefreshToken)
+		s.ErrorIs(err, domain.ErrUnauthorized)
+
+		_, err = s.service.RefreshToken(context.Background(), second.RefreshToken)
+		s.ErrorIs(err, domain.ErrUnauthorized, "tokens rotated from a reused token must stop working")
+	})
+
+	s.Run("should reject unknown and expired tokens", func() {
 		s.SetupTest()
-		s.repo.OnLogin = func(u domain.User) (domain.LoginResponse, error) {
-			return domain.LoginResponse{}, errors.New("invalid credentials")
+		_, err := s.service.RefreshToken(context.Background(), "unknown")
+		s.ErrorIs(err, domain.ErrUnauthorized)
+
+		user := domain.User{ID: domain.NewID(), Username: "jane", Role: domain.RoleMember}
+		s.login(user)
+		s.Require().NoError(s.tokens.SaveRefreshToken(context.Background(), domain.RefreshToken{
+			TokenHash: "stale",
+			UserID:    user.ID,
+			FamilyID:  domain.NewID(),
+			ExpiresAt: time.Now().Add(-time.Minute),
+		}))
+		_, err = s.service.RefreshToken(context.Background(), "stale")
+		s.ErrorIs(err, domain.ErrUnauthorized)
+	})
+
+	s.Run("should reject tokens of deleted users", func() {
+		s.SetupTest()
+		user := domain.User{ID: domain.NewID(), Username: "jane", Role: domain.RoleMember}
+		first := s.login(user)
+
+		s.repo.OnFindByID = func(string) (domain.User, error) {
+			return domain.User{}, domain.ErrUserNotFound
+		}
+		_, err := s.service.RefreshToken(context.Background(), first.RefreshToken)
+		s.ErrorIs(err, domain.ErrUnauthorized)
+	})
+
+	s.Run("should reject tokens of disabled users", func() {
+		s.SetupTest()
+		user := domain.User{ID: domain.NewID(), Username: "jane", Role: domain.RoleMember}
+		first := s.login(user)
+
+		s.repo.OnFindByID = func(string) (domain.User, error) {
+			disabled := user
+			disabled.Disabled = true
+			return disabled, nil
 		}
-		_, err := s.service.LoginUser(domain.User{Username: "jane", Password: "wrong"})
-		s.Error(err)
+		_, err := s.service.RefreshToken(context.Background(), first.RefreshToken)
+		s.ErrorIs(err, domain.ErrAccountDisabled)
+	})
+}
+
+func (s *UserUseCaseSuite) TestLogout() {
+	s.Run("should revoke the access token and refresh token family", func() {
+		s.SetupTest()
+		user := domain.User{ID: domain.NewID(), Username: "jane", Role: domain.RoleMember}
+		first := s.login(user)
+		second, err := s.service.RefreshToken(context.Background(), first.RefreshToken)
+		s.Require().NoError(err)
+
+		caller := domain.Caller{UserID: user.ID.String(), TokenID: "jti-1", TokenExpiresAt: time.Now().Add(time.Minute)}
+		s.Require().NoError(s.service.Logout(context.Background(), caller, second.RefreshToken))
+
+		revoked, err := s.tokens.IsAccessTokenRevoked(context.Background(), "jti-1")
+		s.Require().NoError(err)
+		s.True(revoked)
+
+		_, err = s.service.RefreshToken(context.Background(), second.RefreshToken)
+		s.ErrorIs(err, domain.ErrUnauthorized)
+	})
+
+	s.Run("should leave refresh tokens of other users alone", func() {
+		s.SetupTest()
+		user := domain.User{ID: domain.NewID(), Username: "jane", Role: domain.RoleMember}
+		resp := s.login(user)
+
+		intruder := domain.Caller{UserID: domain.NewID().String(), TokenID: "jti-2", TokenExpiresAt: time.Now().Add(time.Minute)}
+		s.Require().NoError(s.service.Logout(context.Background(), intruder, resp.RefreshToken))
+
+		_, err := s.service.RefreshToken(context.Background(), resp.RefreshToken)
+		s.NoError(err)
+	})
+
+	s.Run("should ignore unknown refresh tokens", func() {
+		s.SetupTest()
+		caller := domain.Caller{UserID: domain.NewID().String(), TokenID: "jti-3", TokenExpiresAt: time.Now().Add(time.Minute)}
+		s.NoError(s.service.Logout(context.Background(), caller, "unknown"))
 	})
 }
 
 func (s *UserUseCaseSuite) TestPromoteUser() {
 	s.Run("should promote user to admin", func() {
 		s.SetupTest()
-		userID := primitive.NewObjectID().Hex()
+		userID := domain.NewID().String()
 		mockUser := domain.User{
-			ID:       primitive.NewObjectID(),
+			ID:       domain.NewID(),
 			Username: "moderator",
 			Role:     "admin",
 		}
@@ -125,7 +514,7 @@ func (s *UserUseCaseSuite) TestPromoteUser() {
 			return mockUser, nil
 		}
 
-		res, err := s.service.PromoteUser(userID)
+		res, err := s.service.PromoteUser(context.Background(), s.admin, userID)
 		s.NoError(err)
 		s.Equal("admin", res.Role)
 		s.Equal(mockUser.Username, res.Username)
@@ -134,10 +523,17 @@ func (s *UserUseCaseSuite) TestPromoteUser() {
 	s.Run("should error if user not found", func() {
 		s.SetupTest()
 		s.repo.OnPromote = func(id string) (domain.User, error) {
-			return domain.User{}, errors.New("not found")
+			return domain.User{}, domain.ErrUserNotFound
 		}
-		_, err := s.service.PromoteUser("invalid-id")
-		s.Error(err)
+		_, err := s.service.PromoteUser(context.Background(), s.admin, "invalid-id")
+		s.ErrorIs(err, domain.ErrNotFound)
+	})
+
+	s.Run("should require the promote permission", func() {
+		s.SetupTest()
+		maintainer := domain.Caller{UserID: domain.NewID().String(), Role: domain.RoleMaintainer}
+		_, err := s.service.PromoteUser(context.Background(), maintainer, domain.NewID().String())
+		s.ErrorIs(err, domain.ErrForbidden)
 	})
 }
 
@@ -146,9 +542,10 @@ func (s *UserUseCaseSuite) TestGetUserByUsername() {
 		s.SetupTest()
 		uname := "alex"
 		expected := domain.User{
-			ID:       primitive.NewObjectID(),
+			ID:       domain.NewID(),
 			Username: uname,
-			Role:     "user",
+			Password: "$2a$10$hash",
+			Role:     domain.RoleMember,
 		}
 
 		s.repo.OnFindByUsername = func(name string) (domain.User, error) {
@@ -156,17 +553,384 @@ func (s *UserUseCaseSuite) TestGetUserByUsername() {
 			return expected, nil
 		}
 
-		u, err := s.service.GetUserByUsername(uname)
+		u, err := s.service.GetUserByUsername(context.Background(), s.admin, uname)
 		s.NoError(err)
 		s.Equal(expected.ID, u.ID)
+		s.Empty(u.Password, "password hashes never leave the usecase")
 	})
 
 	s.Run("should fail for unknown user", func() {
 		s.SetupTest()
 		s.repo.OnFindByUsername = func(name string) (domain.User, error) {
-			return domain.User{}, errors.New("user not found")
+			return domain.User{}, domain.ErrUserNotFound
 		}
-		_, err := s.service.GetUserByUsername("ghost")
-		s.Error(err)
+		_, err := s.service.GetUserByUsername(context.Background(), s.admin, "ghost")
+		s.ErrorIs(err, domain.ErrNotFound)
 	})
-}
\ No newline at end of file
+
+	s.Run("should reject unknown roles", func() {
+		s.SetupTest()
+		_, err := s.service.GetUserByUsername(context.Background(), domain.Caller{UserID: domain.NewID().String(), Role: "guest"}, "alex")
+		s.ErrorIs(err, domain.ErrForbidden)
+	})
+}
+
+// withUsers serves GetUserByID and admin counts from the given users
+func (s *UserUseCaseSuite) withUsers(users ...domain.User) {
+	s.repo.OnFindByID = func(id string) (domain.User, error) {
+		for _, user := range users {
+			if user.ID.String() == id {
+				return user, nil
+			}
+		}
+		return domain.User{}, domain.ErrUserNotFound
+	}
+	s.repo.OnList = func(q domain.UserQuery) ([]domain.User, int64, error) {
+		var matched []domain.User
+		for _, user := range users {
+			if (q.Role == "" || user.Role == q.Role) && (q.Disabled == nil || user.Disabled == *q.Disabled) {
+				matched = append(matched, user)
+			}
+		}
+		return matched, int64(len(matched)), nil
+	}
+}
+
+func (s *UserUseCaseSuite) TestListUsers() {
+	s.Run("should apply the default page size", func() {
+		s.SetupTest()
+		s.repo.OnList = func(q domain.UserQuery) ([]domain.User, int64, error) {
+			s.Equal(usecases.DefaultUserPageSize, q.Limit)
+			s.Equal(domain.RoleViewer, q.Role)
+			return []domain.User{{ID: domain.NewID(), Username: "jane"}}, 1, nil
+		}
+
+		page, err := s.service.ListUsers(context.Background(), s.admin, domain.UserQuery{Role: domain.RoleViewer})
+		s.Require().NoError(err)
+		s.Len(page.Users, 1)
+		s.Equal(int64(1), page.Total)
+		s.Equal(usecases.DefaultUserPageSize, page.Limit)
+	})
+
+	s.Run("should cap the page size", func() {
+		s.SetupTest()
+		s.repo.OnList = func(q domain.UserQuery) ([]domain.User, int64, error) {
+			s.Equal(usecases.MaxUserPageSize, q.Limit)
+			return nil, 0, nil
+		}
+		_, err := s.service.ListUsers(context.Background(), s.admin, domain.UserQuery{Limit: 1000})
+		s.NoError(err)
+	})
+
+	s.Run("should reject invalid queries", func() {
+		s.SetupTest()
+		for _, q := range []domain.UserQuery{{Offset: -1}, {Limit: -1}, {Role: "superuser"}} {
+			_, err := s.service.ListUsers(context.Background(), s.admin, q)
+			s.ErrorIs(err, domain.ErrValidation)
+		}
+	})
+
+	s.Run("should require the manage permission", func() {
+		s.SetupTest()
+		maintainer := domain.Caller{UserID: domain.NewID().String(), Role: domain.RoleMaintainer}
+		_, err := s.service.ListUsers(context.Background(), maintainer, domain.UserQuery{})
+		s.ErrorIs(err, domain.ErrForbidden)
+	})
+}
+
+func (s *UserUseCaseSuite) TestSetUserRole() {
+	admin := domain.User{ID: domain.NewID(), Username: "boss", Role: domain.RoleAdmin}
+	member := domain.User{ID: domain.NewID(), Username: "jane", Role: domain.RoleMember}
+
+	s.Run("should change the role", func() {
+		s.SetupTest()
+		s.withUsers(admin, member)
+		s.repo.OnSetRole = func(id string, role string) (domain.User, error) {
+			s.Equal(member.ID.String(), id)
+			updated := member
+			updated.Role = role
+			return updated, nil
+		}
+
+		user, err := s.service.SetUserRole(context.Background(), s.admin, member.ID.String(), domain.RoleMaintainer)
+		s.Require().NoError(err)
+		s.Equal(domain.RoleMaintainer, user.Role)
+	})
+
+	s.Run("should reject unknown roles", func() {
+		s.SetupTest()
+		_, err := s.service.SetUserRole(context.Background(), s.admin, member.ID.String(), "superuser")
+		s.ErrorIs(err, domain.ErrValidation)
+	})
+
+	s.Run("should not demote the last admin", func() {
+		s.SetupTest()
+		s.repo.OnSetRole = func(id string, role string) (domain.User, error) {
+			return domain.User{}, domain.ErrLastAdmin
+		}
+		_, err := s.service.SetUserRole(context.Background(), s.admin, admin.ID.String(), domain.RoleMember)
+		s.ErrorIs(err, domain.ErrLastAdmin)
+	})
+
+	s.Run("should require the manage permission", func() {
+		s.SetupTest()
+		maintainer := domain.Caller{UserID: domain.NewID().String(), Role: domain.RoleMaintainer}
+		_, err := s.service.SetUserRole(context.Background(), maintainer, member.ID.String(), domain.RoleAdmin)
+		s.ErrorIs(err, domain.ErrForbidden)
+	})
+}
+
+func (s *UserUseCaseSuite) TestSetUserDisabled() {
+	admin := domain.User{ID: domain.NewID(), Username: "boss", Role: domain.RoleAdmin}
+	member := domain.User{ID: domain.NewID(), Username: "jane", Role: domain.RoleMember}
+
+	s.Run("should disable and enable users", func() {
+		s.SetupTest()
+		s.withUsers(admin, member)
+		s.repo.OnSetDisabled = func(id string, disabled bool) (domain.User, error) {
+			updated := member
+			updated.Disabled = disabled
+			return updated, nil
+		}
+
+		user, err := s.service.SetUserDisabled(context.Background(), s.admin, member.ID.String(), true)
+		s.Require().NoError(err)
+		s.True(user.Disabled)
+
+		user, err = s.service.SetUserDisabled(context.Background(), s.admin, member.ID.String(), false)
+		s.Require().NoError(err)
+		s.False(user.Disabled)
+	})
+
+	s.Run("should not disable the last admin", func() {
+		s.SetupTest()
+		s.repo.OnSetDisabled = func(id string, disabled bool) (domain.User, error) {
+			return domain.User{}, domain.ErrLastAdmin
+		}
+		_, err := s.service.SetUserDisabled(context.Background(), s.admin, admin.ID.String(), true)
+		s.ErrorIs(err, domain.ErrLastAdmin)
+	})
+}
+
+func (s *UserUseCaseSuite) TestDeleteUser() {
+	admin := domain.User{Username: "boss", Role: domain.RoleAdmin}
+	member := domain.User{ID: domain.NewID(), Username: "jane", Role: domain.RoleMember}
+	maintainer := domain.User{ID: domain.NewID(), Username: "lead", Role: domain.RoleMaintainer}
+
+	// deleted records which user was deleted
+	deleted := func() *string {
+		var id string
+		s.repo.OnDelete = func(got string) error {
+			id = got
+			return nil
+		}
+		return &id
+	}
+
+	s.Run("should reassign tasks to the caller by default", func() {
+		s.SetupTest()
+		admin.ID = domain.ID(s.admin.UserID)
+		s.withUsers(admin, member)
+		id := deleted()
+		s.tasks.OnReassign = func(from, to domain.ID) error {
+			s.Equal(member.ID, from)
+			s.Equal(admin.ID, to)
+			return nil
+		}
+
+		s.Require().NoError(s.service.DeleteUser(context.Background(), s.admin, member.ID.String(), domain.DeleteUserOptions{}))
+		s.Equal(member.ID.String(), *id)
+	})
+
+	s.Run("should reassign tasks to the given user", func() {
+		s.SetupTest()
+		s.withUsers(member, maintainer)
+		deleted()
+		s.tasks.OnReassign = func(from, to domain.ID) error {
+			s.Equal(maintainer.ID, to)
+			return nil
+		}
+
+		err := s.service.DeleteUser(context.Background(), s.admin, member.ID.String(), domain.DeleteUserOptions{
+			Tasks: domain.TaskPolicyReassign, ReassignTo: maintainer.ID,
+		})
+		s.NoError(err)
+	})
+
+	s.Run("should delete created tasks and unassign the rest", func() {
+		s.SetupTest()
+		s.withUsers(member)
+		deleted()
+		var calls []string
+		s.tasks.OnDeleteCreatedBy = func(id domain.ID) error {
+			calls = append(calls, "delete "+id.String())
+			return nil
+		}
+		s.tasks.OnUnassign = func(id domain.ID) error {
+			calls = append(calls, "unassign "+id.String())
+			return nil
+		}
+
+		err := s.service.DeleteUser(context.Background(), s.admin, member.ID.String(), domain.DeleteUserOptions{Tasks: domain.TaskPolicyDelete})
+		s.Require().NoError(err)
+		s.Equal([]string{"delete " + member.ID.String(), "unassign " + member.ID.String()}, calls)
+	})
+
+	s.Run("should reject invalid reassignment targets", func() {
+		s.SetupTest()
+		s.withUsers(member)
+		for _, to := range []domain.ID{member.ID, domain.NewID(), "not-an-id"} {
+			err := s.service.DeleteUser(context.Background(), s.admin, member.ID.String(), domain.DeleteUserOptions{ReassignTo: to})
+			s.ErrorIs(err, domain.ErrValidation, "reassign to %s", to)
+		}
+	})
+
+	s.Run("should reject unknown task policies", func() {
+		s.SetupTest()
+		s.withUsers(member)
+		err := s.service.DeleteUser(context.Background(), s.admin, member.ID.String(), domain.DeleteUserOptions{Tasks: "archive"})
+		s.ErrorIs(err, domain.ErrValidation)
+	})
+
+	s.Run("should not delete the last admin", func() {
+		s.SetupTest()
+		admin.ID = domain.ID(s.admin.UserID)
+		s.withUsers(admin, member)
+		err := s.service.DeleteUser(context.Background(), s.admin, admin.ID.String(), domain.DeleteUserOptions{ReassignTo: member.ID})
+		s.ErrorIs(err, domain.ErrLastAdmin)
+	})
+
+	s.Run("should require the manage permission", func() {
+		s.SetupTest()
+		caller := domain.Caller{UserID: maintainer.ID.String(), Role: domain.RoleMaintainer}
+		err := s.service.DeleteUser(context.Background(), caller, member.ID.String(), domain.DeleteUserOptions{})
+		s.ErrorIs(err, domain.ErrForbidden)
+	})
+}
+
+func (s *UserUseCaseSuite) TestProfile() {
+	caller := domain.Caller{UserID: domain.NewID().String(), Username: "jane", Role: domain.RoleViewer}
+	stored := domain.User{ID: domain.ID(caller.UserID), Username: "jane", Password: "$2a$10$hash", Role: domain.RoleViewer}
+
+	s.Run("should return the caller without the password", func() {
+		s.SetupTest()
+		s.withUsers(stored)
+		user, err := s.service.GetProfile(context.Background(), caller)
+		s.Require().NoError(err)
+		s.Equal(stored.ID, user.ID)
+		s.Empty(user.Password)
+	})
+
+	s.Run("should rename the caller", func() {
+		s.SetupTest()
+		s.repo.OnUpdateProfile = func(id string, patch domain.ProfilePatch) (domain.User, error) {
+			s.Equal(caller.UserID, id)
+			return domain.User{ID: domain.ID(id), Username: *patch.Username}, nil
+		}
+		name := "janet"
+		user, err := s.service.UpdateProfile(context.Background(), caller, domain.ProfilePatch{Username: &name})
+		s.Require().NoError(err)
+		s.Equal("janet", user.Username)
+	})
+
+	s.Run("should reject empty patches and usernames", func() {
+		s.SetupTest()
+		_, err := s.service.UpdateProfile(context.Background(), caller, domain.ProfilePatch{})
+		s.ErrorIs(err, domain.ErrValidation)
+
+		empty := ""
+		_, err = s.service.UpdateProfile(context.Background(), caller, domain.ProfilePatch{Username: &empty})
+		s.ErrorIs(err, domain.ErrValidation)
+
+		invalid := "jane@"
+		_, err = s.service.UpdateProfile(context.Background(), caller, domain.ProfilePatch{Email: &invalid})
+		s.ErrorIs(err, domain.ErrValidation)
+	})
+
+	s.Run("should let the caller remove their email", func() {
+		s.SetupTest()
+		s.repo.OnUpdateProfile = func(id string, patch domain.ProfilePatch) (domain.User, error) {
+			s.Equal("", *patch.Email)
+			return domain.User{ID: domain.ID(id), Username: "jane"}, nil
+		}
+		empty := ""
+		_, err := s.service.UpdateProfile(context.Background(), caller, domain.ProfilePatch{Email: &empty})
+		s.NoError(err)
+	})
+}
+
+func (s *UserUseCaseSuite) TestChangePassword() {
+	caller := domain.Caller{UserID: domain.NewID().String(), Username: "jane", Role: domain.RoleMember}
+
+	// withPassword stores the caller with the hash of password
+	withPassword := func(password string) *string {
+		hash, err := s.passwords.HashPassword(password)
+		s.Require().NoError(err)
+		s.withUsers(domain.User{ID: domain.ID(caller.UserID), Username: "jane", Password: hash})
+
+		var saved string
+		s.repo.OnSetPassword = func(id string, hash string) error {
+			s.Equal(caller.UserID, id)
+			saved = hash
+			return nil
+		}
+		return &saved
+	}
+
+	s.Run("should store the hash of the new password", func() {
+		s.SetupTest()
+		saved := withPassword("old-secret")
+
+		s.Require().NoError(s.service.ChangePassword(context.Background(), caller, "old-secret", "new-secret"))
+		s.NoError(s.passwords.ComparePassword(*saved, "new-secret"))
+	})
+
+	s.Run("should revoke the user's refresh tokens and the access token used", func() {
+		s.SetupTest()
+		withPassword("old-secret")
+		for hash, userID := range map[string]string{"mine": caller.UserID, "theirs": domain.NewID().String()} {
+			s.Require().NoError(s.tokens.SaveRefreshToken(context.Background(), domain.RefreshToken{
+				TokenHash: hash, UserID: domain.ID(userID), FamilyID: domain.NewID(), ExpiresAt: time.Now().Add(time.Hour),
+			}))
+		}
+
+		withToken := caller
+		withToken.TokenID, withToken.TokenExpiresAt = "jti-1", time.Now().Add(time.Minute)
+		s.Require().NoError(s.service.ChangePassword(context.Background(), withToken, "old-secret", "new-secret"))
+
+		for hash, revoked := range map[string]bool{"mine": true, "theirs": false} {
+			stored, err := s.tokens.GetRefreshToken(context.Background(), hash)
+			s.Require().NoError(err)
+			s.Equal(revoked, stored.Revoked, hash)
+		}
+		revoked, err := s.tokens.IsAccessTokenRevoked(context.Background(), "jti-1")
+		s.Require().NoError(err)
+		s.True(revoked)
+	})
+
+	s.Run("should require the current password", func() {
+		s.SetupTest()
+		saved := withPassword("old-secret")
+
+		err := s.service.ChangePassword(context.Background(), caller, "guess", "new-secret")
+		s.ErrorIs(err, domain.ErrWrongPassword)
+		s.Empty(*saved)
+	})
+
+	s.Run("should reject an empty new password", func() {
+		s.SetupTest()
+		withPassword("old-secret")
+		err := s.service.ChangePassword(context.Background(), caller, "old-secret", "")
+		s.ErrorIs(err, domain.ErrValidation)
+	})
+
+	s.Run("should apply the password policy", func() {
+		s.SetupTest()
+		saved := withPassword("old-secret")
+		err := s.service.ChangePassword(context.Background(), caller, "old-secret", "Jane-Doe-1")
+		var policyErr *domain.PasswordPolicyError
+		s.Require().True(errors.As(err, &policyErr), "got %v", err)
+		s.Equal(domain.PasswordRuleUsername, policyErr.Violations[0].Rule)
+		s.Empty(*saved)
+	})
+}